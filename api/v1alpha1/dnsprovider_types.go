@@ -0,0 +1,123 @@
+/*
+Copyright 2023 sokdakino.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	ConditionTypeProviderReady capiv1beta1.ConditionType = "Ready"
+
+	ConditionReasonSecretNotFound     = "SecretNotFound"
+	ConditionReasonInvalidSecretRef   = "InvalidSecretRef"
+	ConditionReasonProviderInitFailed = "ProviderInitFailed"
+	ConditionReasonVerifyFailed       = "VerifyFailed"
+)
+
+// DNSProviderSpec defines the desired state of DNSProvider and ClusterDNSProvider
+type DNSProviderSpec struct {
+	// Kind is the provider kind registered in the provider registry, e.g. cloudflare
+	Kind string `json:"kind"`
+	// Settings are the provider-specific settings
+	//+optional
+	Settings map[string]string `json:"settings,omitempty"`
+	// CredentialsSecretRef refers the secret holding the credentials, its data are merged into the settings
+	//+optional
+	CredentialsSecretRef *SecretReference `json:"credentialsSecretRef,omitempty"`
+}
+
+// SecretReference refers the secret by name and namespace
+type SecretReference struct {
+	Name string `json:"name"`
+	// Namespace of the secret, required for ClusterDNSProvider and ignored for DNSProvider
+	//+optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// DNSProviderStatus defines the observed state of DNSProvider and ClusterDNSProvider
+type DNSProviderStatus struct {
+	Conditions capiv1beta1.Conditions `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="kind",type=string,JSONPath=".spec.kind"
+//+kubebuilder:printcolumn:name="ready",type=string,JSONPath=".status.conditions[?(@.type=='Ready')].status"
+
+// DNSProvider is the Schema for the dnsproviders API, usable by the Domains in the same namespace
+type DNSProvider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DNSProviderSpec   `json:"spec,omitempty"`
+	Status DNSProviderStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DNSProviderList contains a list of DNSProvider
+type DNSProviderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DNSProvider `json:"items"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="kind",type=string,JSONPath=".spec.kind"
+//+kubebuilder:printcolumn:name="ready",type=string,JSONPath=".status.conditions[?(@.type=='Ready')].status"
+
+// ClusterDNSProvider is the Schema for the clusterdnsproviders API, usable by the Domains in any namespace
+type ClusterDNSProvider struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DNSProviderSpec   `json:"spec,omitempty"`
+	Status DNSProviderStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterDNSProviderList contains a list of ClusterDNSProvider
+type ClusterDNSProviderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterDNSProvider `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DNSProvider{}, &DNSProviderList{}, &ClusterDNSProvider{}, &ClusterDNSProviderList{})
+}
+
+func (p *DNSProvider) GetConditions() capiv1beta1.Conditions {
+	return p.Status.Conditions
+}
+
+func (p *DNSProvider) SetConditions(conds capiv1beta1.Conditions) {
+	p.Status.Conditions = conds
+}
+
+func (p *ClusterDNSProvider) GetConditions() capiv1beta1.Conditions {
+	return p.Status.Conditions
+}
+
+func (p *ClusterDNSProvider) SetConditions(conds capiv1beta1.Conditions) {
+	p.Status.Conditions = conds
+}
@@ -34,10 +34,14 @@ const (
 	ConditionTypeRecordSetRetrieved capiv1beta1.ConditionType = "RecordSetRetrieved"
 	ConditionTypeRecordSetUpdated   capiv1beta1.ConditionType = "RecordSetUpdated"
 	ConditionTypeRecordSetReady     capiv1beta1.ConditionType = "Ready"
+	ConditionTypeOwnershipConflict  capiv1beta1.ConditionType = "OwnershipConflict"
 
 	ConditionReasonServiceAPIFailed = "ServiceAPIRequestFailed"
 	ConditionReasonProviderNotFound = "ProviderNotFound"
 	ConditionReasonZoneNotFound     = "ZoneNotFound"
+
+	ConditionReasonInvalidProviderOptions = "InvalidProviderOptions"
+	ConditionReasonRecordNotOwned         = "RecordNotOwned"
 )
 
 // DomainSpec defines the desired state of Domain
@@ -48,6 +52,12 @@ type DomainSpec struct {
 	Zone     string   `json:"zone"`
 	Records  []string `json:"records"`
 	TTL      int      `json:"ttl"`
+	// ProviderOptions are the provider-specific options of the record, e.g. proxied for cloudflare
+	//+optional
+	ProviderOptions map[string]string `json:"providerOptions,omitempty"`
+	// Adopt allows to take over the existing record which nobody owns
+	//+optional
+	Adopt bool `json:"adopt,omitempty"`
 }
 
 // DomainStatus defines the observed state of Domain
@@ -77,13 +87,21 @@ type RecordStatus struct {
 	TTL     *int     `json:"ttl,omitempty"`
 	//+optional
 	Activated *bool `json:"activated,omitempty"`
+	//+optional
+	Proxied *bool `json:"proxied,omitempty"`
+	// Options are the provider-specific options applied on the record
+	//+optional
+	Options map[string]string `json:"options,omitempty"`
+	// Owner is the owner id the record is claimed with, empty if ownership is not tracked
+	//+optional
+	Owner string `json:"owner,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="provider",type=string,JSONPath=".spec.provider"
-//+kubebuilder:printcolumn:name="virtualhost",type=string,JSONPath="$.spec.name.concat('.', $.spec.zone)"
-//+kubebuilder:printcolumn:name="activated",type=bool,JSONPath=".status.activated"
+//+kubebuilder:printcolumn:name="virtualhost",type=string,JSONPath=".spec.name"
+//+kubebuilder:printcolumn:name="activated",type=boolean,JSONPath=".status.activated"
 
 // Domain is the Schema for the domains API
 type Domain struct {
@@ -125,13 +143,14 @@ func (d *Domain) Update(ctx context.Context, client client.Client, applier func(
 		}
 
 		// apply
-		applier(d)
+		applier(tmp)
 
 		// update
 		if err := client.Update(ctx, tmp); err != nil {
 			return err
 		}
 
+		tmp.DeepCopyInto(d)
 		return nil
 	})
 }
@@ -146,13 +165,14 @@ func (d *Domain) StatusUpdate(ctx context.Context, client client.Client, applier
 		}
 
 		// apply
-		applier(d)
+		applier(tmp)
 
 		// update
 		if err := client.Status().Update(ctx, tmp); err != nil {
 			return err
 		}
 
+		tmp.DeepCopyInto(d)
 		return nil
 	})
 }
@@ -0,0 +1,63 @@
+/*
+Copyright 2023 sokdakino.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// cloudflare-plugin serves the in-tree cloudflare provider as a plugin, which is the reference of the plugin protocol.
+// the credentials are read from the cloudflare envs, the same as the manager.
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/sokdak/dns-ingress/pkg/cloudflare"
+	"github.com/sokdak/dns-ingress/pkg/plugin"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+var setupLog = ctrl.Log.WithName("setup")
+
+func main() {
+	var endpoint string
+	flag.StringVar(&endpoint, "endpoint", "/var/run/dns-ingress/cloudflare.sock",
+		"The unix socket the plugin listens on, which is the endpoint setting of the plugin provider.")
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	client, err := cloudflare.NewCloudFlareClientWithSettings(nil)
+	if err != nil {
+		setupLog.Error(err, "unable to create cloudflare client")
+		os.Exit(1)
+	}
+
+	lis, err := plugin.Listen(endpoint)
+	if err != nil {
+		setupLog.Error(err, "unable to listen")
+		os.Exit(1)
+	}
+
+	setupLog.Info("serving plugin", "kind", cloudflare.ProviderKey, "endpoint", endpoint)
+	server := plugin.NewServer(cloudflare.ProviderKey, client, nil)
+	if err := server.Serve(ctrl.SetupSignalHandler(), lis); err != nil {
+		setupLog.Error(err, "problem serving plugin")
+		os.Exit(1)
+	}
+}
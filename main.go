@@ -17,15 +17,39 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/builtin"
 	"github.com/sokdak/dns-ingress/pkg/cloudflare"
 	"github.com/sokdak/dns-ingress/pkg/controllers"
 	"github.com/sokdak/dns-ingress/pkg/environment"
+	"github.com/sokdak/dns-ingress/pkg/ownership"
 	"github.com/sokdak/dns-ingress/pkg/provider"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/flowcontrol"
+	"math"
 	"os"
+	"strings"
 	"time"
 
+	// Import the providers to register them into the provider registry.
+	_ "github.com/sokdak/dns-ingress/pkg/adguard"
+	_ "github.com/sokdak/dns-ingress/pkg/azuredns"
+	_ "github.com/sokdak/dns-ingress/pkg/clouddns"
+	_ "github.com/sokdak/dns-ingress/pkg/coredns"
+	_ "github.com/sokdak/dns-ingress/pkg/digitalocean"
+	_ "github.com/sokdak/dns-ingress/pkg/hetzner"
+	_ "github.com/sokdak/dns-ingress/pkg/infoblox"
+	_ "github.com/sokdak/dns-ingress/pkg/memory"
+	_ "github.com/sokdak/dns-ingress/pkg/pihole"
+	_ "github.com/sokdak/dns-ingress/pkg/plugin"
+	_ "github.com/sokdak/dns-ingress/pkg/powerdns"
+	_ "github.com/sokdak/dns-ingress/pkg/rfc2136"
+	_ "github.com/sokdak/dns-ingress/pkg/route53"
+	_ "github.com/sokdak/dns-ingress/pkg/webhook"
+	_ "github.com/sokdak/dns-ingress/pkg/zonefile"
+
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -36,6 +60,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	dnsingressiov1alpha1 "github.com/sokdak/dns-ingress/api/v1alpha1"
 	//+kubebuilder:scaffold:imports
@@ -57,11 +82,64 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var enableDomainController bool
+	var enableIngressController bool
+	var enableServiceController bool
+	var defaultDNSProvider string
+	var defaultIngressEndpoint string
+	var defaultDomainZone string
+	var providerConfigPath string
+	var ownerId string
+	var ownershipRegistry string
+	var builtinDNSBindAddress string
+	var builtinDNSZones string
+	var builtinDNSNameservers string
+	var builtinDNSTransferAllowed string
+
+	environment.LoadEnvs()
+
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableDomainController, "enable-domain-controller", true,
+		"Enable the controller which syncs Domain resources with the dns providers.")
+	flag.BoolVar(&enableIngressController, "enable-ingress-controller", true,
+		"Enable the controller which generates Domain resources from Ingress rules.")
+	flag.BoolVar(&enableServiceController, "enable-service-controller", true,
+		"Enable the controller which generates Domain resources from the "+controllers.AnnotationKeyHostname+
+			" annotation of LoadBalancer Services.")
+	flag.StringVar(&providerConfigPath, "provider-config", *environment.ProviderConfigPath,
+		"The path of the provider config file which lists the providers to enable. "+
+			"Only cloudflare provider is enabled using envs if not set, unless its credential envs are missing. "+
+			"Defaults to PROVIDER_CONFIG_PATH env.")
+	flag.StringVar(&defaultDNSProvider, "default-dns-provider", *environment.DefaultDNSProvider,
+		"The dns provider used when an Ingress or Service has no "+controllers.AnnotationKeyIngressDnsProvider+" annotation. "+
+			"Defaults to DEFAULT_DNS_PROVIDER env.")
+	flag.StringVar(&defaultIngressEndpoint, "default-ingress-endpoint", *environment.DefaultIngressEndpoint,
+		"The record target used when an Ingress has no "+controllers.AnnotationKeyIngressEndpoint+" annotation. "+
+			"Defaults to DEFAULT_INGRESS_ENDPOINT env.")
+	flag.StringVar(&defaultDomainZone, "default-domain-zone", *environment.DefaultDomainZone,
+		"The zone used when an Ingress or Service has no "+controllers.AnnotationKeyDomainZone+" annotation. "+
+			"Defaults to DEFAULT_DOMAIN_ZONE env.")
+	flag.StringVar(&ownerId, "owner-id", *environment.OwnerId,
+		"The owner id which identifies the records managed by this instance. Defaults to OWNER_ID env.")
+	flag.StringVar(&ownershipRegistry, "ownership-registry", *environment.OwnershipRegistry,
+		"The registry which tracks the owners of the records, one of txt or none. "+
+			"Records are adopted and deleted without ownership check if none. Defaults to OWNERSHIP_REGISTRY env.")
+	flag.StringVar(&builtinDNSBindAddress, "builtin-dns-bind-address", *environment.BuiltinDNSBindAddress,
+		"The address the builtin authoritative dns server binds to on udp and tcp, e.g. "+builtin.DefaultAddress+". "+
+			"The server is disabled if not set. Defaults to BUILTIN_DNS_BIND_ADDRESS env.")
+	flag.StringVar(&builtinDNSZones, "builtin-dns-zones", *environment.BuiltinDNSZones,
+		"The comma-separated zones the builtin dns server is authoritative for, "+
+			"which are served to the domains of the "+builtin.ProviderKey+" provider. Defaults to BUILTIN_DNS_ZONES env.")
+	flag.StringVar(&builtinDNSNameservers, "builtin-dns-nameservers", *environment.BuiltinDNSNameservers,
+		"The comma-separated nameservers of the zones on the SOA and NS records, ns.<zone> if not set. "+
+			"Defaults to BUILTIN_DNS_NAMESERVERS env.")
+	flag.StringVar(&builtinDNSTransferAllowed, "builtin-dns-transfer-allowed", *environment.BuiltinDNSTransferAllowed,
+		"The comma-separated networks of the secondaries allowed to transfer the zones, "+
+			"zone transfers are refused if not set. Defaults to BUILTIN_DNS_TRANSFER_ALLOWED env.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -94,24 +172,113 @@ func main() {
 		os.Exit(1)
 	}
 
-	environment.LoadEnvs()
+	// builtin dns server has to be set up before the providers, whose zones are verified
+	if len(builtinDNSBindAddress) > 0 {
+		if err = setupBuiltinDNS(mgr, builtinDNSBindAddress, builtinDNSZones, builtinDNSNameservers, builtinDNSTransferAllowed); err != nil {
+			setupLog.Error(err, "unable to set up builtin dns server")
+			os.Exit(1)
+		}
+	}
 
-	cfclient, err := cloudflare.GenerateCloudFlareClientUsingEnvironment()
-	if err != nil {
-		setupLog.Error(err, "unable to init cloudflare provider")
-		os.Exit(1)
+	if enableDomainController {
+		// cloudflare provider is enabled using envs if no provider config is given, none if the envs are not set either
+		providerConfig := &provider.Config{}
+		if len(providerConfigPath) > 0 {
+			providerConfig, err = provider.LoadConfig(providerConfigPath)
+			if err != nil {
+				setupLog.Error(err, "unable to load provider config")
+				os.Exit(1)
+			}
+		} else if cloudflare.HasEnvironmentCredentials() {
+			providerConfig.Providers = []provider.ProviderConfig{{Name: cloudflare.ProviderKey, Kind: cloudflare.ProviderKey}}
+		} else {
+			setupLog.Info("no provider config nor cloudflare credentials given, no provider is enabled from the config")
+		}
+
+		// the provider failing the verification is unavailable and verified again in background,
+		// the domains of the provider wait for it while the other providers keep serving
+		for _, p := range providerConfig.Providers {
+			c, err := provider.DefaultRegistry.Build(p.Kind, p.Settings)
+			if err != nil {
+				setupLog.Error(err, "unable to init provider", "provider", p.Name)
+				os.Exit(1)
+			}
+			if err = verifyProvider(context.Background(), c); err != nil {
+				setupLog.Error(err, "unable to verify provider, marked unavailable", "provider", p.Name)
+				if err = mgr.Add(retryProviderVerification(p.Name, c)); err != nil {
+					setupLog.Error(err, "unable to set up provider verification", "provider", p.Name)
+					os.Exit(1)
+				}
+				continue
+			}
+			provider.DefaultRegistry.Put(p.Name, c)
+		}
+		setupLog.Info("enabled providers", "providers", provider.DefaultRegistry.Names())
+
+		var ownershipRegistryFactory ownership.Factory
+		switch ownershipRegistry {
+		case "txt":
+			ownershipRegistryFactory = ownership.NewTXTRegistryFactory(ownership.DefaultTXTPrefix)
+		case "none":
+			setupLog.Info("ownership of the records is not tracked")
+		default:
+			setupLog.Error(fmt.Errorf("unknown ownership registry %s", ownershipRegistry), "unable to init ownership registry")
+			os.Exit(1)
+		}
+
+		if err = (&controllers.DomainReconciler{
+			Client:           mgr.GetClient(),
+			Scheme:           mgr.GetScheme(),
+			Backoff:          flowcontrol.NewBackOff(1*time.Second, 30*time.Second),
+			ProviderRegistry: provider.DefaultRegistry,
+
+			OwnerId:           ownerId,
+			OwnershipRegistry: ownershipRegistryFactory,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Domain")
+			os.Exit(1)
+		}
+		if err = (&controllers.DNSProviderReconciler{
+			Client:           mgr.GetClient(),
+			Scheme:           mgr.GetScheme(),
+			ProviderRegistry: provider.DefaultRegistry,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "DNSProvider")
+			os.Exit(1)
+		}
+		if err = (&controllers.ClusterDNSProviderReconciler{
+			Client:           mgr.GetClient(),
+			Scheme:           mgr.GetScheme(),
+			ProviderRegistry: provider.DefaultRegistry,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ClusterDNSProvider")
+			os.Exit(1)
+		}
 	}
 
-	if err = (&controllers.DomainReconciler{
-		Client:  mgr.GetClient(),
-		Scheme:  mgr.GetScheme(),
-		Backoff: flowcontrol.NewBackOff(1*time.Second, 30*time.Second),
-		ProviderClientMap: map[string]provider.Client{
-			cloudflare.ProviderKey: cfclient,
-		},
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Domain")
-		os.Exit(1)
+	if enableIngressController {
+		if err = (&controllers.IngressReconciler{
+			Client:                 mgr.GetClient(),
+			Scheme:                 mgr.GetScheme(),
+			DefaultDNSProvider:     defaultDNSProvider,
+			DefaultIngressEndpoint: defaultIngressEndpoint,
+			DefaultDomainZone:      defaultDomainZone,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Ingress")
+			os.Exit(1)
+		}
+	}
+
+	if enableServiceController {
+		if err = (&controllers.ServiceReconciler{
+			Client:             mgr.GetClient(),
+			Scheme:             mgr.GetScheme(),
+			DefaultDNSProvider: defaultDNSProvider,
+			DefaultDomainZone:  defaultDomainZone,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Service")
+			os.Exit(1)
+		}
 	}
 	//+kubebuilder:scaffold:builder
 
@@ -130,3 +297,66 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// setupBuiltinDNS adds the zones to the default index, which is served by the builtin dns server
+// and indexed from the domains on every replica
+func setupBuiltinDNS(mgr ctrl.Manager, addr, zones, nameservers, transferAllowed string) error {
+	networks, err := builtin.ParseNetworks(transferAllowed)
+	if err != nil {
+		return fmt.Errorf("can't parse transfer allowed networks: %w", err)
+	}
+	for _, zone := range strings.Split(zones, ",") {
+		if zone = strings.TrimSpace(zone); len(zone) == 0 {
+			continue
+		}
+		if err := builtin.DefaultIndex.AddZone(zone, strings.Split(nameservers, ",")...); err != nil {
+			return fmt.Errorf("can't add zone: %w", err)
+		}
+	}
+	if len(builtin.DefaultIndex.Zones()) == 0 {
+		return fmt.Errorf("no zone is given for the builtin dns server")
+	}
+
+	if err := mgr.Add(builtin.NewServer(builtin.DefaultIndex, addr, networks)); err != nil {
+		return fmt.Errorf("can't add builtin dns server: %w", err)
+	}
+	if err := (&controllers.BuiltinDNSReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Index:  builtin.DefaultIndex,
+	}).SetupWithManager(mgr); err != nil {
+		return fmt.Errorf("can't create controller BuiltinDNS: %w", err)
+	}
+	setupLog.Info("enabled builtin dns server", "address", addr, "zones", builtin.DefaultIndex.Zones())
+	return nil
+}
+
+// verifyProvider checks the credentials and permissions of the configured provider
+func verifyProvider(ctx context.Context, c provider.Client) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return provider.Verify(ctx, c)
+}
+
+// retryProviderVerification verifies the provider again with backoff until it succeeds, then enables it
+func retryProviderVerification(name string, c provider.Client) manager.RunnableFunc {
+	return func(ctx context.Context) error {
+		backoff := wait.Backoff{Duration: 10 * time.Second, Factor: 2, Steps: math.MaxInt32, Cap: 5 * time.Minute}
+		err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
+			if err := verifyProvider(ctx, c); err != nil {
+				setupLog.Error(err, "unable to verify provider, retrying", "provider", name)
+				return false, nil
+			}
+			provider.DefaultRegistry.Put(name, c)
+			setupLog.Info("enabled provider", "provider", name)
+			return true, nil
+		})
+		if err != nil {
+			_ = provider.Close(c)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+}
@@ -0,0 +1,105 @@
+package adguard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// paths of the api, the rewrites are addressed by domain and answer
+const (
+	controlPrefix     = "/control"
+	statusPath        = "/status"
+	rewriteListPath   = "/rewrite/list"
+	rewriteAddPath    = "/rewrite/add"
+	rewriteDeletePath = "/rewrite/delete"
+)
+
+// Rewrite is a dns rewrite rule, the answer is an ip address for A and AAAA records or a domain for CNAME record
+type Rewrite struct {
+	Domain string `json:"domain"`
+	Answer string `json:"answer"`
+}
+
+// Status is the status of the server
+type Status struct {
+	Version string `json:"version"`
+	Running bool   `json:"running"`
+}
+
+// APIError is returned if the api responds with error status, the api responds the errors in plain text
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("adguard home api error %d: %s", e.StatusCode, e.Message)
+}
+
+// do sends the request to the api, the response is decoded into out if given
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("can't encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.apiUrl+controlPrefix+path, body)
+	if err != nil {
+		return fmt.Errorf("can't create request: %w", err)
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("can't read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message := string(bytes.TrimSpace(respBody))
+		if len(message) == 0 {
+			message = http.StatusText(resp.StatusCode)
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("can't decode response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) listRewrites(ctx context.Context) ([]Rewrite, error) {
+	rewrites := make([]Rewrite, 0)
+	if err := c.do(ctx, http.MethodGet, rewriteListPath, nil, &rewrites); err != nil {
+		return nil, err
+	}
+	return rewrites, nil
+}
+
+func (c *Client) addRewrite(ctx context.Context, r Rewrite) error {
+	return c.do(ctx, http.MethodPost, rewriteAddPath, r, nil)
+}
+
+// deleteRewrite deletes the rewrite, the api succeeds even if the rewrite is already gone
+func (c *Client) deleteRewrite(ctx context.Context, r Rewrite) error {
+	return c.do(ctx, http.MethodPost, rewriteDeletePath, r, nil)
+}
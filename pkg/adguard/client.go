@@ -0,0 +1,344 @@
+package adguard
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const ProviderKey = "adguard"
+
+const (
+	// SettingKeyURL is the url of the web interface, e.g. http://adguard.home.arpa:3000
+	SettingKeyURL      = "url"
+	SettingKeyUsername = "username"
+	SettingKeyPassword = "password"
+)
+
+var DefaultHttpClient = &http.Client{Timeout: 30 * time.Second}
+
+func init() {
+	provider.Register(ProviderKey, NewAdGuardClientWithSettings)
+}
+
+// Client manages the dns rewrites of AdGuard Home, a rewrite to an ip address is A or AAAA record and the others are CNAME.
+// adguard home has no zones, so any zone is served and the zone id is the zone name.
+// TXT records are not supported, so the ownership of the records is not tracked on it.
+type Client struct {
+	provider.Client
+
+	apiUrl     string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+// NewAdGuardClientWithSettings creates the client with the provider settings
+func NewAdGuardClientWithSettings(settings map[string]string) (provider.Client, error) {
+	c, err := NewAdGuardClient(settings[SettingKeyURL], settings[SettingKeyUsername], settings[SettingKeyPassword], DefaultHttpClient)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewAdGuardClient creates the client of the api of the web interface served at the url
+func NewAdGuardClient(apiUrl, username, password string, client *http.Client) (*Client, error) {
+	if len(username) == 0 || len(password) == 0 {
+		return nil, fmt.Errorf("can't create new adguard client: both %s and %s are required", SettingKeyUsername, SettingKeyPassword)
+	}
+	u, err := url.Parse(apiUrl)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return nil, fmt.Errorf("can't create new adguard client: invalid url %s", apiUrl)
+	}
+	if client == nil {
+		client = DefaultHttpClient
+	}
+
+	return &Client{
+		apiUrl:     strings.TrimSuffix(strings.TrimSuffix(apiUrl, "/"), controlPrefix),
+		username:   username,
+		password:   password,
+		httpClient: client,
+	}, nil
+}
+
+// OwnershipUnsupported tells the ownership can't be tracked, as the TXT companions can't be stored
+func (c *Client) OwnershipUnsupported() bool {
+	return true
+}
+
+// Verify checks the credentials are accepted
+func (c *Client) Verify(ctx context.Context) error {
+	status := &Status{}
+	if err := c.do(ctx, http.MethodGet, statusPath, nil, status); err != nil {
+		return fmt.Errorf("can't verify credentials: %w", err)
+	}
+	return nil
+}
+
+// GetZone returns the synthetic zone of the name, since adguard home rewrites any name
+func (c *Client) GetZone(_ context.Context, zoneName string) (*provider.Zone, error) {
+	name := normalizeName(zoneName)
+	if len(name) == 0 {
+		return nil, fmt.Errorf("can't GetZone: zone name is required")
+	}
+	return &provider.Zone{Id: name, Name: name, Activated: true}, nil
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	if !supportedRecordTypes[recordType] {
+		return nil, nil
+	}
+
+	rewrites, err := c.listRecordSet(ctx, provider.JoinName(name, normalizeName(zoneId)), recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	if len(rewrites) == 0 {
+		return nil, nil
+	}
+	return convertRecordSet(name, zoneId, recordType, rewrites), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, _ int, _ map[string]string) (*provider.Domain, error) {
+	if err := validateRecordSet(recordType, records); err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	fqdn := provider.JoinName(name, normalizeName(zoneId))
+
+	// the api adds the rewrite regardless of the others, which would merge the records into the existing recordset
+	current, err := c.listRecordSet(ctx, fqdn, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	if len(current) > 0 {
+		return nil, fmt.Errorf("can't Create: recordset %s %s already exists", fqdn, recordType)
+	}
+
+	created := make([]Rewrite, 0, len(records))
+	for _, r := range records {
+		rewrite := newRewrite(fqdn, recordType, r)
+		if err := c.addRewrite(ctx, rewrite); err != nil {
+			return nil, fmt.Errorf("can't Create: %w", err)
+		}
+		created = append(created, rewrite)
+	}
+	return convertRecordSet(name, zoneId, recordType, created), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, _ int, _ map[string]string) (*provider.Domain, error) {
+	name, currentType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if !supportedRecordTypes[currentType] {
+		return nil, nil
+	}
+	fqdn := provider.JoinName(name, normalizeName(zoneId))
+
+	current, err := c.listRecordSet(ctx, fqdn, currentType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if len(current) == 0 {
+		return nil, nil
+	}
+
+	if err := validateRecordSet(recordType, records); err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	// if type has been changed, remove the current recordset first since CNAME can't coexist with others
+	if currentType != recordType {
+		for _, r := range current {
+			if err := c.deleteRewrite(ctx, r); err != nil {
+				return nil, fmt.Errorf("can't Update: %w", err)
+			}
+		}
+		current, err = c.listRecordSet(ctx, fqdn, recordType)
+		if err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+
+	// keep the rewrites which have wanted answer, the others are stale
+	wanted := map[string]bool{}
+	for _, r := range records {
+		wanted[newRewrite(fqdn, recordType, r).Answer] = true
+	}
+	stale := make([]Rewrite, 0)
+	synced := make([]Rewrite, 0, len(records))
+	for _, r := range current {
+		if !wanted[answerValue(r.Answer)] {
+			stale = append(stale, r)
+			continue
+		}
+		delete(wanted, answerValue(r.Answer))
+		synced = append(synced, r)
+	}
+
+	// the cname of a domain is replaced as it can't have two targets,
+	// otherwise the missing rewrites are added first, then the stale ones are removed to avoid resolution gap
+	if recordType == provider.RecordTypeCNAME {
+		for _, r := range stale {
+			if err := c.deleteRewrite(ctx, r); err != nil {
+				return nil, fmt.Errorf("can't Update: %w", err)
+			}
+		}
+		stale = nil
+	}
+	for _, r := range records {
+		rewrite := newRewrite(fqdn, recordType, r)
+		if !wanted[rewrite.Answer] {
+			continue
+		}
+		delete(wanted, rewrite.Answer)
+		if err := c.addRewrite(ctx, rewrite); err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+		synced = append(synced, rewrite)
+	}
+	for _, r := range stale {
+		if err := c.deleteRewrite(ctx, r); err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+	return convertRecordSet(name, zoneId, recordType, synced), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	if !supportedRecordTypes[recordType] {
+		return nil
+	}
+
+	rewrites, err := c.listRecordSet(ctx, provider.JoinName(name, normalizeName(zoneId)), recordType)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	for _, r := range rewrites {
+		if err := c.deleteRewrite(ctx, r); err != nil {
+			return fmt.Errorf("can't Delete: %w", err)
+		}
+	}
+	return nil
+}
+
+// listRecordSet returns the rewrites of the domain whose answer is of the type.
+// the answers A and AAAA keep the upstream records, which are not part of any recordset.
+func (c *Client) listRecordSet(ctx context.Context, fqdn, recordType string) ([]Rewrite, error) {
+	rewrites, err := c.listRewrites(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]Rewrite, 0)
+	for _, r := range rewrites {
+		if strings.EqualFold(r.Domain, fqdn) && answerType(r.Answer) == recordType {
+			matched = append(matched, r)
+		}
+	}
+	return matched, nil
+}
+
+// convertRecordSet converts the rewrites of the same domain and type into a recordset
+func convertRecordSet(name, zoneId, recordType string, rewrites []Rewrite) *provider.Domain {
+	values := make([]string, 0, len(rewrites))
+	for _, r := range rewrites {
+		values = append(values, answerValue(r.Answer))
+	}
+	sort.Strings(values)
+
+	zoneName := normalizeName(zoneId)
+	return &provider.Domain{
+		Id:        provider.GenerateRecordSetId(name, recordType),
+		Name:      name,
+		Type:      recordType,
+		Records:   values,
+		ZoneId:    zoneId,
+		ZoneName:  zoneName,
+		FQDN:      fmt.Sprintf("%s.", provider.JoinName(name, zoneName)),
+		Activated: true,
+	}
+}
+
+func newRewrite(fqdn, recordType, record string) Rewrite {
+	if recordType == provider.RecordTypeCNAME {
+		return Rewrite{Domain: fqdn, Answer: answerValue(record)}
+	}
+	return Rewrite{Domain: fqdn, Answer: record}
+}
+
+// supportedRecordTypes are the record types of the rewrites
+var supportedRecordTypes = map[string]bool{
+	provider.RecordTypeA:     true,
+	provider.RecordTypeAAAA:  true,
+	provider.RecordTypeCNAME: true,
+}
+
+// validateRecordSet checks the records can be the rewrites of the type
+func validateRecordSet(recordType string, records []string) error {
+	if !supportedRecordTypes[recordType] {
+		return fmt.Errorf("%s records are not supported by adguard home", recordType)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no records given")
+	}
+	if recordType == provider.RecordTypeCNAME && len(records) > 1 {
+		return fmt.Errorf("CNAME recordset can have only one record, got %d", len(records))
+	}
+	for _, r := range records {
+		if answerType(r) != recordType {
+			return fmt.Errorf("invalid %s record %s", recordType, r)
+		}
+	}
+	return nil
+}
+
+// answerType returns the record type the answer of the rewrite serves, empty for the answers keeping the upstream records
+func answerType(answer string) string {
+	if answer == provider.RecordTypeA || answer == provider.RecordTypeAAAA {
+		return ""
+	}
+	ip := net.ParseIP(answer)
+	switch {
+	case ip == nil:
+		return provider.RecordTypeCNAME
+	case ip.To4() != nil:
+		return provider.RecordTypeA
+	default:
+		return provider.RecordTypeAAAA
+	}
+}
+
+// answerValue returns the record of the answer, the hostname of CNAME has no trailing dot
+func answerValue(answer string) string {
+	return strings.TrimSuffix(answer, ".")
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
@@ -0,0 +1,173 @@
+package adguard
+
+import (
+	"context"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testZoneName = "home.arpa"
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	c := f.newClient(t)
+
+	z, err := c.GetZone(ctx, "Home.ARPA.")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: testZoneName, Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: unexpected zone %+v", z)
+	}
+
+	d, err := c.Create(ctx, "nas", z.Id, provider.RecordTypeA, []string{"192.168.1.20", "192.168.1.10"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("nas", provider.RecordTypeA),
+		Name:      "nas",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.168.1.10", "192.168.1.20"},
+		ZoneId:    testZoneName,
+		ZoneName:  testZoneName,
+		FQDN:      "nas.home.arpa.",
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+
+	got, err := f.newClient(t).Get(ctx, d.Id, z.Id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Get: expected %+v, got %+v", expected, got)
+	}
+
+	d, err = c.Update(ctx, d.Id, z.Id, provider.RecordTypeCNAME, []string{"ingress.home.arpa."}, 0, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != "nas/CNAME" || !reflect.DeepEqual(d.Records, []string{"ingress.home.arpa"}) {
+		t.Fatalf("Update: unexpected recordset %+v", d)
+	}
+	if got := f.list(); !reflect.DeepEqual(got, []Rewrite{{Domain: "nas.home.arpa", Answer: "ingress.home.arpa"}}) {
+		t.Fatalf("Update: expected the rewrites replaced with CNAME, got %v", got)
+	}
+
+	// the cname is replaced as the domain can't have two targets
+	if _, err := c.Update(ctx, d.Id, z.Id, provider.RecordTypeCNAME, []string{"lb.home.arpa"}, 0, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := f.list(); !reflect.DeepEqual(got, []Rewrite{{Domain: "nas.home.arpa", Answer: "lb.home.arpa"}}) {
+		t.Fatalf("Update: unexpected rewrites %v", got)
+	}
+
+	if err := c.Delete(ctx, d.Id, z.Id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.list(); len(got) > 0 {
+		t.Fatalf("Delete: expected no rewrites, got %v", got)
+	}
+}
+
+func TestClientUpstreamAnswers(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	c := f.newClient(t)
+
+	// the answer AAAA keeps the upstream records, which is not a recordset
+	f.addRewrite(Rewrite{Domain: "nas.home.arpa", Answer: "192.168.1.5"})
+	f.addRewrite(Rewrite{Domain: "nas.home.arpa", Answer: "AAAA"})
+
+	if d, err := c.GetByName(ctx, "nas", testZoneName, provider.RecordTypeAAAA); err != nil || d != nil {
+		t.Fatalf("GetByName: expected no AAAA recordset, got %+v, %v", d, err)
+	}
+	if _, err := c.Update(ctx, "nas/A", testZoneName, provider.RecordTypeA, []string{"192.168.1.5", "192.168.1.6"}, 0, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := c.Delete(ctx, "nas/A", testZoneName); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.list(); !reflect.DeepEqual(got, []Rewrite{{Domain: "nas.home.arpa", Answer: "AAAA"}}) {
+		t.Fatalf("Delete: expected the upstream answer kept, got %v", got)
+	}
+}
+
+func TestClientUnsupportedRecords(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	c := f.newClient(t)
+
+	if d, err := c.GetByName(ctx, "_owner", testZoneName, provider.RecordTypeTXT); err != nil || d != nil {
+		t.Fatalf("GetByName: expected no TXT recordset, got %+v, %v", d, err)
+	}
+	if _, err := c.Create(ctx, "_owner", testZoneName, provider.RecordTypeTXT, []string{"heritage=dns-ingress"}, 0, nil); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Fatalf("Create: expected error for TXT records, got %v", err)
+	}
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeCNAME, []string{"a.home.arpa", "b.home.arpa"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for CNAME of several targets")
+	}
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeAAAA, []string{"192.168.1.1"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for ipv4 address of AAAA record")
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+
+	if err := f.newClient(t).Verify(ctx); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	unauthorized, err := NewAdGuardClient(f.URL, testUsername, "invalid", f.Client())
+	if err != nil {
+		t.Fatalf("NewAdGuardClient: %v", err)
+	}
+	if err := unauthorized.Verify(ctx); err == nil || !strings.Contains(err.Error(), "invalid username or password") {
+		t.Fatalf("Verify: expected error of invalid credentials, got %v", err)
+	}
+
+	c := f.newClient(t)
+	for _, tt := range []struct {
+		recordType string
+		ttl        int
+		options    map[string]string
+		wantErr    string
+	}{
+		{recordType: provider.RecordTypeA, ttl: 300, wantErr: "ttl of the rewrites follows the adguard home settings"},
+		{recordType: provider.RecordTypeTXT, wantErr: "TXT records are not supported"},
+		{recordType: provider.RecordTypeA, options: map[string]string{"proxied": "true"}, wantErr: "unknown adguard option proxied"},
+	} {
+		if _, err := c.NormalizeOptions(tt.recordType, tt.ttl, tt.options); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("NormalizeOptions: expected error containing %q, got %v", tt.wantErr, err)
+		}
+	}
+}
+
+func TestNewAdGuardClientWithSettings(t *testing.T) {
+	c, err := NewAdGuardClientWithSettings(map[string]string{
+		SettingKeyURL:      "http://adguard.home.arpa:3000/control/",
+		SettingKeyUsername: testUsername,
+		SettingKeyPassword: testPassword,
+	})
+	if err != nil {
+		t.Fatalf("NewAdGuardClientWithSettings: %v", err)
+	}
+	if apiUrl := c.(*Client).apiUrl; apiUrl != "http://adguard.home.arpa:3000" {
+		t.Fatalf("expected api url without control prefix, got %s", apiUrl)
+	}
+	for _, invalid := range []map[string]string{
+		{SettingKeyURL: "http://adguard.home.arpa", SettingKeyUsername: testUsername},
+		{SettingKeyURL: "adguard.home.arpa", SettingKeyUsername: testUsername, SettingKeyPassword: testPassword},
+	} {
+		if _, err := NewAdGuardClientWithSettings(invalid); err == nil {
+			t.Fatalf("NewAdGuardClientWithSettings: expected error for settings %v", invalid)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package adguard
+
+import (
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"github.com/sokdak/dns-ingress/pkg/provider/providertest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	providertest.RunConformance(t, func(t *testing.T) providertest.Fixture {
+		return providertest.Fixture{
+			Client:      newFakeServer(t).newClient(t),
+			ZoneName:    testZoneName,
+			RecordTypes: []string{provider.RecordTypeA, provider.RecordTypeAAAA, provider.RecordTypeCNAME},
+			NoTTL:       true,
+		}
+	})
+}
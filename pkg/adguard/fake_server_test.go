@@ -0,0 +1,116 @@
+package adguard
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testUsername = "admin"
+	testPassword = "adguard"
+)
+
+// fakeServer is a minimal stand-in of the adguard home api serving the dns rewrites
+type fakeServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	rewrites []Rewrite
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	f := &fakeServer{rewrites: []Rewrite{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeServer) newClient(t *testing.T) *Client {
+	c, err := NewAdGuardClient(f.URL, testUsername, testPassword, f.Client())
+	if err != nil {
+		t.Fatalf("NewAdGuardClient: %v", err)
+	}
+	return c
+}
+
+// addRewrite adds the rewrite directly, as if it is made on the web interface
+func (f *fakeServer) addRewrite(r Rewrite) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rewrites = append(f.rewrites, r)
+}
+
+func (f *fakeServer) list() []Rewrite {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Rewrite{}, f.rewrites...)
+}
+
+func (f *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if username, password, ok := r.BasicAuth(); !ok || username != testUsername || password != testPassword {
+		http.Error(w, "invalid username or password", http.StatusUnauthorized)
+		return
+	}
+
+	switch strings.TrimPrefix(r.URL.Path, controlPrefix) {
+	case statusPath:
+		writeJSON(w, &Status{Version: "v0.107.52", Running: true})
+	case rewriteListPath:
+		writeJSON(w, f.rewrites)
+	case rewriteAddPath:
+		rewrite, ok := decodeRewrite(w, r)
+		if !ok {
+			return
+		}
+		for _, existing := range f.rewrites {
+			if existing == rewrite {
+				http.Error(w, "rewrite rule already exists", http.StatusBadRequest)
+				return
+			}
+		}
+		f.rewrites = append(f.rewrites, rewrite)
+	case rewriteDeletePath:
+		rewrite, ok := decodeRewrite(w, r)
+		if !ok {
+			return
+		}
+		kept := make([]Rewrite, 0, len(f.rewrites))
+		for _, existing := range f.rewrites {
+			if existing != rewrite {
+				kept = append(kept, existing)
+			}
+		}
+		f.rewrites = kept
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func decodeRewrite(w http.ResponseWriter, r *http.Request) (Rewrite, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return Rewrite{}, false
+	}
+	rewrite := Rewrite{}
+	if err := json.NewDecoder(r.Body).Decode(&rewrite); err != nil {
+		http.Error(w, "json.Decode: "+err.Error(), http.StatusBadRequest)
+		return Rewrite{}, false
+	}
+	if len(rewrite.Domain) == 0 || len(rewrite.Answer) == 0 {
+		http.Error(w, "domain and answer are required", http.StatusBadRequest)
+		return Rewrite{}, false
+	}
+	return rewrite, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,19 @@
+package adguard
+
+import "fmt"
+
+// NormalizeOptions validates the recordset can be served by the rewrites, adguard home has no options.
+// the rewrites are served with the ttl of the adguard home settings, so ttl is not allowed.
+func (c *Client) NormalizeOptions(recordType string, ttl int, options map[string]string) (map[string]string, error) {
+	for key := range options {
+		return nil, fmt.Errorf("unknown adguard option %s", key)
+	}
+
+	if !supportedRecordTypes[recordType] {
+		return nil, fmt.Errorf("%s records are not supported by adguard home", recordType)
+	}
+	if ttl != 0 {
+		return nil, fmt.Errorf("ttl of the rewrites follows the adguard home settings, got %d", ttl)
+	}
+	return nil, nil
+}
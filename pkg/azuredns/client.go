@@ -0,0 +1,324 @@
+package azuredns
+
+import (
+	"context"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net/http"
+	"strings"
+)
+
+const ProviderKey = "azuredns"
+
+const (
+	SettingKeySubscriptionId = "subscriptionId"
+	SettingKeyResourceGroup  = "resourceGroup"
+	SettingKeyTenantId       = "tenantId"
+	SettingKeyClientId       = "clientId"
+	SettingKeyClientSecret   = "clientSecret"
+
+	SettingKeyZoneType = "zoneType"
+)
+
+const (
+	ZoneTypePublic  = "public"
+	ZoneTypePrivate = "private"
+)
+
+// zone ids are prefixed by the resource type of the zone, which tells the zone is public or private
+const (
+	zoneIdPrefixPublic  = "dnsZones/"
+	zoneIdPrefixPrivate = "privateDnsZones/"
+)
+
+const recordComment = "created and managed by dns-ingress.io"
+
+func init() {
+	provider.Register(ProviderKey, NewAzureDNSClientWithSettings)
+}
+
+// Config is the resource group of the zones, the zones are looked up in the both of azure dns and azure private dns if zone type is not set
+type Config struct {
+	SubscriptionId string
+	ResourceGroup  string
+	// ZoneType is either public or private, any type is allowed if empty
+	ZoneType string
+}
+
+func (c *Config) Validate() error {
+	if len(c.SubscriptionId) == 0 || len(c.ResourceGroup) == 0 {
+		return fmt.Errorf("both %s and %s are required", SettingKeySubscriptionId, SettingKeyResourceGroup)
+	}
+	switch c.ZoneType {
+	case "", ZoneTypePublic, ZoneTypePrivate:
+	default:
+		return fmt.Errorf("unknown zone type %s", c.ZoneType)
+	}
+	return nil
+}
+
+type Client struct {
+	provider.Client
+	Config Config
+
+	public  recordSets
+	private recordSets
+}
+
+// NewAzureDNSClientWithSettings creates the client with the provider settings, the client secret of the service principal is used if set,
+// falls back to the managed identity otherwise, which is the user-assigned one of the client id if set
+func NewAzureDNSClientWithSettings(settings map[string]string) (provider.Client, error) {
+	var cred azcore.TokenCredential
+	var err error
+	tenantId, clientId, clientSecret := settings[SettingKeyTenantId], settings[SettingKeyClientId], settings[SettingKeyClientSecret]
+	if len(clientSecret) > 0 {
+		if len(tenantId) == 0 || len(clientId) == 0 {
+			return nil, fmt.Errorf("can't generate azure dns client using settings, both %s and %s are required with %s",
+				SettingKeyTenantId, SettingKeyClientId, SettingKeyClientSecret)
+		}
+		cred, err = azidentity.NewClientSecretCredential(tenantId, clientId, clientSecret, nil)
+	} else {
+		opts := &azidentity.ManagedIdentityCredentialOptions{}
+		if len(clientId) > 0 {
+			opts.ID = azidentity.ClientID(clientId)
+		}
+		cred, err = azidentity.NewManagedIdentityCredential(opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't create azure credential: %w", err)
+	}
+
+	c, err := NewAzureDNSClient(Config{
+		SubscriptionId: settings[SettingKeySubscriptionId],
+		ResourceGroup:  settings[SettingKeyResourceGroup],
+		ZoneType:       settings[SettingKeyZoneType],
+	}, cred, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewAzureDNSClient creates the client of the zones in the resource group, options are passed to the clients of azure sdk
+func NewAzureDNSClient(c Config, cred azcore.TokenCredential, options *arm.ClientOptions) (*Client, error) {
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("can't create new azure dns client: %w", err)
+	}
+
+	publicFactory, err := armdns.NewClientFactory(c.SubscriptionId, cred, options)
+	if err != nil {
+		return nil, fmt.Errorf("can't create new azure dns client: %w", err)
+	}
+	privateFactory, err := armprivatedns.NewClientFactory(c.SubscriptionId, cred, options)
+	if err != nil {
+		return nil, fmt.Errorf("can't create new azure dns client: %w", err)
+	}
+
+	return &Client{
+		Config: c,
+		public: &publicRecordSets{
+			resourceGroup: c.ResourceGroup,
+			zones:         publicFactory.NewZonesClient(),
+			recordSets:    publicFactory.NewRecordSetsClient(),
+		},
+		private: &privateRecordSets{
+			resourceGroup: c.ResourceGroup,
+			zones:         privateFactory.NewPrivateZonesClient(),
+			recordSets:    privateFactory.NewRecordSetsClient(),
+		},
+	}, nil
+}
+
+// Verify checks the credentials are accepted by listing the zones in the resource group
+func (c *Client) Verify(ctx context.Context) error {
+	api := c.public
+	if c.Config.ZoneType == ZoneTypePrivate {
+		api = c.private
+	}
+	if err := api.verify(ctx); err != nil {
+		return fmt.Errorf("can't verify resource group %s: %w", c.Config.ResourceGroup, err)
+	}
+	return nil
+}
+
+func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone, error) {
+	name := strings.ToLower(strings.TrimSuffix(zoneName, "."))
+
+	zoneIds := make([]string, 0)
+	if c.Config.ZoneType != ZoneTypePrivate {
+		exists, err := c.public.zoneExists(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("can't GetZone: %w", err)
+		}
+		if exists {
+			zoneIds = append(zoneIds, zoneIdPrefixPublic+name)
+		}
+	}
+	if c.Config.ZoneType != ZoneTypePublic {
+		exists, err := c.private.zoneExists(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("can't GetZone: %w", err)
+		}
+		if exists {
+			zoneIds = append(zoneIds, zoneIdPrefixPrivate+name)
+		}
+	}
+
+	if len(zoneIds) == 0 {
+		return nil, fmt.Errorf("can't GetZone: cannot find zone %s in resource group %s", zoneName, c.Config.ResourceGroup)
+	}
+	if len(zoneIds) > 1 {
+		return nil, fmt.Errorf("can't GetZone: both public and private zones are found for %s, set %s to pick one", zoneName, SettingKeyZoneType)
+	}
+
+	return &provider.Zone{
+		Id:        zoneIds[0],
+		Name:      name,
+		Activated: true,
+	}, nil
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	api, zoneName, err := c.parseZoneId(zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+
+	rs, err := api.get(ctx, zoneName, name, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	if rs == nil {
+		return nil, nil
+	}
+	return convertRecordSet(name, zoneId, zoneName, *rs), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	api, zoneName, err := c.parseZoneId(zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Create: no records given for %s", name)
+	}
+	rs, err := newRecordSet(recordType, records, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	// the recordset is created only if not exists, which is never overwritten
+	created, err := api.put(ctx, zoneName, name, rs, "")
+	if err != nil {
+		if hasStatusCode(err, http.StatusPreconditionFailed) {
+			return nil, fmt.Errorf("can't Create: recordset %s %s already exists", provider.JoinName(name, zoneName), recordType)
+		}
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	return convertRecordSet(name, zoneId, zoneName, *created), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	name, currentType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	api, zoneName, err := c.parseZoneId(zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	current, err := api.get(ctx, zoneName, name, currentType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if current == nil {
+		return nil, nil
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Update: no records given for %s", name)
+	}
+	rs, err := newRecordSet(recordType, records, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	// the recordset is replaced only if it's not changed since it's read, which is told by the etag
+	var updated *recordSet
+	if currentType == recordType {
+		updated, err = api.put(ctx, zoneName, name, rs, current.Etag)
+	} else {
+		// azure has no transaction over the recordsets, the current one is deleted first as CNAME can't coexist with the others
+		if err = api.delete(ctx, zoneName, name, currentType, current.Etag); err == nil {
+			updated, err = api.put(ctx, zoneName, name, rs, "")
+		}
+	}
+	if err != nil {
+		if hasStatusCode(err, http.StatusPreconditionFailed) {
+			return nil, fmt.Errorf("can't Update: recordset %s %s has been changed meanwhile", provider.JoinName(name, zoneName), currentType)
+		}
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	return convertRecordSet(name, zoneId, zoneName, *updated), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	api, zoneName, err := c.parseZoneId(zoneId)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	current, err := api.get(ctx, zoneName, name, recordType)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	if current == nil {
+		return nil
+	}
+
+	if err := api.delete(ctx, zoneName, name, recordType, current.Etag); err != nil {
+		if hasStatusCode(err, http.StatusPreconditionFailed) {
+			return fmt.Errorf("can't Delete: recordset %s %s has been changed meanwhile", provider.JoinName(name, zoneName), recordType)
+		}
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	return nil
+}
+
+// parseZoneId returns the recordsets of the zone type and the zone name which the zone id is built from
+func (c *Client) parseZoneId(zoneId string) (recordSets, string, error) {
+	switch {
+	case strings.HasPrefix(zoneId, zoneIdPrefixPublic):
+		return c.public, strings.TrimPrefix(zoneId, zoneIdPrefixPublic), nil
+	case strings.HasPrefix(zoneId, zoneIdPrefixPrivate):
+		return c.private, strings.TrimPrefix(zoneId, zoneIdPrefixPrivate), nil
+	default:
+		return nil, "", fmt.Errorf("invalid zone id %s", zoneId)
+	}
+}
@@ -0,0 +1,330 @@
+package azuredns
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+const (
+	testZoneName = "example.com"
+	testZoneId   = zoneIdPrefixPublic + testZoneName
+)
+
+func newTestClient(t *testing.T) (*fakeServer, *Client) {
+	f := newFakeServer(t)
+	f.addZone(resourceTypePublic, testZoneName)
+	return f, f.newClient(t, "")
+}
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	z, err := c.GetZone(ctx, "Example.COM.")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: testZoneId, Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: expected zone %s of id %s, got %+v", testZoneName, testZoneId, z)
+	}
+
+	d, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeA)
+	if err != nil || d != nil {
+		t.Fatalf("GetByName: expected nothing before create, got %v, %v", d, err)
+	}
+
+	d, err = c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("www", provider.RecordTypeA),
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.0.2.1", "192.0.2.2"},
+		TTL:       ttlDefault,
+		ZoneId:    testZoneId,
+		ZoneName:  testZoneName,
+		FQDN:      "www.example.com.",
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+
+	// a fresh client reads the recordset by the zone id alone
+	got, err := f.newClient(t, "").Get(ctx, d.Id, testZoneId)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Get: expected %+v, got %+v", expected, got)
+	}
+
+	d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if want := []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}; !reflect.DeepEqual(d.Records, want) || d.TTL != 60 {
+		t.Fatalf("Update: expected records %v with ttl 60, got %v with ttl %d", want, d.Records, d.TTL)
+	}
+	if rs := f.recordSet(resourceTypePublic, testZoneName, "www", provider.RecordTypeA); !reflect.DeepEqual(rs.Records, d.Records) || rs.TTL != 60 {
+		t.Fatalf("Update: expected remote records %v, got %+v", d.Records, rs)
+	}
+
+	if err := c.Delete(ctx, d.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if rs := f.recordSet(resourceTypePublic, testZoneName, "www", provider.RecordTypeA); rs != nil {
+		t.Fatalf("Delete: expected no remote recordset, got %+v", rs)
+	}
+
+	d, err = c.Get(ctx, d.Id, testZoneId)
+	if err != nil || d != nil {
+		t.Fatalf("Get: expected nothing after delete, got %v, %v", d, err)
+	}
+	if err := c.Delete(ctx, expected.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: expected no error for missing recordset, got %v", err)
+	}
+	if d, err := c.Update(ctx, expected.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil || d != nil {
+		t.Fatalf("Update: expected nothing for missing recordset, got %v, %v", d, err)
+	}
+}
+
+func TestClientRecordSetIsolatedByType(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	f.addRecordSet(resourceTypePublic, testZoneName, "www", provider.RecordTypeAAAA, map[string]interface{}{"properties": map[string]interface{}{
+		"TTL": 120, "AAAARecords": []map[string]string{{"ipv6Address": "2001:db8::1"}, {"ipv6Address": "2001:db8::2"}},
+	}})
+	a, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	aaaa, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeAAAA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(aaaa.Records, []string{"2001:db8::1", "2001:db8::2"}) || aaaa.TTL != 120 {
+		t.Fatalf("GetByName: expected only AAAA records, got %+v", aaaa)
+	}
+
+	if _, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeAAAA, []string{"2001:db8::3"}, 0, nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Create: expected error for existing recordset, got %v", err)
+	}
+	if rs := f.recordSet(resourceTypePublic, testZoneName, "www", provider.RecordTypeAAAA); !reflect.DeepEqual(rs.Records, []string{"2001:db8::1", "2001:db8::2"}) {
+		t.Fatalf("Create: expected existing AAAA records not overwritten, got %v", rs.Records)
+	}
+
+	if err := c.Delete(ctx, a.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if rs := f.recordSet(resourceTypePublic, testZoneName, "www", provider.RecordTypeAAAA); rs == nil {
+		t.Fatalf("Delete: expected AAAA records untouched")
+	}
+}
+
+func TestClientUpdateChangesType(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	d, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeCNAME, []string{"lb.example.net."}, 120, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != provider.GenerateRecordSetId("www", provider.RecordTypeCNAME) {
+		t.Fatalf("Update: expected id of the CNAME recordset, got %s", d.Id)
+	}
+	if rs := f.recordSet(resourceTypePublic, testZoneName, "www", provider.RecordTypeA); rs != nil {
+		t.Fatalf("Update: expected A recordset removed, got %+v", rs)
+	}
+	if rs := f.recordSet(resourceTypePublic, testZoneName, "www", provider.RecordTypeCNAME); rs == nil || !reflect.DeepEqual(rs.Records, []string{"lb.example.net"}) {
+		t.Fatalf("Update: expected CNAME record, got %+v", rs)
+	}
+
+	got, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeCNAME)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(got, d) {
+		t.Fatalf("GetByName: expected %+v, got %+v", d, got)
+	}
+
+	if _, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for A records conflicting with CNAME")
+	}
+	if _, err := c.Create(ctx, "app", testZoneId, provider.RecordTypeCNAME, []string{"a.example.net", "b.example.net"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for multiple CNAME records")
+	}
+}
+
+func TestClientUpdateConflict(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	d, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// the recordset is changed by someone else between the read and the put
+	f.beforePut = func() {
+		f.beforePut = nil
+		f.touch(resourceTypePublic, testZoneName, "www", provider.RecordTypeA)
+	}
+	if _, err := c.Update(ctx, d.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.2"}, 0, nil); err == nil || !strings.Contains(err.Error(), "changed meanwhile") {
+		t.Fatalf("Update: expected error for the recordset changed meanwhile, got %v", err)
+	}
+	if rs := f.recordSet(resourceTypePublic, testZoneName, "www", provider.RecordTypeA); !reflect.DeepEqual(rs.Records, []string{"192.0.2.1"}) {
+		t.Fatalf("Update: expected the recordset untouched, got %v", rs.Records)
+	}
+
+	// the next update reads the current etag
+	if d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.2"}, 0, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if rs := f.recordSet(resourceTypePublic, testZoneName, "www", provider.RecordTypeA); !reflect.DeepEqual(rs.Records, d.Records) {
+		t.Fatalf("Update: expected remote records %v, got %v", d.Records, rs.Records)
+	}
+}
+
+func TestClientTXTRecordSet(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	long := strings.Repeat("a", provider.TXTStringMaxLength+10)
+	records := []string{`heritage=dns-ingress,"quoted\"`, long}
+	d, err := c.Create(ctx, "_owner", testZoneId, provider.RecordTypeTXT, records, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	want := [][]string{{records[0]}, {long[:provider.TXTStringMaxLength], "aaaaaaaaaa"}}
+	if got := f.txtValues(resourceTypePublic, testZoneName, "_owner"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Create: expected character-strings %v, got %v", want, got)
+	}
+
+	got, err := c.GetByName(ctx, "_owner", testZoneId, provider.RecordTypeTXT)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if want := []string{long, records[0]}; !reflect.DeepEqual(got.Records, want) || !reflect.DeepEqual(d.Records, want) {
+		t.Fatalf("GetByName: expected records %v, got %v", want, got.Records)
+	}
+}
+
+func TestClientPrivateZone(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	f.addZone(resourceTypePrivate, "internal.example.com")
+	c := f.newClient(t, "")
+
+	z, err := c.GetZone(ctx, "internal.example.com")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if z.Id != zoneIdPrefixPrivate+"internal.example.com" {
+		t.Fatalf("GetZone: expected private zone, got %+v", z)
+	}
+
+	d, err := c.Create(ctx, "@", z.Id, provider.RecordTypeA, []string{"10.0.0.1"}, 60, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if d.FQDN != "internal.example.com." {
+		t.Fatalf("Create: expected the apex, got %+v", d)
+	}
+	if rs := f.recordSet(resourceTypePrivate, "internal.example.com", "@", provider.RecordTypeA); rs == nil || !reflect.DeepEqual(rs.Records, []string{"10.0.0.1"}) || rs.TTL != 60 {
+		t.Fatalf("Create: expected remote recordset, got %+v", rs)
+	}
+	if d, err = c.Update(ctx, d.Id, z.Id, provider.RecordTypeA, []string{"10.0.0.2"}, 60, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := c.Delete(ctx, d.Id, z.Id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if rs := f.recordSet(resourceTypePrivate, "internal.example.com", "@", provider.RecordTypeA); rs != nil {
+		t.Fatalf("Delete: expected no remote recordset, got %+v", rs)
+	}
+
+	// the recordsets of the virtual machines registered by the virtual network link can't be changed
+	f.addRecordSet(resourceTypePrivate, "internal.example.com", "vm-1", provider.RecordTypeA, map[string]interface{}{"properties": map[string]interface{}{
+		"ttl": 10, "isAutoRegistered": true, "aRecords": []map[string]string{{"ipv4Address": "10.0.0.4"}},
+	}})
+	d, err = c.GetByName(ctx, "vm-1", z.Id, provider.RecordTypeA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if d.Activated || !reflect.DeepEqual(d.Records, []string{"10.0.0.4"}) {
+		t.Fatalf("GetByName: expected auto-registered recordset not activated, got %+v", d)
+	}
+}
+
+func TestClientGetZonePublicAndPrivate(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+	f.addZone(resourceTypePrivate, testZoneName)
+
+	if z, err := c.GetZone(ctx, testZoneName); err == nil || !strings.Contains(err.Error(), SettingKeyZoneType) {
+		t.Fatalf("GetZone: expected error for ambiguous zones, got %v, %v", z, err)
+	}
+	for zoneType, expected := range map[string]string{ZoneTypePublic: testZoneId, ZoneTypePrivate: zoneIdPrefixPrivate + testZoneName} {
+		z, err := f.newClient(t, zoneType).GetZone(ctx, testZoneName)
+		if err != nil || z.Id != expected {
+			t.Fatalf("GetZone: expected zone %s for zone type %s, got %v, %v", expected, zoneType, z, err)
+		}
+	}
+
+	if z, err := c.GetZone(ctx, "example.org"); err == nil {
+		t.Fatalf("GetZone: expected error for missing zone, got %+v", z)
+	}
+	if d, err := c.GetByName(ctx, "www", "example.com", provider.RecordTypeA); err == nil {
+		t.Fatalf("GetByName: expected error for invalid zone id, got %+v", d)
+	}
+
+	for _, zoneType := range []string{"", ZoneTypePublic, ZoneTypePrivate} {
+		if err := f.newClient(t, zoneType).Verify(ctx); err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+	}
+}
+
+func TestNewAzureDNSClientWithSettings(t *testing.T) {
+	for _, settings := range []map[string]string{
+		{SettingKeySubscriptionId: testSubscriptionId, SettingKeyResourceGroup: testResourceGroup,
+			SettingKeyTenantId: "tenant", SettingKeyClientId: "client", SettingKeyClientSecret: "secret"},
+		{SettingKeySubscriptionId: testSubscriptionId, SettingKeyResourceGroup: testResourceGroup, SettingKeyZoneType: ZoneTypePrivate},
+		{SettingKeySubscriptionId: testSubscriptionId, SettingKeyResourceGroup: testResourceGroup, SettingKeyClientId: "user-assigned"},
+	} {
+		c, err := NewAzureDNSClientWithSettings(settings)
+		if err != nil {
+			t.Fatalf("NewAzureDNSClientWithSettings: %v", err)
+		}
+		if got := c.(*Client).Config; got.SubscriptionId != testSubscriptionId || got.ResourceGroup != testResourceGroup || got.ZoneType != settings[SettingKeyZoneType] {
+			t.Fatalf("expected config from settings %v, got %+v", settings, got)
+		}
+	}
+
+	for _, invalid := range []map[string]string{
+		{},
+		{SettingKeySubscriptionId: testSubscriptionId},
+		{SettingKeyResourceGroup: testResourceGroup},
+		{SettingKeySubscriptionId: testSubscriptionId, SettingKeyResourceGroup: testResourceGroup, SettingKeyClientSecret: "secret"},
+		{SettingKeySubscriptionId: testSubscriptionId, SettingKeyResourceGroup: testResourceGroup, SettingKeyZoneType: "internal"},
+	} {
+		if _, err := NewAzureDNSClientWithSettings(invalid); err == nil {
+			t.Fatalf("NewAzureDNSClientWithSettings: expected error for settings %v", invalid)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package azuredns
+
+import (
+	"testing"
+
+	"github.com/sokdak/dns-ingress/pkg/provider/providertest"
+)
+
+func TestConformance(t *testing.T) {
+	providertest.RunConformance(t, func(t *testing.T) providertest.Fixture {
+		_, c := newTestClient(t)
+		return providertest.Fixture{Client: c, ZoneName: testZoneName}
+	})
+}
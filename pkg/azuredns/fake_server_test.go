@@ -0,0 +1,320 @@
+package azuredns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+)
+
+const (
+	testSubscriptionId = "00000000-0000-0000-0000-000000000000"
+	testResourceGroup  = "dns"
+	testAccessToken    = "test-access-token"
+)
+
+// resource types of the zones in the request path
+const (
+	resourceTypePublic  = "dnsZones"
+	resourceTypePrivate = "privateDnsZones"
+)
+
+// staticCredential issues the access token which the fake server accepts
+type staticCredential struct{}
+
+func (staticCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: testAccessToken, ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// fakeRecordSet is the recordset in the request body of either public or private zone
+type fakeRecordSet struct {
+	etag    string
+	public  *armdns.RecordSet
+	private *armprivatedns.RecordSet
+}
+
+// fakeServer is a minimal stand-in of the azure resource manager serving the zones and recordsets of azure dns and azure private dns
+type fakeServer struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// recordSets by resource type, zone name and type/name of the recordset, the zone exists if it has the map
+	zones map[string]map[string]map[string]*fakeRecordSet
+	// etags counts the etags issued
+	etags int
+	// beforePut is called before the recordset is put, if set
+	beforePut func()
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	f := &fakeServer{zones: map[string]map[string]map[string]*fakeRecordSet{resourceTypePublic: {}, resourceTypePrivate: {}}}
+	// azure sdk refuses to send the token over plain http
+	f.Server = httptest.NewTLSServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// newClient returns the client which talks to the fake server with the access token
+func (f *fakeServer) newClient(t *testing.T, zoneType string) *Client {
+	c, err := NewAzureDNSClient(Config{SubscriptionId: testSubscriptionId, ResourceGroup: testResourceGroup, ZoneType: zoneType}, staticCredential{}, &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Cloud: cloud.Configuration{Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+				cloud.ResourceManager: {Endpoint: f.URL, Audience: "https://management.azure.com"},
+			}},
+			Transport: f.Client(),
+			Retry:     policy.RetryOptions{MaxRetries: -1},
+		},
+		DisableRPRegistration: true,
+	})
+	if err != nil {
+		t.Fatalf("can't create client: %v", err)
+	}
+	return c
+}
+
+// addZone adds the zone of the resource type
+func (f *fakeServer) addZone(resourceType, zoneName string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.zones[resourceType][zoneName] = map[string]*fakeRecordSet{}
+}
+
+// addRecordSet seeds the zone with the recordset body as the api returns it
+func (f *fakeServer) addRecordSet(resourceType, zoneName, name, recordType string, body interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, _ := json.Marshal(body)
+	rs := &fakeRecordSet{etag: f.nextEtag()}
+	if resourceType == resourceTypePublic {
+		rs.public = &armdns.RecordSet{}
+		_ = json.Unmarshal(b, rs.public)
+	} else {
+		rs.private = &armprivatedns.RecordSet{}
+		_ = json.Unmarshal(b, rs.private)
+	}
+	f.zones[resourceType][zoneName][recordSetKey(recordType, name)] = rs
+}
+
+// touch changes the etag of the recordset, as if it is changed outside of dns-ingress
+func (f *fakeServer) touch(resourceType, zoneName, name, recordType string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.zones[resourceType][zoneName][recordSetKey(recordType, name)].etag = f.nextEtag()
+}
+
+// recordSet returns the stored recordset, nil if not found
+func (f *fakeServer) recordSet(resourceType, zoneName, name, recordType string) *recordSet {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rs, ok := f.zones[resourceType][zoneName][recordSetKey(recordType, name)]
+	if !ok {
+		return nil
+	}
+	return rs.convert(recordType)
+}
+
+// txtValues returns the character-strings of the TXT records as stored
+func (f *fakeServer) txtValues(resourceType, zoneName, name string) [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rs := f.zones[resourceType][zoneName][recordSetKey("TXT", name)]
+	values := make([][]string, 0)
+	for _, txt := range rs.public.Properties.TxtRecords {
+		value := make([]string, 0, len(txt.Value))
+		for _, v := range txt.Value {
+			value = append(value, *v)
+		}
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) < len(values[j]) })
+	return values
+}
+
+func (rs *fakeRecordSet) convert(recordType string) *recordSet {
+	var r *recordSet
+	if rs.public != nil {
+		r = fromPublicRecordSet(recordType, *rs.public)
+	} else {
+		r = fromPrivateRecordSet(recordType, *rs.private)
+	}
+	r.Etag = rs.etag
+	sort.Strings(r.Records)
+	return r
+}
+
+func (f *fakeServer) nextEtag() string {
+	f.etags++
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", f.etags)
+}
+
+func recordSetKey(recordType, name string) string {
+	return fmt.Sprintf("%s/%s", recordType, strings.ToLower(name))
+}
+
+func (f *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut && f.beforePut != nil {
+		f.beforePut()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+testAccessToken {
+		writeError(w, http.StatusUnauthorized, "AuthenticationFailed", "Authentication failed.")
+		return
+	}
+
+	// /subscriptions/{subscription}/resourceGroups/{group}/providers/Microsoft.Network/{resourceType}[/{zone}[/{type}/{name}]]
+	prefix := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/", testSubscriptionId, testResourceGroup)
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		writeError(w, http.StatusNotFound, "ResourceGroupNotFound", "Resource group could not be found.")
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	zones, ok := f.zones[parts[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "InvalidResourceType", "The resource type could not be found.")
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		list := make([]map[string]interface{}, 0, len(zones))
+		for zoneName := range zones {
+			list = append(list, zoneBody(parts[0], zoneName))
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"value": list})
+	case len(parts) >= 2:
+		recordSets, ok := zones[parts[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "ResourceNotFound", "The Resource '"+parts[1]+"' was not found.")
+			return
+		}
+		switch {
+		case len(parts) == 2 && r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, zoneBody(parts[0], parts[1]))
+		case len(parts) == 4:
+			f.serveRecordSet(w, r, parts[0], parts[1], strings.ToUpper(parts[2]), parts[3], recordSets)
+		default:
+			writeError(w, http.StatusNotFound, "NotFound", "Not Found")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", "Not Found")
+	}
+}
+
+func (f *fakeServer) serveRecordSet(w http.ResponseWriter, r *http.Request, resourceType, zoneName, recordType, name string, recordSets map[string]*fakeRecordSet) {
+	key := recordSetKey(recordType, name)
+	current, exists := recordSets[key]
+	ifMatch := r.Header.Get("If-Match")
+	if len(ifMatch) > 0 && (!exists || current.etag != ifMatch) {
+		writeError(w, http.StatusPreconditionFailed, "PreconditionFailed", "The condition '"+ifMatch+"' in the If-Match header was not satisfied.")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			writeError(w, http.StatusNotFound, "NotFound", "The resource record '"+name+"' does not exist in resource group '"+testResourceGroup+"'.")
+			return
+		}
+		writeJSON(w, http.StatusOK, recordSetBody(resourceType, zoneName, recordType, name, current))
+	case http.MethodPut:
+		if exists && r.Header.Get("If-None-Match") == "*" {
+			writeError(w, http.StatusPreconditionFailed, "PreconditionFailed", "The Record set "+name+" exists already and hence cannot be created again.")
+			return
+		}
+		// CNAME can't coexist with the other recordsets of the same name
+		for k := range recordSets {
+			otherType, otherName, _ := strings.Cut(k, "/")
+			if otherName == strings.ToLower(name) && otherType != recordType && (otherType == "CNAME" || recordType == "CNAME") {
+				writeError(w, http.StatusConflict, "Conflict", "The record set could not be created because a CNAME record set with the same name already exists or is being created.")
+				return
+			}
+		}
+
+		rs := &fakeRecordSet{etag: f.nextEtag()}
+		var err error
+		if resourceType == resourceTypePublic {
+			rs.public = &armdns.RecordSet{}
+			err = json.NewDecoder(r.Body).Decode(rs.public)
+		} else {
+			rs.private = &armprivatedns.RecordSet{}
+			err = json.NewDecoder(r.Body).Decode(rs.private)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidRequestContent", err.Error())
+			return
+		}
+		if converted := rs.convert(recordType); len(converted.Records) == 0 || converted.TTL <= 0 {
+			writeError(w, http.StatusBadRequest, "BadRequest", "The recordset has no records or ttl.")
+			return
+		}
+
+		recordSets[key] = rs
+		status := http.StatusCreated
+		if exists {
+			status = http.StatusOK
+		}
+		writeJSON(w, status, recordSetBody(resourceType, zoneName, recordType, name, rs))
+	case http.MethodDelete:
+		if !exists {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		delete(recordSets, key)
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "Method Not Allowed")
+	}
+}
+
+func zoneBody(resourceType, zoneName string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/%s/%s", testSubscriptionId, testResourceGroup, resourceType, zoneName),
+		"name":     zoneName,
+		"type":     "Microsoft.Network/" + resourceType,
+		"location": "global",
+	}
+}
+
+// recordSetBody returns the stored recordset with its identity and etag
+func recordSetBody(resourceType, zoneName, recordType, name string, rs *fakeRecordSet) interface{} {
+	id := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/%s/%s/%s/%s",
+		testSubscriptionId, testResourceGroup, resourceType, zoneName, recordType, name)
+	if rs.public != nil {
+		body := *rs.public
+		body.ID, body.Name, body.Etag = to.Ptr(id), to.Ptr(name), to.Ptr(rs.etag)
+		body.Type = to.Ptr("Microsoft.Network/dnszones/" + recordType)
+		return body
+	}
+	body := *rs.private
+	body.ID, body.Name, body.Etag = to.Ptr(id), to.Ptr(name), to.Ptr(rs.etag)
+	body.Type = to.Ptr("Microsoft.Network/privateDnsZones/" + recordType)
+	return body
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError responds in the error format of the azure resource manager
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{"error": map[string]string{"code": code, "message": message}})
+}
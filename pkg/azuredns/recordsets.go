@@ -0,0 +1,311 @@
+package azuredns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/dns/armdns"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/privatedns/armprivatedns"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ttlDefault is applied if ttl is not set
+const ttlDefault = 300
+
+// metadataKeyManagedBy marks the recordsets in their metadata
+const metadataKeyManagedBy = "managedBy"
+
+// recordSet is the recordset of either public or private zone
+type recordSet struct {
+	Type    string
+	TTL     int
+	Records []string
+	// Etag changes on every change of the recordset
+	Etag string
+	// AutoRegistered is set on the recordsets of the virtual machines registered by the virtual network link
+	AutoRegistered bool
+}
+
+// recordSets reads and changes the recordsets of the zones, which are either public or private
+type recordSets interface {
+	// verify checks the zones in the resource group can be listed
+	verify(ctx context.Context) error
+	// zoneExists reports whether the zone exists in the resource group
+	zoneExists(ctx context.Context, zoneName string) (bool, error)
+	// get returns the recordset, nil if not found
+	get(ctx context.Context, zoneName, name, recordType string) (*recordSet, error)
+	// put creates the recordset if etag is empty, fails if exists then, replaces the recordset of the etag otherwise
+	put(ctx context.Context, zoneName, name string, rs recordSet, etag string) (*recordSet, error)
+	// delete deletes the recordset of the etag
+	delete(ctx context.Context, zoneName, name, recordType, etag string) error
+}
+
+// conditions returns the If-Match and If-None-Match headers of put, the recordset is only created if etag is empty
+func conditions(etag string) (ifMatch, ifNoneMatch *string) {
+	if len(etag) == 0 {
+		return nil, to.Ptr("*")
+	}
+	return to.Ptr(etag), nil
+}
+
+// publicRecordSets is the recordsets of the azure dns zones
+type publicRecordSets struct {
+	resourceGroup string
+	zones         *armdns.ZonesClient
+	recordSets    *armdns.RecordSetsClient
+}
+
+func (p *publicRecordSets) verify(ctx context.Context) error {
+	_, err := p.zones.NewListByResourceGroupPager(p.resourceGroup, nil).NextPage(ctx)
+	return err
+}
+
+func (p *publicRecordSets) zoneExists(ctx context.Context, zoneName string) (bool, error) {
+	if _, err := p.zones.Get(ctx, p.resourceGroup, zoneName, nil); err != nil {
+		if hasStatusCode(err, http.StatusNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *publicRecordSets) get(ctx context.Context, zoneName, name, recordType string) (*recordSet, error) {
+	resp, err := p.recordSets.Get(ctx, p.resourceGroup, zoneName, name, armdns.RecordType(recordType), nil)
+	if err != nil {
+		if hasStatusCode(err, http.StatusNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return fromPublicRecordSet(recordType, resp.RecordSet), nil
+}
+
+func (p *publicRecordSets) put(ctx context.Context, zoneName, name string, rs recordSet, etag string) (*recordSet, error) {
+	props := &armdns.RecordSetProperties{
+		TTL:      to.Ptr(int64(rs.TTL)),
+		Metadata: map[string]*string{metadataKeyManagedBy: to.Ptr(recordComment)},
+	}
+	switch rs.Type {
+	case provider.RecordTypeA:
+		for _, r := range rs.Records {
+			props.ARecords = append(props.ARecords, &armdns.ARecord{IPv4Address: to.Ptr(r)})
+		}
+	case provider.RecordTypeAAAA:
+		for _, r := range rs.Records {
+			props.AaaaRecords = append(props.AaaaRecords, &armdns.AaaaRecord{IPv6Address: to.Ptr(r)})
+		}
+	case provider.RecordTypeCNAME:
+		props.CnameRecord = &armdns.CnameRecord{Cname: to.Ptr(rs.Records[0])}
+	case provider.RecordTypeTXT:
+		for _, r := range rs.Records {
+			props.TxtRecords = append(props.TxtRecords, &armdns.TxtRecord{Value: to.SliceOfPtrs(provider.SplitTXT(r)...)})
+		}
+	}
+
+	ifMatch, ifNoneMatch := conditions(etag)
+	resp, err := p.recordSets.CreateOrUpdate(ctx, p.resourceGroup, zoneName, name, armdns.RecordType(rs.Type),
+		armdns.RecordSet{Properties: props}, &armdns.RecordSetsClientCreateOrUpdateOptions{IfMatch: ifMatch, IfNoneMatch: ifNoneMatch})
+	if err != nil {
+		return nil, err
+	}
+	return fromPublicRecordSet(rs.Type, resp.RecordSet), nil
+}
+
+func (p *publicRecordSets) delete(ctx context.Context, zoneName, name, recordType, etag string) error {
+	_, err := p.recordSets.Delete(ctx, p.resourceGroup, zoneName, name, armdns.RecordType(recordType),
+		&armdns.RecordSetsClientDeleteOptions{IfMatch: to.Ptr(etag)})
+	return err
+}
+
+func fromPublicRecordSet(recordType string, rs armdns.RecordSet) *recordSet {
+	r := &recordSet{Type: recordType, Etag: deref(rs.Etag), Records: []string{}}
+	props := rs.Properties
+	if props == nil {
+		return r
+	}
+	r.TTL = int(deref(props.TTL))
+	for _, a := range props.ARecords {
+		r.Records = append(r.Records, deref(a.IPv4Address))
+	}
+	for _, aaaa := range props.AaaaRecords {
+		r.Records = append(r.Records, deref(aaaa.IPv6Address))
+	}
+	if props.CnameRecord != nil {
+		r.Records = append(r.Records, deref(props.CnameRecord.Cname))
+	}
+	for _, txt := range props.TxtRecords {
+		r.Records = append(r.Records, joinTXT(txt.Value))
+	}
+	return r
+}
+
+// privateRecordSets is the recordsets of the azure private dns zones
+type privateRecordSets struct {
+	resourceGroup string
+	zones         *armprivatedns.PrivateZonesClient
+	recordSets    *armprivatedns.RecordSetsClient
+}
+
+func (p *privateRecordSets) verify(ctx context.Context) error {
+	_, err := p.zones.NewListByResourceGroupPager(p.resourceGroup, nil).NextPage(ctx)
+	return err
+}
+
+func (p *privateRecordSets) zoneExists(ctx context.Context, zoneName string) (bool, error) {
+	if _, err := p.zones.Get(ctx, p.resourceGroup, zoneName, nil); err != nil {
+		if hasStatusCode(err, http.StatusNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *privateRecordSets) get(ctx context.Context, zoneName, name, recordType string) (*recordSet, error) {
+	resp, err := p.recordSets.Get(ctx, p.resourceGroup, zoneName, armprivatedns.RecordType(recordType), name, nil)
+	if err != nil {
+		if hasStatusCode(err, http.StatusNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return fromPrivateRecordSet(recordType, resp.RecordSet), nil
+}
+
+func (p *privateRecordSets) put(ctx context.Context, zoneName, name string, rs recordSet, etag string) (*recordSet, error) {
+	props := &armprivatedns.RecordSetProperties{
+		TTL:      to.Ptr(int64(rs.TTL)),
+		Metadata: map[string]*string{metadataKeyManagedBy: to.Ptr(recordComment)},
+	}
+	switch rs.Type {
+	case provider.RecordTypeA:
+		for _, r := range rs.Records {
+			props.ARecords = append(props.ARecords, &armprivatedns.ARecord{IPv4Address: to.Ptr(r)})
+		}
+	case provider.RecordTypeAAAA:
+		for _, r := range rs.Records {
+			props.AaaaRecords = append(props.AaaaRecords, &armprivatedns.AaaaRecord{IPv6Address: to.Ptr(r)})
+		}
+	case provider.RecordTypeCNAME:
+		props.CnameRecord = &armprivatedns.CnameRecord{Cname: to.Ptr(rs.Records[0])}
+	case provider.RecordTypeTXT:
+		for _, r := range rs.Records {
+			props.TxtRecords = append(props.TxtRecords, &armprivatedns.TxtRecord{Value: to.SliceOfPtrs(provider.SplitTXT(r)...)})
+		}
+	}
+
+	ifMatch, ifNoneMatch := conditions(etag)
+	resp, err := p.recordSets.CreateOrUpdate(ctx, p.resourceGroup, zoneName, armprivatedns.RecordType(rs.Type), name,
+		armprivatedns.RecordSet{Properties: props}, &armprivatedns.RecordSetsClientCreateOrUpdateOptions{IfMatch: ifMatch, IfNoneMatch: ifNoneMatch})
+	if err != nil {
+		return nil, err
+	}
+	return fromPrivateRecordSet(rs.Type, resp.RecordSet), nil
+}
+
+func (p *privateRecordSets) delete(ctx context.Context, zoneName, name, recordType, etag string) error {
+	_, err := p.recordSets.Delete(ctx, p.resourceGroup, zoneName, armprivatedns.RecordType(recordType), name,
+		&armprivatedns.RecordSetsClientDeleteOptions{IfMatch: to.Ptr(etag)})
+	return err
+}
+
+func fromPrivateRecordSet(recordType string, rs armprivatedns.RecordSet) *recordSet {
+	r := &recordSet{Type: recordType, Etag: deref(rs.Etag), Records: []string{}}
+	props := rs.Properties
+	if props == nil {
+		return r
+	}
+	r.TTL = int(deref(props.TTL))
+	r.AutoRegistered = props.IsAutoRegistered != nil && *props.IsAutoRegistered
+	for _, a := range props.ARecords {
+		r.Records = append(r.Records, deref(a.IPv4Address))
+	}
+	for _, aaaa := range props.AaaaRecords {
+		r.Records = append(r.Records, deref(aaaa.IPv6Address))
+	}
+	if props.CnameRecord != nil {
+		r.Records = append(r.Records, deref(props.CnameRecord.Cname))
+	}
+	for _, txt := range props.TxtRecords {
+		r.Records = append(r.Records, joinTXT(txt.Value))
+	}
+	return r
+}
+
+// newRecordSet builds the recordset of the records, CNAME target is stored without trailing dot as azure reports
+func newRecordSet(recordType string, records []string, ttl int) (recordSet, error) {
+	if recordType == provider.RecordTypeCNAME && len(records) != 1 {
+		return recordSet{}, fmt.Errorf("CNAME recordset must have a single record, got %d", len(records))
+	}
+	rs := recordSet{Type: recordType, TTL: normalizeTTL(ttl), Records: make([]string, 0, len(records))}
+	switch recordType {
+	case provider.RecordTypeA, provider.RecordTypeAAAA, provider.RecordTypeTXT:
+	case provider.RecordTypeCNAME:
+		records = []string{strings.TrimSuffix(records[0], ".")}
+	default:
+		return recordSet{}, fmt.Errorf("record type %s is not supported", recordType)
+	}
+	rs.Records = append(rs.Records, records...)
+	return rs, nil
+}
+
+// convertRecordSet converts the recordset of the zone into a recordset, auto-registered ones are not activated as they can't be changed
+func convertRecordSet(name, zoneId, zoneName string, rs recordSet) *provider.Domain {
+	records := make([]string, 0, len(rs.Records))
+	for _, r := range rs.Records {
+		if rs.Type == provider.RecordTypeCNAME {
+			r = strings.TrimSuffix(r, ".")
+		}
+		records = append(records, r)
+	}
+	sort.Strings(records)
+
+	return &provider.Domain{
+		Id:        provider.GenerateRecordSetId(name, rs.Type),
+		Name:      name,
+		Type:      rs.Type,
+		Records:   records,
+		TTL:       rs.TTL,
+		ZoneId:    zoneId,
+		ZoneName:  zoneName,
+		FQDN:      fmt.Sprintf("%s.", provider.JoinName(name, zoneName)),
+		Activated: !rs.AutoRegistered,
+	}
+}
+
+// joinTXT joins the character-strings of the TXT value
+func joinTXT(value []*string) string {
+	var b strings.Builder
+	for _, v := range value {
+		b.WriteString(deref(v))
+	}
+	return b.String()
+}
+
+// hasStatusCode reports whether the error is the response of the status code
+func hasStatusCode(err error, statusCode int) bool {
+	var respErr *azcore.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == statusCode
+}
+
+func normalizeTTL(ttl int) int {
+	if ttl <= 0 {
+		return ttlDefault
+	}
+	return ttl
+}
+
+// deref returns the value of the pointer, the zero value if nil
+func deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
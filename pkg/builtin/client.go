@@ -0,0 +1,173 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"strings"
+)
+
+const ProviderKey = "builtin"
+
+// ZoneIdPrefix prefixes the zone ids of the builtin provider, which tells the domains served by the builtin dns server
+const ZoneIdPrefix = "builtin:"
+
+func init() {
+	provider.Register(ProviderKey, NewBuiltinClientWithSettings)
+}
+
+// Client manages the recordsets of the builtin dns server, which is run by the manager for the clusters without dns backend.
+// the zones are configured on the manager, and the index is rebuilt from the ready domains on restart.
+// the ownership records are not rebuilt as they have no domains, so the ownership is not tracked on it.
+type Client struct {
+	provider.Client
+
+	index *Index
+}
+
+// NewBuiltinClientWithSettings creates the client of the default index, there's no settings
+func NewBuiltinClientWithSettings(_ map[string]string) (provider.Client, error) {
+	c, err := NewBuiltinClient(DefaultIndex)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewBuiltinClient creates the client managing the recordsets on the index
+func NewBuiltinClient(index *Index) (*Client, error) {
+	if index == nil {
+		return nil, fmt.Errorf("can't create new builtin client: index is required")
+	}
+	return &Client{index: index}, nil
+}
+
+// ZoneNameOf returns the zone name of the zone id, false if the zone is not of the builtin provider
+func ZoneNameOf(zoneId string) (string, bool) {
+	if !strings.HasPrefix(zoneId, ZoneIdPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(zoneId, ZoneIdPrefix), true
+}
+
+// OwnershipUnsupported tells the ownership can't be tracked, as the companions are lost on restart or on another leader
+func (c *Client) OwnershipUnsupported() bool {
+	return true
+}
+
+// Verify checks the builtin dns server serves any zone, which is not the case if it is not enabled on the manager
+func (c *Client) Verify(_ context.Context) error {
+	if len(c.index.Zones()) == 0 {
+		return fmt.Errorf("can't verify builtin provider: no zone is served, the builtin dns server may not be enabled")
+	}
+	return nil
+}
+
+func (c *Client) GetZone(_ context.Context, zoneName string) (*provider.Zone, error) {
+	name := normalizeName(zoneName)
+	if !c.index.HasZone(name) {
+		return nil, fmt.Errorf("can't GetZone: cannot find zone %s", zoneName)
+	}
+	return &provider.Zone{Id: ZoneIdPrefix + name, Name: name, Activated: true}, nil
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	d, err := c.Get(ctx, provider.GenerateRecordSetId(name, recordType), zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Get(_ context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	zoneName, err := zoneNameOf(zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+
+	rs, err := c.index.Get(zoneName, name, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	if rs == nil {
+		return nil, nil
+	}
+	return convertRecordSet(name, zoneId, recordType, rs), nil
+}
+
+func (c *Client) Create(_ context.Context, name, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	zoneName, err := zoneNameOf(zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	rs, err := c.index.Create(zoneName, name, recordType, records, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	return convertRecordSet(name, zoneId, recordType, rs), nil
+}
+
+func (c *Client) Update(_ context.Context, id, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	name, currentType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	zoneName, err := zoneNameOf(zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	rs, err := c.index.Update(zoneName, name, currentType, recordType, records, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if rs == nil {
+		return nil, nil
+	}
+	return convertRecordSet(name, zoneId, recordType, rs), nil
+}
+
+func (c *Client) Delete(_ context.Context, id, zoneId string) error {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	zoneName, err := zoneNameOf(zoneId)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	if err := c.index.Delete(zoneName, name, recordType); err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	return nil
+}
+
+// convertRecordSet converts the recordset of the index into the domain
+func convertRecordSet(name, zoneId, recordType string, rs *RecordSet) *provider.Domain {
+	zoneName, _ := ZoneNameOf(zoneId)
+	return &provider.Domain{
+		Id:        provider.GenerateRecordSetId(name, recordType),
+		Name:      name,
+		Type:      recordType,
+		Records:   rs.Records,
+		TTL:       rs.TTL,
+		ZoneId:    zoneId,
+		ZoneName:  zoneName,
+		FQDN:      fmt.Sprintf("%s.", provider.JoinName(name, zoneName)),
+		Activated: true,
+	}
+}
+
+func zoneNameOf(zoneId string) (string, error) {
+	zoneName, ok := ZoneNameOf(zoneId)
+	if !ok {
+		return "", fmt.Errorf("unknown zone id %s", zoneId)
+	}
+	return zoneName, nil
+}
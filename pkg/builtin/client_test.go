@@ -0,0 +1,151 @@
+package builtin
+
+import (
+	"context"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testZoneName = "cluster.internal"
+
+// newTestIndex returns the index serving the test zone, which is not the default index
+func newTestIndex(t *testing.T) *Index {
+	index := NewIndex()
+	if err := index.AddZone(testZoneName); err != nil {
+		t.Fatalf("AddZone: %v", err)
+	}
+	return index
+}
+
+func newTestClient(t *testing.T) *Client {
+	c, err := NewBuiltinClient(newTestIndex(t))
+	if err != nil {
+		t.Fatalf("NewBuiltinClient: %v", err)
+	}
+	return c
+}
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+
+	z, err := c.GetZone(ctx, "Cluster.Internal.")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: "builtin:cluster.internal", Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: unexpected zone %+v", z)
+	}
+	if zoneName, ok := ZoneNameOf(z.Id); !ok || zoneName != testZoneName {
+		t.Fatalf("ZoneNameOf: expected %s, got %s, %v", testZoneName, zoneName, ok)
+	}
+	if _, err := c.GetZone(ctx, "example.com"); err == nil {
+		t.Fatalf("GetZone: expected error for the zone not served")
+	}
+
+	d, err := c.Create(ctx, "www", z.Id, provider.RecordTypeA, []string{"10.0.0.2", "10.0.0.1", "10.0.0.2"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("www", provider.RecordTypeA),
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"10.0.0.1", "10.0.0.2"},
+		TTL:       ttlDefault,
+		ZoneId:    z.Id,
+		ZoneName:  testZoneName,
+		FQDN:      "www.cluster.internal.",
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+
+	// the records are shared with the other clients of the index
+	other, err := NewBuiltinClient(c.index)
+	if err != nil {
+		t.Fatalf("NewBuiltinClient: %v", err)
+	}
+	if got, err := other.Get(ctx, d.Id, z.Id); err != nil || !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Get: expected %+v, got %+v, %v", expected, got, err)
+	}
+
+	d, err = c.Update(ctx, d.Id, z.Id, provider.RecordTypeCNAME, []string{"ingress.cluster.internal."}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != "www/CNAME" || d.TTL != 60 || !reflect.DeepEqual(d.Records, []string{"ingress.cluster.internal"}) {
+		t.Fatalf("Update: unexpected recordset %+v", d)
+	}
+	if got, err := c.GetByName(ctx, "www", z.Id, provider.RecordTypeA); err != nil || got != nil {
+		t.Fatalf("GetByName: expected the A recordset replaced, got %+v, %v", got, err)
+	}
+
+	if err := c.Delete(ctx, d.Id, z.Id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got, err := c.Get(ctx, d.Id, z.Id); err != nil || got != nil {
+		t.Fatalf("Get: expected the recordset deleted, got %+v, %v", got, err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t)
+	zoneId := ZoneIdPrefix + testZoneName
+
+	if err := c.Verify(ctx); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	empty, err := NewBuiltinClient(NewIndex())
+	if err != nil {
+		t.Fatalf("NewBuiltinClient: %v", err)
+	}
+	if err := empty.Verify(ctx); err == nil || !strings.Contains(err.Error(), "no zone is served") {
+		t.Fatalf("Verify: expected error of no zone, got %v", err)
+	}
+
+	if _, err := c.Get(ctx, "www/A", testZoneName); err == nil || !strings.Contains(err.Error(), "unknown zone id") {
+		t.Fatalf("Get: expected error of the zone id of the other provider, got %v", err)
+	}
+	if _, err := c.Create(ctx, "@", zoneId, provider.RecordTypeCNAME, []string{"example.com"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for CNAME at the zone apex")
+	}
+	if _, err := c.Create(ctx, "www", zoneId, provider.RecordTypeA, []string{"10.0.0.1"}, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := c.Create(ctx, "www", zoneId, provider.RecordTypeCNAME, []string{"example.com"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for CNAME conflicting with A record")
+	}
+
+	for _, tt := range []struct {
+		recordType string
+		ttl        int
+		options    map[string]string
+		wantErr    string
+	}{
+		{recordType: provider.RecordTypeA, ttl: -1, wantErr: "ttl must be between 0 and 2147483647"},
+		{recordType: "MX", wantErr: "MX records are not supported"},
+		{recordType: provider.RecordTypeA, options: map[string]string{"proxied": "true"}, wantErr: "unknown builtin option proxied"},
+	} {
+		if _, err := c.NormalizeOptions(tt.recordType, tt.ttl, tt.options); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("NormalizeOptions: expected error containing %q, got %v", tt.wantErr, err)
+		}
+	}
+}
+
+func TestNewBuiltinClientWithSettings(t *testing.T) {
+	c, err := NewBuiltinClientWithSettings(nil)
+	if err != nil {
+		t.Fatalf("NewBuiltinClientWithSettings: %v", err)
+	}
+	if c.(*Client).index != DefaultIndex {
+		t.Fatalf("expected the client of the default index")
+	}
+	if _, err := NewBuiltinClient(nil); err == nil {
+		t.Fatalf("NewBuiltinClient: expected error without index")
+	}
+}
@@ -0,0 +1,12 @@
+package builtin
+
+import (
+	"github.com/sokdak/dns-ingress/pkg/provider/providertest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	providertest.RunConformance(t, func(t *testing.T) providertest.Fixture {
+		return providertest.Fixture{Client: newTestClient(t), ZoneName: testZoneName}
+	})
+}
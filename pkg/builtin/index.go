@@ -0,0 +1,330 @@
+package builtin
+
+import (
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ttlDefault is applied if ttl is not set
+const ttlDefault = 300
+
+// RecordSet is the records of a name and type served by the builtin dns server
+type RecordSet struct {
+	Records []string
+	TTL     int
+}
+
+// Index keeps the recordsets of the zones the builtin dns server is authoritative for.
+// it is written by the provider client on the leader, and by the domains on every replica.
+type Index struct {
+	mu sync.RWMutex
+	// zones by zone name
+	zones map[string]*zone
+}
+
+type zone struct {
+	name        string
+	nameservers []string
+	hostmaster  string
+	serial      uint32
+	// recordSets by the fully-qualified name and the record type
+	recordSets map[string]map[string]RecordSet
+}
+
+// DefaultIndex is the index served by the builtin provider kind
+var DefaultIndex = NewIndex()
+
+func NewIndex() *Index {
+	return &Index{zones: map[string]*zone{}}
+}
+
+// AddZone adds the empty zone of the name, which is delegated to the nameservers.
+// the nameserver defaults to ns.<zone> if not given, whose address is served from the recordsets.
+func (i *Index) AddZone(zoneName string, nameservers ...string) error {
+	name := normalizeName(zoneName)
+	if len(name) == 0 {
+		return fmt.Errorf("zone name is required")
+	}
+	z := &zone{
+		name:       name,
+		hostmaster: provider.JoinName("hostmaster", name),
+		serial:     uint32(time.Now().Unix()),
+		recordSets: map[string]map[string]RecordSet{},
+	}
+	for _, ns := range nameservers {
+		if ns = normalizeName(ns); len(ns) > 0 {
+			z.nameservers = append(z.nameservers, ns)
+		}
+	}
+	if len(z.nameservers) == 0 {
+		z.nameservers = []string{provider.JoinName("ns", name)}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, found := i.zones[name]; found {
+		return fmt.Errorf("zone %s already exists", name)
+	}
+	i.zones[name] = z
+	return nil
+}
+
+// Zones returns the names of the zones in order
+func (i *Index) Zones() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	names := make([]string, 0, len(i.zones))
+	for name := range i.zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasZone returns whether the zone of the name is served
+func (i *Index) HasZone(zoneName string) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	_, found := i.zones[normalizeName(zoneName)]
+	return found
+}
+
+// Get returns the recordset of the name relative to the zone, nil if not found
+func (i *Index) Get(zoneName, name, recordType string) (*RecordSet, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	z, err := i.zoneOf(zoneName)
+	if err != nil {
+		return nil, err
+	}
+	rs, found := z.recordSets[z.fqdn(name)][recordType]
+	if !found {
+		return nil, nil
+	}
+	return copyRecordSet(rs), nil
+}
+
+// Create adds the recordset, which fails if the recordset already exists
+func (i *Index) Create(zoneName, name, recordType string, records []string, ttl int) (*RecordSet, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	z, err := i.zoneOf(zoneName)
+	if err != nil {
+		return nil, err
+	}
+	if _, found := z.recordSets[z.fqdn(name)][recordType]; found {
+		return nil, fmt.Errorf("recordset %s %s already exists", z.fqdn(name), recordType)
+	}
+	return z.put(name, recordType, records, ttl, "")
+}
+
+// Update replaces the recordset of the current type with the records, nil if the current one is not found
+func (i *Index) Update(zoneName, name, currentType, recordType string, records []string, ttl int) (*RecordSet, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	z, err := i.zoneOf(zoneName)
+	if err != nil {
+		return nil, err
+	}
+	if _, found := z.recordSets[z.fqdn(name)][currentType]; !found {
+		return nil, nil
+	}
+	return z.put(name, recordType, records, ttl, currentType)
+}
+
+// Set creates or replaces the recordset
+func (i *Index) Set(zoneName, name, recordType string, records []string, ttl int) (*RecordSet, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	z, err := i.zoneOf(zoneName)
+	if err != nil {
+		return nil, err
+	}
+	return z.put(name, recordType, records, ttl, recordType)
+}
+
+// Delete removes the recordset, it is not an error if the recordset is not found
+func (i *Index) Delete(zoneName, name, recordType string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	z, err := i.zoneOf(zoneName)
+	if err != nil {
+		return err
+	}
+	z.remove(z.fqdn(name), recordType)
+	return nil
+}
+
+// zoneOf returns the zone of the name, the lock has to be held by the caller
+func (i *Index) zoneOf(zoneName string) (*zone, error) {
+	z, found := i.zones[normalizeName(zoneName)]
+	if !found {
+		return nil, fmt.Errorf("zone %s is not served by the builtin dns server", zoneName)
+	}
+	return z, nil
+}
+
+// findZone returns the zone which is the closest to the fully-qualified name, nil if none is authoritative.
+// the read lock has to be held by the caller.
+func (i *Index) findZone(fqdn string) *zone {
+	for name := fqdn; ; name = parentName(name) {
+		if z, found := i.zones[name]; found {
+			return z
+		}
+		if len(name) == 0 {
+			return nil
+		}
+	}
+}
+
+// put validates the records as a dns server would and stores the recordset,
+// the recordset of the replaced type is removed and ignored on conflicts
+func (z *zone) put(name, recordType string, records []string, ttl int, replacedType string) (*RecordSet, error) {
+	if len(name) == 0 {
+		return nil, fmt.Errorf("record name is required")
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no records given for %s", name)
+	}
+
+	normalized := make([]string, 0, len(records))
+	seen := map[string]bool{}
+	for _, r := range records {
+		r, err := normalizeRecord(recordType, r)
+		if err != nil {
+			return nil, err
+		}
+		// a recordset is a set, the duplicated records are merged
+		if !seen[r] {
+			seen[r] = true
+			normalized = append(normalized, r)
+		}
+	}
+	sort.Strings(normalized)
+
+	fqdn := z.fqdn(name)
+	// CNAME can't coexist with the other records of the name
+	for t := range z.recordSets[fqdn] {
+		if t == replacedType || t == recordType {
+			continue
+		}
+		if recordType == provider.RecordTypeCNAME || t == provider.RecordTypeCNAME {
+			return nil, fmt.Errorf("%s record of %s conflicts with the existing %s record", recordType, name, t)
+		}
+	}
+	if recordType == provider.RecordTypeCNAME && len(normalized) > 1 {
+		return nil, fmt.Errorf("CNAME record of %s can't have multiple records", name)
+	}
+	if fqdn == z.name && recordType == provider.RecordTypeCNAME {
+		return nil, fmt.Errorf("CNAME record can't be at the zone apex")
+	}
+
+	if ttl <= 0 {
+		ttl = ttlDefault
+	}
+	rs := RecordSet{Records: normalized, TTL: ttl}
+	if len(replacedType) > 0 {
+		delete(z.recordSets[fqdn], replacedType)
+	}
+	if z.recordSets[fqdn] == nil {
+		z.recordSets[fqdn] = map[string]RecordSet{}
+	}
+	z.recordSets[fqdn][recordType] = rs
+	z.bumpSerial()
+	return copyRecordSet(rs), nil
+}
+
+// remove removes the recordset of the fully-qualified name, the serial changes only if it is found
+func (z *zone) remove(fqdn, recordType string) {
+	if _, found := z.recordSets[fqdn][recordType]; !found {
+		return
+	}
+	delete(z.recordSets[fqdn], recordType)
+	if len(z.recordSets[fqdn]) == 0 {
+		delete(z.recordSets, fqdn)
+	}
+	z.bumpSerial()
+}
+
+// bumpSerial increases the serial of the zone, which follows the clock not to go back on restart
+func (z *zone) bumpSerial() {
+	serial := z.serial + 1
+	if now := uint32(time.Now().Unix()); now > serial {
+		serial = now
+	}
+	z.serial = serial
+}
+
+// fqdn returns the fully-qualified name of the record name relative to the zone, without trailing dot
+func (z *zone) fqdn(name string) string {
+	return normalizeName(provider.JoinName(name, z.name))
+}
+
+// exists returns whether the name has any record, or is an empty non-terminal of the zone
+func (z *zone) exists(fqdn string) bool {
+	if fqdn == z.name || len(z.recordSets[fqdn]) > 0 {
+		return true
+	}
+	suffix := "." + fqdn
+	for name := range z.recordSets {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeRecord validates the record of the type, CNAME is returned without trailing dot as the other providers do
+func normalizeRecord(recordType, record string) (string, error) {
+	switch recordType {
+	case provider.RecordTypeA:
+		if ip := net.ParseIP(record); ip == nil || ip.To4() == nil {
+			return "", fmt.Errorf("invalid A record %s", record)
+		}
+	case provider.RecordTypeAAAA:
+		if ip := net.ParseIP(record); ip == nil || ip.To4() != nil {
+			return "", fmt.Errorf("invalid AAAA record %s", record)
+		}
+	case provider.RecordTypeCNAME:
+		record = strings.TrimSuffix(record, ".")
+		if len(record) == 0 {
+			return "", fmt.Errorf("invalid CNAME record, target is empty")
+		}
+	case provider.RecordTypeTXT:
+	default:
+		return "", fmt.Errorf("%s records are not supported by the builtin dns server", recordType)
+	}
+	return record, nil
+}
+
+// supportedRecordTypes are the record types the builtin dns server answers
+var supportedRecordTypes = map[string]bool{
+	provider.RecordTypeA:     true,
+	provider.RecordTypeAAAA:  true,
+	provider.RecordTypeCNAME: true,
+	provider.RecordTypeTXT:   true,
+}
+
+// copyRecordSet copies the recordset not to share the records with the caller
+func copyRecordSet(rs RecordSet) *RecordSet {
+	return &RecordSet{Records: append([]string{}, rs.Records...), TTL: rs.TTL}
+}
+
+// parentName returns the name without the first label, empty for the root
+func parentName(name string) string {
+	idx := strings.Index(name, ".")
+	if idx < 0 {
+		return ""
+	}
+	return name[idx+1:]
+}
+
+// normalizeName returns the name in lower case without trailing dot
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
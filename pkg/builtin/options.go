@@ -0,0 +1,21 @@
+package builtin
+
+import (
+	"fmt"
+	"math"
+)
+
+// NormalizeOptions validates the recordset can be served by the builtin dns server, which has no options
+func (c *Client) NormalizeOptions(recordType string, ttl int, options map[string]string) (map[string]string, error) {
+	for key := range options {
+		return nil, fmt.Errorf("unknown builtin option %s", key)
+	}
+
+	if !supportedRecordTypes[recordType] {
+		return nil, fmt.Errorf("%s records are not supported by the builtin dns server", recordType)
+	}
+	if ttl < 0 || ttl > math.MaxInt32 {
+		return nil, fmt.Errorf("ttl must be between 0 and %d, got %d", math.MaxInt32, ttl)
+	}
+	return nil, nil
+}
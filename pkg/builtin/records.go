@@ -0,0 +1,203 @@
+package builtin
+
+import (
+	"github.com/miekg/dns"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net"
+	"sort"
+)
+
+const (
+	// the timers of the synthesized SOA record, which drive the secondaries
+	soaRefresh = 3600
+	soaRetry   = 600
+	soaExpire  = 604800
+	// soaMinimum is the ttl of the negative answers
+	soaMinimum = 60
+	// apexTTL is the ttl of the synthesized SOA and NS records
+	apexTTL = 3600
+
+	// maxCNAMEChain limits the CNAME records followed in the zone
+	maxCNAMEChain = 8
+)
+
+// lookupResult is the answer of a query from the zone
+type lookupResult struct {
+	answer []dns.RR
+	// authority has the SOA record if the last name of the answer has no records of the type
+	authority []dns.RR
+	rcode     int
+}
+
+// lookup answers the query from the zone which is authoritative for the name, nil if no zone is.
+// the CNAME records are followed as long as the target is in the same zone, and
+// the wildcard records answer the names which don't exist.
+func (i *Index) lookup(qname string, qtype uint16) *lookupResult {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	name := normalizeName(qname)
+	z := i.findZone(name)
+	if z == nil {
+		return nil
+	}
+
+	result, negative := i.resolve(z, name, qtype)
+	if negative {
+		result.authority = []dns.RR{z.negativeSOA()}
+	}
+	return result
+}
+
+// resolve answers the name from the zone, which returns whether the answer is negative.
+// the read lock has to be held by the caller.
+func (i *Index) resolve(z *zone, name string, qtype uint16) (*lookupResult, bool) {
+	result := &lookupResult{rcode: dns.RcodeSuccess}
+	visited := map[string]bool{}
+	for len(visited) < maxCNAMEChain && !visited[name] {
+		visited[name] = true
+		source := name
+		if !z.exists(name) {
+			source = "*." + z.closestEncloser(name)
+			if len(z.recordSets[source]) == 0 {
+				result.rcode = dns.RcodeNameError
+				return result, true
+			}
+		}
+
+		rrs := z.rrsets(source, dns.Fqdn(name), qtype)
+		if len(rrs) > 0 {
+			result.answer = append(result.answer, rrs...)
+			return result, false
+		}
+		cname, found := z.recordSets[source][provider.RecordTypeCNAME]
+		if !found || qtype == dns.TypeCNAME {
+			return result, true
+		}
+		result.answer = append(result.answer, newRRs(dns.Fqdn(name), provider.RecordTypeCNAME, cname)...)
+		target := normalizeName(cname.Records[0])
+		if i.findZone(target) != z {
+			return result, false
+		}
+		name = target
+	}
+	return result, false
+}
+
+// transfer returns the records of the zone in order of AXFR, which starts and ends with the SOA record
+func (i *Index) transfer(zoneName string) ([]dns.RR, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	z, found := i.zones[normalizeName(zoneName)]
+	if !found {
+		return nil, false
+	}
+
+	names := make([]string, 0, len(z.recordSets))
+	for name := range z.recordSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	soa := z.soa()
+	rrs := append([]dns.RR{soa}, z.ns()...)
+	for _, name := range names {
+		recordTypes := make([]string, 0, len(z.recordSets[name]))
+		for t := range z.recordSets[name] {
+			recordTypes = append(recordTypes, t)
+		}
+		sort.Strings(recordTypes)
+		for _, t := range recordTypes {
+			rrs = append(rrs, newRRs(dns.Fqdn(name), t, z.recordSets[name][t])...)
+		}
+	}
+	return append(rrs, soa), true
+}
+
+// closestEncloser returns the closest ancestor of the name which exists, the apex at the latest
+func (z *zone) closestEncloser(name string) string {
+	for name != z.name {
+		name = parentName(name)
+		if z.exists(name) {
+			return name
+		}
+	}
+	return z.name
+}
+
+// rrsets returns the records of the source name matching the query type with the owner name,
+// the SOA and NS records are synthesized at the apex
+func (z *zone) rrsets(source, owner string, qtype uint16) []dns.RR {
+	rrs := make([]dns.RR, 0)
+	if source == z.name {
+		if qtype == dns.TypeSOA || qtype == dns.TypeANY {
+			rrs = append(rrs, z.soa())
+		}
+		if qtype == dns.TypeNS || qtype == dns.TypeANY {
+			rrs = append(rrs, z.ns()...)
+		}
+	}
+
+	recordTypes := make([]string, 0, len(z.recordSets[source]))
+	for t := range z.recordSets[source] {
+		if qtype == dns.TypeANY || dns.StringToType[t] == qtype {
+			recordTypes = append(recordTypes, t)
+		}
+	}
+	sort.Strings(recordTypes)
+	for _, t := range recordTypes {
+		rrs = append(rrs, newRRs(owner, t, z.recordSets[source][t])...)
+	}
+	return rrs
+}
+
+// soa returns the synthesized SOA record of the zone
+func (z *zone) soa() *dns.SOA {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: dns.Fqdn(z.name), Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: apexTTL},
+		Ns:      dns.Fqdn(z.nameservers[0]),
+		Mbox:    dns.Fqdn(z.hostmaster),
+		Serial:  z.serial,
+		Refresh: soaRefresh,
+		Retry:   soaRetry,
+		Expire:  soaExpire,
+		Minttl:  soaMinimum,
+	}
+}
+
+// negativeSOA returns the SOA record of the negative answers, whose ttl is the negative caching ttl
+func (z *zone) negativeSOA() *dns.SOA {
+	soa := z.soa()
+	soa.Hdr.Ttl = soaMinimum
+	return soa
+}
+
+// ns returns the synthesized NS records of the zone
+func (z *zone) ns() []dns.RR {
+	rrs := make([]dns.RR, 0, len(z.nameservers))
+	for _, ns := range z.nameservers {
+		rrs = append(rrs, &dns.NS{
+			Hdr: dns.RR_Header{Name: dns.Fqdn(z.name), Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: apexTTL},
+			Ns:  dns.Fqdn(ns),
+		})
+	}
+	return rrs
+}
+
+// newRRs builds the resource records of the recordset, the records are validated on the index
+func newRRs(owner, recordType string, rs RecordSet) []dns.RR {
+	hdr := dns.RR_Header{Name: owner, Rrtype: dns.StringToType[recordType], Class: dns.ClassINET, Ttl: uint32(rs.TTL)}
+	rrs := make([]dns.RR, 0, len(rs.Records))
+	for _, r := range rs.Records {
+		switch recordType {
+		case provider.RecordTypeA:
+			rrs = append(rrs, &dns.A{Hdr: hdr, A: net.ParseIP(r).To4()})
+		case provider.RecordTypeAAAA:
+			rrs = append(rrs, &dns.AAAA{Hdr: hdr, AAAA: net.ParseIP(r)})
+		case provider.RecordTypeCNAME:
+			rrs = append(rrs, &dns.CNAME{Hdr: hdr, Target: dns.Fqdn(r)})
+		case provider.RecordTypeTXT:
+			rrs = append(rrs, &dns.TXT{Hdr: hdr, Txt: provider.SplitEscapedTXT(r)})
+		}
+	}
+	return rrs
+}
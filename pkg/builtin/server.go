@@ -0,0 +1,213 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAddress is the address the builtin dns server listens on udp and tcp
+	DefaultAddress = ":53"
+
+	// transferChunkSize is the number of records in an envelope of the zone transfer
+	transferChunkSize = 100
+	// maxUDPSize caps the udp payload size advertised by the clients with edns0
+	maxUDPSize = 4096
+	// shutdownTimeout bounds the time to finish the queries on shutdown
+	shutdownTimeout = 5 * time.Second
+)
+
+// Server is the authoritative dns server of the zones on the index, which runs on every replica of the manager
+type Server struct {
+	index *Index
+	addr  string
+	// transferAllowed are the networks of the secondaries which can transfer the zones
+	transferAllowed []*net.IPNet
+}
+
+// NewServer creates the server listening on the address, the zone transfers are refused if no network is allowed
+func NewServer(index *Index, addr string, transferAllowed []*net.IPNet) *Server {
+	if len(addr) == 0 {
+		addr = DefaultAddress
+	}
+	return &Server{index: index, addr: addr, transferAllowed: transferAllowed}
+}
+
+// ParseNetworks parses the comma-separated networks in CIDR notation, a single address is a network of itself
+func ParseNetworks(value string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0)
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); len(s) == 0 {
+			continue
+		}
+		if ip := net.ParseIP(s); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %s", s)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// NeedLeaderElection lets the followers answer the queries as well, since the index is synced on every replica
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the queries on udp and tcp until the context is done
+func (s *Server) Start(ctx context.Context) error {
+	pc, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		return fmt.Errorf("can't listen udp on %s: %w", s.addr, err)
+	}
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		_ = pc.Close()
+		return fmt.Errorf("can't listen tcp on %s: %w", s.addr, err)
+	}
+	log.FromContext(ctx).Info("starting builtin dns server", "address", s.addr, "zones", s.index.Zones())
+	return s.Serve(ctx, pc, l)
+}
+
+// Serve serves the queries on the packet connection and the listener until the context is done
+func (s *Server) Serve(ctx context.Context, pc net.PacketConn, l net.Listener) error {
+	errs := make(chan error, 2)
+	wg := sync.WaitGroup{}
+	running := make([]*dns.Server, 0, 2)
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		for _, srv := range running {
+			_ = srv.ShutdownContext(shutdownCtx)
+		}
+		wg.Wait()
+	}()
+
+	for _, srv := range []*dns.Server{{PacketConn: pc, Handler: s}, {Listener: l, Handler: s}} {
+		// wait for the server to start, otherwise it can't be shut down
+		started := make(chan struct{})
+		srv.NotifyStartedFunc = func() { close(started) }
+		wg.Add(1)
+		go func(srv *dns.Server) {
+			defer wg.Done()
+			errs <- srv.ActivateAndServe()
+		}(srv)
+		select {
+		case <-started:
+			running = append(running, srv)
+		case err := <-errs:
+			return fmt.Errorf("can't serve dns: %w", err)
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errs:
+		return fmt.Errorf("can't serve dns: %w", err)
+	}
+}
+
+// ServeDNS answers the query from the index
+func (s *Server) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	if len(req.Question) == 1 && req.Opcode == dns.OpcodeQuery &&
+		(req.Question[0].Qtype == dns.TypeAXFR || req.Question[0].Qtype == dns.TypeIXFR) {
+		s.transfer(w, req)
+		return
+	}
+
+	m := s.answer(req)
+	size := dns.MinMsgSize
+	if _, tcp := w.RemoteAddr().(*net.TCPAddr); tcp {
+		size = dns.MaxMsgSize
+	} else if opt := req.IsEdns0(); opt != nil && int(opt.UDPSize()) > size {
+		size = int(opt.UDPSize())
+		if size > maxUDPSize {
+			size = maxUDPSize
+		}
+	}
+	m.Truncate(size)
+	_ = w.WriteMsg(m)
+}
+
+// answer builds the response of the query
+func (s *Server) answer(req *dns.Msg) *dns.Msg {
+	m := new(dns.Msg)
+	if req.Opcode != dns.OpcodeQuery {
+		return m.SetRcode(req, dns.RcodeNotImplemented)
+	}
+	if len(req.Question) != 1 {
+		return m.SetRcode(req, dns.RcodeFormatError)
+	}
+	m.SetReply(req)
+	if opt := req.IsEdns0(); opt != nil {
+		m.SetEdns0(maxUDPSize, false)
+	}
+
+	q := req.Question[0]
+	result := s.index.lookup(q.Name, q.Qtype)
+	if result == nil || (q.Qclass != dns.ClassINET && q.Qclass != dns.ClassANY) {
+		m.Rcode = dns.RcodeRefused
+		return m
+	}
+	m.Authoritative = true
+	m.Rcode = result.rcode
+	m.Answer = result.answer
+	m.Ns = result.authority
+	return m
+}
+
+// transfer sends the records of the zone to the secondary, which is allowed only on tcp from the allowed networks.
+// IXFR is answered with the entire zone as the history of the zone is not kept.
+func (s *Server) transfer(w dns.ResponseWriter, req *dns.Msg) {
+	q := req.Question[0]
+	m := new(dns.Msg)
+	addr, tcp := w.RemoteAddr().(*net.TCPAddr)
+	if !tcp || !s.transferAllowedFrom(addr.IP) {
+		_ = w.WriteMsg(m.SetRcode(req, dns.RcodeRefused))
+		return
+	}
+	rrs, found := s.index.transfer(q.Name)
+	if !found {
+		_ = w.WriteMsg(m.SetRcode(req, dns.RcodeNotAuth))
+		return
+	}
+
+	// the envelopes are buffered entirely, so nothing is left blocked if the secondary goes away
+	ch := make(chan *dns.Envelope, len(rrs)/transferChunkSize+1)
+	for len(rrs) > 0 {
+		n := transferChunkSize
+		if n > len(rrs) {
+			n = len(rrs)
+		}
+		ch <- &dns.Envelope{RR: rrs[:n]}
+		rrs = rrs[n:]
+	}
+	close(ch)
+	if err := new(dns.Transfer).Out(w, req, ch); err != nil {
+		_ = w.Close()
+	}
+}
+
+func (s *Server) transferAllowedFrom(ip net.IP) bool {
+	for _, network := range s.transferAllowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
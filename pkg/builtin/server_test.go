@@ -0,0 +1,293 @@
+package builtin
+
+import (
+	"context"
+	"github.com/miekg/dns"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testServer is the builtin dns server listening on udp and tcp of the same port of loopback
+type testServer struct {
+	*Server
+	addr string
+}
+
+func newTestServer(t *testing.T, index *Index, transferAllowed string) *testServer {
+	networks, err := ParseNetworks(transferAllowed)
+	if err != nil {
+		t.Fatalf("ParseNetworks: %v", err)
+	}
+	s := &testServer{Server: NewServer(index, "", networks)}
+
+	var pc net.PacketConn
+	var l net.Listener
+	for i := 0; i < 10 && l == nil; i++ {
+		if pc, err = net.ListenPacket("udp", "127.0.0.1:0"); err != nil {
+			t.Fatalf("can't listen udp: %v", err)
+		}
+		if l, err = net.Listen("tcp", pc.LocalAddr().String()); err != nil {
+			_ = pc.Close()
+		}
+	}
+	if l == nil {
+		t.Fatalf("can't listen tcp and udp on the same port")
+	}
+	s.addr = pc.LocalAddr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, pc, l) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+	return s
+}
+
+// exchange sends the query on the network and returns the response
+func (s *testServer) exchange(t *testing.T, network, name string, qtype uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	r, _, err := (&dns.Client{Net: network}).Exchange(m, s.addr)
+	if err != nil {
+		t.Fatalf("can't exchange %s %s: %v", name, dns.TypeToString[qtype], err)
+	}
+	return r
+}
+
+// answers returns the answer section in presentation format without ttl, in order
+func answers(rrs []dns.RR) []string {
+	values := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		rr = dns.Copy(rr)
+		rr.Header().Ttl = 0
+		values = append(values, strings.ReplaceAll(rr.String(), "\t", " "))
+	}
+	return values
+}
+
+func newTestServerIndex(t *testing.T) *Index {
+	index := NewIndex()
+	if err := index.AddZone(testZoneName, "ns1.cluster.internal", "ns2.cluster.internal."); err != nil {
+		t.Fatalf("AddZone: %v", err)
+	}
+	for _, rs := range []struct {
+		name       string
+		recordType string
+		records    []string
+	}{
+		{name: "@", recordType: provider.RecordTypeA, records: []string{"10.0.0.1"}},
+		{name: "ns1", recordType: provider.RecordTypeA, records: []string{"10.0.0.53"}},
+		{name: "www", recordType: provider.RecordTypeA, records: []string{"10.0.0.2", "10.0.0.3"}},
+		{name: "www", recordType: provider.RecordTypeAAAA, records: []string{"fd00::2"}},
+		{name: "www", recordType: provider.RecordTypeTXT, records: []string{`owner="default"`}},
+		{name: "app", recordType: provider.RecordTypeCNAME, records: []string{"www.cluster.internal"}},
+		{name: "external", recordType: provider.RecordTypeCNAME, records: []string{"example.com"}},
+		{name: "dangling", recordType: provider.RecordTypeCNAME, records: []string{"missing.cluster.internal"}},
+		{name: "db.prod", recordType: provider.RecordTypeA, records: []string{"10.0.1.1"}},
+		{name: "*.apps", recordType: provider.RecordTypeA, records: []string{"10.0.2.1"}},
+	} {
+		if _, err := index.Create(testZoneName, rs.name, rs.recordType, rs.records, 60); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	return index
+}
+
+func TestServerAnswers(t *testing.T) {
+	s := newTestServer(t, newTestServerIndex(t), "")
+
+	for _, tt := range []struct {
+		name      string
+		qtype     uint16
+		rcode     int
+		answer    []string
+		authority bool
+	}{
+		{name: "www.cluster.internal", qtype: dns.TypeA, answer: []string{
+			"www.cluster.internal. 0 IN A 10.0.0.2",
+			"www.cluster.internal. 0 IN A 10.0.0.3",
+		}},
+		{name: "WWW.Cluster.Internal", qtype: dns.TypeAAAA, answer: []string{"www.cluster.internal. 0 IN AAAA fd00::2"}},
+		{name: "www.cluster.internal", qtype: dns.TypeTXT, answer: []string{`www.cluster.internal. 0 IN TXT "owner=\"default\""`}},
+		// the name exists without the type
+		{name: "ns1.cluster.internal", qtype: dns.TypeAAAA, authority: true},
+		// the empty non-terminal exists as well
+		{name: "prod.cluster.internal", qtype: dns.TypeA, authority: true},
+		{name: "missing.cluster.internal", qtype: dns.TypeA, rcode: dns.RcodeNameError, authority: true},
+		{name: "app.cluster.internal", qtype: dns.TypeA, answer: []string{
+			"app.cluster.internal. 0 IN CNAME www.cluster.internal.",
+			"www.cluster.internal. 0 IN A 10.0.0.2",
+			"www.cluster.internal. 0 IN A 10.0.0.3",
+		}},
+		{name: "app.cluster.internal", qtype: dns.TypeCNAME, answer: []string{"app.cluster.internal. 0 IN CNAME www.cluster.internal."}},
+		// the target out of the zone is left to the resolver
+		{name: "external.cluster.internal", qtype: dns.TypeA, answer: []string{"external.cluster.internal. 0 IN CNAME example.com."}},
+		{name: "dangling.cluster.internal", qtype: dns.TypeA, rcode: dns.RcodeNameError, authority: true, answer: []string{
+			"dangling.cluster.internal. 0 IN CNAME missing.cluster.internal.",
+		}},
+		{name: "web.apps.cluster.internal", qtype: dns.TypeA, answer: []string{"web.apps.cluster.internal. 0 IN A 10.0.2.1"}},
+		{name: "web.apps.cluster.internal", qtype: dns.TypeAAAA, authority: true},
+		// the wildcard doesn't match the names below the existing ones
+		{name: "www.db.prod.cluster.internal", qtype: dns.TypeA, rcode: dns.RcodeNameError, authority: true},
+		{name: "cluster.internal", qtype: dns.TypeA, answer: []string{"cluster.internal. 0 IN A 10.0.0.1"}},
+		{name: "cluster.internal", qtype: dns.TypeNS, answer: []string{
+			"cluster.internal. 0 IN NS ns1.cluster.internal.",
+			"cluster.internal. 0 IN NS ns2.cluster.internal.",
+		}},
+	} {
+		r := s.exchange(t, "udp", tt.name, tt.qtype)
+		if r.Rcode != tt.rcode || !r.Authoritative {
+			t.Fatalf("%s %s: expected authoritative %s, got %s", tt.name, dns.TypeToString[tt.qtype], dns.RcodeToString[tt.rcode], dns.RcodeToString[r.Rcode])
+		}
+		if got := answers(r.Answer); !reflect.DeepEqual(got, append([]string{}, tt.answer...)) {
+			t.Fatalf("%s %s: expected answer %v, got %v", tt.name, dns.TypeToString[tt.qtype], tt.answer, got)
+		}
+		if hasSOA := len(r.Ns) == 1 && r.Ns[0].Header().Rrtype == dns.TypeSOA; hasSOA != tt.authority {
+			t.Fatalf("%s %s: expected SOA in authority %v, got %v", tt.name, dns.TypeToString[tt.qtype], tt.authority, r.Ns)
+		}
+	}
+
+	// the zones not served are refused, since the server is not a resolver
+	if r := s.exchange(t, "udp", "example.com", dns.TypeA); r.Rcode != dns.RcodeRefused || r.Authoritative {
+		t.Fatalf("expected REFUSED for the zone not served, got %s", dns.RcodeToString[r.Rcode])
+	}
+}
+
+func TestServerSOA(t *testing.T) {
+	index := newTestServerIndex(t)
+	s := newTestServer(t, index, "")
+
+	soaOf := func() *dns.SOA {
+		r := s.exchange(t, "tcp", testZoneName, dns.TypeSOA)
+		if len(r.Answer) != 1 {
+			t.Fatalf("expected SOA record, got %v", r.Answer)
+		}
+		return r.Answer[0].(*dns.SOA)
+	}
+	soa := soaOf()
+	if soa.Ns != "ns1.cluster.internal." || soa.Mbox != "hostmaster.cluster.internal." || soa.Minttl != soaMinimum {
+		t.Fatalf("unexpected SOA record %s", soa)
+	}
+
+	// the serial changes on every change of the zone for the secondaries to refresh
+	if _, err := index.Set(testZoneName, "www", provider.RecordTypeA, []string{"10.0.0.4"}, 60); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if serial := soaOf().Serial; serial <= soa.Serial {
+		t.Fatalf("expected the serial increased from %d, got %d", soa.Serial, serial)
+	}
+	soa = soaOf()
+	if err := index.Delete(testZoneName, "missing", provider.RecordTypeA); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if serial := soaOf().Serial; serial != soa.Serial {
+		t.Fatalf("expected the serial kept on no change, got %d from %d", serial, soa.Serial)
+	}
+}
+
+func TestServerTruncation(t *testing.T) {
+	index := newTestServerIndex(t)
+	records := make([]string, 0, 64)
+	for i := 1; i <= 64; i++ {
+		records = append(records, net.IPv4(10, 1, 0, byte(i)).String())
+	}
+	if _, err := index.Create(testZoneName, "pool", provider.RecordTypeA, records, 60); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	s := newTestServer(t, index, "")
+
+	if r := s.exchange(t, "udp", "pool.cluster.internal", dns.TypeA); !r.Truncated {
+		t.Fatalf("expected the udp response truncated, got %d records", len(r.Answer))
+	}
+	if r := s.exchange(t, "tcp", "pool.cluster.internal", dns.TypeA); r.Truncated || len(r.Answer) != len(records) {
+		t.Fatalf("expected %d records on tcp, got %d", len(records), len(r.Answer))
+	}
+}
+
+func TestServerTransfer(t *testing.T) {
+	index := newTestServerIndex(t)
+
+	transfer := func(s *testServer, zoneName string) ([]dns.RR, error) {
+		m := new(dns.Msg)
+		m.SetAxfr(dns.Fqdn(zoneName))
+		envelopes, err := new(dns.Transfer).In(m, s.addr)
+		if err != nil {
+			return nil, err
+		}
+		rrs := make([]dns.RR, 0)
+		for e := range envelopes {
+			if e.Error != nil {
+				return nil, e.Error
+			}
+			rrs = append(rrs, e.RR...)
+		}
+		return rrs, nil
+	}
+
+	s := newTestServer(t, index, "10.0.0.0/8, 127.0.0.1")
+	rrs, err := transfer(s, testZoneName)
+	if err != nil {
+		t.Fatalf("AXFR: %v", err)
+	}
+	if rrs[0].Header().Rrtype != dns.TypeSOA || rrs[len(rrs)-1].Header().Rrtype != dns.TypeSOA {
+		t.Fatalf("expected the transfer starts and ends with SOA, got %v", rrs)
+	}
+	got := answers(rrs[1 : len(rrs)-1])
+	sort.Strings(got)
+	expected := []string{
+		`*.apps.cluster.internal. 0 IN A 10.0.2.1`,
+		`app.cluster.internal. 0 IN CNAME www.cluster.internal.`,
+		`cluster.internal. 0 IN A 10.0.0.1`,
+		`cluster.internal. 0 IN NS ns1.cluster.internal.`,
+		`cluster.internal. 0 IN NS ns2.cluster.internal.`,
+		`dangling.cluster.internal. 0 IN CNAME missing.cluster.internal.`,
+		`db.prod.cluster.internal. 0 IN A 10.0.1.1`,
+		`external.cluster.internal. 0 IN CNAME example.com.`,
+		`ns1.cluster.internal. 0 IN A 10.0.0.53`,
+		`www.cluster.internal. 0 IN A 10.0.0.2`,
+		`www.cluster.internal. 0 IN A 10.0.0.3`,
+		`www.cluster.internal. 0 IN AAAA fd00::2`,
+		`www.cluster.internal. 0 IN TXT "owner=\"default\""`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("AXFR: expected %v, got %v", expected, got)
+	}
+
+	if _, err := transfer(s, "example.com"); err == nil {
+		t.Fatalf("AXFR: expected error for the zone not served")
+	}
+	// the transfer is only over tcp
+	if r := s.exchange(t, "udp", testZoneName, dns.TypeAXFR); r.Rcode != dns.RcodeRefused {
+		t.Fatalf("AXFR: expected REFUSED over udp, got %s", dns.RcodeToString[r.Rcode])
+	}
+
+	denied := newTestServer(t, index, "")
+	if _, err := transfer(denied, testZoneName); err == nil {
+		t.Fatalf("AXFR: expected error from the network not allowed")
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks(" 10.0.0.0/8,192.0.2.1 ,fd00::1,")
+	if err != nil {
+		t.Fatalf("ParseNetworks: %v", err)
+	}
+	got := make([]string, 0, len(networks))
+	for _, n := range networks {
+		got = append(got, n.String())
+	}
+	if expected := []string{"10.0.0.0/8", "192.0.2.1/32", "fd00::1/128"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("ParseNetworks: expected %v, got %v", expected, got)
+	}
+	if _, err := ParseNetworks("10.0.0.0/33"); err == nil {
+		t.Fatalf("ParseNetworks: expected error for invalid network")
+	}
+}
@@ -0,0 +1,161 @@
+package clouddns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const (
+	defaultEndpoint = "https://dns.googleapis.com"
+	apiPrefix       = "/dns/v1"
+)
+
+// scope is the oauth2 scope which allows to read and change the managed zones
+const scope = "https://www.googleapis.com/auth/ndev.clouddns.readwrite"
+
+// reasons of the api errors
+const (
+	errorReasonAlreadyExists   = "alreadyExists"
+	errorReasonConditionNotMet = "conditionNotMet"
+)
+
+// Project is the project object of the api
+type Project struct {
+	Id     string `json:"id"`
+	Number string `json:"number,omitempty"`
+}
+
+// ManagedZone is the managed zone object of the api, identified by its name in the project
+type ManagedZone struct {
+	Id                      string                   `json:"id,omitempty"`
+	Name                    string                   `json:"name"`
+	DNSName                 string                   `json:"dnsName"`
+	Visibility              string                   `json:"visibility,omitempty"`
+	PrivateVisibilityConfig *PrivateVisibilityConfig `json:"privateVisibilityConfig,omitempty"`
+}
+
+// PrivateVisibilityConfig lists the vpc networks which the private zone is visible to
+type PrivateVisibilityConfig struct {
+	Networks []Network `json:"networks,omitempty"`
+}
+
+type Network struct {
+	NetworkUrl string `json:"networkUrl"`
+}
+
+type managedZonesPage struct {
+	ManagedZones  []ManagedZone `json:"managedZones"`
+	NextPageToken string        `json:"nextPageToken,omitempty"`
+}
+
+// ResourceRecordSet is the recordset of the api, identified by name and type
+type ResourceRecordSet struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	TTL     int      `json:"ttl,omitempty"`
+	Rrdatas []string `json:"rrdatas"`
+}
+
+type rrsetsPage struct {
+	Rrsets        []ResourceRecordSet `json:"rrsets"`
+	NextPageToken string              `json:"nextPageToken,omitempty"`
+}
+
+// Change is the change of the api, the deletions and additions are applied atomically.
+// deletions have to match the current recordsets exactly, which fails with conditionNotMet otherwise.
+type Change struct {
+	Id        string              `json:"id,omitempty"`
+	Status    string              `json:"status,omitempty"`
+	Additions []ResourceRecordSet `json:"additions,omitempty"`
+	Deletions []ResourceRecordSet `json:"deletions,omitempty"`
+}
+
+// APIError is returned if the api responds with error status
+type APIError struct {
+	StatusCode int
+	Message    string `json:"message"`
+	Errors     []struct {
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	} `json:"errors,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("cloud dns api error %d: %s", e.StatusCode, e.Message)
+}
+
+// hasReason reports whether the error is caused by the reason
+func (e *APIError) hasReason(reason string) bool {
+	for _, err := range e.Errors {
+		if err.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+type errorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+// do sends the request to the api of the project, the response is decoded into out if given
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	u := fmt.Sprintf("%s%s/projects/%s%s", c.endpoint, apiPrefix, url.PathEscape(c.project), path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("can't encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return fmt.Errorf("can't create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("can't read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		errResp := &errorResponse{}
+		if err := json.Unmarshal(b, errResp); err != nil || errResp.Error == nil {
+			errResp.Error = &APIError{Message: string(bytes.TrimSpace(b))}
+		}
+		errResp.Error.StatusCode = resp.StatusCode
+		return errResp.Error
+	}
+
+	if out == nil || len(b) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("can't decode response: %w", err)
+	}
+	return nil
+}
+
+func managedZonePath(zoneId string) string {
+	return fmt.Sprintf("/managedZones/%s", url.PathEscape(zoneId))
+}
@@ -0,0 +1,362 @@
+package clouddns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ProviderKey = "clouddns"
+
+const (
+	SettingKeyProject           = "project"
+	SettingKeyServiceAccountKey = "serviceAccountKey"
+	SettingKeyEndpoint          = "endpoint"
+
+	SettingKeyZoneVisibility = "zoneVisibility"
+	SettingKeyNetwork        = "network"
+)
+
+const (
+	ZoneVisibilityPublic  = "public"
+	ZoneVisibilityPrivate = "private"
+)
+
+const httpTimeout = 30 * time.Second
+
+func init() {
+	provider.Register(ProviderKey, NewCloudDNSClientWithSettings)
+}
+
+// ZoneFilter picks the managed zone among the ones which have the same dns name.
+// public and private zones may have the same dns name, and private ones may be visible to different networks.
+type ZoneFilter struct {
+	// Visibility is either public or private, any visibility is allowed if empty
+	Visibility string
+	// Network requires the private zone to be visible to the vpc network, either its name or url
+	Network string
+}
+
+type Client struct {
+	provider.Client
+
+	project    string
+	endpoint   string
+	zoneFilter ZoneFilter
+	httpClient *http.Client
+
+	// zoneNames caches zone name by zone id
+	zoneNames sync.Map
+}
+
+// NewCloudDNSClientWithSettings creates the client with the provider settings, the service account key is used if set,
+// falls back to the application default credentials otherwise, e.g. workload identity on GKE
+func NewCloudDNSClientWithSettings(settings map[string]string) (provider.Client, error) {
+	ctx := context.Background()
+
+	var creds *google.Credentials
+	var err error
+	if key := settings[SettingKeyServiceAccountKey]; len(key) > 0 {
+		creds, err = google.CredentialsFromJSON(ctx, []byte(key), scope)
+		if err != nil {
+			return nil, fmt.Errorf("can't load service account key: %w", err)
+		}
+	} else {
+		creds, err = google.FindDefaultCredentials(ctx, scope)
+		if err != nil {
+			return nil, fmt.Errorf("can't find default credentials: %w", err)
+		}
+	}
+
+	project := settings[SettingKeyProject]
+	if len(project) == 0 {
+		project = creds.ProjectID
+	}
+	httpClient := &http.Client{
+		Timeout:   httpTimeout,
+		Transport: &oauth2.Transport{Source: creds.TokenSource, Base: http.DefaultTransport},
+	}
+
+	c, err := NewCloudDNSClient(project, settings[SettingKeyEndpoint], ZoneFilter{
+		Visibility: settings[SettingKeyZoneVisibility],
+		Network:    settings[SettingKeyNetwork],
+	}, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewCloudDNSClient creates the client of the managed zones in the project,
+// requests are sent to the endpoint if set and authorized by the http client
+func NewCloudDNSClient(project, endpoint string, zoneFilter ZoneFilter, httpClient *http.Client) (*Client, error) {
+	if len(project) == 0 {
+		return nil, fmt.Errorf("can't create new cloud dns client: %s is required", SettingKeyProject)
+	}
+	switch zoneFilter.Visibility {
+	case "", ZoneVisibilityPublic, ZoneVisibilityPrivate:
+	default:
+		return nil, fmt.Errorf("can't create new cloud dns client: unknown zone visibility %s", zoneFilter.Visibility)
+	}
+	if len(zoneFilter.Network) > 0 && zoneFilter.Visibility == ZoneVisibilityPublic {
+		return nil, fmt.Errorf("can't create new cloud dns client: network can't be set for public zone")
+	}
+
+	if len(endpoint) == 0 {
+		endpoint = defaultEndpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return nil, fmt.Errorf("can't create new cloud dns client: invalid endpoint %s", endpoint)
+	}
+	if httpClient == nil {
+		return nil, fmt.Errorf("can't create new cloud dns client: http client is required")
+	}
+
+	return &Client{
+		project:    project,
+		endpoint:   strings.TrimSuffix(strings.TrimSuffix(endpoint, "/"), apiPrefix),
+		zoneFilter: zoneFilter,
+		httpClient: httpClient,
+	}, nil
+}
+
+// Verify checks the credentials are accepted and the project exists
+func (c *Client) Verify(ctx context.Context) error {
+	if err := c.do(ctx, http.MethodGet, "", nil, nil, &Project{}); err != nil {
+		return fmt.Errorf("can't verify project %s: %w", c.project, err)
+	}
+	return nil
+}
+
+func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone, error) {
+	dnsName := fmt.Sprintf("%s.", strings.ToLower(strings.TrimSuffix(zoneName, ".")))
+
+	matchedZones := make([]ManagedZone, 0)
+	query := url.Values{"dnsName": {dnsName}}
+	for {
+		page := &managedZonesPage{}
+		if err := c.do(ctx, http.MethodGet, "/managedZones", query, nil, page); err != nil {
+			return nil, fmt.Errorf("can't GetZone: %w", err)
+		}
+		for _, z := range page.ManagedZones {
+			if strings.ToLower(z.DNSName) == dnsName && c.matchZone(z) {
+				matchedZones = append(matchedZones, z)
+			}
+		}
+		if len(page.NextPageToken) == 0 {
+			break
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+
+	if len(matchedZones) == 0 {
+		return nil, fmt.Errorf("can't GetZone: cannot find zone %s", zoneName)
+	}
+	if len(matchedZones) > 1 {
+		return nil, fmt.Errorf("can't GetZone: %d managed zones are found for %s, set %s or %s to pick one",
+			len(matchedZones), zoneName, SettingKeyZoneVisibility, SettingKeyNetwork)
+	}
+
+	z := matchedZones[0]
+	name := strings.TrimSuffix(dnsName, ".")
+	c.zoneNames.Store(z.Name, name)
+
+	return &provider.Zone{
+		Id:        z.Name,
+		Name:      name,
+		Activated: true,
+	}, nil
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+
+	rrset, err := c.getRRSet(ctx, zoneId, fqdnOf(name, zoneName), recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	if rrset == nil {
+		return nil, nil
+	}
+	return convertRRSet(name, zoneId, zoneName, *rrset), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Create: no records given for %s", name)
+	}
+
+	// the addition fails if the rrset exists, which is never overwritten
+	rrset := newRRSet(fqdnOf(name, zoneName), recordType, records, ttl)
+	if err := c.change(ctx, zoneId, &Change{Additions: []ResourceRecordSet{rrset}}); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.hasReason(errorReasonAlreadyExists) {
+			return nil, fmt.Errorf("can't Create: recordset %s %s already exists", rrset.Name, recordType)
+		}
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	return convertRRSet(name, zoneId, zoneName, rrset), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	name, currentType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	fqdn := fqdnOf(name, zoneName)
+	current, err := c.getRRSet(ctx, zoneId, fqdn, currentType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if current == nil {
+		return nil, nil
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Update: no records given for %s", name)
+	}
+
+	// the current rrset is replaced in a single change, which fails if the rrset has been changed since it's read
+	rrset := newRRSet(fqdn, recordType, records, ttl)
+	if !equalRRSet(*current, rrset) {
+		if err := c.change(ctx, zoneId, &Change{Deletions: []ResourceRecordSet{*current}, Additions: []ResourceRecordSet{rrset}}); err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+	return convertRRSet(name, zoneId, zoneName, rrset), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	current, err := c.getRRSet(ctx, zoneId, fqdnOf(name, zoneName), recordType)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	if current == nil {
+		return nil
+	}
+
+	// the api requires the exact rrset to delete it
+	if err := c.change(ctx, zoneId, &Change{Deletions: []ResourceRecordSet{*current}}); err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	return nil
+}
+
+// matchZone reports whether the managed zone satisfies the zone filter
+func (c *Client) matchZone(z ManagedZone) bool {
+	private := z.Visibility == ZoneVisibilityPrivate
+	switch {
+	case c.zoneFilter.Visibility == ZoneVisibilityPublic && private:
+		return false
+	case (c.zoneFilter.Visibility == ZoneVisibilityPrivate || len(c.zoneFilter.Network) > 0) && !private:
+		return false
+	case len(c.zoneFilter.Network) == 0:
+		return true
+	}
+
+	if z.PrivateVisibilityConfig == nil {
+		return false
+	}
+	for _, n := range z.PrivateVisibilityConfig.Networks {
+		// network urls end with projects/{project}/global/networks/{network}
+		if n.NetworkUrl == c.zoneFilter.Network || strings.HasSuffix(n.NetworkUrl, "/"+c.zoneFilter.Network) {
+			return true
+		}
+	}
+	return false
+}
+
+// getZoneName returns the zone name of the zone id
+func (c *Client) getZoneName(ctx context.Context, zoneId string) (string, error) {
+	if zoneName, ok := c.zoneNames.Load(zoneId); ok {
+		return zoneName.(string), nil
+	}
+
+	z := &ManagedZone{}
+	if err := c.do(ctx, http.MethodGet, managedZonePath(zoneId), nil, nil, z); err != nil {
+		return "", fmt.Errorf("can't get managed zone %s: %w", zoneId, err)
+	}
+	zoneName := strings.ToLower(strings.TrimSuffix(z.DNSName, "."))
+	c.zoneNames.Store(zoneId, zoneName)
+	return zoneName, nil
+}
+
+// getRRSet returns the rrset which has the name and type
+func (c *Client) getRRSet(ctx context.Context, zoneId, fqdn, recordType string) (*ResourceRecordSet, error) {
+	page := &rrsetsPage{}
+	query := url.Values{"name": {fqdn}, "type": {recordType}}
+	if err := c.do(ctx, http.MethodGet, managedZonePath(zoneId)+"/rrsets", query, nil, page); err != nil {
+		return nil, err
+	}
+
+	for _, rrset := range page.Rrsets {
+		if strings.ToLower(rrset.Name) == fqdn && rrset.Type == recordType {
+			return &rrset, nil
+		}
+	}
+	return nil, nil
+}
+
+// change applies the deletions and additions atomically, the change is not waited to be done as it's visible on the api at once
+func (c *Client) change(ctx context.Context, zoneId string, change *Change) error {
+	return c.do(ctx, http.MethodPost, managedZonePath(zoneId)+"/changes", nil, change, &Change{})
+}
+
+// equalRRSet reports whether the rrsets have the same ttl and records regardless of the order
+func equalRRSet(a, b ResourceRecordSet) bool {
+	if strings.ToLower(a.Name) != strings.ToLower(b.Name) || a.Type != b.Type || a.TTL != b.TTL {
+		return false
+	}
+	ra, rb := append([]string{}, a.Rrdatas...), append([]string{}, b.Rrdatas...)
+	sort.Strings(ra)
+	sort.Strings(rb)
+	return reflect.DeepEqual(ra, rb)
+}
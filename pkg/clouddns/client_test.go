@@ -0,0 +1,374 @@
+package clouddns
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+const (
+	testZoneName = "example.com"
+	testZoneId   = "example-com"
+)
+
+func newTestClient(t *testing.T) (*fakeServer, *Client) {
+	f := newFakeServer(t)
+	f.addZone(testZoneId, testZoneName)
+	return f, f.newClient(t, ZoneFilter{})
+}
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	z, err := c.GetZone(ctx, testZoneName)
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: testZoneId, Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: expected zone %s of id %s, got %+v", testZoneName, testZoneId, z)
+	}
+
+	d, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeA)
+	if err != nil || d != nil {
+		t.Fatalf("GetByName: expected nothing before create, got %v, %v", d, err)
+	}
+
+	d, err = c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("www", provider.RecordTypeA),
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.0.2.1", "192.0.2.2"},
+		TTL:       ttlDefault,
+		ZoneId:    testZoneId,
+		ZoneName:  testZoneName,
+		FQDN:      "www.example.com.",
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+
+	// a fresh client has to resolve the zone name by itself
+	for _, get := range []func() (*provider.Domain, error){
+		func() (*provider.Domain, error) { return c.GetByName(ctx, "www", testZoneId, provider.RecordTypeA) },
+		func() (*provider.Domain, error) { return f.newClient(t, ZoneFilter{}).Get(ctx, d.Id, testZoneId) },
+	} {
+		got, err := get()
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Get: expected %+v, got %+v", expected, got)
+		}
+	}
+
+	// all the records are replaced in a single change
+	changes := f.changes
+	d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if want := []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}; !reflect.DeepEqual(d.Records, want) || d.TTL != 60 {
+		t.Fatalf("Update: expected records %v with ttl 60, got %v with ttl %d", want, d.Records, d.TTL)
+	}
+	if got := f.rrdatas(testZoneId, "www.example.com.", provider.RecordTypeA); !reflect.DeepEqual(got, d.Records) {
+		t.Fatalf("Update: expected remote records %v, got %v", d.Records, got)
+	}
+	if f.changes != changes+1 {
+		t.Fatalf("Update: expected a single change, got %d", f.changes-changes)
+	}
+
+	// nothing is changed if the records are the same
+	if _, err := c.Update(ctx, d.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.4", "192.0.2.3", "192.0.2.2"}, 60, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if f.changes != changes+1 {
+		t.Fatalf("Update: expected no change for the same records")
+	}
+
+	if err := c.Delete(ctx, d.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if rrset := f.rrset(testZoneId, "www.example.com.", provider.RecordTypeA); rrset != nil {
+		t.Fatalf("Delete: expected no remote rrset, got %+v", rrset)
+	}
+
+	d, err = c.Get(ctx, d.Id, testZoneId)
+	if err != nil || d != nil {
+		t.Fatalf("Get: expected nothing after delete, got %v, %v", d, err)
+	}
+	if err := c.Delete(ctx, expected.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: expected no error for missing recordset, got %v", err)
+	}
+	if d, err := c.Update(ctx, expected.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil || d != nil {
+		t.Fatalf("Update: expected nothing for missing recordset, got %v, %v", d, err)
+	}
+}
+
+func TestClientRecordSetIsolatedByType(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	f.addRRSet(testZoneId, ResourceRecordSet{Name: "www.example.com.", Type: provider.RecordTypeAAAA, TTL: 120,
+		Rrdatas: []string{"2001:db8::1", "2001:db8::2"}})
+	a, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	aaaa, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeAAAA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(aaaa.Records, []string{"2001:db8::1", "2001:db8::2"}) || aaaa.TTL != 120 {
+		t.Fatalf("GetByName: expected only AAAA records, got %+v", aaaa)
+	}
+
+	if _, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeAAAA, []string{"2001:db8::3"}, 0, nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Create: expected error for existing recordset, got %v", err)
+	}
+	if got := f.rrdatas(testZoneId, "www.example.com.", provider.RecordTypeAAAA); !reflect.DeepEqual(got, []string{"2001:db8::1", "2001:db8::2"}) {
+		t.Fatalf("Create: expected existing AAAA records not overwritten, got %v", got)
+	}
+
+	if err := c.Delete(ctx, a.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.rrdatas(testZoneId, "www.example.com.", provider.RecordTypeAAAA); !reflect.DeepEqual(got, []string{"2001:db8::1", "2001:db8::2"}) {
+		t.Fatalf("Delete: expected AAAA records untouched, got %v", got)
+	}
+}
+
+func TestClientUpdateChangesType(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	d, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// CNAME conflicts with A records, which is rejected unless they are deleted in the same change
+	d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeCNAME, []string{"lb.example.net"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != provider.GenerateRecordSetId("www", provider.RecordTypeCNAME) {
+		t.Fatalf("Update: expected id of the CNAME recordset, got %s", d.Id)
+	}
+	if rrset := f.rrset(testZoneId, "www.example.com.", provider.RecordTypeA); rrset != nil {
+		t.Fatalf("Update: expected A rrset removed, got %+v", rrset)
+	}
+	if got := f.rrdatas(testZoneId, "www.example.com.", provider.RecordTypeCNAME); !reflect.DeepEqual(got, []string{"lb.example.net."}) {
+		t.Fatalf("Update: expected CNAME record, got %v", got)
+	}
+
+	got, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeCNAME)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(got, d) {
+		t.Fatalf("GetByName: expected %+v, got %+v", d, got)
+	}
+
+	if _, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for A records conflicting with CNAME")
+	}
+}
+
+func TestClientUpdateConflict(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	d, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	current := f.rrset(testZoneId, "www.example.com.", provider.RecordTypeA)
+
+	// the rrset is changed by someone else between the read and the change
+	stale := *current
+	f.zones[testZoneId].rrsets[0].Rrdatas = []string{"192.0.2.99"}
+	err = c.change(ctx, testZoneId, &Change{
+		Deletions: []ResourceRecordSet{stale},
+		Additions: []ResourceRecordSet{newRRSet("www.example.com.", provider.RecordTypeA, []string{"192.0.2.2"}, 0)},
+	})
+	if err == nil || !strings.Contains(err.Error(), "Precondition not met") {
+		t.Fatalf("change: expected precondition failure for the stale rrset, got %v", err)
+	}
+	if got := f.rrdatas(testZoneId, "www.example.com.", provider.RecordTypeA); !reflect.DeepEqual(got, []string{"192.0.2.99"}) {
+		t.Fatalf("change: expected the rrset untouched, got %v", got)
+	}
+
+	// Update reads the current rrset again
+	if d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.2"}, 0, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := f.rrdatas(testZoneId, "www.example.com.", provider.RecordTypeA); !reflect.DeepEqual(got, d.Records) {
+		t.Fatalf("Update: expected remote records %v, got %v", d.Records, got)
+	}
+}
+
+func TestClientTXTRecordSet(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	long := strings.Repeat("a", provider.TXTStringMaxLength+10)
+	records := []string{`heritage=dns-ingress,"quoted\"`, long}
+	d, err := c.Create(ctx, "_owner", testZoneId, provider.RecordTypeTXT, records, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	want := []string{
+		`"` + long[:provider.TXTStringMaxLength] + `" "aaaaaaaaaa"`,
+		`"heritage=dns-ingress,\"quoted\\\""`,
+	}
+	if got := f.rrdatas(testZoneId, "_owner.example.com.", provider.RecordTypeTXT); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Create: expected quoted remote records %v, got %v", want, got)
+	}
+
+	// the api keeps the unquoted value given by the others as is
+	f.addRRSet(testZoneId, ResourceRecordSet{Name: "_unquoted.example.com.", Type: provider.RecordTypeTXT, TTL: 300,
+		Rrdatas: []string{"v=spf1"}})
+	for name, want := range map[string][]string{"_owner": {long, records[0]}, "_unquoted": {"v=spf1"}} {
+		got, err := c.GetByName(ctx, name, testZoneId, provider.RecordTypeTXT)
+		if err != nil {
+			t.Fatalf("GetByName: %v", err)
+		}
+		if !reflect.DeepEqual(got.Records, want) {
+			t.Fatalf("GetByName: expected records %v, got %v", want, got.Records)
+		}
+	}
+	if !reflect.DeepEqual(d.Records, []string{long, records[0]}) {
+		t.Fatalf("Create: expected records %v, got %v", records, d.Records)
+	}
+}
+
+func TestClientGetZonePublicAndPrivate(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	f.addZone("example-com-public", testZoneName)
+	f.addZone("example-com-internal", testZoneName, "internal")
+	f.addZone("example-com-shared", testZoneName, "shared", "staging")
+	f.addZone("example-org", "example.org")
+
+	if z, err := f.newClient(t, ZoneFilter{}).GetZone(ctx, testZoneName); err == nil || !strings.Contains(err.Error(), "3 managed zones") {
+		t.Fatalf("GetZone: expected error for ambiguous zones, got %v, %v", z, err)
+	}
+
+	for _, tt := range []struct {
+		zoneFilter ZoneFilter
+		expected   string
+	}{
+		{ZoneFilter{Visibility: ZoneVisibilityPublic}, "example-com-public"},
+		{ZoneFilter{Network: "internal"}, "example-com-internal"},
+		{ZoneFilter{Visibility: ZoneVisibilityPrivate, Network: "staging"}, "example-com-shared"},
+		{ZoneFilter{Network: "projects/" + testProject + "/global/networks/shared"}, "example-com-shared"},
+		{ZoneFilter{}, "example-org"},
+	} {
+		zoneName := testZoneName
+		if tt.expected == "example-org" {
+			zoneName = "Example.ORG."
+		}
+		z, err := f.newClient(t, tt.zoneFilter).GetZone(ctx, zoneName)
+		if err != nil {
+			t.Fatalf("GetZone: expected zone %s for filter %+v, got %v", tt.expected, tt.zoneFilter, err)
+		}
+		if z.Id != tt.expected {
+			t.Fatalf("GetZone: expected zone %s for filter %+v, got %s", tt.expected, tt.zoneFilter, z.Id)
+		}
+	}
+
+	for _, zoneFilter := range []ZoneFilter{{Visibility: ZoneVisibilityPrivate}, {Network: "default"}} {
+		if z, err := f.newClient(t, zoneFilter).GetZone(ctx, testZoneName); err == nil {
+			t.Fatalf("GetZone: expected error for filter %+v, got %+v", zoneFilter, z)
+		}
+	}
+	if z, err := f.newClient(t, ZoneFilter{}).GetZone(ctx, "sub.example.org"); err == nil {
+		t.Fatalf("GetZone: expected error for missing zone, got %+v", z)
+	}
+}
+
+// newServiceAccountKey returns the service account key in json, whose tokens are issued by the fake server
+func newServiceAccountKey(t *testing.T, f *fakeServer, project string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("can't generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("can't marshal key: %v", err)
+	}
+	b, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     project,
+		"private_key_id": "0123456789abcdef",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "dns-ingress@" + testProject + ".iam.gserviceaccount.com",
+		"client_id":      "123456789",
+		"token_uri":      f.URL + "/token",
+	})
+	return string(b)
+}
+
+func TestNewCloudDNSClientWithSettings(t *testing.T) {
+	f, _ := newTestClient(t)
+	key := newServiceAccountKey(t, f, testProject)
+
+	// the project of the service account is used unless set
+	for _, settings := range []map[string]string{
+		{SettingKeyServiceAccountKey: key, SettingKeyEndpoint: f.URL},
+		{SettingKeyServiceAccountKey: newServiceAccountKey(t, f, "other"), SettingKeyProject: testProject, SettingKeyEndpoint: f.URL + apiPrefix + "/"},
+	} {
+		c, err := NewCloudDNSClientWithSettings(settings)
+		if err != nil {
+			t.Fatalf("NewCloudDNSClientWithSettings: %v", err)
+		}
+		if c.(*Client).project != testProject {
+			t.Fatalf("expected project %s, got %s", testProject, c.(*Client).project)
+		}
+		if err := provider.Verify(context.Background(), c); err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+		if z, err := c.GetZone(context.Background(), testZoneName); err != nil || z.Id != testZoneId {
+			t.Fatalf("GetZone: expected zone %s, got %v, %v", testZoneId, z, err)
+		}
+	}
+	if f.tokenRequests != 2 {
+		t.Fatalf("expected a token issued per client, got %d", f.tokenRequests)
+	}
+
+	c, err := NewCloudDNSClientWithSettings(map[string]string{SettingKeyServiceAccountKey: key, SettingKeyProject: "unknown", SettingKeyEndpoint: f.URL})
+	if err != nil {
+		t.Fatalf("NewCloudDNSClientWithSettings: %v", err)
+	}
+	if err := provider.Verify(context.Background(), c); err == nil {
+		t.Fatalf("Verify: expected error for unknown project")
+	}
+
+	for _, invalid := range []map[string]string{
+		{SettingKeyServiceAccountKey: "{"},
+		{SettingKeyServiceAccountKey: newServiceAccountKey(t, f, "")},
+		{SettingKeyServiceAccountKey: key, SettingKeyZoneVisibility: "internal"},
+		{SettingKeyServiceAccountKey: key, SettingKeyZoneVisibility: ZoneVisibilityPublic, SettingKeyNetwork: "default"},
+		{SettingKeyServiceAccountKey: key, SettingKeyEndpoint: "localhost:8080"},
+	} {
+		if _, err := NewCloudDNSClientWithSettings(invalid); err == nil {
+			t.Fatalf("NewCloudDNSClientWithSettings: expected error for settings %v", invalid)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package clouddns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	testProject     = "dns-project"
+	testAccessToken = "test-access-token"
+)
+
+// fakeZone is the managed zone along with its rrsets
+type fakeZone struct {
+	ManagedZone
+	rrsets []ResourceRecordSet
+}
+
+// fakeServer is a minimal stand-in of the cloud dns rest api serving managed zones, rrsets and changes,
+// along with the oauth2 token endpoint which exchanges the signed jwt of the service account
+type fakeServer struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// zones by managed zone name
+	zones map[string]*fakeZone
+	// changes counts the changes applied
+	changes int
+	// tokenRequests counts the token exchanges
+	tokenRequests int
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	f := &fakeServer{zones: map[string]*fakeZone{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// newClient returns the client which talks to the fake server with the access token
+func (f *fakeServer) newClient(t *testing.T, zoneFilter ZoneFilter) *Client {
+	httpClient := &http.Client{Transport: &oauth2.Transport{
+		Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: testAccessToken}),
+		Base:   f.Client().Transport,
+	}}
+	c, err := NewCloudDNSClient(testProject, f.URL, zoneFilter, httpClient)
+	if err != nil {
+		t.Fatalf("can't create client: %v", err)
+	}
+	return c
+}
+
+// addZone adds the managed zone, which is private if visible to any network
+func (f *fakeServer) addZone(name, dnsName string, networks ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	z := &fakeZone{ManagedZone: ManagedZone{
+		Id:         strconv.Itoa(len(f.zones) + 1000),
+		Name:       name,
+		DNSName:    fmt.Sprintf("%s.", dnsName),
+		Visibility: ZoneVisibilityPublic,
+	}}
+	if len(networks) > 0 {
+		z.Visibility = ZoneVisibilityPrivate
+		z.PrivateVisibilityConfig = &PrivateVisibilityConfig{}
+		for _, n := range networks {
+			z.PrivateVisibilityConfig.Networks = append(z.PrivateVisibilityConfig.Networks, Network{
+				NetworkUrl: fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/global/networks/%s", testProject, n),
+			})
+		}
+	}
+	f.zones[name] = z
+}
+
+// addRRSet seeds the managed zone with the rrset, e.g. the unquoted TXT which the client never writes
+func (f *fakeServer) addRRSet(zoneId string, rrset ResourceRecordSet) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	z := f.zones[zoneId]
+	z.rrsets = append(z.rrsets, rrset)
+}
+
+// rrset returns the rrset which has the fqdn and type
+func (f *fakeServer) rrset(zoneId, fqdn, rrType string) *ResourceRecordSet {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, rrset := range f.zones[zoneId].rrsets {
+		if rrset.Name == fqdn && rrset.Type == rrType {
+			return &rrset
+		}
+	}
+	return nil
+}
+
+// rrdatas returns the sorted rrdatas of the rrset
+func (f *fakeServer) rrdatas(zoneId, fqdn, rrType string) []string {
+	rrset := f.rrset(zoneId, fqdn, rrType)
+	if rrset == nil {
+		return nil
+	}
+	rrdatas := append([]string{}, rrset.Rrdatas...)
+	sort.Strings(rrdatas)
+	return rrdatas
+}
+
+func (f *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/token" {
+		f.issueToken(w, r)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+testAccessToken {
+		writeError(w, http.StatusUnauthorized, "", "Request had invalid authentication credentials.")
+		return
+	}
+
+	// /dns/v1/projects/{project}[/managedZones[/{zone}[/rrsets|/changes]]]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, apiPrefix+"/"), "/")
+	if len(parts) < 2 || parts[0] != "projects" {
+		writeError(w, http.StatusNotFound, "notFound", "Not Found")
+		return
+	}
+	if parts[1] != testProject {
+		writeError(w, http.StatusForbidden, "forbidden", "The caller does not have permission")
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, &Project{Id: testProject, Number: "123456789"})
+	case len(parts) == 3 && parts[2] == "managedZones" && r.Method == http.MethodGet:
+		f.listZones(w, r)
+	case len(parts) >= 4 && parts[2] == "managedZones":
+		z, ok := f.zones[parts[3]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "notFound", "The 'parameters.managedZone' resource named '"+parts[3]+"' does not exist.")
+			return
+		}
+		switch {
+		case len(parts) == 4 && r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, &z.ManagedZone)
+		case len(parts) == 5 && parts[4] == "rrsets" && r.Method == http.MethodGet:
+			f.listRRSets(w, r, z)
+		case len(parts) == 5 && parts[4] == "changes" && r.Method == http.MethodPost:
+			f.createChange(w, r, z)
+		default:
+			writeError(w, http.StatusNotFound, "notFound", "Not Found")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "notFound", "Not Found")
+	}
+}
+
+// issueToken accepts any jwt bearer grant, the signature is not verified
+func (f *fakeServer) issueToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || len(r.Form.Get("assertion")) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	f.tokenRequests++
+	writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": testAccessToken, "token_type": "Bearer", "expires_in": 3600})
+}
+
+// listZones lists a zone per page, so the client has to follow the page tokens
+func (f *fakeServer) listZones(w http.ResponseWriter, r *http.Request) {
+	dnsName := r.URL.Query().Get("dnsName")
+	zones := make([]ManagedZone, 0, len(f.zones))
+	for _, z := range f.zones {
+		if len(dnsName) == 0 || z.DNSName == dnsName {
+			zones = append(zones, z.ManagedZone)
+		}
+	}
+	sort.Slice(zones, func(i, j int) bool { return zones[i].Name < zones[j].Name })
+
+	start := 0
+	if token := r.URL.Query().Get("pageToken"); len(token) > 0 {
+		start, _ = strconv.Atoi(token)
+	}
+	page := &managedZonesPage{ManagedZones: []ManagedZone{}}
+	if start < len(zones) {
+		page.ManagedZones = zones[start : start+1]
+		if start+1 < len(zones) {
+			page.NextPageToken = strconv.Itoa(start + 1)
+		}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (f *fakeServer) listRRSets(w http.ResponseWriter, r *http.Request, z *fakeZone) {
+	query := r.URL.Query()
+	if len(query.Get("type")) > 0 && len(query.Get("name")) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid", "Invalid value for 'parameters.name': ''")
+		return
+	}
+	page := &rrsetsPage{Rrsets: []ResourceRecordSet{}}
+	for _, rrset := range z.rrsets {
+		if name := query.Get("name"); len(name) > 0 && rrset.Name != name {
+			continue
+		}
+		if rrType := query.Get("type"); len(rrType) > 0 && rrset.Type != rrType {
+			continue
+		}
+		page.Rrsets = append(page.Rrsets, rrset)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// createChange applies all the deletions and additions or none of them
+func (f *fakeServer) createChange(w http.ResponseWriter, r *http.Request, z *fakeZone) {
+	change := &Change{}
+	if err := json.NewDecoder(r.Body).Decode(change); err != nil {
+		writeError(w, http.StatusBadRequest, "parseError", err.Error())
+		return
+	}
+	if len(change.Additions) == 0 && len(change.Deletions) == 0 {
+		writeError(w, http.StatusBadRequest, "required", "The 'entity.change' parameter is required but was missing.")
+		return
+	}
+
+	rrsets := append([]ResourceRecordSet{}, z.rrsets...)
+	for i, deletion := range change.Deletions {
+		found := -1
+		for j, rrset := range rrsets {
+			if rrset.Name == deletion.Name && rrset.Type == deletion.Type {
+				found = j
+			}
+		}
+		if found < 0 {
+			writeError(w, http.StatusNotFound, "notFound", fmt.Sprintf("The 'entity.change.deletions[%d]' resource named '%s (%s)' does not exist.", i, deletion.Name, deletion.Type))
+			return
+		}
+		if !equalRRSet(rrsets[found], deletion) {
+			writeError(w, http.StatusPreconditionFailed, errorReasonConditionNotMet, fmt.Sprintf("Precondition not met for 'entity.change.deletions[%d]'", i))
+			return
+		}
+		rrsets = append(rrsets[:found], rrsets[found+1:]...)
+	}
+
+	for i, addition := range change.Additions {
+		if !strings.HasSuffix(addition.Name, z.DNSName) {
+			writeError(w, http.StatusBadRequest, "invalid", fmt.Sprintf("Invalid value for 'entity.change.additions[%d].name': '%s'", i, addition.Name))
+			return
+		}
+		if addition.TTL <= 0 || len(addition.Rrdatas) == 0 {
+			writeError(w, http.StatusBadRequest, "invalid", fmt.Sprintf("Invalid value for 'entity.change.additions[%d]'", i))
+			return
+		}
+		for _, rrset := range rrsets {
+			if rrset.Name != addition.Name {
+				continue
+			}
+			if rrset.Type == addition.Type {
+				writeError(w, http.StatusConflict, errorReasonAlreadyExists, fmt.Sprintf("The resource 'entity.change.additions[%d]' named '%s (%s)' already exists", i, addition.Name, addition.Type))
+				return
+			}
+			if rrset.Type == "CNAME" || addition.Type == "CNAME" {
+				writeError(w, http.StatusBadRequest, "cnameResourceRecordSetConflict", fmt.Sprintf("The resource record set 'entity.change.additions[%d]' is invalid because a CNAME resource record set of the same name already exists or is being added.", i))
+				return
+			}
+		}
+		rrsets = append(rrsets, addition)
+	}
+
+	z.rrsets = rrsets
+	f.changes++
+	change.Id = strconv.Itoa(f.changes)
+	change.Status = "done"
+	writeJSON(w, http.StatusOK, change)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError responds in the error format of the google apis
+func writeError(w http.ResponseWriter, status int, reason, message string) {
+	e := map[string]interface{}{"code": status, "message": message}
+	if len(reason) > 0 {
+		e["errors"] = []map[string]string{{"reason": reason, "message": message}}
+	}
+	writeJSON(w, status, map[string]interface{}{"error": e})
+}
@@ -0,0 +1,82 @@
+package clouddns
+
+import (
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"sort"
+	"strings"
+)
+
+// ttlDefault is applied if ttl is not set
+const ttlDefault = 300
+
+// newRRSet builds the rrset of the records, which are in presentation format on the api
+func newRRSet(fqdn, recordType string, records []string, ttl int) ResourceRecordSet {
+	rrset := ResourceRecordSet{
+		Name:    fqdn,
+		Type:    recordType,
+		TTL:     normalizeTTL(ttl),
+		Rrdatas: make([]string, 0, len(records)),
+	}
+	for _, r := range records {
+		rrset.Rrdatas = append(rrset.Rrdatas, toRrdata(recordType, r))
+	}
+	return rrset
+}
+
+// convertRRSet converts the rrset into a recordset
+func convertRRSet(name, zoneId, zoneName string, rrset ResourceRecordSet) *provider.Domain {
+	records := make([]string, 0, len(rrset.Rrdatas))
+	for _, r := range rrset.Rrdatas {
+		records = append(records, fromRrdata(rrset.Type, r))
+	}
+	sort.Strings(records)
+
+	return &provider.Domain{
+		Id:        provider.GenerateRecordSetId(name, rrset.Type),
+		Name:      name,
+		Type:      rrset.Type,
+		Records:   records,
+		TTL:       rrset.TTL,
+		ZoneId:    zoneId,
+		ZoneName:  zoneName,
+		FQDN:      fqdnOf(name, zoneName),
+		Activated: true,
+	}
+}
+
+// toRrdata returns the record in presentation format the api requires
+func toRrdata(recordType, record string) string {
+	switch recordType {
+	case provider.RecordTypeCNAME:
+		return fmt.Sprintf("%s.", strings.TrimSuffix(record, "."))
+	case provider.RecordTypeTXT:
+		return provider.QuoteTXT(record)
+	default:
+		return record
+	}
+}
+
+// fromRrdata returns the record in the form given on Create, reverse of toRrdata
+func fromRrdata(recordType, rrdata string) string {
+	switch recordType {
+	case provider.RecordTypeCNAME:
+		return strings.TrimSuffix(rrdata, ".")
+	case provider.RecordTypeTXT:
+		return provider.UnquoteTXT(rrdata)
+	default:
+		return rrdata
+	}
+}
+
+// fqdnOf returns the lowercase fully-qualified name with trailing dot, which the api identifies rrsets by
+func fqdnOf(name, zoneName string) string {
+	return fmt.Sprintf("%s.", strings.ToLower(provider.JoinName(name, zoneName)))
+}
+
+func normalizeTTL(ttl int) int {
+	if ttl <= 0 {
+		return ttlDefault
+	}
+	return ttl
+}
@@ -0,0 +1,131 @@
+package cloudflare
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/sokdak/dns-ingress/pkg/environment"
+)
+
+func TestClientUsesAPIToken(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t, cloudflare.Zone{ID: testZoneId, Name: testZoneName, Permissions: []string{"#zone:read", permissionDNSRecordsEdit}})
+	f.tokens["token"] = tokenStatusActive
+
+	// api token takes precedence over the global api key
+	c := f.newClientWithCredentials(t, Credentials{APIToken: "token", AuthKey: "wrong", AuthEmail: testAuthEmail})
+	if len(c.CfClient.APIToken) == 0 {
+		t.Fatalf("expected the client to use api token")
+	}
+	if _, err := c.GetZone(ctx, testZoneName); err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if err := c.Verify(ctx); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+func TestClientVerify(t *testing.T) {
+	ctx := context.Background()
+	editable := cloudflare.Zone{ID: testZoneId, Name: testZoneName, Permissions: []string{"#zone:read", permissionDNSRecordsEdit}}
+	readOnly := cloudflare.Zone{ID: testZoneId, Name: testZoneName, Permissions: []string{"#zone:read", "#dns_records:read"}}
+
+	tests := []struct {
+		name        string
+		zones       []cloudflare.Zone
+		tokenStatus string
+		credentials Credentials
+		wantErr     string
+	}{
+		{name: "api key", zones: []cloudflare.Zone{editable},
+			credentials: Credentials{AuthKey: testAuthKey, AuthEmail: testAuthEmail}},
+		{name: "invalid api key", zones: []cloudflare.Zone{editable},
+			credentials: Credentials{AuthKey: "wrong", AuthEmail: testAuthEmail}, wantErr: "can't verify api key"},
+		{name: "active token", zones: []cloudflare.Zone{editable}, tokenStatus: tokenStatusActive,
+			credentials: Credentials{APIToken: "token"}},
+		{name: "unknown token", zones: []cloudflare.Zone{editable},
+			credentials: Credentials{APIToken: "token"}, wantErr: "can't verify api token"},
+		{name: "disabled token", zones: []cloudflare.Zone{editable}, tokenStatus: "disabled",
+			credentials: Credentials{APIToken: "token"}, wantErr: "token is disabled"},
+		{name: "token without zones", tokenStatus: tokenStatusActive,
+			credentials: Credentials{APIToken: "token"}, wantErr: "no zone is accessible"},
+		{name: "token without dns edit permission", zones: []cloudflare.Zone{readOnly}, tokenStatus: tokenStatusActive,
+			credentials: Credentials{APIToken: "token"}, wantErr: permissionDNSRecordsEdit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeServer(t, tt.zones...)
+			if len(tt.tokenStatus) > 0 {
+				f.tokens["token"] = tt.tokenStatus
+			}
+
+			err := f.newClientWithCredentials(t, tt.credentials).Verify(ctx)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Verify: expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewCloudFlareClientWithSettings(t *testing.T) {
+	environment.LoadEnvs()
+
+	c, err := NewCloudFlareClientWithSettings(map[string]string{SettingKeyAPIToken: "token"})
+	if err != nil {
+		t.Fatalf("NewCloudFlareClientWithSettings: %v", err)
+	}
+	if c.(*Client).CfClient.APIToken != "token" {
+		t.Fatalf("expected the client to use api token")
+	}
+
+	c, err = NewCloudFlareClientWithSettings(map[string]string{SettingKeyAuthKey: testAuthKey, SettingKeyAuthEmail: testAuthEmail})
+	if err != nil {
+		t.Fatalf("NewCloudFlareClientWithSettings: %v", err)
+	}
+	if c.(*Client).CfClient.APIKey != testAuthKey {
+		t.Fatalf("expected the client to use api key")
+	}
+
+	if _, err = NewCloudFlareClientWithSettings(map[string]string{SettingKeyAuthKey: testAuthKey}); err == nil {
+		t.Fatalf("expected error without auth email")
+	}
+}
+
+func TestHasEnvironmentCredentials(t *testing.T) {
+	tests := []struct {
+		name string
+		envs map[string]string
+		want bool
+	}{
+		{name: "no envs", envs: map[string]string{}},
+		{name: "api token", envs: map[string]string{"CLOUDFLARE_API_TOKEN": "token"}, want: true},
+		{name: "api key", envs: map[string]string{"CLOUDFLARE_AUTH_KEY": testAuthKey, "CLOUDFLARE_AUTH_EMAIL": testAuthEmail}, want: true},
+		{name: "api key without email", envs: map[string]string{"CLOUDFLARE_AUTH_KEY": testAuthKey}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the envs are loaded again once restored
+			t.Cleanup(environment.LoadEnvs)
+			for _, key := range []string{"CLOUDFLARE_API_TOKEN", "CLOUDFLARE_AUTH_KEY", "CLOUDFLARE_AUTH_EMAIL"} {
+				t.Setenv(key, tt.envs[key])
+				if _, ok := tt.envs[key]; !ok {
+					os.Unsetenv(key)
+				}
+			}
+			environment.LoadEnvs()
+
+			if got := HasEnvironmentCredentials(); got != tt.want {
+				t.Fatalf("HasEnvironmentCredentials: expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -2,44 +2,175 @@ package cloudflare
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/cloudflare/cloudflare-go"
 	"github.com/sokdak/dns-ingress/pkg/common"
 	"github.com/sokdak/dns-ingress/pkg/environment"
 	"github.com/sokdak/dns-ingress/pkg/provider"
 	"net/http"
+	"sort"
+	"strconv"
+	"sync"
 )
 
 const ProviderKey = "cloudflare"
 
+const (
+	SettingKeyAPIToken  = "apiToken"
+	SettingKeyAuthKey   = "authKey"
+	SettingKeyAuthEmail = "authEmail"
+
+	SettingKeyRetryMaxCount = "retryMaxCount"
+	SettingKeyRetryMinDelay = "retryMinDelay"
+	SettingKeyRetryMaxDelay = "retryMaxDelay"
+)
+
+const recordComment = "created and managed by dns-ingress.io"
+
+// permissionDNSRecordsEdit is the zone permission required to manage the records
+const permissionDNSRecordsEdit = "#dns_records:edit"
+
+const tokenStatusActive = "active"
+
+func init() {
+	provider.Register(ProviderKey, NewCloudFlareClientWithSettings)
+}
+
 type Client struct {
 	CfClient *cloudflare.API
 	provider.Client
+
+	// zoneNames caches zone name by zone id
+	zoneNames sync.Map
 }
 
 func GenerateCloudFlareClientUsingEnvironment() (*Client, error) {
-	if environment.CloudflareAuthKey == nil || environment.CloudflareAuthEmail == nil {
+	credentials := credentialsFromEnvironment()
+	if !credentials.valid() {
 		return nil, fmt.Errorf("can't generate cfclient using envs, missing envs")
 	}
 
 	return NewCloudFlareClient(
-		*environment.CloudflareAuthKey, *environment.CloudflareAuthEmail, http.DefaultClient,
+		credentials, http.DefaultClient,
+		*environment.CloudflareClientRateLimit,
+		retryPolicyFromEnvironment(), *environment.CloudflareClientDebugMode)
+}
+
+// HasEnvironmentCredentials returns true if the credential envs are set, which the client is generated with
+func HasEnvironmentCredentials() bool {
+	return credentialsFromEnvironment().valid()
+}
+
+// credentialsFromEnvironment returns the credentials of the envs, either api token or both auth key and email
+func credentialsFromEnvironment() Credentials {
+	credentials := Credentials{}
+	if environment.CloudflareAPIToken != nil {
+		credentials.APIToken = *environment.CloudflareAPIToken
+	}
+	if environment.CloudflareAuthKey != nil && environment.CloudflareAuthEmail != nil {
+		credentials.AuthKey, credentials.AuthEmail = *environment.CloudflareAuthKey, *environment.CloudflareAuthEmail
+	}
+	return credentials
+}
+
+// NewCloudFlareClientWithSettings creates the client with the provider settings, falls back to envs if credentials are not set
+func NewCloudFlareClientWithSettings(settings map[string]string) (provider.Client, error) {
+	credentials := Credentials{
+		APIToken:  settings[SettingKeyAPIToken],
+		AuthKey:   settings[SettingKeyAuthKey],
+		AuthEmail: settings[SettingKeyAuthEmail],
+	}
+	if credentials == (Credentials{}) {
+		c, err := GenerateCloudFlareClientUsingEnvironment()
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	if !credentials.valid() {
+		return nil, fmt.Errorf("can't generate cfclient using settings, either %s or both %s and %s are required",
+			SettingKeyAPIToken, SettingKeyAuthKey, SettingKeyAuthEmail)
+	}
+
+	retryPolicy, err := retryPolicyFromSettings(settings, retryPolicyFromEnvironment())
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := NewCloudFlareClient(
+		credentials, http.DefaultClient,
 		*environment.CloudflareClientRateLimit,
-		RetryPolicy{
-			MaxRetryCount: 0,
-			MinDelay:      0,
-			MaxDelay:      0,
-		}, *environment.CloudflareClientDebugMode)
+		retryPolicy, *environment.CloudflareClientDebugMode)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// retryPolicyFromEnvironment returns DefaultRetryPolicy overridden by the retry envs which are set
+func retryPolicyFromEnvironment() RetryPolicy {
+	policy := DefaultRetryPolicy
+	if environment.CloudflareClientRetryMaxCount != nil {
+		policy.MaxRetryCount = *environment.CloudflareClientRetryMaxCount
+	}
+	if environment.CloudflareClientRetryMinDelay != nil {
+		policy.MinDelay = *environment.CloudflareClientRetryMinDelay
+	}
+	if environment.CloudflareClientRetryMaxDelay != nil {
+		policy.MaxDelay = *environment.CloudflareClientRetryMaxDelay
+	}
+	return policy
 }
 
-func NewCloudFlareClient(key, email string, client *http.Client, rateLimits float64, retryPolicy RetryPolicy, debug bool) (*Client, error) {
+// retryPolicyFromSettings returns the base retry policy overridden by the retry settings which are set
+func retryPolicyFromSettings(settings map[string]string, base RetryPolicy) (RetryPolicy, error) {
+	policy := base
+	for key, value := range map[string]*int{
+		SettingKeyRetryMaxCount: &policy.MaxRetryCount,
+		SettingKeyRetryMinDelay: &policy.MinDelay,
+		SettingKeyRetryMaxDelay: &policy.MaxDelay,
+	} {
+		s, ok := settings[key]
+		if !ok {
+			continue
+		}
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return RetryPolicy{}, fmt.Errorf("can't parse setting %s: %w", key, err)
+		}
+		*value = i
+	}
+	return policy, nil
+}
+
+// NewCloudFlareClient creates the client, requests are retried with the retry policy by the transport of the client
+func NewCloudFlareClient(credentials Credentials, client *http.Client, rateLimits float64, retryPolicy RetryPolicy, debug bool, extraOpts ...cloudflare.Option) (*Client, error) {
+	if err := retryPolicy.Validate(); err != nil {
+		return nil, fmt.Errorf("can't create new cf-client: %w", err)
+	}
+	if client == nil {
+		client = DefaultHttpClient
+	}
+	retryClient := *client
+	retryClient.Transport = newRetryTransport(client.Transport, retryPolicy)
+
 	opts := []cloudflare.Option{
-		cloudflare.HTTPClient(client),
+		cloudflare.HTTPClient(&retryClient),
 		cloudflare.UsingRateLimit(rateLimits),
-		cloudflare.UsingRetryPolicy(retryPolicy.MaxRetryCount, retryPolicy.MinDelay, retryPolicy.MaxDelay),
+		// retries are done by the transport, which can tell idempotent requests and Retry-After
+		cloudflare.UsingRetryPolicy(0, 0, 0),
 		cloudflare.Debug(debug),
 	}
-	api, err := cloudflare.New(key, email, opts...)
+	opts = append(opts, extraOpts...)
+
+	var api *cloudflare.API
+	var err error
+	if len(credentials.APIToken) > 0 {
+		api, err = cloudflare.NewWithAPIToken(credentials.APIToken, opts...)
+	} else {
+		api, err = cloudflare.New(credentials.AuthKey, credentials.AuthEmail, opts...)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("can't create new cf-client: %w", err)
 	}
@@ -48,6 +179,39 @@ func NewCloudFlareClient(key, email string, client *http.Client, rateLimits floa
 	}, nil
 }
 
+// Verify checks the credentials are accepted by cloudflare.
+// api token has to be active and granted to edit dns records of the zones it can read.
+func (c *Client) Verify(ctx context.Context) error {
+	if len(c.CfClient.APIToken) == 0 {
+		if _, err := c.CfClient.UserDetails(ctx); err != nil {
+			return fmt.Errorf("can't verify api key: %w", err)
+		}
+		return nil
+	}
+
+	token, err := c.CfClient.VerifyAPIToken(ctx)
+	if err != nil {
+		return fmt.Errorf("can't verify api token: %w", err)
+	}
+	if token.Status != tokenStatusActive {
+		return fmt.Errorf("can't verify api token: token is %s", token.Status)
+	}
+
+	zones, err := c.CfClient.ListZones(ctx)
+	if err != nil {
+		return fmt.Errorf("can't verify api token, missing zone read permission: %w", err)
+	}
+	if len(zones) == 0 {
+		return fmt.Errorf("can't verify api token: no zone is accessible with the token")
+	}
+	for _, z := range zones {
+		if common.ContainsString(z.Permissions, permissionDNSRecordsEdit) {
+			return nil
+		}
+	}
+	return fmt.Errorf("can't verify api token: %s permission is not granted on any zone", permissionDNSRecordsEdit)
+}
+
 func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone, error) {
 	zones, err := c.CfClient.ListZones(ctx, zoneName)
 	if err != nil {
@@ -67,6 +231,7 @@ func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone,
 	if matchedZones == nil {
 		return nil, fmt.Errorf("can't GetZone: cannot find zone %s", zoneName)
 	}
+	c.zoneNames.Store(matchedZones.ID, matchedZones.Name)
 
 	return &provider.Zone{
 		Id:        matchedZones.ID,
@@ -75,122 +240,326 @@ func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone,
 	}, nil
 }
 
-func (c *Client) GetByName(ctx context.Context, name, zoneId string) (*provider.Domain, error) {
-	listParam := cloudflare.ListDNSRecordsParams{
-		Name: name,
-		ResultInfo: cloudflare.ResultInfo{
-			Page:    0,
-			PerPage: 0,
-		},
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
 	}
-	records, _, err := c.CfClient.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zoneId), listParam)
+
+	records, err := c.listRecordSet(ctx, zoneId, provider.JoinName(name, zoneName), recordType)
 	if err != nil {
 		return nil, fmt.Errorf("can't GetByName: %w", err)
 	}
 
-	var r *cloudflare.DNSRecord
-	for _, record := range records {
-		if record.Name == name {
-			r = &record
-			break
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return convertRecordSet(name, zoneId, zoneName, records), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, found, err := c.resolveRecordSetId(ctx, id, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, options map[string]string) (*provider.Domain, error) {
+	proxied, err := parseProxied(options)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Create: no records given for %s", name)
+	}
+
+	// cloudflare creates the record regardless of the recordset, which would merge the records into the existing one
+	fqdn := provider.JoinName(name, zoneName)
+	current, err := c.listRecordSet(ctx, zoneId, fqdn, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	if len(current) > 0 {
+		return nil, fmt.Errorf("can't Create: recordset %s %s already exists", fqdn, recordType)
+	}
+
+	ttl = normalizeTTL(ttl)
+	created := make([]cloudflare.DNSRecord, 0, len(records))
+	for _, content := range records {
+		r, err := c.createRecord(ctx, zoneId, fqdn, recordType, content, ttl, proxied)
+		if err != nil {
+			return nil, fmt.Errorf("can't Create: %w", err)
 		}
+		created = append(created, r)
 	}
 
-	if r == nil {
-		return nil, fmt.Errorf("can't GetByName: recordset name %s is not found in zoneId %s", name, zoneId)
+	return convertRecordSet(name, zoneId, zoneName, created), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, options map[string]string) (*provider.Domain, error) {
+	proxied, err := parseProxied(options)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
 	}
 
-	return &provider.Domain{
-		Id:        r.ID,
-		Name:      r.Name,
-		Type:      r.Type,
-		Records:   []string{r.Content},
-		ZoneId:    r.ZoneID,
-		ZoneName:  r.ZoneName,
-		FQDN:      fmt.Sprintf("%s.%s.", r.Name, r.ZoneName),
-		TTL:       r.TTL,
-		Activated: true,
-	}, nil
+	name, currentType, found, err := c.resolveRecordSetId(ctx, id, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	fqdn := provider.JoinName(name, zoneName)
+	ttl = normalizeTTL(ttl)
+
+	current, err := c.listRecordSet(ctx, zoneId, fqdn, currentType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if len(current) == 0 {
+		return nil, nil
+	}
+
+	// if type has been changed, remove the current recordset first since CNAME can't coexist with others
+	if currentType != recordType {
+		for _, r := range current {
+			if err := c.deleteRecord(ctx, zoneId, r.ID); err != nil {
+				return nil, fmt.Errorf("can't Update: %w", err)
+			}
+		}
+		current, err = c.listRecordSet(ctx, zoneId, fqdn, recordType)
+		if err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+
+	// keep the members which have wanted content, the others are stale
+	wanted := map[string]bool{}
+	for _, content := range records {
+		wanted[content] = true
+	}
+	stale := make([]cloudflare.DNSRecord, 0)
+	synced := make([]cloudflare.DNSRecord, 0, len(records))
+	for _, r := range current {
+		if !wanted[r.Content] {
+			stale = append(stale, r)
+			continue
+		}
+		delete(wanted, r.Content)
+
+		if r.TTL != ttl || isProxied(r) != proxied {
+			r, err = c.CfClient.UpdateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneId), cloudflare.UpdateDNSRecordParams{
+				ID:      r.ID,
+				Type:    r.Type,
+				Name:    r.Name,
+				Content: r.Content,
+				TTL:     ttl,
+				Proxied: common.BoolPointer(proxied),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("can't Update: %w", err)
+			}
+		}
+		synced = append(synced, r)
+	}
+
+	// create the missing members first, then remove the stale ones to avoid resolution gap
+	for _, content := range records {
+		if !wanted[content] {
+			continue
+		}
+		delete(wanted, content)
+		r, err := c.createRecord(ctx, zoneId, fqdn, recordType, content, ttl, proxied)
+		if err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+		synced = append(synced, r)
+	}
+
+	for _, r := range stale {
+		if err := c.deleteRecord(ctx, zoneId, r.ID); err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+
+	if len(synced) == 0 {
+		return nil, nil
+	}
+	return convertRecordSet(name, zoneId, zoneName, synced), nil
 }
 
-func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, found, err := c.resolveRecordSetId(ctx, id, zoneId)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	if !found {
+		return nil
+	}
+
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	records, err := c.listRecordSet(ctx, zoneId, provider.JoinName(name, zoneName), recordType)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	for _, r := range records {
+		if err := c.deleteRecord(ctx, zoneId, r.ID); err != nil {
+			return fmt.Errorf("can't Delete: %w", err)
+		}
+	}
+	return nil
+}
+
+// resolveRecordSetId returns name and type of the recordset.
+// id of a single cloudflare record is also accepted for the recordsets managed by older versions.
+func (c *Client) resolveRecordSetId(ctx context.Context, id, zoneId string) (string, string, bool, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err == nil {
+		return name, recordType, true, nil
+	}
+
 	r, err := c.CfClient.GetDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneId), id)
 	if err != nil {
-		return nil, fmt.Errorf("can't Get: %w", err)
+		if isNotFound(err) {
+			return "", "", false, nil
+		}
+		return "", "", false, err
 	}
 
-	return &provider.Domain{
-		Id:        r.ID,
-		Name:      r.Name,
-		Type:      r.Type,
-		Records:   []string{r.Content},
-		TTL:       r.TTL,
-		ZoneId:    r.ZoneID,
-		ZoneName:  r.ZoneName,
-		FQDN:      fmt.Sprintf("%s.%s.", r.Name, r.ZoneName),
-		Activated: true,
-	}, nil
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return "", "", false, err
+	}
+	return provider.RelativeName(r.Name, zoneName), r.Type, true, nil
 }
 
-func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int) (*provider.Domain, error) {
-	params := cloudflare.CreateDNSRecordParams{
-		Type:    recordType,
-		Name:    name,
-		Content: records[0],
-		TTL:     ttl,
-		Proxied: common.BoolPointer(false),
-		Locked:  true,
-		Comment: "created and managed by dns-ingress.io",
+// getZoneName returns the zone name of the zone id
+func (c *Client) getZoneName(ctx context.Context, zoneId string) (string, error) {
+	if zoneName, ok := c.zoneNames.Load(zoneId); ok {
+		return zoneName.(string), nil
 	}
-	r, err := c.CfClient.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneId), params)
+
+	z, err := c.CfClient.ZoneDetails(ctx, zoneId)
 	if err != nil {
-		return nil, fmt.Errorf("can't Create: %w", err)
+		return "", fmt.Errorf("can't get zone details of %s: %w", zoneId, err)
 	}
+	c.zoneNames.Store(z.ID, z.Name)
+	return z.Name, nil
+}
 
-	return &provider.Domain{
-		Id:        r.ID,
-		Name:      r.Name,
-		Type:      r.Type,
-		Records:   []string{r.Content},
-		TTL:       r.TTL,
-		ZoneId:    r.ZoneID,
-		ZoneName:  r.ZoneName,
-		FQDN:      fmt.Sprintf("%s.%s.", r.Name, r.ZoneName),
-		Activated: true,
-	}, nil
+// listRecordSet returns all the records which have the same name and type
+func (c *Client) listRecordSet(ctx context.Context, zoneId, fqdn, recordType string) ([]cloudflare.DNSRecord, error) {
+	listParam := cloudflare.ListDNSRecordsParams{
+		Name: fqdn,
+		Type: recordType,
+	}
+	records, _, err := c.CfClient.ListDNSRecords(ctx, cloudflare.ZoneIdentifier(zoneId), listParam)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]cloudflare.DNSRecord, 0, len(records))
+	for _, r := range records {
+		if r.Name == fqdn && r.Type == recordType {
+			matched = append(matched, r)
+		}
+	}
+	return matched, nil
 }
 
-func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int) (*provider.Domain, error) {
-	params := cloudflare.UpdateDNSRecordParams{
-		ID:      id,
+func (c *Client) createRecord(ctx context.Context, zoneId, fqdn, recordType, content string, ttl int, proxied bool) (cloudflare.DNSRecord, error) {
+	params := cloudflare.CreateDNSRecordParams{
 		Type:    recordType,
-		Content: records[0],
+		Name:    fqdn,
+		Content: content,
 		TTL:     ttl,
-		Proxied: common.BoolPointer(false),
+		Proxied: common.BoolPointer(proxied),
+		Locked:  true,
+		Comment: recordComment,
 	}
-	r, err := c.CfClient.UpdateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneId), params)
-	if err != nil {
-		return nil, fmt.Errorf("can't Update: %w", err)
+	return c.CfClient.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneId), params)
+}
+
+func (c *Client) deleteRecord(ctx context.Context, zoneId, id string) error {
+	if err := c.CfClient.DeleteDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneId), id); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// convertRecordSet converts the cloudflare records of the same name and type into a recordset.
+// proxied is reported only if all the records agree, so partially proxied recordset always drifts.
+func convertRecordSet(name, zoneId, zoneName string, records []cloudflare.DNSRecord) *provider.Domain {
+	contents := make([]string, 0, len(records))
+	proxiedCount := 0
+	for _, r := range records {
+		contents = append(contents, r.Content)
+		if isProxied(r) {
+			proxiedCount++
+		}
+	}
+	sort.Strings(contents)
+
+	var proxied *bool
+	options := map[string]string{}
+	if proxiedCount == 0 || proxiedCount == len(records) {
+		proxied = common.BoolPointer(proxiedCount > 0)
+		options[OptionKeyProxied] = strconv.FormatBool(*proxied)
 	}
 
+	r := records[0]
 	return &provider.Domain{
-		Id:        r.ID,
-		Name:      r.Name,
+		Id:        provider.GenerateRecordSetId(name, r.Type),
+		Name:      name,
 		Type:      r.Type,
-		Records:   []string{r.Content},
+		Records:   contents,
 		TTL:       r.TTL,
-		ZoneId:    r.ZoneID,
-		ZoneName:  r.ZoneName,
-		FQDN:      fmt.Sprintf("%s.%s.", r.Name, r.ZoneName),
+		ZoneId:    zoneId,
+		ZoneName:  zoneName,
+		FQDN:      fmt.Sprintf("%s.", provider.JoinName(name, zoneName)),
 		Activated: true,
-	}, nil
+		Proxied:   proxied,
+		Options:   options,
+	}
 }
 
-func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
-	err := c.CfClient.DeleteDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneId), id)
-	if err != nil {
-		// TODO: consider not found condition
-		return fmt.Errorf("can't Delete: %w", err)
+func isProxied(r cloudflare.DNSRecord) bool {
+	return r.Proxied != nil && *r.Proxied
+}
+
+// normalizeTTL returns the ttl cloudflare accepts; 1 means automatic
+func normalizeTTL(ttl int) int {
+	if ttl <= 0 {
+		return 1
 	}
-	return nil
+	return ttl
+}
+
+func isNotFound(err error) bool {
+	var notFoundErr *cloudflare.NotFoundError
+	return errors.As(err, &notFoundErr)
 }
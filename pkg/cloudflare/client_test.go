@@ -0,0 +1,192 @@
+package cloudflare
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/sokdak/dns-ingress/pkg/common"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+const (
+	testZoneId   = "023e105f4ecef8ad9ca31a8372d0c353"
+	testZoneName = "example.com"
+)
+
+func newTestClient(t *testing.T) (*fakeServer, *Client) {
+	f := newFakeServer(t, cloudflare.Zone{ID: testZoneId, Name: testZoneName})
+	return f, f.newClient(t)
+}
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	z, err := c.GetZone(ctx, testZoneName)
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if z.Id != testZoneId {
+		t.Fatalf("GetZone: expected zone id %s, got %s", testZoneId, z.Id)
+	}
+
+	d, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeA)
+	if err != nil || d != nil {
+		t.Fatalf("GetByName: expected nothing before create, got %v, %v", d, err)
+	}
+
+	d, err = c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 300, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("www", provider.RecordTypeA),
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.0.2.1", "192.0.2.2"},
+		TTL:       300,
+		ZoneId:    testZoneId,
+		ZoneName:  testZoneName,
+		FQDN:      "www.example.com.",
+		Activated: true,
+		Proxied:   common.BoolPointer(false),
+		Options:   map[string]string{OptionKeyProxied: "false"},
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+
+	for _, get := range []func() (*provider.Domain, error){
+		func() (*provider.Domain, error) { return c.GetByName(ctx, "www", testZoneId, provider.RecordTypeA) },
+		func() (*provider.Domain, error) { return c.Get(ctx, d.Id, testZoneId) },
+	} {
+		got, err := get()
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Get: expected %+v, got %+v", expected, got)
+		}
+	}
+
+	d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}, 300, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if want := []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}; !reflect.DeepEqual(d.Records, want) {
+		t.Fatalf("Update: expected records %v, got %v", want, d.Records)
+	}
+	if got := f.contents("www.example.com", provider.RecordTypeA); !reflect.DeepEqual(got, d.Records) {
+		t.Fatalf("Update: expected remote records %v, got %v", d.Records, got)
+	}
+
+	if err := c.Delete(ctx, d.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.contents("www.example.com", provider.RecordTypeA); len(got) != 0 {
+		t.Fatalf("Delete: expected no remote records, got %v", got)
+	}
+
+	d, err = c.Get(ctx, d.Id, testZoneId)
+	if err != nil || d != nil {
+		t.Fatalf("Get: expected nothing after delete, got %v, %v", d, err)
+	}
+}
+
+func TestClientRecordSetIsolatedByType(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	f.addRecord(cloudflare.DNSRecord{Name: "www.example.com", Type: provider.RecordTypeAAAA, Content: "2001:db8::1", ZoneID: testZoneId, TTL: 1})
+	a, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if a.TTL != 1 {
+		t.Fatalf("Create: expected automatic ttl, got %d", a.TTL)
+	}
+
+	aaaa, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeAAAA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(aaaa.Records, []string{"2001:db8::1"}) {
+		t.Fatalf("GetByName: expected only AAAA records, got %v", aaaa.Records)
+	}
+
+	if err := c.Delete(ctx, a.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.contents("www.example.com", provider.RecordTypeAAAA); len(got) != 1 {
+		t.Fatalf("Delete: expected AAAA records untouched, got %v", got)
+	}
+}
+
+func TestClientCreateRefusesExistingRecordSet(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	f.addRecord(cloudflare.DNSRecord{Name: "www.example.com", Type: provider.RecordTypeA, Content: "192.0.2.1", ZoneID: testZoneId, TTL: 1})
+	if _, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.2"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error on existing recordset")
+	}
+	if got := f.contents("www.example.com", provider.RecordTypeA); !reflect.DeepEqual(got, []string{"192.0.2.1"}) {
+		t.Fatalf("Create: expected existing recordset untouched, got %v", got)
+	}
+}
+
+func TestClientUpdateChangesType(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	d, err := c.Create(ctx, "@", testZoneId, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if d.FQDN != "example.com." {
+		t.Fatalf("Create: expected apex fqdn, got %s", d.FQDN)
+	}
+
+	d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeCNAME, []string{"lb.example.net"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != provider.GenerateRecordSetId("@", provider.RecordTypeCNAME) {
+		t.Fatalf("Update: expected id of the CNAME recordset, got %s", d.Id)
+	}
+	if got := f.contents("example.com", provider.RecordTypeA); len(got) != 0 {
+		t.Fatalf("Update: expected A records removed, got %v", got)
+	}
+	if got := f.contents("example.com", provider.RecordTypeCNAME); !reflect.DeepEqual(got, []string{"lb.example.net"}) {
+		t.Fatalf("Update: expected CNAME record, got %v", got)
+	}
+}
+
+func TestClientAcceptsLegacyRecordId(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	r := f.addRecord(cloudflare.DNSRecord{Name: "legacy.example.com", Type: provider.RecordTypeA, Content: "192.0.2.1", ZoneID: testZoneId, TTL: 1})
+	f.addRecord(cloudflare.DNSRecord{Name: "legacy.example.com", Type: provider.RecordTypeA, Content: "192.0.2.2", ZoneID: testZoneId, TTL: 1})
+
+	d, err := c.Get(ctx, r.ID, testZoneId)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if d.Id != provider.GenerateRecordSetId("legacy", provider.RecordTypeA) || len(d.Records) != 2 {
+		t.Fatalf("Get: expected the whole recordset, got %+v", d)
+	}
+
+	if err := c.Delete(ctx, r.ID, testZoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.contents("legacy.example.com", provider.RecordTypeA); len(got) != 0 {
+		t.Fatalf("Delete: expected no remote records, got %v", got)
+	}
+
+	if err := c.Delete(ctx, r.ID, testZoneId); err != nil {
+		t.Fatalf("Delete: expected no error for missing record, got %v", err)
+	}
+}
@@ -2,6 +2,18 @@ package cloudflare
 
 import "net/http"
 
+// Credentials of the cloudflare api, the scoped api token is used if present, otherwise the global api key with email
+type Credentials struct {
+	APIToken  string
+	AuthKey   string
+	AuthEmail string
+}
+
+// valid returns true if either api token or both auth key and email are set
+func (c Credentials) valid() bool {
+	return len(c.APIToken) > 0 || (len(c.AuthKey) > 0 && len(c.AuthEmail) > 0)
+}
+
 type RetryPolicy struct {
 	MaxRetryCount int
 	MinDelay      int
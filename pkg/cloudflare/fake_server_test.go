@@ -0,0 +1,267 @@
+package cloudflare
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+)
+
+// fakeServer is a minimal stand-in of the cloudflare v4 api serving zones and dns records
+type fakeServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	zones   map[string]cloudflare.Zone
+	records map[string]cloudflare.DNSRecord
+	nextId  int
+
+	// tokens has the status of the api tokens accepted by the server
+	tokens map[string]string
+}
+
+const (
+	testAuthKey   = "key"
+	testAuthEmail = "user@example.com"
+)
+
+func newFakeServer(t *testing.T, zones ...cloudflare.Zone) *fakeServer {
+	f := &fakeServer{
+		zones:   map[string]cloudflare.Zone{},
+		records: map[string]cloudflare.DNSRecord{},
+		tokens:  map[string]string{},
+	}
+	for _, z := range zones {
+		f.zones[z.ID] = z
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// newClient returns the client which talks to the fake server
+func (f *fakeServer) newClient(t *testing.T) *Client {
+	return f.newClientWithCredentials(t, Credentials{AuthKey: testAuthKey, AuthEmail: testAuthEmail})
+}
+
+// newClientWithCredentials returns the client which talks to the fake server with the credentials
+func (f *fakeServer) newClientWithCredentials(t *testing.T, credentials Credentials) *Client {
+	c, err := NewCloudFlareClient(credentials, f.Client(), DefaultRateLimit*1000,
+		RetryPolicy{}, false, cloudflare.BaseURL(f.URL))
+	if err != nil {
+		t.Fatalf("can't create client: %v", err)
+	}
+	return c
+}
+
+// addRecord seeds the zone with the record, e.g. one of several records sharing the name and type
+func (f *fakeServer) addRecord(r cloudflare.DNSRecord) cloudflare.DNSRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextId++
+	r.ID = fmt.Sprintf("%032x", f.nextId)
+	f.records[r.ID] = r
+	return r
+}
+
+// contents returns the sorted contents of the records which have the name and type
+func (f *fakeServer) contents(name, recordType string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	contents := make([]string, 0)
+	for _, r := range f.records {
+		if r.Name == name && r.Type == recordType {
+			contents = append(contents, r.Content)
+		}
+	}
+	sort.Strings(contents)
+	return contents
+}
+
+// proxied returns the proxied state of the records which have the name and type, in order of the contents
+func (f *fakeServer) proxied(name, recordType string) []bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	records := make([]cloudflare.DNSRecord, 0)
+	for _, r := range f.records {
+		if r.Name == name && r.Type == recordType {
+			records = append(records, r)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Content < records[j].Content })
+	proxied := make([]bool, 0, len(records))
+	for _, r := range records {
+		proxied = append(proxied, r.Proxied != nil && *r.Proxied)
+	}
+	return proxied
+}
+
+func (f *fakeServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	token, authorized := f.authorize(req)
+	if !authorized {
+		writeError(w, http.StatusForbidden, 9109, "invalid access token")
+		return
+	}
+
+	paths := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(paths) == 3 && paths[0] == "user" && paths[1] == "tokens" && paths[2] == "verify":
+		if len(token) == 0 {
+			writeError(w, http.StatusBadRequest, 6003, "invalid request headers")
+			return
+		}
+		writeResult(w, http.StatusOK, cloudflare.APITokenVerifyBody{ID: "token-id", Status: f.tokens[token]})
+	case len(paths) == 1 && paths[0] == "user" && req.Method == http.MethodGet:
+		writeResult(w, http.StatusOK, cloudflare.User{ID: "user-id", Email: testAuthEmail})
+	case len(paths) == 1 && paths[0] == "zones" && req.Method == http.MethodGet:
+		zones := make([]cloudflare.Zone, 0)
+		for _, z := range f.zones {
+			if name := req.URL.Query().Get("name"); len(name) == 0 || name == z.Name {
+				zones = append(zones, z)
+			}
+		}
+		writeResult(w, http.StatusOK, zones)
+	case len(paths) == 2 && paths[0] == "zones" && req.Method == http.MethodGet:
+		z, ok := f.zones[paths[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, 1001, "invalid zone identifier")
+			return
+		}
+		writeResult(w, http.StatusOK, z)
+	case len(paths) >= 3 && paths[0] == "zones" && paths[2] == "dns_records":
+		z, ok := f.zones[paths[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, 1001, "invalid zone identifier")
+			return
+		}
+		if len(paths) == 3 {
+			f.serveRecords(w, req, z)
+			return
+		}
+		f.serveRecord(w, req, z, paths[3])
+	default:
+		writeError(w, http.StatusNotFound, 7000, "no route for that uri")
+	}
+}
+
+// authorize checks the credentials of the request, returns the api token if it is used
+func (f *fakeServer) authorize(req *http.Request) (string, bool) {
+	if bearer := req.Header.Get("Authorization"); len(bearer) > 0 {
+		token := strings.TrimPrefix(bearer, "Bearer ")
+		_, ok := f.tokens[token]
+		return token, ok
+	}
+	return "", req.Header.Get("X-Auth-Key") == testAuthKey && req.Header.Get("X-Auth-Email") == testAuthEmail
+}
+
+func (f *fakeServer) serveRecords(w http.ResponseWriter, req *http.Request, z cloudflare.Zone) {
+	switch req.Method {
+	case http.MethodGet:
+		q := req.URL.Query()
+		records := make([]cloudflare.DNSRecord, 0)
+		for _, r := range f.records {
+			if r.ZoneID != z.ID {
+				continue
+			}
+			if name := q.Get("name"); len(name) > 0 && name != r.Name {
+				continue
+			}
+			if recordType := q.Get("type"); len(recordType) > 0 && recordType != r.Type {
+				continue
+			}
+			records = append(records, r)
+		}
+		sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+		writeResult(w, http.StatusOK, records)
+	case http.MethodPost:
+		r := cloudflare.DNSRecord{}
+		if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+			writeError(w, http.StatusBadRequest, 9207, err.Error())
+			return
+		}
+		for _, existing := range f.records {
+			if existing.Name == r.Name && existing.Type == r.Type && existing.Content == r.Content {
+				writeError(w, http.StatusBadRequest, 81057, "record already exists")
+				return
+			}
+		}
+		f.nextId++
+		r.ID = fmt.Sprintf("%032x", f.nextId)
+		r.ZoneID = z.ID
+		r.ZoneName = z.Name
+		f.records[r.ID] = r
+		writeResult(w, http.StatusOK, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, 10000, "method not allowed")
+	}
+}
+
+func (f *fakeServer) serveRecord(w http.ResponseWriter, req *http.Request, z cloudflare.Zone, id string) {
+	r, ok := f.records[id]
+	if !ok || r.ZoneID != z.ID {
+		writeError(w, http.StatusNotFound, 81044, "record does not exist")
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		writeResult(w, http.StatusOK, r)
+	case http.MethodPatch:
+		patch := cloudflare.UpdateDNSRecordParams{}
+		if err := json.NewDecoder(req.Body).Decode(&patch); err != nil {
+			writeError(w, http.StatusBadRequest, 9207, err.Error())
+			return
+		}
+		if len(patch.Content) > 0 {
+			r.Content = patch.Content
+		}
+		if patch.TTL > 0 {
+			r.TTL = patch.TTL
+		}
+		if patch.Proxied != nil {
+			r.Proxied = patch.Proxied
+		}
+		f.records[id] = r
+		writeResult(w, http.StatusOK, r)
+	case http.MethodDelete:
+		delete(f.records, id)
+		writeResult(w, http.StatusOK, map[string]string{"id": id})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, 10000, "method not allowed")
+	}
+}
+
+func writeResult(w http.ResponseWriter, status int, result interface{}) {
+	body := map[string]interface{}{
+		"success":  true,
+		"errors":   []interface{}{},
+		"messages": []interface{}{},
+		"result":   result,
+	}
+	if records, ok := result.([]cloudflare.DNSRecord); ok {
+		body["result_info"] = cloudflare.ResultInfo{Page: 1, PerPage: len(records) + 1, TotalPages: 1, Count: len(records), Total: len(records)}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  false,
+		"errors":   []map[string]interface{}{{"code": code, "message": message}},
+		"messages": []interface{}{},
+		"result":   nil,
+	})
+}
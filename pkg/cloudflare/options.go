@@ -0,0 +1,63 @@
+package cloudflare
+
+import (
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"strconv"
+)
+
+// OptionKeyProxied puts the records behind the cloudflare proxy
+const OptionKeyProxied = "proxied"
+
+// ttl constraints of cloudflare, 1 means automatic.
+// minimum ttl is 30 for enterprise zones and 60 for the others, the latter is left to the api
+const (
+	ttlAutomatic = 1
+	ttlMin       = 30
+	ttlMax       = 86400
+)
+
+// proxiableRecordTypes are the record types which can be proxied among the managed ones
+var proxiableRecordTypes = map[string]bool{
+	provider.RecordTypeA:     true,
+	provider.RecordTypeAAAA:  true,
+	provider.RecordTypeCNAME: true,
+}
+
+// NormalizeOptions validates the options with the proxied and ttl constraints of cloudflare
+func (c *Client) NormalizeOptions(recordType string, ttl int, options map[string]string) (map[string]string, error) {
+	for key := range options {
+		if key != OptionKeyProxied {
+			return nil, fmt.Errorf("unknown cloudflare option %s", key)
+		}
+	}
+
+	proxied, err := parseProxied(options)
+	if err != nil {
+		return nil, err
+	}
+	if proxied && !proxiableRecordTypes[recordType] {
+		return nil, fmt.Errorf("%s record can't be proxied", recordType)
+	}
+	if proxied && normalizeTTL(ttl) != ttlAutomatic {
+		return nil, fmt.Errorf("ttl of proxied record must be automatic, got %d", ttl)
+	}
+	if ttl := normalizeTTL(ttl); ttl != ttlAutomatic && (ttl < ttlMin || ttl > ttlMax) {
+		return nil, fmt.Errorf("ttl must be between %d and %d, got %d", ttlMin, ttlMax, ttl)
+	}
+
+	return map[string]string{OptionKeyProxied: strconv.FormatBool(proxied)}, nil
+}
+
+// parseProxied returns the proxied option, which is false if not set
+func parseProxied(options map[string]string) (bool, error) {
+	value, ok := options[OptionKeyProxied]
+	if !ok {
+		return false, nil
+	}
+	proxied, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("can't parse cloudflare option %s: %w", OptionKeyProxied, err)
+	}
+	return proxied, nil
+}
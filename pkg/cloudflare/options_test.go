@@ -0,0 +1,103 @@
+package cloudflare
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/sokdak/dns-ingress/pkg/common"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+func TestClientNormalizeOptions(t *testing.T) {
+	c := &Client{}
+	tests := []struct {
+		name       string
+		recordType string
+		ttl        int
+		options    map[string]string
+		want       map[string]string
+		wantErr    string
+	}{
+		{name: "defaults to not proxied", recordType: provider.RecordTypeA, ttl: 300,
+			want: map[string]string{OptionKeyProxied: "false"}},
+		{name: "proxied with automatic ttl", recordType: provider.RecordTypeCNAME, ttl: 0,
+			options: map[string]string{OptionKeyProxied: "true"}, want: map[string]string{OptionKeyProxied: "true"}},
+		{name: "proxied with explicit automatic ttl", recordType: provider.RecordTypeAAAA, ttl: 1,
+			options: map[string]string{OptionKeyProxied: "1"}, want: map[string]string{OptionKeyProxied: "true"}},
+		{name: "proxied with ttl", recordType: provider.RecordTypeA, ttl: 300,
+			options: map[string]string{OptionKeyProxied: "true"}, wantErr: "ttl of proxied record must be automatic"},
+		{name: "proxied txt", recordType: provider.RecordTypeTXT,
+			options: map[string]string{OptionKeyProxied: "true"}, wantErr: "TXT record can't be proxied"},
+		{name: "invalid proxied", recordType: provider.RecordTypeA,
+			options: map[string]string{OptionKeyProxied: "yes"}, wantErr: "can't parse cloudflare option"},
+		{name: "unknown option", recordType: provider.RecordTypeA,
+			options: map[string]string{"weight": "1"}, wantErr: "unknown cloudflare option weight"},
+		{name: "ttl out of range", recordType: provider.RecordTypeA, ttl: 90000, wantErr: "ttl must be between"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.NormalizeOptions(tt.recordType, tt.ttl, tt.options)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeOptions: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClientProxiedRecordSet(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+	proxied := map[string]string{OptionKeyProxied: "true"}
+
+	d, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 0, proxied)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if d.Proxied == nil || !*d.Proxied || !reflect.DeepEqual(d.Options, proxied) {
+		t.Fatalf("Create: expected proxied recordset, got %v, %v", d.Proxied, d.Options)
+	}
+	if got := f.proxied("www.example.com", provider.RecordTypeA); !reflect.DeepEqual(got, []bool{true, true}) {
+		t.Fatalf("Create: expected remote records to be proxied, got %v", got)
+	}
+
+	// turning off proxy updates the kept members
+	d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 300, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Proxied == nil || *d.Proxied || d.TTL != 300 {
+		t.Fatalf("Update: expected not proxied recordset with ttl, got %v, %d", d.Proxied, d.TTL)
+	}
+	if got := f.proxied("www.example.com", provider.RecordTypeA); !reflect.DeepEqual(got, []bool{false, false}) {
+		t.Fatalf("Update: expected remote records not to be proxied, got %v", got)
+	}
+}
+
+func TestClientReportsPartiallyProxiedRecordSet(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+	for i, content := range []string{"192.0.2.1", "192.0.2.2"} {
+		f.addRecord(cloudflare.DNSRecord{ZoneID: testZoneId, ZoneName: testZoneName, Name: "www.example.com",
+			Type: provider.RecordTypeA, Content: content, TTL: 1, Proxied: common.BoolPointer(i == 0)})
+	}
+
+	d, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if d.Proxied != nil || provider.OptionsEqual(d.Options, map[string]string{OptionKeyProxied: "false"}) {
+		t.Fatalf("GetByName: expected proxied to be unknown, got %v, %v", d.Proxied, d.Options)
+	}
+}
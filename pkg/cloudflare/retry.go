@@ -0,0 +1,142 @@
+package cloudflare
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Validate checks the retry policy, delays are in seconds
+func (p RetryPolicy) Validate() error {
+	if p.MaxRetryCount < 0 || p.MinDelay < 0 || p.MaxDelay < 0 {
+		return fmt.Errorf("invalid retry policy: values must not be negative")
+	}
+	if p.MaxDelay < p.MinDelay {
+		return fmt.Errorf("invalid retry policy: max delay %d is less than min delay %d", p.MaxDelay, p.MinDelay)
+	}
+	return nil
+}
+
+// backoff returns the delay before the attempt, which starts from 1
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	return p.clamp(time.Duration(math.Pow(2, float64(attempt-1)) * float64(time.Duration(p.MinDelay)*time.Second)))
+}
+
+// clamp limits the delay to the max delay
+func (p RetryPolicy) clamp(delay time.Duration) time.Duration {
+	if maxDelay := time.Duration(p.MaxDelay) * time.Second; delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// retryTransport retries the cloudflare api requests with the retry policy.
+// rate-limited requests are always retried after the Retry-After of the response, capped by the max delay, since those are not processed,
+// while server errors and transport errors are only retried on idempotent methods.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+
+	// sleep waits for the duration, returns error if the context is done before
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newRetryTransport(base http.RoundTripper, policy RetryPolicy) *retryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &retryTransport{
+		base:   base,
+		policy: policy,
+		sleep:  sleepContext,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		// the request must not be modified, the retry is sent as a clone with the rewound body
+		r := req
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("can't retry request %s %s: body is not rewindable", req.Method, req.URL.Path)
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("can't retry request %s %s: %w", req.Method, req.URL.Path, err)
+			}
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(r)
+		if attempt >= t.policy.MaxRetryCount {
+			return resp, err
+		}
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			if !isIdempotent(req.Method) || req.Context().Err() != nil {
+				return resp, err
+			}
+			delay = t.policy.backoff(attempt + 1)
+		case resp.StatusCode == http.StatusTooManyRequests:
+			delay = t.policy.clamp(retryAfter(resp, time.Now()))
+			if delay < 0 {
+				delay = t.policy.backoff(attempt + 1)
+			}
+		case resp.StatusCode >= http.StatusInternalServerError && isIdempotent(req.Method):
+			delay = t.policy.backoff(attempt + 1)
+		default:
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if err := t.sleep(req.Context(), delay); err != nil {
+			return nil, fmt.Errorf("operation aborted during backoff: %w", err)
+		}
+	}
+}
+
+// isIdempotent reports whether the request with the method can be sent again without side effects
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses Retry-After header of the response in seconds or http date, returns negative if not present
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if len(value) == 0 {
+		return -1
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return -1
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
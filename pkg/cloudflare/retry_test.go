@@ -0,0 +1,244 @@
+package cloudflare
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/sokdak/dns-ingress/pkg/environment"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+// flakyServer responds with the statuses in order, then delegates to the next handler
+type flakyServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	header   http.Header
+	bodies   []string
+	attempts int
+}
+
+func newFlakyServer(t *testing.T, next http.Handler, header http.Header, statuses ...int) *flakyServer {
+	f := &flakyServer{statuses: statuses, header: header}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		f.mu.Lock()
+		f.attempts++
+		body, _ := io.ReadAll(req.Body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		f.bodies = append(f.bodies, string(body))
+		var status int
+		if len(f.statuses) > 0 {
+			status, f.statuses = f.statuses[0], f.statuses[1:]
+		}
+		f.mu.Unlock()
+
+		if status == 0 {
+			next.ServeHTTP(w, req)
+			return
+		}
+		for k, v := range f.header {
+			w.Header()[k] = v
+		}
+		writeError(w, status, 10000, http.StatusText(status))
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// newTestTransport returns the transport which records delays instead of sleeping
+func newTestTransport(policy RetryPolicy) (*retryTransport, *[]time.Duration) {
+	delays := &[]time.Duration{}
+	t := newRetryTransport(nil, policy)
+	t.sleep = func(ctx context.Context, d time.Duration) error {
+		*delays = append(*delays, d)
+		return nil
+	}
+	return t, delays
+}
+
+func TestRetryTransport(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeResult(w, http.StatusOK, map[string]string{})
+	})
+	policy := RetryPolicy{MaxRetryCount: 3, MinDelay: 1, MaxDelay: 3}
+
+	tests := []struct {
+		name         string
+		method       string
+		header       http.Header
+		statuses     []int
+		wantStatus   int
+		wantAttempts int
+		wantDelays   []time.Duration
+	}{
+		{name: "idempotent request retried on server error", method: http.MethodGet,
+			statuses: []int{503, 502}, wantStatus: 200, wantAttempts: 3,
+			wantDelays: []time.Duration{1 * time.Second, 2 * time.Second}},
+		{name: "delay is capped by max delay", method: http.MethodDelete,
+			statuses: []int{500, 500, 500}, wantStatus: 200, wantAttempts: 4,
+			wantDelays: []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second}},
+		{name: "gives up after max retry count", method: http.MethodGet,
+			statuses: []int{500, 500, 500, 500}, wantStatus: 500, wantAttempts: 4,
+			wantDelays: []time.Duration{1 * time.Second, 2 * time.Second, 3 * time.Second}},
+		{name: "non-idempotent request not retried on server error", method: http.MethodPost,
+			statuses: []int{500}, wantStatus: 500, wantAttempts: 1},
+		{name: "patch is not retried on server error", method: http.MethodPatch,
+			statuses: []int{502}, wantStatus: 502, wantAttempts: 1},
+		{name: "rate limited non-idempotent request retried after Retry-After", method: http.MethodPost,
+			header: http.Header{"Retry-After": []string{"2"}}, statuses: []int{429}, wantStatus: 200, wantAttempts: 2,
+			wantDelays: []time.Duration{2 * time.Second}},
+		{name: "Retry-After is capped by max delay", method: http.MethodPost,
+			header: http.Header{"Retry-After": []string{"86400"}}, statuses: []int{429}, wantStatus: 200, wantAttempts: 2,
+			wantDelays: []time.Duration{3 * time.Second}},
+		{name: "rate limited request without Retry-After uses backoff", method: http.MethodGet,
+			statuses: []int{429, 429}, wantStatus: 200, wantAttempts: 3,
+			wantDelays: []time.Duration{1 * time.Second, 2 * time.Second}},
+		{name: "client error is not retried", method: http.MethodGet,
+			statuses: []int{400}, wantStatus: 400, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFlakyServer(t, ok, tt.header, tt.statuses...)
+			transport, delays := newTestTransport(policy)
+
+			req, err := http.NewRequest(tt.method, s.URL, strings.NewReader(`{"name":"www"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := req.Body
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			resp.Body.Close()
+
+			if req.Body != body {
+				t.Errorf("expected the request not to be modified on retry")
+			}
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if s.attempts != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, s.attempts)
+			}
+			if !reflect.DeepEqual(*delays, append([]time.Duration{}, tt.wantDelays...)) {
+				t.Errorf("expected delays %v, got %v", tt.wantDelays, *delays)
+			}
+			for _, b := range s.bodies {
+				if b != `{"name":"www"}` {
+					t.Errorf("expected the body to be sent again on retry, got %q", b)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              -1,
+		"3":                             3 * time.Second,
+		"invalid":                       -1,
+		"Sun, 01 Jan 2023 00:00:05 GMT": 5 * time.Second,
+		"Sat, 31 Dec 2022 23:59:00 GMT": 0,
+	}
+	for value, want := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		if len(value) > 0 {
+			resp.Header.Set("Retry-After", value)
+		}
+		if got := retryAfter(resp, now); got != want {
+			t.Errorf("retryAfter(%q): expected %v, got %v", value, want, got)
+		}
+	}
+}
+
+func TestClientRetriesRateLimitedCreate(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t, cloudflare.Zone{ID: testZoneId, Name: testZoneName})
+	s := newFlakyServer(t, f.Server.Config.Handler, http.Header{"Retry-After": []string{"0"}}, 429, 429)
+
+	c, err := NewCloudFlareClient(Credentials{AuthKey: testAuthKey, AuthEmail: testAuthEmail}, s.Client(),
+		DefaultRateLimit*1000, RetryPolicy{MaxRetryCount: 3}, false, cloudflare.BaseURL(s.URL))
+	if err != nil {
+		t.Fatalf("NewCloudFlareClient: %v", err)
+	}
+
+	if _, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 300, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got := f.contents("www."+testZoneName, provider.RecordTypeA); !reflect.DeepEqual(got, []string{"192.0.2.1"}) {
+		t.Fatalf("expected the record to be created once, got %v", got)
+	}
+}
+
+func TestClientDoesNotRetryFailedCreate(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t, cloudflare.Zone{ID: testZoneId, Name: testZoneName})
+	s := newFlakyServer(t, f.Server.Config.Handler, nil, 0, 0, 500)
+
+	c, err := NewCloudFlareClient(Credentials{AuthKey: testAuthKey, AuthEmail: testAuthEmail}, s.Client(),
+		DefaultRateLimit*1000, RetryPolicy{MaxRetryCount: 3}, false, cloudflare.BaseURL(s.URL))
+	if err != nil {
+		t.Fatalf("NewCloudFlareClient: %v", err)
+	}
+
+	// the first requests read the zone and the current recordset, the third one creates the record and fails
+	if _, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 300, nil); err == nil {
+		t.Fatalf("Create: expected error on server error")
+	}
+	if s.attempts != 3 {
+		t.Fatalf("expected create not to be retried, got %d attempts", s.attempts)
+	}
+}
+
+func TestRetryPolicyFromEnvironment(t *testing.T) {
+	environment.LoadEnvs()
+	if got := retryPolicyFromEnvironment(); got != DefaultRetryPolicy {
+		t.Fatalf("expected DefaultRetryPolicy without envs, got %+v", got)
+	}
+
+	t.Setenv("CLOUDFLARE_CLIENT_RETRY_MAX_COUNT", "5")
+	t.Setenv("CLOUDFLARE_CLIENT_RETRY_MAX_DELAY", "10")
+	environment.LoadEnvs()
+	t.Cleanup(environment.LoadEnvs)
+
+	want := RetryPolicy{MaxRetryCount: 5, MinDelay: DefaultRetryPolicy.MinDelay, MaxDelay: 10}
+	if got := retryPolicyFromEnvironment(); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	got, err := retryPolicyFromSettings(map[string]string{SettingKeyRetryMinDelay: "2"}, want)
+	if err != nil {
+		t.Fatalf("retryPolicyFromSettings: %v", err)
+	}
+	if want.MinDelay = 2; got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if _, err := retryPolicyFromSettings(map[string]string{SettingKeyRetryMaxCount: "many"}, want); err == nil {
+		t.Fatalf("expected error on invalid setting")
+	}
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	if err := DefaultRetryPolicy.Validate(); err != nil {
+		t.Fatalf("DefaultRetryPolicy: %v", err)
+	}
+	if err := (RetryPolicy{MaxRetryCount: 1, MinDelay: 3, MaxDelay: 1}).Validate(); err == nil {
+		t.Fatalf("expected error when max delay is less than min delay")
+	}
+	if err := (RetryPolicy{MaxRetryCount: -1}).Validate(); err == nil {
+		t.Fatalf("expected error on negative retry count")
+	}
+}
@@ -15,3 +15,12 @@ func IntPointer(i int) *int {
 func Float64Pointer(f float64) *float64 {
 	return &f
 }
+
+func ContainsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2023 sokdakino.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/api/v1alpha1"
+	"github.com/sokdak/dns-ingress/pkg/builtin"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sync"
+)
+
+// BuiltinDNSReconciler indexes the recordsets of the ready domains served by the builtin dns server.
+// it runs on every replica, so the followers answer the queries and the index is rebuilt on restart.
+type BuiltinDNSReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	Index  *builtin.Index
+
+	mu sync.Mutex
+	// indexed are the recordsets indexed by the domains
+	indexed map[types.NamespacedName]indexedRecordSet
+}
+
+type indexedRecordSet struct {
+	zoneName   string
+	name       string
+	recordType string
+}
+
+//+kubebuilder:rbac:groups=dns-ingress.io,resources=domains,verbs=get;list;watch
+
+func (r *BuiltinDNSReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	domain := &v1alpha1.Domain{}
+	if err := r.Client.Get(ctx, req.NamespacedName, domain); err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("can't get domain object: %w", err)
+		}
+		domain = nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if domain == nil || domain.DeletionTimestamp != nil || domain.Status.Record == nil || !isBuiltinZone(domain) {
+		// the domain is gone or left the builtin dns server
+		return ctrl.Result{}, r.unindex(req.NamespacedName)
+	}
+	// the recordset of the domain not ready is kept, which is served until the domain gets ready again
+	if !conditions.IsTrue(domain, v1alpha1.ConditionTypeRecordSetReady) {
+		return ctrl.Result{}, nil
+	}
+
+	zoneName, _ := builtin.ZoneNameOf(domain.Status.Zone.Id)
+	current := indexedRecordSet{zoneName: zoneName, name: domain.Status.Record.Name, recordType: domain.Status.Record.Type}
+	if previous, found := r.indexed[req.NamespacedName]; found && previous != current {
+		if err := r.unindex(req.NamespacedName); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	ttl := 0
+	if domain.Status.Record.TTL != nil {
+		ttl = *domain.Status.Record.TTL
+	}
+	if _, err := r.Index.Set(zoneName, current.name, current.recordType, domain.Status.Record.Records, ttl); err != nil {
+		return ctrl.Result{}, fmt.Errorf("can't index the recordset: %w", err)
+	}
+	if r.indexed == nil {
+		r.indexed = map[types.NamespacedName]indexedRecordSet{}
+	}
+	r.indexed[req.NamespacedName] = current
+	return ctrl.Result{}, nil
+}
+
+// unindex removes the recordset indexed by the domain, the lock has to be held by the caller
+func (r *BuiltinDNSReconciler) unindex(nsn types.NamespacedName) error {
+	rs, found := r.indexed[nsn]
+	if !found {
+		return nil
+	}
+	if err := r.Index.Delete(rs.zoneName, rs.name, rs.recordType); err != nil {
+		return fmt.Errorf("can't remove the recordset from the index: %w", err)
+	}
+	delete(r.indexed, nsn)
+	return nil
+}
+
+// isBuiltinZone returns whether the zone of the domain is served by the builtin dns server
+func isBuiltinZone(domain *v1alpha1.Domain) bool {
+	if domain.Status.Zone == nil {
+		return false
+	}
+	_, ok := builtin.ZoneNameOf(domain.Status.Zone.Id)
+	return ok
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BuiltinDNSReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	needLeaderElection := false
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("builtindns").
+		For(&v1alpha1.Domain{}).
+		WithOptions(controller.Options{NeedLeaderElection: &needLeaderElection}).
+		Complete(r)
+}
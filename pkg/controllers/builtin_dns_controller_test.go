@@ -0,0 +1,87 @@
+/*
+Copyright 2023 sokdakino.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sokdak/dns-ingress/api/v1alpha1"
+	"github.com/sokdak/dns-ingress/pkg/builtin"
+	"github.com/sokdak/dns-ingress/pkg/common"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+var _ = Describe("BuiltinDNSReconciler", func() {
+	const (
+		timeout  = 10 * time.Second
+		interval = 250 * time.Millisecond
+	)
+
+	recordsOf := func(name, recordType string) func() []string {
+		return func() []string {
+			rs, err := testBuiltinIndex.Get(testBuiltinZoneName, name, recordType)
+			if err != nil || rs == nil {
+				return nil
+			}
+			return rs.Records
+		}
+	}
+
+	It("should index the recordset of the ready domain and remove it on deletion", func() {
+		domain := &v1alpha1.Domain{
+			ObjectMeta: metav1.ObjectMeta{Name: "builtin-sample", Namespace: "default"},
+			Spec: v1alpha1.DomainSpec{
+				Provider: builtin.ProviderKey,
+				Type:     provider.RecordTypeA,
+				Name:     "www",
+				Zone:     testBuiltinZoneName,
+				Records:  []string{"10.0.0.1"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, domain)).To(Succeed())
+
+		By("marking the domain ready on the builtin zone")
+		domain.Status.Zone = &v1alpha1.ZoneStatus{Name: testBuiltinZoneName, Id: builtin.ZoneIdPrefix + testBuiltinZoneName}
+		domain.Status.Record = &v1alpha1.RecordStatus{
+			Name:    "www",
+			Id:      provider.GenerateRecordSetId("www", provider.RecordTypeA),
+			Type:    provider.RecordTypeA,
+			Records: []string{"10.0.0.1"},
+			TTL:     common.IntPointer(300),
+		}
+		conditions.MarkTrue(domain, v1alpha1.ConditionTypeRecordSetReady)
+		Expect(k8sClient.Status().Update(ctx, domain)).To(Succeed())
+		Eventually(recordsOf("www", provider.RecordTypeA), timeout, interval).Should(Equal([]string{"10.0.0.1"}))
+
+		By("changing the type of the record")
+		domain.Status.Record.Type = provider.RecordTypeCNAME
+		domain.Status.Record.Id = provider.GenerateRecordSetId("www", provider.RecordTypeCNAME)
+		domain.Status.Record.Records = []string{"ingress.builtin.internal"}
+		Expect(k8sClient.Status().Update(ctx, domain)).To(Succeed())
+		Eventually(recordsOf("www", provider.RecordTypeCNAME), timeout, interval).Should(Equal([]string{"ingress.builtin.internal"}))
+		Expect(recordsOf("www", provider.RecordTypeA)()).To(BeNil())
+
+		By("deleting the domain")
+		Expect(k8sClient.Delete(ctx, domain)).To(Succeed())
+		Eventually(recordsOf("www", provider.RecordTypeCNAME), timeout, interval).Should(BeNil())
+	})
+})
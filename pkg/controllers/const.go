@@ -2,10 +2,17 @@ package controllers
 
 const (
 	LabelKeyDomainMappedIngressName = "dns-ingress.io/mapped-ingress"
+	LabelKeyDomainMappedServiceName = "dns-ingress.io/mapped-service"
 
 	AnnotationKeyIngressDnsProvider = "dns-ingress.io/service-provider"
 	AnnotationKeyDomainZone         = "dns-ingress.io/zone"
 	AnnotationKeyIngressEndpoint    = "dns-ingress.io/ingress-endpoint"
+	AnnotationKeyRecordType         = "dns-ingress.io/record-type"
+	AnnotationKeyProviderOptions    = "dns-ingress.io/provider-options"
+	AnnotationKeyAdopt              = "dns-ingress.io/adopt"
+	AnnotationKeyHostname           = "dns-ingress.io/hostname"
 
 	FinalizerDomain = "dns-ingress.io/finalizer"
+
+	IndexKeyCredentialsSecretRef = ".spec.credentialsSecretRef"
 )
@@ -0,0 +1,269 @@
+/*
+Copyright 2023 sokdakino.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/api/v1alpha1"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"reflect"
+	"sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// DNSProviderReconciler reconciles a DNSProvider object into the provider registry
+type DNSProviderReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	ProviderRegistry *provider.Registry
+
+	// secretReader reads the credentials secrets from the api server, only the metadata of the secrets are cached
+	secretReader client.Reader
+}
+
+// ClusterDNSProviderReconciler reconciles a ClusterDNSProvider object into the provider registry
+type ClusterDNSProviderReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	ProviderRegistry *provider.Registry
+
+	// secretReader reads the credentials secrets from the api server, only the metadata of the secrets are cached
+	secretReader client.Reader
+}
+
+//+kubebuilder:rbac:groups=dns-ingress.io,resources=dnsproviders,verbs=get;list;watch
+//+kubebuilder:rbac:groups=dns-ingress.io,resources=dnsproviders/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=dns-ingress.io,resources=clusterdnsproviders,verbs=get;list;watch
+//+kubebuilder:rbac:groups=dns-ingress.io,resources=clusterdnsproviders/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+func (r *DNSProviderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx).WithValues(GenerateReconcileInformationLabelKeySet(req.NamespacedName)...)
+	l.Info("start reconcile")
+	defer l.Info("end reconcile")
+
+	registryName := GenerateDNSProviderRegistryName(req.Namespace, req.Name)
+
+	// get dnsprovider object, remove from the registry if deleted
+	dnsProvider := &v1alpha1.DNSProvider{}
+	if err := r.Client.Get(ctx, req.NamespacedName, dnsProvider); err != nil {
+		if k8serrors.IsNotFound(err) {
+			r.ProviderRegistry.Disable(registryName)
+			l.Info("disabled provider since dnsprovider object has been deleted")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("can't get dnsprovider object: %w", err)
+	}
+	if dnsProvider.DeletionTimestamp != nil {
+		r.ProviderRegistry.Disable(registryName)
+		return ctrl.Result{}, nil
+	}
+
+	// credentials of the namespaced provider always come from its own namespace
+	copiedProvider := dnsProvider.DeepCopy()
+	err := enableDNSProvider(ctx, r.secretReader, r.ProviderRegistry, registryName, dnsProvider.Spec, dnsProvider.Namespace, copiedProvider)
+	if !reflect.DeepEqual(copiedProvider.Status, dnsProvider.Status) {
+		if err := r.Client.Status().Update(ctx, copiedProvider); err != nil {
+			return ctrl.Result{}, fmt.Errorf("can't update dnsprovider status: %w", err)
+		}
+	}
+	return ctrl.Result{}, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DNSProviderReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.DNSProvider{}, IndexKeyCredentialsSecretRef,
+		func(obj client.Object) []string {
+			p := obj.(*v1alpha1.DNSProvider)
+			if p.Spec.CredentialsSecretRef == nil {
+				return nil
+			}
+			return []string{types.NamespacedName{Namespace: p.Namespace, Name: p.Spec.CredentialsSecretRef.Name}.String()}
+		}); err != nil {
+		return err
+	}
+	r.secretReader = mgr.GetAPIReader()
+
+	// secrets are watched by metadata not to cache all the secrets of the cluster
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.DNSProvider{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+			providers := &v1alpha1.DNSProviderList{}
+			if err := r.Client.List(ctx, providers, client.InNamespace(obj.GetNamespace()),
+				client.MatchingFields{IndexKeyCredentialsSecretRef: client.ObjectKeyFromObject(obj).String()}); err != nil {
+				log.FromContext(ctx).Error(err, "can't list dnsproviders referring the secret")
+				return nil
+			}
+			requests := make([]reconcile.Request, 0, len(providers.Items))
+			for _, p := range providers.Items {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&p)})
+			}
+			return requests
+		}), builder.OnlyMetadata).
+		Complete(r)
+}
+
+func (r *ClusterDNSProviderReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx).WithValues(GenerateReconcileInformationLabelKeySet(req.NamespacedName)...)
+	l.Info("start reconcile")
+	defer l.Info("end reconcile")
+
+	registryName := GenerateClusterDNSProviderRegistryName(req.Name)
+
+	// get clusterdnsprovider object, remove from the registry if deleted
+	dnsProvider := &v1alpha1.ClusterDNSProvider{}
+	if err := r.Client.Get(ctx, req.NamespacedName, dnsProvider); err != nil {
+		if k8serrors.IsNotFound(err) {
+			r.ProviderRegistry.Disable(registryName)
+			l.Info("disabled provider since clusterdnsprovider object has been deleted")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("can't get clusterdnsprovider object: %w", err)
+	}
+	if dnsProvider.DeletionTimestamp != nil {
+		r.ProviderRegistry.Disable(registryName)
+		return ctrl.Result{}, nil
+	}
+
+	secretNamespace := ""
+	if dnsProvider.Spec.CredentialsSecretRef != nil {
+		secretNamespace = dnsProvider.Spec.CredentialsSecretRef.Namespace
+	}
+
+	copiedProvider := dnsProvider.DeepCopy()
+	err := enableDNSProvider(ctx, r.secretReader, r.ProviderRegistry, registryName, dnsProvider.Spec, secretNamespace, copiedProvider)
+	if !reflect.DeepEqual(copiedProvider.Status, dnsProvider.Status) {
+		if err := r.Client.Status().Update(ctx, copiedProvider); err != nil {
+			return ctrl.Result{}, fmt.Errorf("can't update clusterdnsprovider status: %w", err)
+		}
+	}
+	return ctrl.Result{}, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterDNSProviderReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &v1alpha1.ClusterDNSProvider{}, IndexKeyCredentialsSecretRef,
+		func(obj client.Object) []string {
+			p := obj.(*v1alpha1.ClusterDNSProvider)
+			if p.Spec.CredentialsSecretRef == nil {
+				return nil
+			}
+			return []string{types.NamespacedName{Namespace: p.Spec.CredentialsSecretRef.Namespace, Name: p.Spec.CredentialsSecretRef.Name}.String()}
+		}); err != nil {
+		return err
+	}
+	r.secretReader = mgr.GetAPIReader()
+
+	// secrets are watched by metadata not to cache all the secrets of the cluster
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.ClusterDNSProvider{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+			providers := &v1alpha1.ClusterDNSProviderList{}
+			if err := r.Client.List(ctx, providers,
+				client.MatchingFields{IndexKeyCredentialsSecretRef: client.ObjectKeyFromObject(obj).String()}); err != nil {
+				log.FromContext(ctx).Error(err, "can't list clusterdnsproviders referring the secret")
+				return nil
+			}
+			requests := make([]reconcile.Request, 0, len(providers.Items))
+			for _, p := range providers.Items {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&p)})
+			}
+			return requests
+		}), builder.OnlyMetadata).
+		Complete(r)
+}
+
+// enableDNSProvider builds the provider client with the settings and the credentials, then put it into the registry once verified.
+// the result is marked on the Ready condition of the setter.
+func enableDNSProvider(ctx context.Context, c client.Reader, registry *provider.Registry, registryName string,
+	spec v1alpha1.DNSProviderSpec, secretNamespace string, setter conditions.Setter) error {
+	l := log.FromContext(ctx)
+
+	settings := map[string]string{}
+	for k, v := range spec.Settings {
+		settings[k] = v
+	}
+
+	// merge credentials into the settings
+	if spec.CredentialsSecretRef != nil {
+		// the secret of the cluster-scoped provider can't be found without the namespace
+		if len(secretNamespace) == 0 {
+			registry.Disable(registryName)
+			conditions.MarkFalse(setter, v1alpha1.ConditionTypeProviderReady,
+				v1alpha1.ConditionReasonInvalidSecretRef, v1beta1.ConditionSeverityError,
+				"namespace of credentials secret %s is required", spec.CredentialsSecretRef.Name)
+			return nil
+		}
+
+		secret := &corev1.Secret{}
+		secretNsn := types.NamespacedName{Namespace: secretNamespace, Name: spec.CredentialsSecretRef.Name}
+		if err := c.Get(ctx, secretNsn, secret); err != nil {
+			if k8serrors.IsNotFound(err) {
+				registry.Disable(registryName)
+				conditions.MarkFalse(setter, v1alpha1.ConditionTypeProviderReady,
+					v1alpha1.ConditionReasonSecretNotFound, v1beta1.ConditionSeverityError,
+					"credentials secret %s is not found", secretNsn.String())
+				return nil
+			}
+			return fmt.Errorf("can't get credentials secret: %w", err)
+		}
+		for k, v := range secret.Data {
+			settings[k] = string(v)
+		}
+	}
+
+	providerClient, err := registry.Build(spec.Kind, settings)
+	if err != nil {
+		registry.Disable(registryName)
+		conditions.MarkFalse(setter, v1alpha1.ConditionTypeProviderReady,
+			v1alpha1.ConditionReasonProviderInitFailed, v1beta1.ConditionSeverityError,
+			"can't build provider %s: %s", registryName, err.Error())
+		return nil
+	}
+
+	// check credentials and permissions of the new client before it serves domains.
+	// the failure may be transient, so the previously verified client keeps serving until the new one is verified.
+	if err := provider.Verify(ctx, providerClient); err != nil {
+		_ = provider.Close(providerClient)
+		message := err.Error()
+		if _, found := registry.Get(registryName); found {
+			message = fmt.Sprintf("%s, previous client is kept", message)
+		}
+		conditions.MarkFalse(setter, v1alpha1.ConditionTypeProviderReady,
+			v1alpha1.ConditionReasonVerifyFailed, v1beta1.ConditionSeverityError,
+			"%s", message)
+		return fmt.Errorf("can't verify provider %s: %w", registryName, err)
+	}
+	registry.Put(registryName, providerClient)
+
+	l.Info("enabled provider", "provider", registryName, "kind", spec.Kind)
+	conditions.MarkTrue(setter, v1alpha1.ConditionTypeProviderReady)
+	return nil
+}
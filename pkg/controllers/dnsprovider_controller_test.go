@@ -0,0 +1,191 @@
+/*
+Copyright 2023 sokdakino.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sokdak/dns-ingress/api/v1alpha1"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("DNSProviderReconciler", func() {
+	const (
+		timeout  = 10 * time.Second
+		interval = 250 * time.Millisecond
+	)
+
+	newSecret := func(name, namespace, token string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Data:       map[string][]byte{testFakeProviderSettingName: []byte(token)},
+		}
+	}
+
+	settingsOf := func(token string) func() map[string]string {
+		return func() map[string]string {
+			v, found := testFakeProviderSettings.Load(token)
+			if !found {
+				return nil
+			}
+			return v.(map[string]string)
+		}
+	}
+
+	It("should enable the provider with the credentials and rebuild it on secret change", func() {
+		secret := newSecret("dnsprovider-credentials", "default", "first")
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+
+		dnsProvider := &v1alpha1.DNSProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: "dnsprovider-sample", Namespace: "default"},
+			Spec: v1alpha1.DNSProviderSpec{
+				Kind:                 testFakeProviderKind,
+				Settings:             map[string]string{"zone": "example.com"},
+				CredentialsSecretRef: &v1alpha1.SecretReference{Name: secret.Name},
+			},
+		}
+		Expect(k8sClient.Create(ctx, dnsProvider)).To(Succeed())
+
+		registryName := GenerateDNSProviderRegistryName(dnsProvider.Namespace, dnsProvider.Name)
+		Eventually(func() bool {
+			_, found := testProviderRegistry.Get(registryName)
+			return found
+		}, timeout, interval).Should(BeTrue())
+		Expect(settingsOf("first")()).To(HaveKeyWithValue("zone", "example.com"))
+
+		Eventually(func() bool {
+			p := &v1alpha1.DNSProvider{}
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(dnsProvider), p); err != nil {
+				return false
+			}
+			return conditions.IsTrue(p, v1alpha1.ConditionTypeProviderReady)
+		}, timeout, interval).Should(BeTrue())
+
+		By("rotating the credentials")
+		secret.Data[testFakeProviderSettingName] = []byte("second")
+		Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+		Eventually(settingsOf("second"), timeout, interval).Should(HaveKeyWithValue("zone", "example.com"))
+
+		By("deleting the provider")
+		Expect(k8sClient.Delete(ctx, dnsProvider)).To(Succeed())
+		Eventually(func() bool {
+			_, found := testProviderRegistry.Get(registryName)
+			return found
+		}, timeout, interval).Should(BeFalse())
+	})
+
+	It("should mark not ready when the credentials secret is missing", func() {
+		dnsProvider := &v1alpha1.ClusterDNSProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: "clusterdnsprovider-missing"},
+			Spec: v1alpha1.DNSProviderSpec{
+				Kind:                 testFakeProviderKind,
+				CredentialsSecretRef: &v1alpha1.SecretReference{Name: "missing", Namespace: "default"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, dnsProvider)).To(Succeed())
+
+		Eventually(func() string {
+			p := &v1alpha1.ClusterDNSProvider{}
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(dnsProvider), p); err != nil {
+				return ""
+			}
+			return conditions.GetReason(p, v1alpha1.ConditionTypeProviderReady)
+		}, timeout, interval).Should(Equal(v1alpha1.ConditionReasonSecretNotFound))
+
+		_, found := testProviderRegistry.Get(GenerateClusterDNSProviderRegistryName(dnsProvider.Name))
+		Expect(found).To(BeFalse())
+
+		By("creating the secret afterwards")
+		Expect(k8sClient.Create(ctx, newSecret("missing", "default", "cluster"))).To(Succeed())
+		Eventually(func() bool {
+			_, found := testProviderRegistry.Get(GenerateClusterDNSProviderRegistryName(dnsProvider.Name))
+			return found
+		}, timeout, interval).Should(BeTrue())
+	})
+
+	It("should mark not ready when the credentials secret of the cluster provider has no namespace", func() {
+		dnsProvider := &v1alpha1.ClusterDNSProvider{
+			ObjectMeta: metav1.ObjectMeta{Name: "clusterdnsprovider-no-namespace"},
+			Spec: v1alpha1.DNSProviderSpec{
+				Kind:                 testFakeProviderKind,
+				CredentialsSecretRef: &v1alpha1.SecretReference{Name: "credentials"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, dnsProvider)).To(Succeed())
+
+		Eventually(func() string {
+			p := &v1alpha1.ClusterDNSProvider{}
+			if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(dnsProvider), p); err != nil {
+				return ""
+			}
+			return conditions.GetReason(p, v1alpha1.ConditionTypeProviderReady)
+		}, timeout, interval).Should(Equal(v1alpha1.ConditionReasonInvalidSecretRef))
+
+		_, found := testProviderRegistry.Get(GenerateClusterDNSProviderRegistryName(dnsProvider.Name))
+		Expect(found).To(BeFalse())
+	})
+
+	It("should keep the verified client until the rebuilt one is verified", func() {
+		registry := provider.NewRegistry()
+		var verifyErr error
+		registry.Register(testFakeProviderKind, func(settings map[string]string) (provider.Client, error) {
+			return &verifyingClient{MockClient: &provider.MockClient{}, err: verifyErr}, nil
+		})
+		spec := v1alpha1.DNSProviderSpec{Kind: testFakeProviderKind}
+		dnsProvider := &v1alpha1.DNSProvider{}
+
+		By("failing the first verification")
+		verifyErr = errors.New("connection refused")
+		Expect(enableDNSProvider(ctx, nil, registry, "verified", spec, "default", dnsProvider)).NotTo(Succeed())
+		_, found := registry.Get("verified")
+		Expect(found).To(BeFalse())
+
+		By("passing the verification")
+		verifyErr = nil
+		Expect(enableDNSProvider(ctx, nil, registry, "verified", spec, "default", dnsProvider)).To(Succeed())
+		verified, found := registry.Get("verified")
+		Expect(found).To(BeTrue())
+		Expect(conditions.IsTrue(dnsProvider, v1alpha1.ConditionTypeProviderReady)).To(BeTrue())
+
+		By("failing the verification of the rebuilt client")
+		verifyErr = errors.New("connection refused")
+		Expect(enableDNSProvider(ctx, nil, registry, "verified", spec, "default", dnsProvider)).NotTo(Succeed())
+		current, found := registry.Get("verified")
+		Expect(found).To(BeTrue())
+		Expect(current).To(BeIdenticalTo(verified))
+		Expect(conditions.GetReason(dnsProvider, v1alpha1.ConditionTypeProviderReady)).To(Equal(v1alpha1.ConditionReasonVerifyFailed))
+	})
+})
+
+// verifyingClient is the provider client whose verification returns err
+type verifyingClient struct {
+	*provider.MockClient
+	err error
+}
+
+func (c *verifyingClient) Verify(_ context.Context) error {
+	return c.err
+}
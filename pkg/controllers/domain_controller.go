@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"github.com/sokdak/dns-ingress/api/v1alpha1"
 	"github.com/sokdak/dns-ingress/pkg/common"
+	"github.com/sokdak/dns-ingress/pkg/ownership"
 	"github.com/sokdak/dns-ingress/pkg/provider"
+	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/util/flowcontrol"
@@ -40,8 +42,13 @@ type DomainReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 
-	Backoff           *flowcontrol.Backoff
-	ProviderClientMap map[string]provider.Client
+	Backoff          *flowcontrol.Backoff
+	ProviderRegistry *provider.Registry
+
+	// OwnerId identifies this instance on the ownership registry
+	OwnerId string
+	// OwnershipRegistry tracks the owners of the recordsets, ownership is not tracked if nil
+	OwnershipRegistry ownership.Factory
 }
 
 //+kubebuilder:rbac:groups=dns-ingress.io,resources=domains,verbs=get;list;watch;create;update;patch;delete
@@ -49,9 +56,9 @@ type DomainReconciler struct {
 //+kubebuilder:rbac:groups=dns-ingress.io,resources=domains/finalizers,verbs=update
 
 func (r *DomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	l := log.FromContext(ctx)
-	l.Info("start reconcile", GenerateReconcileInformationLabelKeySet(req.NamespacedName))
-	defer l.Info("end reconcile", GenerateReconcileInformationLabelKeySet(req.NamespacedName))
+	l := log.FromContext(ctx).WithValues(GenerateReconcileInformationLabelKeySet(req.NamespacedName)...)
+	l.Info("start reconcile")
+	defer l.Info("end reconcile")
 
 	// get domain object
 	domain := &v1alpha1.Domain{}
@@ -63,39 +70,33 @@ func (r *DomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	}
 
 	// check provider available
-	service, found := r.ProviderClientMap[domain.Spec.Provider]
+	service, found := r.resolveProvider(domain)
 	if !found {
 		copiedDomain := domain.DeepCopy()
 		conditions.MarkFalse(copiedDomain, v1alpha1.ConditionTypeProviderLoaded,
 			v1alpha1.ConditionReasonProviderNotFound, v1beta1.ConditionSeverityError,
-			"dns provider %s not found on configuration", domain.Spec.Provider)
+			"dns provider %s not found on dnsproviders or configuration", domain.Spec.Provider)
 
-		if !reflect.DeepEqual(copiedDomain, domain) {
-			if err := r.Client.Update(ctx, domain); err != nil {
+		if !reflect.DeepEqual(copiedDomain.Status, domain.Status) {
+			if err := domain.StatusUpdate(ctx, r.Client, func(d *v1alpha1.Domain) {
+				d.Status.Conditions = copiedDomain.Status.Conditions
+			}); err != nil {
 				return ctrl.Result{}, err
 			}
 		}
-		return ctrl.Result{}, nil
+		// provider may become ready later by dnsprovider objects
+		return ctrl.Result{RequeueAfter: GetNextBackoffDuration(r.Backoff, req.NamespacedName, "Provider-Get")}, nil
 	}
+	ResetBackoff(r.Backoff, req.NamespacedName, "Provider-Get")
 
 	// if has deletionTimestamp with finalizer, delete the record
 	if domain.DeletionTimestamp != nil && controllerutil.ContainsFinalizer(domain, FinalizerDomain) {
 		if domain.Status.Record != nil && len(domain.Status.Record.Id) > 0 {
-			rs, err := service.Get(ctx, domain.Status.Record.Id, domain.Status.Zone.Id)
-			if err != nil {
+			if err := r.teardownRecordSet(ctx, service, domain); err != nil {
 				l.Error(err, "Reconciler error")
-				return ctrl.Result{RequeueAfter: GetNextBackoffDuration(r.Backoff, req.NamespacedName, "Delete-Get")}, nil
-			}
-			ResetBackoff(r.Backoff, req.NamespacedName, "Delete-Get")
-
-			// if recordset exists then try to delete
-			if rs != nil {
-				if err := service.Delete(ctx, domain.Status.Record.Id, domain.Status.Zone.Id); err != nil {
-					l.Error(err, "Reconciler error")
-					return ctrl.Result{RequeueAfter: GetNextBackoffDuration(r.Backoff, req.NamespacedName, "Delete")}, nil
-				}
-				ResetBackoff(r.Backoff, req.NamespacedName, "Delete")
+				return ctrl.Result{RequeueAfter: GetNextBackoffDuration(r.Backoff, req.NamespacedName, "Delete")}, nil
 			}
+			ResetBackoff(r.Backoff, req.NamespacedName, "Delete")
 		}
 
 		if controllerutil.RemoveFinalizer(domain, FinalizerDomain) {
@@ -118,9 +119,8 @@ func (r *DomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 	// if ProviderChanged true, teardown the record and requeue
 	if conditions.IsTrue(domain, v1alpha1.ConditionTypeProviderChanged) {
-		l.Info("provider change detected",
-			GenerateReconcileInformationLabelKeySetByDomain(domain))
-		if err := teardownRecordSet(ctx, service, domain); err != nil {
+		l.Info("provider change detected")
+		if err := r.teardownRecordSet(ctx, service, domain); err != nil {
 			l.Error(err, "Reconciler error")
 			return ctrl.Result{RequeueAfter: GetNextBackoffDuration(r.Backoff, req.NamespacedName, "ProviderChanged-Delete")}, nil
 		}
@@ -149,16 +149,16 @@ func (r *DomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 	// if status.provider is not present, copy provider to status
 	if len(domain.Status.Provider) == 0 {
-		return ctrl.Result{Requeue: true}, domain.Update(ctx, r.Client, func(d *v1alpha1.Domain) {
+		return ctrl.Result{Requeue: true}, domain.StatusUpdate(ctx, r.Client, func(d *v1alpha1.Domain) {
+			conditions.MarkTrue(d, v1alpha1.ConditionTypeProviderLoaded)
 			d.Status.Provider = d.Spec.Provider
 		})
 	}
 
 	// if ZoneChanged true, teardown the record and requeue
 	if conditions.IsTrue(domain, v1alpha1.ConditionTypeZoneChanged) {
-		l.Info("zone change detected",
-			GenerateReconcileInformationLabelKeySetByDomain(domain))
-		if err := teardownRecordSet(ctx, service, domain); err != nil {
+		l.Info("zone change detected")
+		if err := r.teardownRecordSet(ctx, service, domain); err != nil {
 			l.Error(err, "Reconciler error")
 			return ctrl.Result{RequeueAfter: GetNextBackoffDuration(r.Backoff, req.NamespacedName, "ZoneChanged-Delete")}, nil
 		}
@@ -228,9 +228,29 @@ func (r *DomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	// validate provider options before touching the record, wait for the spec to be fixed if invalid
+	options, err := provider.NormalizeOptions(service, domain.Spec.Type, domain.Spec.TTL, domain.Spec.ProviderOptions)
+	if err != nil {
+		if err := domain.StatusUpdate(ctx, r.Client, func(d *v1alpha1.Domain) {
+			conditions.MarkFalse(d, v1alpha1.ConditionTypeRecordSetReady,
+				v1alpha1.ConditionReasonInvalidProviderOptions, v1beta1.ConditionSeverityError,
+				"invalid provider options: %s", err.Error())
+		}); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if domain.Status.Record != nil &&
+		conditions.GetReason(domain, v1alpha1.ConditionTypeRecordSetReady) == v1alpha1.ConditionReasonInvalidProviderOptions {
+		// options are fixed without any change on the record, let it be marked ready again
+		return ctrl.Result{Requeue: true}, domain.StatusUpdate(ctx, r.Client, func(d *v1alpha1.Domain) {
+			conditions.MarkTrue(d, v1alpha1.ConditionTypeRecordSetRetrieved)
+		})
+	}
+
 	// if status.record is empty then try to load the record (get or create)
 	if domain.Status.Record == nil {
-		rs, err := service.GetByName(ctx, domain.Name, domain.Status.Zone.Id)
+		rs, err := service.GetByName(ctx, domain.Spec.Name, domain.Status.Zone.Id, domain.Spec.Type)
 		if err != nil {
 			if err := domain.StatusUpdate(ctx, r.Client, func(d *v1alpha1.Domain) {
 				conditions.MarkFalse(d, v1alpha1.ConditionTypeRecordSetRetrieved,
@@ -243,8 +263,13 @@ func (r *DomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		}
 		ResetBackoff(r.Backoff, req.NamespacedName, "Record-Get")
 
+		// claim the recordset before creating, the existing one is taken over only if it is allowed
+		if result, err := r.claimRecordSet(ctx, service, domain, domain.Spec.Type, rs != nil, domain.Spec.Adopt); err != nil || !result.IsZero() {
+			return result, err
+		}
+
 		if rs == nil {
-			rs, err = service.Create(ctx, domain.Spec.Name, domain.Status.Zone.Id, domain.Spec.Type, domain.Spec.Records, domain.Spec.TTL)
+			rs, err = service.Create(ctx, domain.Spec.Name, domain.Status.Zone.Id, domain.Spec.Type, domain.Spec.Records, domain.Spec.TTL, options)
 			if err != nil {
 				if err := domain.StatusUpdate(ctx, r.Client, func(d *v1alpha1.Domain) {
 					conditions.Delete(d, v1alpha1.ConditionTypeRecordSetRetrieved)
@@ -263,6 +288,7 @@ func (r *DomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		sort.Strings(rs.Records)
 		if err := domain.StatusUpdate(ctx, r.Client, func(d *v1alpha1.Domain) {
 			conditions.Delete(d, v1alpha1.ConditionTypeRecordSetRetrieved)
+			conditions.Delete(d, v1alpha1.ConditionTypeOwnershipConflict)
 			conditions.MarkTrue(d, v1alpha1.ConditionTypeRecordSetCreated)
 			d.Status.Record = &v1alpha1.RecordStatus{
 				Name:      rs.Name,
@@ -271,6 +297,9 @@ func (r *DomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 				Records:   rs.Records,
 				TTL:       common.IntPointer(rs.TTL),
 				Activated: common.BoolPointer(rs.Activated),
+				Proxied:   rs.Proxied,
+				Options:   rs.Options,
+				Owner:     r.ownerId(service),
 			}
 			d.Status.FQDN = rs.FQDN
 		}); err != nil {
@@ -280,11 +309,41 @@ func (r *DomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		ResetBackoff(r.Backoff, req.NamespacedName, "Record-K8sUpdate-Create")
 	}
 
+	// claim the recordset which has no owner on the status or another one, e.g. the owner id is changed or the domain is copied.
+	// only the recordset managed before the ownership is tracked is taken over, the others are checked as usual.
+	if r.ownershipRegistry(service) != nil && domain.Status.Record.Owner != r.OwnerId {
+		adopt := len(domain.Status.Record.Owner) == 0 || domain.Spec.Adopt
+		if result, err := r.claimRecordSet(ctx, service, domain, domain.Status.Record.Type, true, adopt); err != nil || !result.IsZero() {
+			return result, err
+		}
+		return ctrl.Result{Requeue: true}, domain.StatusUpdate(ctx, r.Client, func(d *v1alpha1.Domain) {
+			conditions.Delete(d, v1alpha1.ConditionTypeOwnershipConflict)
+			d.Status.Record.Owner = r.OwnerId
+		})
+	}
+
 	// if status.record.** and spec.** mismatched then try to update the record (update)
+	// zero ttl lets the provider decide, so it never drifts
 	sort.Strings(domain.Spec.Records)
 	if domain.Status.Record.Name != domain.Spec.Name || domain.Status.Record.Type != domain.Spec.Type ||
-		!reflect.DeepEqual(domain.Status.Record.Records, domain.Spec.Records) || *domain.Status.Record.TTL != domain.Spec.TTL {
-		rs, err := service.Update(ctx, domain.Status.Record.Id, domain.Status.Zone.Id, domain.Spec.Type, domain.Spec.Records, domain.Spec.TTL)
+		!reflect.DeepEqual(domain.Status.Record.Records, domain.Spec.Records) ||
+		(domain.Spec.TTL > 0 && *domain.Status.Record.TTL != domain.Spec.TTL) ||
+		!provider.OptionsEqual(domain.Status.Record.Options, options) {
+		// the recordset of the new type has to be claimed as well since the update merges into it
+		if domain.Status.Record.Type != domain.Spec.Type && r.ownershipRegistry(service) != nil {
+			existing, err := service.GetByName(ctx, domain.Spec.Name, domain.Status.Zone.Id, domain.Spec.Type)
+			if err != nil {
+				l.Error(err, "Reconciler error")
+				return ctrl.Result{RequeueAfter: GetNextBackoffDuration(r.Backoff, req.NamespacedName, "Record-Update-Get")}, nil
+			}
+			ResetBackoff(r.Backoff, req.NamespacedName, "Record-Update-Get")
+
+			if result, err := r.claimRecordSet(ctx, service, domain, domain.Spec.Type, existing != nil, domain.Spec.Adopt); err != nil || !result.IsZero() {
+				return result, err
+			}
+		}
+
+		rs, err := service.Update(ctx, domain.Status.Record.Id, domain.Status.Zone.Id, domain.Spec.Type, domain.Spec.Records, domain.Spec.TTL, options)
 		if err != nil {
 			if err := domain.StatusUpdate(ctx, r.Client, func(d *v1alpha1.Domain) {
 				conditions.MarkFalse(d, v1alpha1.ConditionTypeRecordSetUpdated,
@@ -312,9 +371,17 @@ func (r *DomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			return ctrl.Result{Requeue: true}, nil
 		}
 
+		// release the recordset of the previous type
+		if registry := r.ownershipRegistry(service); registry != nil && domain.Status.Record.Type != rs.Type {
+			if err := registry.Release(ctx, domain.Status.Zone.Id, domain.Status.Record.Name, domain.Status.Record.Type); err != nil {
+				l.Error(err, "Reconciler error")
+			}
+		}
+
 		// sort strings before put in the status
 		sort.Strings(rs.Records)
 		if err := domain.StatusUpdate(ctx, r.Client, func(d *v1alpha1.Domain) {
+			conditions.Delete(d, v1alpha1.ConditionTypeOwnershipConflict)
 			conditions.MarkTrue(d, v1alpha1.ConditionTypeRecordSetUpdated)
 			d.Status.Record = &v1alpha1.RecordStatus{
 				Name:      rs.Name,
@@ -323,6 +390,9 @@ func (r *DomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 				Records:   rs.Records,
 				TTL:       common.IntPointer(rs.TTL),
 				Activated: common.BoolPointer(rs.Activated),
+				Proxied:   rs.Proxied,
+				Options:   rs.Options,
+				Owner:     r.ownerId(service),
 			}
 			d.Status.FQDN = rs.FQDN
 		}); err != nil {
@@ -350,6 +420,21 @@ func (r *DomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	return ctrl.Result{}, nil
 }
 
+// resolveProvider finds the provider client of the domain.
+// DNSProvider in the same namespace takes precedence over ClusterDNSProvider, then the provider configuration.
+func (r *DomainReconciler) resolveProvider(domain *v1alpha1.Domain) (provider.Client, bool) {
+	for _, name := range []string{
+		GenerateDNSProviderRegistryName(domain.Namespace, domain.Spec.Provider),
+		GenerateClusterDNSProviderRegistryName(domain.Spec.Provider),
+		domain.Spec.Provider,
+	} {
+		if c, found := r.ProviderRegistry.Get(name); found {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *DomainReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -357,11 +442,100 @@ func (r *DomainReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
-func teardownRecordSet(ctx context.Context, service provider.Client, domain *v1alpha1.Domain) error {
-	if domain.Status.Record != nil && len(domain.Status.Record.Id) > 0 {
-		if err := service.Delete(ctx, domain.Status.Record.Id, domain.Status.Zone.Id); err != nil {
+// teardownRecordSet deletes the recordset of the domain and releases it.
+// the recordset is left as is if it is not owned by the domain.
+func (r *DomainReconciler) teardownRecordSet(ctx context.Context, service provider.Client, domain *v1alpha1.Domain) error {
+	if domain.Status.Record == nil || len(domain.Status.Record.Id) == 0 {
+		return nil
+	}
+
+	registry := r.ownershipRegistry(service)
+	if registry != nil {
+		owned := domain.Status.Record.Owner == r.OwnerId
+		if owned {
+			var err error
+			owned, err = ownership.IsOwnedBy(ctx, registry, domain.Status.Zone.Id,
+				domain.Status.Record.Name, domain.Status.Record.Type, r.owner(domain))
+			if err != nil {
+				return fmt.Errorf("can't teardown recordset: %w", err)
+			}
+		}
+		if !owned {
+			log.FromContext(ctx).Info("skipping recordset deletion since it is not owned by the domain",
+				GenerateReconcileInformationLabelKeySetByDomain(domain)...)
+			return nil
+		}
+	}
+
+	if err := service.Delete(ctx, domain.Status.Record.Id, domain.Status.Zone.Id); err != nil {
+		return fmt.Errorf("can't teardown recordset: %w", err)
+	}
+	if registry != nil {
+		if err := registry.Release(ctx, domain.Status.Zone.Id, domain.Status.Record.Name, domain.Status.Record.Type); err != nil {
 			return fmt.Errorf("can't teardown recordset: %w", err)
 		}
 	}
 	return nil
 }
+
+// claimRecordSet claims the recordset of the type for the domain.
+// on conflict, it is reported on the domain and retried after backoff since the owner may release it later.
+func (r *DomainReconciler) claimRecordSet(ctx context.Context, service provider.Client, domain *v1alpha1.Domain, recordType string, exists, adopt bool) (ctrl.Result, error) {
+	registry := r.ownershipRegistry(service)
+	if registry == nil {
+		return ctrl.Result{}, nil
+	}
+
+	nsn := client.ObjectKeyFromObject(domain)
+	err := ownership.Claim(ctx, registry, domain.Status.Zone.Id, domain.Spec.Name, recordType, r.owner(domain), exists, adopt)
+	if err == nil {
+		ResetBackoff(r.Backoff, nsn, "Record-Ownership")
+		return ctrl.Result{}, nil
+	}
+
+	log.FromContext(ctx).Error(err, "Reconciler error")
+	if err := domain.StatusUpdate(ctx, r.Client, func(d *v1alpha1.Domain) {
+		if ownership.IsConflict(err) {
+			conditions.Set(d, &v1beta1.Condition{
+				Type:    v1alpha1.ConditionTypeOwnershipConflict,
+				Status:  corev1.ConditionTrue,
+				Reason:  v1alpha1.ConditionReasonRecordNotOwned,
+				Message: err.Error(),
+			})
+			conditions.MarkFalse(d, v1alpha1.ConditionTypeRecordSetReady,
+				v1alpha1.ConditionReasonRecordNotOwned, v1beta1.ConditionSeverityError, "%s", err.Error())
+			return
+		}
+		conditions.MarkFalse(d, v1alpha1.ConditionTypeRecordSetRetrieved,
+			v1alpha1.ConditionReasonServiceAPIFailed, v1beta1.ConditionSeverityError,
+			"request failed: %s", err.Error())
+	}); err != nil {
+		log.FromContext(ctx).Error(err, "Reconciler error")
+	}
+	return ctrl.Result{RequeueAfter: GetNextBackoffDuration(r.Backoff, nsn, "Record-Ownership")}, nil
+}
+
+// ownershipRegistry returns the ownership registry on the provider,
+// nil if ownership is not tracked or the provider can't keep the ownership
+func (r *DomainReconciler) ownershipRegistry(service provider.Client) ownership.Registry {
+	if r.OwnershipRegistry == nil || !provider.SupportsOwnership(service) {
+		return nil
+	}
+	return r.OwnershipRegistry(service)
+}
+
+// ownerId returns the owner id recorded on the status, empty if ownership is not tracked on the provider
+func (r *DomainReconciler) ownerId(service provider.Client) string {
+	if r.ownershipRegistry(service) == nil {
+		return ""
+	}
+	return r.OwnerId
+}
+
+// owner returns the owner of the recordsets managed by the domain
+func (r *DomainReconciler) owner(domain *v1alpha1.Domain) ownership.Owner {
+	return ownership.Owner{
+		Id:       r.OwnerId,
+		Resource: fmt.Sprintf("domain/%s/%s", domain.Namespace, domain.Name),
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2023 sokdakino.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sokdak/dns-ingress/api/v1alpha1"
+	"github.com/sokdak/dns-ingress/pkg/memory"
+	"github.com/sokdak/dns-ingress/pkg/ownership"
+	"github.com/sokdak/dns-ingress/pkg/pihole"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("DomainReconciler", func() {
+	const (
+		testMemoryProviderName = "memory"
+		testMemoryZoneName     = "memory.internal"
+		testPiholeProviderName = "pihole"
+		testPiholeZoneName     = "lan.internal"
+		testOwnerId            = "test"
+	)
+
+	var (
+		memoryClient *memory.Client
+		piholeHosts  *fakePiholeHosts
+		reconciler   *DomainReconciler
+	)
+
+	BeforeEach(func() {
+		var err error
+		memoryClient, err = memory.NewMemoryClient(testMemoryZoneName)
+		Expect(err).NotTo(HaveOccurred())
+
+		piholeHosts = &fakePiholeHosts{}
+		piholeServer := httptest.NewServer(piholeHosts)
+		DeferCleanup(piholeServer.Close)
+		piholeClient, err := pihole.NewPiholeClient(piholeServer.URL, "secret", piholeServer.Client())
+		Expect(err).NotTo(HaveOccurred())
+
+		registry := provider.NewRegistry()
+		registry.Put(testMemoryProviderName, memoryClient)
+		registry.Put(testPiholeProviderName, piholeClient)
+		// the reconciler is driven by the specs, not to race with the specs of the other controllers updating the domain status
+		reconciler = &DomainReconciler{
+			Client:           k8sClient,
+			Scheme:           k8sClient.Scheme(),
+			Backoff:          flowcontrol.NewBackOff(time.Millisecond, time.Millisecond),
+			ProviderRegistry: registry,
+
+			OwnerId:           testOwnerId,
+			OwnershipRegistry: ownership.NewTXTRegistryFactory(ownership.DefaultTXTPrefix),
+		}
+	})
+
+	// reconcile reconciles the domain until it has nothing to do right away, then returns the latest domain
+	reconcile := func(domain *v1alpha1.Domain) *v1alpha1.Domain {
+		req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(domain)}
+		for i := 0; i < 20; i++ {
+			result, err := reconciler.Reconcile(ctx, req)
+			Expect(err).NotTo(HaveOccurred())
+			if !result.Requeue {
+				break
+			}
+		}
+		latest := &v1alpha1.Domain{}
+		Expect(k8sClient.Get(ctx, req.NamespacedName, latest)).To(Succeed())
+		return latest
+	}
+
+	newDomain := func(name string) *v1alpha1.Domain {
+		return &v1alpha1.Domain{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: v1alpha1.DomainSpec{
+				Provider: testMemoryProviderName,
+				Type:     provider.RecordTypeA,
+				Name:     name,
+				Zone:     testMemoryZoneName,
+				Records:  []string{"192.0.2.1"},
+			},
+		}
+	}
+
+	companionOf := func(domain *v1alpha1.Domain) *provider.Domain {
+		rs, err := memoryClient.GetByName(ctx, ownership.DefaultTXTPrefix+"a."+domain.Spec.Name, domain.Status.Zone.Id, provider.RecordTypeTXT)
+		Expect(err).NotTo(HaveOccurred())
+		return rs
+	}
+
+	Context("when the recordset on the status has no owner", func() {
+		It("should take over the recordset managed before the ownership is tracked", func() {
+			domain := newDomain("untracked")
+			Expect(k8sClient.Create(ctx, domain)).To(Succeed())
+			domain = reconcile(domain)
+			Expect(domain.Status.Record.Owner).To(Equal(testOwnerId))
+
+			By("forgetting the owner as the domains synced before the ownership is tracked")
+			Expect(memoryClient.Delete(ctx, companionOf(domain).Id, domain.Status.Zone.Id)).To(Succeed())
+			Expect(domain.StatusUpdate(ctx, k8sClient, func(d *v1alpha1.Domain) {
+				d.Status.Record.Owner = ""
+			})).To(Succeed())
+
+			domain = reconcile(domain)
+			Expect(domain.Status.Record.Owner).To(Equal(testOwnerId))
+			Expect(companionOf(domain)).NotTo(BeNil())
+			Expect(conditions.Has(domain, v1alpha1.ConditionTypeOwnershipConflict)).To(BeFalse())
+		})
+	})
+
+	Context("when the recordset on the status has another owner", func() {
+		It("should refuse the recordset without owner instead of taking it over", func() {
+			domain := newDomain("copied")
+			Expect(k8sClient.Create(ctx, domain)).To(Succeed())
+			domain = reconcile(domain)
+			Expect(domain.Status.Record.Owner).To(Equal(testOwnerId))
+
+			By("copying the domain from another cluster, whose records have no companion here")
+			Expect(memoryClient.Delete(ctx, companionOf(domain).Id, domain.Status.Zone.Id)).To(Succeed())
+			Expect(domain.StatusUpdate(ctx, k8sClient, func(d *v1alpha1.Domain) {
+				d.Status.Record.Owner = "other"
+			})).To(Succeed())
+
+			domain = reconcile(domain)
+			Expect(conditions.IsTrue(domain, v1alpha1.ConditionTypeOwnershipConflict)).To(BeTrue())
+			Expect(domain.Status.Record.Owner).To(Equal("other"))
+			Expect(companionOf(domain)).To(BeNil())
+		})
+	})
+
+	Context("when the provider can't keep the ownership", func() {
+		It("should manage the recordset without tracking the ownership", func() {
+			domain := &v1alpha1.Domain{
+				ObjectMeta: metav1.ObjectMeta{Name: "pihole", Namespace: "default"},
+				Spec: v1alpha1.DomainSpec{
+					Provider: testPiholeProviderName,
+					Type:     provider.RecordTypeA,
+					Name:     "nas",
+					Zone:     testPiholeZoneName,
+					Records:  []string{"192.0.2.10"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, domain)).To(Succeed())
+			domain = reconcile(domain)
+
+			Expect(conditions.IsTrue(domain, v1alpha1.ConditionTypeRecordSetReady)).To(BeTrue())
+			Expect(conditions.Has(domain, v1alpha1.ConditionTypeOwnershipConflict)).To(BeFalse())
+			Expect(domain.Status.Record.Owner).To(BeEmpty())
+			Expect(piholeHosts.list()).To(ConsistOf("192.0.2.10 nas.lan.internal"))
+
+			By("deleting the domain without the ownership check")
+			Expect(k8sClient.Delete(ctx, domain)).To(Succeed())
+			_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(domain)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(piholeHosts.list()).To(BeEmpty())
+		})
+	})
+})
+
+// fakePiholeHosts serves the local dns hosts of the pi-hole api, which is enough for A records
+type fakePiholeHosts struct {
+	mu    sync.Mutex
+	hosts []string
+}
+
+func (f *fakePiholeHosts) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	const hostsPath = "/api/config/dns/hosts/"
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/auth":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"session": map[string]interface{}{"valid": true, "sid": "sid"}})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/config/dns":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"config": map[string]interface{}{"dns": map[string]interface{}{"hosts": f.hosts}}})
+	case strings.HasPrefix(r.URL.EscapedPath(), hostsPath):
+		entry, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), hostsPath))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		hosts := make([]string, 0, len(f.hosts))
+		for _, h := range f.hosts {
+			if h != entry {
+				hosts = append(hosts, h)
+			}
+		}
+		if r.Method == http.MethodPut {
+			hosts = append(hosts, entry)
+		}
+		f.hosts = hosts
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakePiholeHosts) list() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.hosts...)
+}
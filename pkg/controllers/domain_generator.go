@@ -0,0 +1,302 @@
+/*
+Copyright 2023 sokdakino.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/api/v1alpha1"
+	"github.com/sokdak/dns-ingress/pkg/common"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"go.uber.org/multierr"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"strings"
+)
+
+// domainGenerator syncs the domains of the hosts published by the owner object, e.g. Ingress and Service.
+// the domains are labeled with the owner name and owned by it, so the garbage collector deletes them along with the owner.
+type domainGenerator struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// labelKey is the label of the domains which has the owner name
+	labelKey string
+	// namePrefix is prepended to the domain names, not to collide with the domains of other owner kinds having the same name
+	namePrefix         string
+	defaultDNSProvider string
+	defaultDomainZone  string
+}
+
+// sync creates or updates the domain of every host and record type, then deletes the dangling ones.
+// the provider, zone, options and adopt annotations of the owner are applied on the domains.
+// if no record set is given, e.g. load balancer is not assigned yet, the existing domains of the hosts are kept as is.
+func (g *domainGenerator) sync(ctx context.Context, owner client.Object, hosts []string, recordSets map[string][]string) error {
+	l := log.FromContext(ctx).WithValues(GenerateReconcileInformationLabelKeySet(client.ObjectKeyFromObject(owner))...)
+
+	// get domain resource by owner labels
+	domainObjList := &v1alpha1.DomainList{}
+	objListOpts := []client.ListOption{
+		client.MatchingLabels{g.labelKey: owner.GetName()},
+		client.InNamespace(owner.GetNamespace()),
+	}
+	if err := g.Client.List(ctx, domainObjList, objListOpts...); err != nil {
+		return fmt.Errorf("can't list domain objects: %w", err)
+	}
+	l.Info("listed actual domains", "count", len(domainObjList.Items))
+
+	// generating canonical host map, keyed by vhost and record type
+	actualHosts := map[string]*v1alpha1.Domain{}
+	for _, domain := range domainObjList.Items {
+		hostKey := GenerateDomainHostKey(provider.JoinName(domain.Spec.Name, domain.Spec.Zone), domainRecordType(&domain))
+		actualHosts[hostKey] = domain.DeepCopy()
+	}
+
+	// multierr for add/delete operations
+	errs := multierr.Combine(nil)
+
+	// sync for new vhost and existing entries
+	seenHosts := map[string]bool{}
+	desiredHosts := map[string]bool{}
+	for _, host := range hosts {
+		// hosts without name are catch-all, nothing to register; skip duplicated hosts
+		if len(host) == 0 || seenHosts[host] {
+			continue
+		}
+		seenHosts[host] = true
+
+		// if load balancer is not assigned yet, keep the existing domains as is
+		if len(recordSets) == 0 {
+			l.Info("skipping domain sync since owner has no endpoint yet", "vhost", host)
+			for k, domain := range actualHosts {
+				if provider.JoinName(domain.Spec.Name, domain.Spec.Zone) == host {
+					desiredHosts[k] = true
+				}
+			}
+			continue
+		}
+
+		for _, recordType := range SortedRecordTypes(recordSets) {
+			hostKey := GenerateDomainHostKey(host, recordType)
+			desiredHosts[hostKey] = true
+
+			domainObj, ok := actualHosts[hostKey]
+			if !ok {
+				// if not exist, create a new domain resource
+				err := g.handleDomainCreation(ctx, owner, host, recordType, recordSets[recordType])
+				errs = multierr.Append(errs, err)
+				continue
+			}
+
+			// if exists, update the domain resource
+			err := g.handleDomainUpdate(ctx, owner, domainObj, host, recordType, recordSets[recordType])
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	// sync for dangling entries
+	for host, domain := range actualHosts {
+		if desiredHosts[host] {
+			continue
+		}
+		if err := g.Delete(ctx, domain); err != nil {
+			// if already deleted, continue iterating
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			l.Error(err, "occurred error while deleting domain resource",
+				"vhost", host)
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		l.Info("deleted dangling domain",
+			"vhost", host,
+			"name", domain.Name,
+			"namespace", domain.Namespace)
+	}
+
+	// if sync has error, retry the reconcile again
+	if len(multierr.Errors(errs)) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (g *domainGenerator) handleDomainCreation(ctx context.Context, owner client.Object, vhost, recordType string, records []string) error {
+	l := log.FromContext(ctx).WithValues(GenerateReconcileInformationLabelKeySet(client.ObjectKeyFromObject(owner))...)
+	annotations := owner.GetAnnotations()
+
+	// get provider and zone from annotation
+	provider, ok := annotations[AnnotationKeyIngressDnsProvider]
+	if !ok {
+		provider = g.defaultDNSProvider
+	}
+
+	domainZone, ok := annotations[AnnotationKeyDomainZone]
+	if !ok {
+		domainZone = g.defaultDomainZone
+	}
+
+	// split the vhost into name and zone
+	name, err := SplitHost(vhost, domainZone)
+	if err != nil {
+		return fmt.Errorf("can't create domain: %w", err)
+	}
+
+	providerOptions, err := ParseProviderOptions(annotations[AnnotationKeyProviderOptions])
+	if err != nil {
+		return fmt.Errorf("can't create domain: %w", err)
+	}
+
+	// prototyping object
+	newDomain := &v1alpha1.Domain{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s%s-%s-%s", g.namePrefix, owner.GetName(), common.GenerateMD5Hash(vhost), strings.ToLower(recordType)),
+			Namespace: owner.GetNamespace(),
+			Labels:    map[string]string{g.labelKey: owner.GetName()},
+		},
+		Spec: v1alpha1.DomainSpec{
+			Provider: provider,
+			Type:     recordType,
+			Name:     name,
+			Zone:     domainZone,
+			Records:  records,
+
+			ProviderOptions: providerOptions,
+			Adopt:           annotations[AnnotationKeyAdopt] == "true",
+		},
+	}
+
+	// set controller reference
+	_ = controllerutil.SetControllerReference(owner, newDomain, g.Scheme)
+
+	// create object
+	if err := g.Client.Create(ctx, newDomain); err != nil {
+		return fmt.Errorf("can't create domain: %w", err)
+	}
+
+	l.Info("created domain resource",
+		"vhost", vhost, "provider", provider, "type", recordType, "records", records, "zone", domainZone)
+	return nil
+}
+
+func (g *domainGenerator) handleDomainUpdate(ctx context.Context, owner client.Object, domain *v1alpha1.Domain, vhost, recordType string, records []string) error {
+	l := log.FromContext(ctx).WithValues(GenerateReconcileInformationLabelKeySet(client.ObjectKeyFromObject(owner))...)
+	annotations := owner.GetAnnotations()
+
+	// get provider and zone from annotation
+	provider, ok := annotations[AnnotationKeyIngressDnsProvider]
+	if !ok {
+		provider = g.defaultDNSProvider
+	}
+
+	domainZone, ok := annotations[AnnotationKeyDomainZone]
+	if !ok {
+		domainZone = g.defaultDomainZone
+	}
+
+	// split the vhost into name and zone
+	name, err := SplitHost(vhost, domainZone)
+	if err != nil {
+		return fmt.Errorf("can't update domain: %w", err)
+	}
+
+	providerOptions, err := ParseProviderOptions(annotations[AnnotationKeyProviderOptions])
+	if err != nil {
+		return fmt.Errorf("can't update domain: %w", err)
+	}
+
+	// update object with RetryOnConflict
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		// get object
+		tmpDomainObj := &v1alpha1.Domain{}
+		tmpDomainNamespacedName := types.NamespacedName{Namespace: domain.Namespace, Name: domain.Name}
+		if err := g.Client.Get(ctx, tmpDomainNamespacedName, tmpDomainObj); err != nil {
+			return fmt.Errorf("can't RetryOnConflict; can't get object: %w", err)
+		}
+
+		// force apply
+		modifiedTmpDomainObj := tmpDomainObj.DeepCopy()
+		if modifiedTmpDomainObj.Spec.Provider != provider {
+			modifiedTmpDomainObj.Spec.Provider = provider
+		}
+
+		// the domain generated before the record type is inferred has no type yet
+		if modifiedTmpDomainObj.Spec.Type != recordType {
+			modifiedTmpDomainObj.Spec.Type = recordType
+		}
+
+		if !reflect.DeepEqual(modifiedTmpDomainObj.Spec.Records, records) {
+			modifiedTmpDomainObj.Spec.Records = records
+		}
+
+		if modifiedTmpDomainObj.Spec.Name != name {
+			modifiedTmpDomainObj.Spec.Name = name
+		}
+
+		if modifiedTmpDomainObj.Spec.Zone != domainZone {
+			modifiedTmpDomainObj.Spec.Zone = domainZone
+		}
+
+		if !reflect.DeepEqual(modifiedTmpDomainObj.Spec.ProviderOptions, providerOptions) {
+			modifiedTmpDomainObj.Spec.ProviderOptions = providerOptions
+		}
+
+		if adopt := annotations[AnnotationKeyAdopt] == "true"; modifiedTmpDomainObj.Spec.Adopt != adopt {
+			modifiedTmpDomainObj.Spec.Adopt = adopt
+		}
+
+		// update
+		if !reflect.DeepEqual(modifiedTmpDomainObj, tmpDomainObj) {
+			if err := g.Client.Update(ctx, modifiedTmpDomainObj); err != nil {
+				return fmt.Errorf("can't RetryOnConflict; can't update object: %w", err)
+			}
+			l.Info("updated domain resource",
+				"provider", fmt.Sprintf("%s -> %s", tmpDomainObj.Spec.Provider, modifiedTmpDomainObj.Spec.Provider),
+				"type", fmt.Sprintf("%s -> %s", tmpDomainObj.Spec.Type, modifiedTmpDomainObj.Spec.Type),
+				"records", fmt.Sprintf("%v -> %v", tmpDomainObj.Spec.Records, modifiedTmpDomainObj.Spec.Records),
+				"name", fmt.Sprintf("%s -> %s", tmpDomainObj.Spec.Name, modifiedTmpDomainObj.Spec.Name),
+				"zone", fmt.Sprintf("%s -> %s", tmpDomainObj.Spec.Zone, modifiedTmpDomainObj.Spec.Zone),
+				"providerOptions", fmt.Sprintf("%v -> %v", tmpDomainObj.Spec.ProviderOptions, modifiedTmpDomainObj.Spec.ProviderOptions))
+		}
+
+		return nil
+	})
+}
+
+// domainRecordType returns the record type managed by the domain.
+// the domains generated before the record type is inferred, named <owner>-<hash> without the type suffix, have no type on the spec.
+// they are adopted by the type of their record instead of being replaced by the typed ones, which would delete and recreate the live records.
+func domainRecordType(domain *v1alpha1.Domain) string {
+	if len(domain.Spec.Type) > 0 {
+		return domain.Spec.Type
+	}
+	if domain.Status.Record != nil && len(domain.Status.Record.Type) > 0 {
+		return domain.Status.Record.Type
+	}
+	if len(domain.Spec.Records) > 0 {
+		return InferRecordType(domain.Spec.Records[0])
+	}
+	return ""
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2023 sokdakino.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sokdak/dns-ingress/api/v1alpha1"
+	"github.com/sokdak/dns-ingress/pkg/common"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	v1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("IngressReconciler", func() {
+	const (
+		timeout  = 10 * time.Second
+		interval = 250 * time.Millisecond
+	)
+
+	newIngress := func(name string, annotations map[string]string, hosts ...string) *v1.Ingress {
+		pathType := v1.PathTypePrefix
+		rules := make([]v1.IngressRule, 0, len(hosts))
+		for _, host := range hosts {
+			rules = append(rules, v1.IngressRule{
+				Host: host,
+				IngressRuleValue: v1.IngressRuleValue{
+					HTTP: &v1.HTTPIngressRuleValue{
+						Paths: []v1.HTTPIngressPath{{
+							Path:     "/",
+							PathType: &pathType,
+							Backend: v1.IngressBackend{
+								Service: &v1.IngressServiceBackend{
+									Name: "backend",
+									Port: v1.ServiceBackendPort{Number: 80},
+								},
+							},
+						}},
+					},
+				},
+			})
+		}
+		return &v1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "default",
+				Annotations: annotations,
+			},
+			Spec: v1.IngressSpec{Rules: rules},
+		}
+	}
+
+	listDomains := func(ingress *v1.Ingress) func() ([]v1alpha1.Domain, error) {
+		return func() ([]v1alpha1.Domain, error) {
+			domainList := &v1alpha1.DomainList{}
+			err := k8sClient.List(ctx, domainList,
+				client.InNamespace(ingress.Namespace),
+				client.MatchingLabels{LabelKeyDomainMappedIngressName: ingress.Name})
+			return domainList.Items, err
+		}
+	}
+
+	Context("when an ingress with rules is created", func() {
+		It("should create a domain owned by the ingress for every rule", func() {
+			ingress := newIngress("ingress-defaults", nil, "www.example.com", "api.example.com")
+			Expect(k8sClient.Create(ctx, ingress)).To(Succeed())
+
+			Eventually(listDomains(ingress), timeout, interval).Should(HaveLen(2))
+
+			domains, err := listDomains(ingress)()
+			Expect(err).NotTo(HaveOccurred())
+			names := make([]string, 0, len(domains))
+			for _, domain := range domains {
+				names = append(names, domain.Spec.Name)
+				Expect(domain.Spec.Provider).To(Equal(testDefaultDNSProvider))
+				Expect(domain.Spec.Zone).To(Equal(testDefaultDomainZone))
+				Expect(domain.Spec.Records).To(Equal([]string{testDefaultIngressEndpoint}))
+
+				owner := metav1.GetControllerOf(&domain)
+				Expect(owner).NotTo(BeNil())
+				Expect(owner.Kind).To(Equal("Ingress"))
+				Expect(owner.Name).To(Equal(ingress.Name))
+				Expect(owner.UID).To(Equal(ingress.UID))
+			}
+			Expect(names).To(ConsistOf("www", "api"))
+		})
+
+		It("should prefer the annotations over the defaults", func() {
+			ingress := newIngress("ingress-annotated", map[string]string{
+				AnnotationKeyIngressDnsProvider: "other",
+				AnnotationKeyIngressEndpoint:    "192.0.2.10",
+				AnnotationKeyDomainZone:         "example.org",
+			}, "app.example.org", "example.org")
+			Expect(k8sClient.Create(ctx, ingress)).To(Succeed())
+
+			Eventually(listDomains(ingress), timeout, interval).Should(HaveLen(2))
+
+			domains, err := listDomains(ingress)()
+			Expect(err).NotTo(HaveOccurred())
+			names := make([]string, 0, len(domains))
+			for _, domain := range domains {
+				names = append(names, domain.Spec.Name)
+				Expect(domain.Spec.Provider).To(Equal("other"))
+				Expect(domain.Spec.Zone).To(Equal("example.org"))
+				Expect(domain.Spec.Records).To(Equal([]string{"192.0.2.10"}))
+			}
+			Expect(names).To(ConsistOf("app", provider.RecordNameApex))
+		})
+	})
+
+	Context("when the record type is inferred from the endpoints", func() {
+		It("should split mixed endpoints into a domain per record type", func() {
+			ingress := newIngress("ingress-mixed", map[string]string{
+				AnnotationKeyIngressEndpoint: "192.0.2.30, 2001:db8::30,192.0.2.31",
+			}, "mixed.example.com")
+			Expect(k8sClient.Create(ctx, ingress)).To(Succeed())
+
+			Eventually(listDomains(ingress), timeout, interval).Should(HaveLen(2))
+			domains, err := listDomains(ingress)()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(domains).To(ContainElements(
+				HaveField("Spec", And(
+					HaveField("Type", "A"),
+					HaveField("Records", Equal([]string{"192.0.2.30", "192.0.2.31"})))),
+				HaveField("Spec", And(
+					HaveField("Type", "AAAA"),
+					HaveField("Records", Equal([]string{"2001:db8::30"}))))))
+		})
+
+		It("should override the inferred record type with the annotation", func() {
+			ingress := newIngress("ingress-record-type", map[string]string{
+				AnnotationKeyIngressEndpoint: "192.0.2.40",
+				AnnotationKeyRecordType:      "txt",
+			}, "txt.example.com")
+			Expect(k8sClient.Create(ctx, ingress)).To(Succeed())
+
+			Eventually(listDomains(ingress), timeout, interval).Should(HaveLen(1))
+			domains, err := listDomains(ingress)()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(domains[0].Spec.Type).To(Equal("TXT"))
+			Expect(domains[0].Spec.Records).To(Equal([]string{"192.0.2.40"}))
+		})
+
+		It("should pass the provider options annotation to the domains and follow the changes", func() {
+			ingress := newIngress("ingress-provider-options", map[string]string{
+				AnnotationKeyIngressEndpoint: "192.0.2.50",
+				AnnotationKeyProviderOptions: "proxied=true",
+			}, "proxied.example.com")
+			Expect(k8sClient.Create(ctx, ingress)).To(Succeed())
+
+			Eventually(listDomains(ingress), timeout, interval).Should(HaveLen(1))
+			domains, err := listDomains(ingress)()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(domains[0].Spec.ProviderOptions).To(Equal(map[string]string{"proxied": "true"}))
+
+			By("removing the annotation")
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ingress), ingress)).To(Succeed())
+			delete(ingress.Annotations, AnnotationKeyProviderOptions)
+			Expect(k8sClient.Update(ctx, ingress)).To(Succeed())
+			Eventually(func() (map[string]string, error) {
+				domains, err := listDomains(ingress)()
+				if err != nil || len(domains) != 1 {
+					return nil, err
+				}
+				return domains[0].Spec.ProviderOptions, nil
+			}, timeout, interval).Should(BeEmpty())
+		})
+	})
+
+	Context("when the ingress has load balancer status", func() {
+		setLoadBalancer := func(ingress *v1.Ingress, lbs ...v1.IngressLoadBalancerIngress) {
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ingress), ingress)).To(Succeed())
+			ingress.Status.LoadBalancer.Ingress = lbs
+			Expect(k8sClient.Status().Update(ctx, ingress)).To(Succeed())
+		}
+
+		domainSpec := func(ingress *v1.Ingress) func() (v1alpha1.DomainSpec, error) {
+			return func() (v1alpha1.DomainSpec, error) {
+				domains, err := listDomains(ingress)()
+				if err != nil || len(domains) != 1 {
+					return v1alpha1.DomainSpec{}, err
+				}
+				return domains[0].Spec, nil
+			}
+		}
+
+		It("should use the load balancer addresses as records and follow the changes", func() {
+			ingress := newIngress("ingress-lb", nil, "lb.example.com")
+			Expect(k8sClient.Create(ctx, ingress)).To(Succeed())
+
+			setLoadBalancer(ingress, v1.IngressLoadBalancerIngress{IP: "198.51.100.2"}, v1.IngressLoadBalancerIngress{IP: "198.51.100.1"})
+			Eventually(domainSpec(ingress), timeout, interval).Should(And(
+				HaveField("Type", "A"),
+				HaveField("Records", Equal([]string{"198.51.100.1", "198.51.100.2"}))))
+
+			setLoadBalancer(ingress, v1.IngressLoadBalancerIngress{IP: "2001:db8::1"})
+			Eventually(domainSpec(ingress), timeout, interval).Should(And(
+				HaveField("Type", "AAAA"),
+				HaveField("Records", Equal([]string{"2001:db8::1"}))))
+
+			setLoadBalancer(ingress, v1.IngressLoadBalancerIngress{Hostname: "lb.elb.example.net"})
+			Eventually(domainSpec(ingress), timeout, interval).Should(And(
+				HaveField("Type", "CNAME"),
+				HaveField("Records", Equal([]string{"lb.elb.example.net"}))))
+		})
+
+		It("should prefer the endpoint annotation over the load balancer addresses", func() {
+			ingress := newIngress("ingress-lb-annotated", map[string]string{
+				AnnotationKeyIngressEndpoint: "192.0.2.20",
+			}, "lb-annotated.example.com")
+			Expect(k8sClient.Create(ctx, ingress)).To(Succeed())
+			Eventually(domainSpec(ingress), timeout, interval).Should(
+				HaveField("Records", Equal([]string{"192.0.2.20"})))
+
+			setLoadBalancer(ingress, v1.IngressLoadBalancerIngress{IP: "198.51.100.1"})
+			Consistently(domainSpec(ingress), time.Second, interval).Should(
+				HaveField("Records", Equal([]string{"192.0.2.20"})))
+		})
+	})
+
+	Context("when a domain has been generated before the record type is inferred", func() {
+		It("should adopt the domain instead of recreating it under the typed name", func() {
+			ingress := newIngress("ingress-legacy", nil, "legacy.example.com")
+			legacyDomain := &v1alpha1.Domain{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("%s-%s", ingress.Name, common.GenerateMD5Hash("legacy.example.com")),
+					Namespace: ingress.Namespace,
+					Labels:    map[string]string{LabelKeyDomainMappedIngressName: ingress.Name},
+				},
+				Spec: v1alpha1.DomainSpec{
+					Provider: testDefaultDNSProvider,
+					Name:     "legacy",
+					Zone:     testDefaultDomainZone,
+					Records:  []string{testDefaultIngressEndpoint},
+				},
+			}
+			Expect(k8sClient.Create(ctx, legacyDomain)).To(Succeed())
+			Expect(k8sClient.Create(ctx, ingress)).To(Succeed())
+
+			Eventually(func() (string, error) {
+				domain := &v1alpha1.Domain{}
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(legacyDomain), domain)
+				return domain.Spec.Type, err
+			}, timeout, interval).Should(Equal(provider.RecordTypeA))
+			Consistently(listDomains(ingress), time.Second, interval).Should(And(
+				HaveLen(1),
+				ContainElement(HaveField("Name", legacyDomain.Name))))
+		})
+	})
+
+	Context("when a rule is removed from the ingress", func() {
+		It("should delete the dangling domain", func() {
+			ingress := newIngress("ingress-shrink", nil, "a.example.com", "b.example.com")
+			Expect(k8sClient.Create(ctx, ingress)).To(Succeed())
+			Eventually(listDomains(ingress), timeout, interval).Should(HaveLen(2))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ingress), ingress)).To(Succeed())
+			ingress.Spec.Rules = ingress.Spec.Rules[:1]
+			Expect(k8sClient.Update(ctx, ingress)).To(Succeed())
+
+			Eventually(listDomains(ingress), timeout, interval).Should(HaveLen(1))
+			domains, err := listDomains(ingress)()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(domains[0].Spec.Name).To(Equal("a"))
+		})
+	})
+})
@@ -0,0 +1,109 @@
+/*
+Copyright 2023 sokdakino.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"strings"
+)
+
+// ServiceReconciler generates the domains of the hostnames annotated on the LoadBalancer services,
+// whose records are the addresses of the load balancer
+type ServiceReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	DefaultDNSProvider string
+	DefaultDomainZone  string
+}
+
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;
+//+kubebuilder:rbac:groups="",resources=services/status,verbs=get;
+
+func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx).WithValues(GenerateReconcileInformationLabelKeySet(req.NamespacedName)...)
+	l.Info("start reconcile")
+	defer l.Info("end reconcile")
+
+	// get service object
+	serviceObj := &corev1.Service{}
+	if err := r.Client.Get(ctx, req.NamespacedName, serviceObj); err != nil {
+		// if service not found, kube-gc will delete all related domain records
+		if k8serrors.IsNotFound(err) {
+			l.Info("ignoring since service object has been deleted")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf("can't get service object: %w", err)
+	}
+
+	// the domains are deleted if the service is no longer a LoadBalancer or the annotation is removed
+	hosts := make([]string, 0)
+	if serviceObj.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		for _, host := range SplitAnnotationValues(serviceObj.Annotations[AnnotationKeyHostname]) {
+			// hostnames are canonical as the ingress hosts are, to be compared with the domains
+			hosts = append(hosts, strings.ToLower(strings.TrimSuffix(host, ".")))
+		}
+	}
+	l.Info("got service hostnames", "vhosts", len(hosts))
+
+	// infer record sets from the load balancer, each record type maps to its own domain resource
+	endpoints := make([]string, 0)
+	for _, lb := range serviceObj.Status.LoadBalancer.Ingress {
+		if len(lb.IP) > 0 {
+			endpoints = append(endpoints, lb.IP)
+		}
+		if len(lb.Hostname) > 0 {
+			endpoints = append(endpoints, lb.Hostname)
+		}
+	}
+	recordSets, ignored := InferRecordSets(endpoints, serviceObj.Annotations[AnnotationKeyRecordType])
+	if len(ignored) > 0 {
+		l.Info("ignored endpoints which can't be served along with the others", "endpoints", ignored)
+	}
+	if err := r.domainGenerator().sync(ctx, serviceObj, hosts, recordSets); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Service{}).
+		Owns(&v1alpha1.Domain{}).
+		Complete(r)
+}
+
+// domainGenerator returns the generator of the domains mapped to the services
+func (r *ServiceReconciler) domainGenerator() *domainGenerator {
+	return &domainGenerator{
+		Client:             r.Client,
+		Scheme:             r.Scheme,
+		labelKey:           LabelKeyDomainMappedServiceName,
+		namePrefix:         "svc-",
+		defaultDNSProvider: r.DefaultDNSProvider,
+		defaultDomainZone:  r.DefaultDomainZone,
+	}
+}
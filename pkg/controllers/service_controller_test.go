@@ -0,0 +1,180 @@
+/*
+Copyright 2023 sokdakino.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sokdak/dns-ingress/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("ServiceReconciler", func() {
+	const (
+		timeout  = 10 * time.Second
+		interval = 250 * time.Millisecond
+	)
+
+	newService := func(name string, serviceType corev1.ServiceType, annotations map[string]string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "default",
+				Annotations: annotations,
+			},
+			Spec: corev1.ServiceSpec{
+				Type:  serviceType,
+				Ports: []corev1.ServicePort{{Name: "http", Port: 80}},
+			},
+		}
+	}
+
+	setLoadBalancer := func(service *corev1.Service, lbs ...corev1.LoadBalancerIngress) {
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(service), service)).To(Succeed())
+		service.Status.LoadBalancer.Ingress = lbs
+		Expect(k8sClient.Status().Update(ctx, service)).To(Succeed())
+	}
+
+	listDomains := func(service *corev1.Service) func() ([]v1alpha1.Domain, error) {
+		return func() ([]v1alpha1.Domain, error) {
+			domainList := &v1alpha1.DomainList{}
+			err := k8sClient.List(ctx, domainList,
+				client.InNamespace(service.Namespace),
+				client.MatchingLabels{LabelKeyDomainMappedServiceName: service.Name})
+			return domainList.Items, err
+		}
+	}
+
+	It("should create a domain owned by the service for every hostname once the load balancer is assigned", func() {
+		service := newService("service-lb", corev1.ServiceTypeLoadBalancer, map[string]string{
+			AnnotationKeyHostname: "db.example.com, cache.example.com.",
+		})
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+		Consistently(listDomains(service), time.Second, interval).Should(BeEmpty())
+
+		setLoadBalancer(service, corev1.LoadBalancerIngress{IP: "198.51.100.10"})
+		Eventually(listDomains(service), timeout, interval).Should(HaveLen(2))
+
+		domains, err := listDomains(service)()
+		Expect(err).NotTo(HaveOccurred())
+		names := make([]string, 0, len(domains))
+		for _, domain := range domains {
+			names = append(names, domain.Spec.Name)
+			Expect(domain.Spec.Provider).To(Equal(testDefaultDNSProvider))
+			Expect(domain.Spec.Zone).To(Equal(testDefaultDomainZone))
+			Expect(domain.Spec.Type).To(Equal("A"))
+			Expect(domain.Spec.Records).To(Equal([]string{"198.51.100.10"}))
+
+			owner := metav1.GetControllerOf(&domain)
+			Expect(owner).NotTo(BeNil())
+			Expect(owner.Kind).To(Equal("Service"))
+			Expect(owner.UID).To(Equal(service.UID))
+		}
+		Expect(names).To(ConsistOf("db", "cache"))
+
+		By("removing a hostname")
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(service), service)).To(Succeed())
+		service.Annotations[AnnotationKeyHostname] = "db.example.com"
+		Expect(k8sClient.Update(ctx, service)).To(Succeed())
+		Eventually(listDomains(service), timeout, interval).Should(HaveLen(1))
+	})
+
+	It("should honor the provider and zone annotations and follow the load balancer", func() {
+		service := newService("service-annotated", corev1.ServiceTypeLoadBalancer, map[string]string{
+			AnnotationKeyHostname:           "example.org,api.example.org",
+			AnnotationKeyIngressDnsProvider: "other",
+			AnnotationKeyDomainZone:         "example.org",
+		})
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+		setLoadBalancer(service, corev1.LoadBalancerIngress{Hostname: "lb.elb.example.net"})
+
+		Eventually(listDomains(service), timeout, interval).Should(HaveLen(2))
+		domains, err := listDomains(service)()
+		Expect(err).NotTo(HaveOccurred())
+		for _, domain := range domains {
+			Expect(domain.Spec.Provider).To(Equal("other"))
+			Expect(domain.Spec.Zone).To(Equal("example.org"))
+			Expect(domain.Spec.Type).To(Equal("CNAME"))
+		}
+
+		By("changing the load balancer to addresses")
+		setLoadBalancer(service, corev1.LoadBalancerIngress{IP: "198.51.100.20"}, corev1.LoadBalancerIngress{IP: "2001:db8::20"})
+		Eventually(func() ([]string, error) {
+			domains, err := listDomains(service)()
+			types := make([]string, 0, len(domains))
+			for _, domain := range domains {
+				types = append(types, domain.Spec.Type)
+			}
+			return types, err
+		}, timeout, interval).Should(ConsistOf("A", "A", "AAAA", "AAAA"))
+	})
+
+	It("should delete the domains when the service is no longer a LoadBalancer", func() {
+		service := newService("service-type", corev1.ServiceTypeLoadBalancer, map[string]string{
+			AnnotationKeyHostname: "type.example.com",
+		})
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+		setLoadBalancer(service, corev1.LoadBalancerIngress{IP: "198.51.100.30"})
+		Eventually(listDomains(service), timeout, interval).Should(HaveLen(1))
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(service), service)).To(Succeed())
+		service.Spec.Type = corev1.ServiceTypeClusterIP
+		Expect(k8sClient.Update(ctx, service)).To(Succeed())
+		Eventually(listDomains(service), timeout, interval).Should(BeEmpty())
+	})
+
+	It("should not collide with the domains of an ingress having the same name and hostname", func() {
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "default"},
+			Spec:       networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{{Host: "shared.example.com"}}},
+		}
+		Expect(k8sClient.Create(ctx, ingress)).To(Succeed())
+		service := newService("shared", corev1.ServiceTypeLoadBalancer, map[string]string{
+			AnnotationKeyHostname: "shared.example.com",
+		})
+		Expect(k8sClient.Create(ctx, service)).To(Succeed())
+		setLoadBalancer(service, corev1.LoadBalancerIngress{IP: "198.51.100.40"})
+
+		Eventually(listDomains(service), timeout, interval).Should(HaveLen(1))
+		Eventually(func() ([]v1alpha1.Domain, error) {
+			domainList := &v1alpha1.DomainList{}
+			err := k8sClient.List(ctx, domainList,
+				client.InNamespace(ingress.Namespace),
+				client.MatchingLabels{LabelKeyDomainMappedIngressName: ingress.Name})
+			return domainList.Items, err
+		}, timeout, interval).Should(HaveLen(1))
+
+		domains := &v1alpha1.DomainList{}
+		Expect(k8sClient.List(ctx, domains, client.InNamespace("default"))).To(Succeed())
+		owners := map[string]string{}
+		for _, domain := range domains.Items {
+			if owner := metav1.GetControllerOf(&domain); owner != nil && owner.Name == "shared" {
+				owners[owner.Kind] = domain.Name
+				Expect(domain.Spec.Name).To(Equal("shared"))
+			}
+		}
+		Expect(owners).To(HaveLen(2))
+		Expect(owners["Service"]).To(HavePrefix("svc-shared-"))
+		Expect(owners["Ingress"]).NotTo(Equal(owners["Service"]))
+	})
+})
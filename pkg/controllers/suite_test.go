@@ -17,7 +17,9 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -25,12 +27,15 @@ import (
 
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	dnsingressiov1alpha1 "github.com/sokdak/dns-ingress/api/v1alpha1"
+	"github.com/sokdak/dns-ingress/pkg/builtin"
+	"github.com/sokdak/dns-ingress/pkg/provider"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -40,6 +45,26 @@ import (
 var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
+var ctx context.Context
+var cancel context.CancelFunc
+
+// testProviderRegistry has the fake provider kind which keeps the settings of the latest built client by its token
+var testProviderRegistry *provider.Registry
+var testFakeProviderSettings sync.Map
+
+// testBuiltinIndex is the index of the builtin dns server serving the builtin zone
+var testBuiltinIndex *builtin.Index
+
+const (
+	testDefaultDNSProvider     = "cloudflare"
+	testDefaultIngressEndpoint = "192.0.2.1"
+	testDefaultDomainZone      = "example.com"
+
+	testFakeProviderKind        = "fake"
+	testFakeProviderSettingName = "token"
+
+	testBuiltinZoneName = "builtin.internal"
+)
 
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
@@ -50,9 +75,11 @@ func TestAPIs(t *testing.T) {
 var _ = BeforeSuite(func() {
 	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
 
+	ctx, cancel = context.WithCancel(context.TODO())
+
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
-		CRDDirectoryPaths:     []string{filepath.Join("..", "config", "crd", "bases")},
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
 		ErrorIfCRDPathMissing: true,
 	}
 
@@ -71,9 +98,71 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(k8sClient).NotTo(BeNil())
 
+	By("starting the controller manager")
+	k8sManager, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:             scheme.Scheme,
+		MetricsBindAddress: "0",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	testProviderRegistry = provider.NewRegistry()
+	testProviderRegistry.Register(testFakeProviderKind, func(settings map[string]string) (provider.Client, error) {
+		testFakeProviderSettings.Store(settings[testFakeProviderSettingName], settings)
+		return &provider.MockClient{}, nil
+	})
+
+	err = (&DNSProviderReconciler{
+		Client:           k8sManager.GetClient(),
+		Scheme:           k8sManager.GetScheme(),
+		ProviderRegistry: testProviderRegistry,
+	}).SetupWithManager(k8sManager)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = (&ClusterDNSProviderReconciler{
+		Client:           k8sManager.GetClient(),
+		Scheme:           k8sManager.GetScheme(),
+		ProviderRegistry: testProviderRegistry,
+	}).SetupWithManager(k8sManager)
+	Expect(err).NotTo(HaveOccurred())
+
+	testBuiltinIndex = builtin.NewIndex()
+	Expect(testBuiltinIndex.AddZone(testBuiltinZoneName)).To(Succeed())
+	err = (&BuiltinDNSReconciler{
+		Client: k8sManager.GetClient(),
+		Scheme: k8sManager.GetScheme(),
+		Index:  testBuiltinIndex,
+	}).SetupWithManager(k8sManager)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = (&IngressReconciler{
+		Client:                 k8sManager.GetClient(),
+		Scheme:                 k8sManager.GetScheme(),
+		DefaultDNSProvider:     testDefaultDNSProvider,
+		DefaultIngressEndpoint: testDefaultIngressEndpoint,
+		DefaultDomainZone:      testDefaultDomainZone,
+	}).SetupWithManager(k8sManager)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = (&ServiceReconciler{
+		Client:             k8sManager.GetClient(),
+		Scheme:             k8sManager.GetScheme(),
+		DefaultDNSProvider: testDefaultDNSProvider,
+		DefaultDomainZone:  testDefaultDomainZone,
+	}).SetupWithManager(k8sManager)
+	Expect(err).NotTo(HaveOccurred())
+
+	go func() {
+		defer GinkgoRecover()
+		err := k8sManager.Start(ctx)
+		Expect(err).NotTo(HaveOccurred(), "failed to run manager")
+	}()
 })
 
 var _ = AfterSuite(func() {
+	if testEnv == nil {
+		return
+	}
+	cancel()
 	By("tearing down the test environment")
 	err := testEnv.Stop()
 	Expect(err).NotTo(HaveOccurred())
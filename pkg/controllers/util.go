@@ -3,22 +3,26 @@ package controllers
 import (
 	"fmt"
 	"github.com/sokdak/dns-ingress/api/v1alpha1"
+	"github.com/sokdak/dns-ingress/pkg/provider"
 	v1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/flowcontrol"
+	"net"
+	"sort"
+	"strings"
 	"time"
 )
 
-func GenerateReconcileInformationLabelKeySet(nsn types.NamespacedName) []string {
-	return []string{"namespace", nsn.Namespace, "name", nsn.Name}
+func GenerateReconcileInformationLabelKeySet(nsn types.NamespacedName) []interface{} {
+	return []interface{}{"namespace", nsn.Namespace, "name", nsn.Name}
 }
 
-func GenerateReconcileInformationLabelKeySetByIngress(ingress *v1.Ingress) []string {
-	return []string{"namespace", ingress.Namespace, "name", ingress.Name}
+func GenerateReconcileInformationLabelKeySetByIngress(ingress *v1.Ingress) []interface{} {
+	return []interface{}{"namespace", ingress.Namespace, "name", ingress.Name}
 }
 
-func GenerateReconcileInformationLabelKeySetByDomain(domain *v1alpha1.Domain) []string {
-	return []string{"namespace", domain.Namespace, "name", domain.Name}
+func GenerateReconcileInformationLabelKeySetByDomain(domain *v1alpha1.Domain) []interface{} {
+	return []interface{}{"namespace", domain.Namespace, "name", domain.Name}
 }
 
 func GetNextBackoffDuration(backoff *flowcontrol.Backoff, req types.NamespacedName, funcName string) time.Duration {
@@ -31,3 +35,136 @@ func ResetBackoff(backoff *flowcontrol.Backoff, req types.NamespacedName, funcNa
 	backoffKey := fmt.Sprintf("%s/%s/%s", req.Namespace, req.Name, funcName)
 	backoff.Reset(backoffKey)
 }
+
+// SplitHost splits the vhost into the record name relative to the zone
+func SplitHost(vhost, zone string) (string, error) {
+	vhost = strings.TrimSuffix(vhost, ".")
+	zone = strings.TrimSuffix(zone, ".")
+	if len(zone) == 0 {
+		return "", fmt.Errorf("can't split host %s: zone is empty", vhost)
+	}
+	if vhost == zone {
+		return provider.RecordNameApex, nil
+	}
+	if !strings.HasSuffix(vhost, "."+zone) {
+		return "", fmt.Errorf("can't split host %s: not belongs to zone %s", vhost, zone)
+	}
+	return strings.TrimSuffix(vhost, "."+zone), nil
+}
+
+// InferRecordType returns the record type fits to the endpoint; A for ipv4, AAAA for ipv6 and CNAME for dns names
+func InferRecordType(endpoint string) string {
+	ip := net.ParseIP(endpoint)
+	if ip == nil {
+		return provider.RecordTypeCNAME
+	}
+	if ip.To4() != nil {
+		return provider.RecordTypeA
+	}
+	return provider.RecordTypeAAAA
+}
+
+// InferRecordSets groups the endpoints into record sets by the record type.
+// if recordType is given, it overrides the inference and all endpoints go into a single record set.
+// CNAME can't coexist with other records on the same name, so hostnames are dropped if any address exists,
+// and only the first hostname is kept since CNAME can't have multiple values. the endpoints left out are returned.
+func InferRecordSets(endpoints []string, recordType string) (map[string][]string, []string) {
+	recordSets := map[string][]string{}
+	ignored := make([]string, 0)
+	if len(endpoints) == 0 {
+		return recordSets, ignored
+	}
+
+	recordType = strings.ToUpper(strings.TrimSpace(recordType))
+	if len(recordType) > 0 {
+		recordSets[recordType] = append([]string{}, endpoints...)
+	} else {
+		for _, ep := range endpoints {
+			t := InferRecordType(ep)
+			recordSets[t] = append(recordSets[t], ep)
+		}
+		if len(recordSets) > 1 {
+			ignored = append(ignored, recordSets[provider.RecordTypeCNAME]...)
+			delete(recordSets, provider.RecordTypeCNAME)
+		}
+	}
+
+	for t, records := range recordSets {
+		sort.Strings(records)
+		records = uniqueStrings(records)
+		if t == provider.RecordTypeCNAME {
+			ignored = append(ignored, records[1:]...)
+			records = records[:1]
+		}
+		recordSets[t] = records
+	}
+	sort.Strings(ignored)
+	return recordSets, uniqueStrings(ignored)
+}
+
+// SortedRecordTypes returns the record types of the record sets in order
+func SortedRecordTypes(recordSets map[string][]string) []string {
+	types := make([]string, 0, len(recordSets))
+	for t := range recordSets {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// GenerateDomainHostKey generates the key identifying the domain by vhost and record type
+func GenerateDomainHostKey(vhost, recordType string) string {
+	return fmt.Sprintf("%s/%s", vhost, recordType)
+}
+
+// SplitAnnotationValues splits the comma separated annotation value
+func SplitAnnotationValues(value string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if len(v) > 0 {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// ParseProviderOptions parses comma separated key=value pairs, e.g. "proxied=true"
+func ParseProviderOptions(value string) (map[string]string, error) {
+	values := SplitAnnotationValues(value)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	options := make(map[string]string, len(values))
+	for _, v := range values {
+		key, val, ok := strings.Cut(v, "=")
+		key = strings.TrimSpace(key)
+		if !ok || len(key) == 0 {
+			return nil, fmt.Errorf("invalid provider option %q, must be key=value", v)
+		}
+		options[key] = strings.TrimSpace(val)
+	}
+	return options, nil
+}
+
+// uniqueStrings removes duplicates from the sorted strings
+func uniqueStrings(sorted []string) []string {
+	unique := make([]string, 0, len(sorted))
+	for _, s := range sorted {
+		if len(unique) > 0 && unique[len(unique)-1] == s {
+			continue
+		}
+		unique = append(unique, s)
+	}
+	return unique
+}
+
+// GenerateDNSProviderRegistryName generates the name of the DNSProvider in the provider registry
+func GenerateDNSProviderRegistryName(namespace, name string) string {
+	return fmt.Sprintf("dnsprovider/%s/%s", namespace, name)
+}
+
+// GenerateClusterDNSProviderRegistryName generates the name of the ClusterDNSProvider in the provider registry
+func GenerateClusterDNSProviderRegistryName(name string) string {
+	return fmt.Sprintf("clusterdnsprovider/%s", name)
+}
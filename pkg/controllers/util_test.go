@@ -0,0 +1,51 @@
+/*
+Copyright 2023 sokdakino.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+var _ = Describe("InferRecordSets", func() {
+	It("Should group the endpoints by the record type", func() {
+		recordSets, ignored := InferRecordSets([]string{"192.0.2.2", "2001:db8::1", "192.0.2.1", "192.0.2.2"}, "")
+		Expect(recordSets).To(Equal(map[string][]string{
+			provider.RecordTypeA:    {"192.0.2.1", "192.0.2.2"},
+			provider.RecordTypeAAAA: {"2001:db8::1"},
+		}))
+		Expect(ignored).To(BeEmpty())
+	})
+
+	It("Should report the hostnames dropped in favor of the addresses", func() {
+		recordSets, ignored := InferRecordSets([]string{"lb.example.net", "192.0.2.1", "lb2.example.net"}, "")
+		Expect(recordSets).To(Equal(map[string][]string{provider.RecordTypeA: {"192.0.2.1"}}))
+		Expect(ignored).To(Equal([]string{"lb.example.net", "lb2.example.net"}))
+	})
+
+	It("Should report the hostnames left out of CNAME", func() {
+		recordSets, ignored := InferRecordSets([]string{"lb2.example.net", "lb.example.net", "lb.example.net"}, "")
+		Expect(recordSets).To(Equal(map[string][]string{provider.RecordTypeCNAME: {"lb.example.net"}}))
+		Expect(ignored).To(Equal([]string{"lb2.example.net"}))
+
+		recordSets, ignored = InferRecordSets([]string{"192.0.2.1", "lb.example.net"}, "cname")
+		Expect(recordSets).To(Equal(map[string][]string{provider.RecordTypeCNAME: {"192.0.2.1"}}))
+		Expect(ignored).To(Equal([]string{"lb.example.net"}))
+	})
+})
@@ -0,0 +1,333 @@
+package coredns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const ProviderKey = "coredns"
+
+const (
+	SettingKeyEndpoint   = "endpoint"
+	SettingKeyPathPrefix = "pathPrefix"
+	SettingKeyUsername   = "username"
+	SettingKeyPassword   = "password"
+	// SettingKeyCACert is the pem encoded ca certificate verifying the https endpoint
+	SettingKeyCACert = "caCert"
+	// SettingKeyClientCert and SettingKeyClientKey are the pem encoded client certificate and its key
+	SettingKeyClientCert = "clientCert"
+	SettingKeyClientKey  = "clientKey"
+)
+
+// defaultPathPrefix is the path prefix the etcd plugin reads the records under by default
+const defaultPathPrefix = "/skydns"
+
+// DefaultDialTimeout is the timeout of connecting to the etcd member
+var DefaultDialTimeout = 30 * time.Second
+
+func init() {
+	provider.Register(ProviderKey, NewCoreDNSClientWithSettings)
+}
+
+// Config is the connection config of the etcd cluster which the coredns etcd plugin reads
+type Config struct {
+	// Endpoint is the client url of the etcd member, e.g. http://etcd:2379 or https://etcd:2379
+	Endpoint string
+	// PathPrefix is the path configured to the etcd plugin, defaults to /skydns
+	PathPrefix string
+	// Username and Password authenticates if the auth is enabled
+	Username string
+	Password string
+	// CACert, ClientCert and ClientKey are the pem encoded tls settings of the https endpoint
+	CACert     string
+	ClientCert string
+	ClientKey  string
+}
+
+// Validate checks the config and fills the defaults
+func (c *Config) Validate() error {
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return fmt.Errorf("invalid endpoint %s", c.Endpoint)
+	}
+	c.Endpoint = strings.TrimSuffix(c.Endpoint, "/")
+
+	if len(c.PathPrefix) == 0 {
+		c.PathPrefix = defaultPathPrefix
+	}
+	if !strings.HasPrefix(c.PathPrefix, "/") {
+		return fmt.Errorf("path prefix %s has to start with /", c.PathPrefix)
+	}
+	c.PathPrefix = strings.TrimSuffix(c.PathPrefix, "/")
+
+	if len(c.Username) == 0 && len(c.Password) > 0 {
+		return fmt.Errorf("username is required with password")
+	}
+
+	if (len(c.ClientCert) > 0) != (len(c.ClientKey) > 0) {
+		return fmt.Errorf("both client certificate and key are required")
+	}
+	if !c.secure() && len(c.CACert)+len(c.ClientCert) > 0 {
+		return fmt.Errorf("tls settings are given to the plain endpoint %s", c.Endpoint)
+	}
+	return nil
+}
+
+// secure tells the endpoint is connected over tls
+func (c *Config) secure() bool {
+	return strings.HasPrefix(c.Endpoint, "https://")
+}
+
+type Client struct {
+	provider.Client
+	Config Config
+
+	etcd *etcdClient
+}
+
+// NewCoreDNSClientWithSettings creates the client with the provider settings
+func NewCoreDNSClientWithSettings(settings map[string]string) (provider.Client, error) {
+	c, err := NewCoreDNSClient(Config{
+		Endpoint:   settings[SettingKeyEndpoint],
+		PathPrefix: settings[SettingKeyPathPrefix],
+		Username:   settings[SettingKeyUsername],
+		Password:   settings[SettingKeyPassword],
+		CACert:     settings[SettingKeyCACert],
+		ClientCert: settings[SettingKeyClientCert],
+		ClientKey:  settings[SettingKeyClientKey],
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewCoreDNSClient creates the client which writes the records into etcd through the v3 api
+func NewCoreDNSClient(config Config) (*Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("can't create new coredns client: %w", err)
+	}
+
+	etcd, err := newEtcdClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("can't create new coredns client: %w", err)
+	}
+	return &Client{
+		Config: config,
+		etcd:   etcd,
+	}, nil
+}
+
+// Verify checks the etcd member is reachable with the credentials
+func (c *Client) Verify(ctx context.Context) error {
+	// reading the path prefix checks the credentials and the permission of the key space
+	etcd, err := c.etcd.connect()
+	if err == nil {
+		_, err = etcd.Get(ctx, c.Config.PathPrefix, clientv3.WithCountOnly())
+	}
+	if err != nil {
+		return fmt.Errorf("can't verify etcd %s: %w", c.Config.Endpoint, err)
+	}
+	return nil
+}
+
+// Close closes the connection to etcd
+func (c *Client) Close() error {
+	return c.etcd.Close()
+}
+
+// GetZone returns the zone of the name, the zones are configured to the etcd plugin rather than stored in etcd
+func (c *Client) GetZone(_ context.Context, zoneName string) (*provider.Zone, error) {
+	name := strings.ToLower(strings.TrimSuffix(zoneName, "."))
+	if len(name) == 0 {
+		return nil, fmt.Errorf("can't GetZone: zone name is empty")
+	}
+	return &provider.Zone{
+		Id:        name,
+		Name:      name,
+		Activated: true,
+	}, nil
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	values, err := c.getValues(ctx, name, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	return convertValues(name, zoneId, recordType, values), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Create: no records given for %s", name)
+	}
+	services, err := newServices(c.namePath(name, zoneId), recordType, records, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	values, err := c.getValues(ctx, name, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	if current := convertValues(name, zoneId, recordType, values); current != nil {
+		return nil, fmt.Errorf("can't Create: recordset %s %s already exists", current.FQDN, recordType)
+	}
+
+	if err := c.apply(ctx, nil, services); err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	return c.GetByName(ctx, name, zoneId, recordType)
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	name, currentType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	values, err := c.getValues(ctx, name, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	current := make([]value, 0, len(values))
+	for _, v := range values {
+		if v.recordType == currentType {
+			current = append(current, v)
+		}
+	}
+	if len(current) == 0 {
+		return nil, nil
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Update: no records given for %s", name)
+	}
+	services, err := newServices(c.namePath(name, zoneId), recordType, records, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	// the current values are replaced in a single txn, which fails if any of them has been modified meanwhile
+	if err := c.apply(ctx, current, services); err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	return c.GetByName(ctx, name, zoneId, recordType)
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	values, err := c.getValues(ctx, name, zoneId)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	ops := make([]clientv3.Op, 0, len(values))
+	for _, v := range values {
+		if v.recordType == recordType {
+			ops = append(ops, clientv3.OpDelete(v.key))
+		}
+	}
+	// deleting the recordset which doesn't exist succeeds
+	if len(ops) == 0 {
+		return nil
+	}
+
+	etcd, err := c.etcd.connect()
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	if _, err := etcd.Txn(ctx).Then(ops...).Commit(); err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	return nil
+}
+
+// namePath returns the key of the name in the zone
+func (c *Client) namePath(name, zoneName string) string {
+	return namePath(c.Config.PathPrefix, provider.JoinName(name, zoneName))
+}
+
+// getValues returns all the values stored for the name
+func (c *Client) getValues(ctx context.Context, name, zoneName string) ([]value, error) {
+	path := c.namePath(name, zoneName)
+	// the range covers the name path itself and its children
+	etcd, err := c.etcd.connect()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := etcd.Get(ctx, path, clientv3.WithRange(clientv3.GetPrefixRangeEnd(path+"/")))
+	if err != nil {
+		return nil, fmt.Errorf("can't get keys of %s: %w", path, err)
+	}
+	return parseValues(path, resp.Kvs), nil
+}
+
+// apply replaces the current values with the services in a single txn.
+// the keys of the current values are compared not modified, and the others not existing.
+func (c *Client) apply(ctx context.Context, current []value, services map[string]Service) error {
+	compares := make([]clientv3.Cmp, 0, len(current)+len(services))
+	ops := make([]clientv3.Op, 0, len(current)+len(services))
+
+	revisions := make(map[string]int64, len(current))
+	for _, v := range current {
+		revisions[v.key] = v.modRevision
+		compares = append(compares, notModified(v.key, v.modRevision))
+		// etcd refuses the txn which puts and deletes the same key
+		if _, ok := services[v.key]; !ok {
+			ops = append(ops, clientv3.OpDelete(v.key))
+		}
+	}
+	keys := make([]string, 0, len(services))
+	for key := range services {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := services[key]
+		if _, ok := revisions[key]; !ok {
+			compares = append(compares, notExists(key))
+		}
+		b, err := json.Marshal(&s)
+		if err != nil {
+			return fmt.Errorf("can't encode service: %w", err)
+		}
+		ops = append(ops, clientv3.OpPut(key, string(b)))
+	}
+
+	etcd, err := c.etcd.connect()
+	if err != nil {
+		return err
+	}
+	resp, err := etcd.Txn(ctx).If(compares...).Then(ops...).Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return fmt.Errorf("keys have been changed concurrently")
+	}
+	return nil
+}
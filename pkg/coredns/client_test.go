@@ -0,0 +1,337 @@
+package coredns
+
+import (
+	"context"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+const testZoneName = "example.com"
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f := newTestEtcd(t, nil)
+	c := f.newClient(t, Config{Endpoint: f.Endpoint})
+
+	z, err := c.GetZone(ctx, "Example.COM.")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: testZoneName, Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: expected zone %s, got %+v", testZoneName, z)
+	}
+
+	d, err := c.GetByName(ctx, "www", z.Id, provider.RecordTypeA)
+	if err != nil || d != nil {
+		t.Fatalf("GetByName: expected nothing before create, got %v, %v", d, err)
+	}
+
+	d, err = c.Create(ctx, "www", z.Id, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("www", provider.RecordTypeA),
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.0.2.1", "192.0.2.2"},
+		TTL:       ttlDefault,
+		ZoneId:    testZoneName,
+		ZoneName:  testZoneName,
+		FQDN:      "www.example.com.",
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+
+	// every value is a service under the reversed path, which the etcd plugin reads
+	want := []string{"/skydns/com/example/www/dns-ingress-a-0", "/skydns/com/example/www/dns-ingress-a-1"}
+	if got := f.keys(t, "/skydns/"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Create: expected keys %v, got %v", want, got)
+	}
+	if got := f.get(t, want[0]); got != `{"host":"192.0.2.1","ttl":300}` {
+		t.Fatalf("Create: expected skydns service, got %s", got)
+	}
+
+	got, err := f.newClient(t, Config{Endpoint: f.Endpoint}).Get(ctx, d.Id, z.Id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Get: expected %+v, got %+v", expected, got)
+	}
+
+	// all the values are replaced in a single txn
+	revision := f.revision(t)
+	d, err = c.Update(ctx, d.Id, z.Id, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if want := []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}; !reflect.DeepEqual(d.Records, want) || d.TTL != 60 {
+		t.Fatalf("Update: expected records %v with ttl 60, got %v with ttl %d", want, d.Records, d.TTL)
+	}
+	if got := f.revision(t); got != revision+1 {
+		t.Fatalf("Update: expected a single txn, got %d revisions", got-revision)
+	}
+
+	d, err = c.Update(ctx, d.Id, z.Id, provider.RecordTypeA, []string{"192.0.2.5"}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := f.keys(t, "/skydns/"); !reflect.DeepEqual(got, []string{"/skydns/com/example/www/dns-ingress-a-0"}) {
+		t.Fatalf("Update: expected stale values removed, got %v", got)
+	}
+
+	if err := c.Delete(ctx, d.Id, z.Id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.keys(t, "/skydns/"); len(got) != 0 {
+		t.Fatalf("Delete: expected no keys, got %v", got)
+	}
+
+	d, err = c.Get(ctx, d.Id, z.Id)
+	if err != nil || d != nil {
+		t.Fatalf("Get: expected nothing after delete, got %v, %v", d, err)
+	}
+	if err := c.Delete(ctx, expected.Id, z.Id); err != nil {
+		t.Fatalf("Delete: expected no error for missing recordset, got %v", err)
+	}
+	if d, err := c.Update(ctx, expected.Id, z.Id, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil || d != nil {
+		t.Fatalf("Update: expected nothing for missing recordset, got %v, %v", d, err)
+	}
+}
+
+func TestClientRecordSetIsolatedByType(t *testing.T) {
+	ctx := context.Background()
+	f := newTestEtcd(t, nil)
+	c := f.newClient(t, Config{Endpoint: f.Endpoint})
+
+	// the service stored at the name path itself, and the one of the subdomain
+	f.put(t, "/skydns/com/example/www", `{"host":"2001:db8::1","ttl":120}`)
+	f.put(t, "/skydns/com/example/www/api", `{"host":"192.0.2.9"}`)
+	a, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !reflect.DeepEqual(a.Records, []string{"192.0.2.1"}) {
+		t.Fatalf("Create: expected A records without the subdomain, got %v", a.Records)
+	}
+
+	aaaa, err := c.GetByName(ctx, "www", testZoneName, provider.RecordTypeAAAA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(aaaa.Records, []string{"2001:db8::1"}) || aaaa.TTL != 120 {
+		t.Fatalf("GetByName: expected only AAAA records, got %+v", aaaa)
+	}
+
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeAAAA, []string{"2001:db8::2"}, 0, nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Create: expected error for existing recordset, got %v", err)
+	}
+
+	if err := c.Delete(ctx, a.Id, testZoneName); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.keys(t, "/skydns/"); !reflect.DeepEqual(got, []string{"/skydns/com/example/www", "/skydns/com/example/www/api"}) {
+		t.Fatalf("Delete: expected the other keys untouched, got %v", got)
+	}
+}
+
+func TestClientUpdateChangesType(t *testing.T) {
+	ctx := context.Background()
+	f := newTestEtcd(t, nil)
+	c := f.newClient(t, Config{Endpoint: f.Endpoint})
+
+	d, err := c.Create(ctx, "@", testZoneName, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	d, err = c.Update(ctx, d.Id, testZoneName, provider.RecordTypeCNAME, []string{"lb.example.net."}, 120, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("@", provider.RecordTypeCNAME),
+		Name:      "@",
+		Type:      provider.RecordTypeCNAME,
+		Records:   []string{"lb.example.net"},
+		TTL:       120,
+		ZoneId:    testZoneName,
+		ZoneName:  testZoneName,
+		FQDN:      "example.com.",
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Update: expected %+v, got %+v", expected, d)
+	}
+	if got := f.keys(t, "/skydns/"); !reflect.DeepEqual(got, []string{"/skydns/com/example/dns-ingress-cname-0"}) {
+		t.Fatalf("Update: expected A values replaced with CNAME, got %v", got)
+	}
+	if a, err := c.GetByName(ctx, "@", testZoneName, provider.RecordTypeA); err != nil || a != nil {
+		t.Fatalf("GetByName: expected no A recordset, got %v, %v", a, err)
+	}
+}
+
+func TestClientUpdateConflict(t *testing.T) {
+	ctx := context.Background()
+	f := newTestEtcd(t, nil)
+	c := f.newClient(t, Config{Endpoint: f.Endpoint})
+
+	d, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	values, err := c.getValues(ctx, "www", testZoneName)
+	if err != nil {
+		t.Fatalf("getValues: %v", err)
+	}
+	// the value is modified after it has been read
+	f.put(t, "/skydns/com/example/www/dns-ingress-a-0", `{"host":"192.0.2.9"}`)
+	services, _ := newServices("/skydns/com/example/www", provider.RecordTypeA, []string{"192.0.2.2"}, 0)
+	if err := c.apply(ctx, values, services); err == nil {
+		t.Fatalf("apply: expected conflict with the concurrent modification")
+	}
+	if got := f.get(t, "/skydns/com/example/www/dns-ingress-a-0"); got != `{"host":"192.0.2.9"}` {
+		t.Fatalf("apply: expected the concurrent modification kept, got %s", got)
+	}
+
+	if _, err := c.Update(ctx, d.Id, testZoneName, provider.RecordTypeA, []string{"192.0.2.2"}, 0, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+}
+
+func TestClientTXTRecordSet(t *testing.T) {
+	ctx := context.Background()
+	f := newTestEtcd(t, nil)
+	c := f.newClient(t, Config{Endpoint: f.Endpoint})
+
+	records := []string{`heritage=dns-ingress,"quoted\"`, "v=spf1 -all"}
+	d, err := c.Create(ctx, "_owner", testZoneName, provider.RecordTypeTXT, records, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, records) {
+		t.Fatalf("Create: expected records %v, got %v", records, d.Records)
+	}
+	if got := f.get(t, "/skydns/com/example/_owner/dns-ingress-txt-1"); got != `{"text":"v=spf1 -all","ttl":300}` {
+		t.Fatalf("Create: expected skydns text service, got %s", got)
+	}
+
+	if _, err := c.Create(ctx, "mx", testZoneName, "MX", []string{"10 mail.example.com"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for unsupported record type")
+	}
+	if _, err := c.Create(ctx, "v6", testZoneName, provider.RecordTypeA, []string{"2001:db8::1"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for AAAA record given as A")
+	}
+}
+
+func TestClientAuthentication(t *testing.T) {
+	ctx := context.Background()
+	f := newTestEtcd(t, nil)
+	f.enableAuth(t)
+
+	if err := provider.Verify(ctx, f.newClient(t, Config{Endpoint: f.Endpoint})); err == nil {
+		t.Fatalf("Verify: expected error without credentials")
+	}
+
+	c := f.newClient(t, Config{Endpoint: f.Endpoint, Username: testUsername, Password: testPassword})
+	if err := provider.Verify(ctx, c); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	c = f.newClient(t, Config{Endpoint: f.Endpoint, Username: testUsername, Password: "wrong"})
+	if err := provider.Verify(ctx, c); err == nil {
+		t.Fatalf("Verify: expected error for wrong password")
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	ctx := context.Background()
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, "etcd", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, testUsername, x509.ExtKeyUsageClientAuth)
+
+	dir := t.TempDir()
+	files := map[string]string{"ca.pem": ca.cert, "server.pem": serverCert, "server-key.pem": serverKey}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("can't write %s: %v", name, err)
+		}
+	}
+	f := newTestEtcd(t, func(cfg *embed.Config) {
+		cfg.ListenClientUrls[0].Scheme = "https"
+		cfg.ClientTLSInfo = transport.TLSInfo{
+			CertFile:       filepath.Join(dir, "server.pem"),
+			KeyFile:        filepath.Join(dir, "server-key.pem"),
+			TrustedCAFile:  filepath.Join(dir, "ca.pem"),
+			ClientCertAuth: true,
+		}
+	})
+
+	c := f.newClient(t, Config{Endpoint: f.Endpoint, CACert: ca.cert, ClientCert: clientCert, ClientKey: clientKey})
+	if err := provider.Verify(ctx, c); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// the member requires the client certificate, and the server certificate is not trusted by the system roots
+	for _, config := range []Config{
+		{Endpoint: f.Endpoint, CACert: ca.cert},
+		{Endpoint: f.Endpoint, ClientCert: clientCert, ClientKey: clientKey},
+	} {
+		ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		err := provider.Verify(ctx, f.newClient(t, config))
+		cancel()
+		if err == nil {
+			t.Fatalf("Verify: expected error for tls config %+v", config)
+		}
+	}
+}
+
+func TestNewCoreDNSClientWithSettings(t *testing.T) {
+	c, err := NewCoreDNSClientWithSettings(map[string]string{
+		SettingKeyEndpoint:   "http://etcd.kube-system:2379/",
+		SettingKeyPathPrefix: "/coredns/",
+	})
+	if err != nil {
+		t.Fatalf("NewCoreDNSClientWithSettings: %v", err)
+	}
+	expected := Config{Endpoint: "http://etcd.kube-system:2379", PathPrefix: "/coredns"}
+	if got := c.(*Client).Config; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected config %+v, got %+v", expected, got)
+	}
+	if got := c.(*Client).namePath("*.apps", "Example.com"); got != "/coredns/com/example/apps/*" {
+		t.Fatalf("expected reversed path, got %s", got)
+	}
+
+	for _, invalid := range []map[string]string{
+		{},
+		{SettingKeyEndpoint: "etcd:2379"},
+		{SettingKeyEndpoint: "http://etcd:2379", SettingKeyPathPrefix: "skydns"},
+		{SettingKeyEndpoint: "http://etcd:2379", SettingKeyPassword: testPassword},
+		{SettingKeyEndpoint: "http://etcd:2379", SettingKeyCACert: "ca"},
+		{SettingKeyEndpoint: "https://etcd:2379", SettingKeyClientCert: "cert"},
+		{SettingKeyEndpoint: "https://etcd:2379", SettingKeyCACert: "ca"},
+	} {
+		if _, err := NewCoreDNSClientWithSettings(invalid); err == nil {
+			t.Fatalf("NewCoreDNSClientWithSettings: expected error for settings %v", invalid)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package coredns
+
+import (
+	"testing"
+
+	"github.com/sokdak/dns-ingress/pkg/provider/providertest"
+)
+
+func TestConformance(t *testing.T) {
+	providertest.RunConformance(t, func(t *testing.T) providertest.Fixture {
+		f := newTestEtcd(t, nil)
+		return providertest.Fixture{Client: f.newClient(t, Config{Endpoint: f.Endpoint}), ZoneName: testZoneName}
+	})
+}
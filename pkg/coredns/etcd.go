@@ -0,0 +1,101 @@
+package coredns
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sync"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// notExists compares the key doesn't exist
+func notExists(key string) clientv3.Cmp {
+	return clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+}
+
+// notModified compares the key is not modified since the revision
+func notModified(key string, revision int64) clientv3.Cmp {
+	return clientv3.Compare(clientv3.ModRevision(key), "=", revision)
+}
+
+// etcdClient connects to the etcd member by the first request rather than on creation,
+// since the v3 client authenticates while connecting, which would fail the creation if the member is not reachable yet.
+type etcdClient struct {
+	config clientv3.Config
+
+	mu     sync.Mutex
+	client *clientv3.Client
+}
+
+func newEtcdClient(config Config) (*etcdClient, error) {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &etcdClient{
+		config: clientv3.Config{
+			Endpoints:   []string{config.Endpoint},
+			DialTimeout: DefaultDialTimeout,
+			Username:    config.Username,
+			Password:    config.Password,
+			TLS:         tlsConfig,
+			// the errors are returned to the caller, which logs them
+			Logger: zap.NewNop(),
+		},
+	}, nil
+}
+
+// connect returns the client connected to the member, connecting again if the previous attempt has failed
+func (e *etcdClient) connect() (*clientv3.Client, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.client != nil {
+		return e.client, nil
+	}
+
+	client, err := clientv3.New(e.config)
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to etcd: %w", err)
+	}
+	e.client = client
+	return e.client, nil
+}
+
+// Close closes the connection if connected
+func (e *etcdClient) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.client == nil {
+		return nil
+	}
+	err := e.client.Close()
+	e.client = nil
+	return err
+}
+
+// tlsConfig returns the tls config of the https endpoint, nil for the plain http one.
+// the server certificate is verified with the system roots unless the ca certificate is given.
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	if !c.secure() {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(c.CACert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(c.CACert)) {
+			return nil, fmt.Errorf("can't parse ca certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(c.ClientCert) > 0 {
+		cert, err := tls.X509KeyPair([]byte(c.ClientCert), []byte(c.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("can't parse client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
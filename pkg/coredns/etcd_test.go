@@ -0,0 +1,202 @@
+package coredns
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+const (
+	testUsername = "dns-ingress"
+	testPassword = "secret"
+)
+
+// testEtcd is the single member etcd cluster embedded in the test
+type testEtcd struct {
+	*embed.Etcd
+	// Endpoint is the client url of the member
+	Endpoint string
+
+	// root is the client of the root user, which reads and writes the keys as if outside of dns-ingress
+	root *clientv3.Client
+}
+
+// newTestEtcd starts the member, configure modifies the config before starting, e.g. to enable tls
+func newTestEtcd(t *testing.T, configure func(cfg *embed.Config)) *testEtcd {
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+	cfg.UnsafeNoFsync = true
+	cfg.ListenPeerUrls = []url.URL{{Scheme: "http", Host: "127.0.0.1:0"}}
+	cfg.AdvertisePeerUrls = cfg.ListenPeerUrls
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	cfg.ListenClientUrls = []url.URL{{Scheme: "http", Host: "127.0.0.1:0"}}
+	if configure != nil {
+		configure(cfg)
+	}
+	cfg.AdvertiseClientUrls = cfg.ListenClientUrls
+
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatalf("can't start etcd: %v", err)
+	}
+	t.Cleanup(e.Close)
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(30 * time.Second):
+		t.Fatalf("etcd is not ready")
+	}
+
+	f := &testEtcd{Etcd: e, Endpoint: cfg.ListenClientUrls[0].Scheme + "://" + e.Clients[0].Addr().String()}
+	f.root = f.connect(t, Config{Endpoint: f.Endpoint})
+	return f
+}
+
+// newClient returns the client of the config connecting to the member, with the default path prefix if not given
+func (f *testEtcd) newClient(t *testing.T, config Config) *Client {
+	c, err := NewCoreDNSClient(config)
+	if err != nil {
+		t.Fatalf("can't create client: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+// connect returns the etcd client of the config connected to the member
+func (f *testEtcd) connect(t *testing.T, config Config) *clientv3.Client {
+	etcd, err := f.newClient(t, config).etcd.connect()
+	if err != nil {
+		t.Fatalf("can't connect: %v", err)
+	}
+	return etcd
+}
+
+// enableAuth enables the authentication with the root user and the test user granted the root role
+func (f *testEtcd) enableAuth(t *testing.T) {
+	ctx := context.Background()
+	for _, user := range []string{"root", testUsername} {
+		if _, err := f.root.UserAdd(ctx, user, testPassword); err != nil {
+			t.Fatalf("can't add user %s: %v", user, err)
+		}
+		if _, err := f.root.UserGrantRole(ctx, user, "root"); err != nil {
+			t.Fatalf("can't grant root role to %s: %v", user, err)
+		}
+	}
+	if _, err := f.root.AuthEnable(ctx); err != nil {
+		t.Fatalf("can't enable auth: %v", err)
+	}
+	f.root = f.connect(t, Config{Endpoint: f.Endpoint, Username: "root", Password: testPassword})
+}
+
+// put stores the value directly, as if it is written outside of dns-ingress
+func (f *testEtcd) put(t *testing.T, key, value string) {
+	if _, err := f.root.Put(context.Background(), key, value); err != nil {
+		t.Fatalf("can't put %s: %v", key, err)
+	}
+}
+
+// get returns the value of the key, empty if not exists
+func (f *testEtcd) get(t *testing.T, key string) string {
+	resp, err := f.root.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("can't get %s: %v", key, err)
+	}
+	if len(resp.Kvs) == 0 {
+		return ""
+	}
+	return string(resp.Kvs[0].Value)
+}
+
+// keys returns the sorted keys having the prefix
+func (f *testEtcd) keys(t *testing.T, prefix string) []string {
+	resp, err := f.root.Get(context.Background(), prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		t.Fatalf("can't get keys of %s: %v", prefix, err)
+	}
+	keys := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		keys = append(keys, string(kv.Key))
+	}
+	return keys
+}
+
+// revision returns the current revision of the key space, which every txn increments once
+func (f *testEtcd) revision(t *testing.T) int64 {
+	resp, err := f.root.Get(context.Background(), "/", clientv3.WithCountOnly())
+	if err != nil {
+		t.Fatalf("can't get revision: %v", err)
+	}
+	return resp.Header.Revision
+}
+
+// testCA issues the certificates of the member and the client
+type testCA struct {
+	cert string
+
+	template *x509.Certificate
+	key      *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("can't generate ca key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "dns-ingress test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("can't create ca certificate: %v", err)
+	}
+	return &testCA{
+		cert:     string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		template: template,
+		key:      key,
+	}
+}
+
+// issue returns the pem encoded certificate of the loopback address and its key
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("can't generate key of %s: %v", commonName, err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.template, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("can't create certificate of %s: %v", commonName, err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("can't encode key of %s: %v", commonName, err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
@@ -0,0 +1,159 @@
+package coredns
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ttlDefault is applied if ttl is not set, the etcd plugin falls back to its own ttl if the service has none
+const ttlDefault = 300
+
+// valueKeyPrefix prefixes the keys of the values, which are the children of the name path
+const valueKeyPrefix = "dns-ingress-"
+
+// valueKeyPattern matches the keys of the values written by dns-ingress, which carry the record type
+var valueKeyPattern = regexp.MustCompile(`^` + valueKeyPrefix + `(a|aaaa|cname|txt)-[0-9]+$`)
+
+// Service is the skydns service json, which the coredns etcd plugin reads a record from
+type Service struct {
+	Host string `json:"host,omitempty"`
+	Text string `json:"text,omitempty"`
+	TTL  uint32 `json:"ttl,omitempty"`
+}
+
+// value is the record stored in the key
+type value struct {
+	key         string
+	recordType  string
+	record      string
+	ttl         int
+	modRevision int64
+}
+
+// namePath returns the key of the name, labels are reversed under the path prefix,
+// e.g. www.example.com is stored at /skydns/com/example/www
+func namePath(pathPrefix, fqdn string) string {
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(fqdn, ".")), ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return fmt.Sprintf("%s/%s", pathPrefix, strings.Join(labels, "/"))
+}
+
+// valueKey returns the key of i-th value of the recordset
+func valueKey(path, recordType string, i int) string {
+	return fmt.Sprintf("%s/%s%s-%d", path, valueKeyPrefix, strings.ToLower(recordType), i)
+}
+
+// newServices builds the services of the records keyed by the value keys
+func newServices(path, recordType string, records []string, ttl int) (map[string]Service, error) {
+	sorted := append([]string{}, records...)
+	sort.Strings(sorted)
+
+	services := make(map[string]Service, len(sorted))
+	for i, r := range sorted {
+		s := Service{TTL: uint32(normalizeTTL(ttl))}
+		switch recordType {
+		case provider.RecordTypeA, provider.RecordTypeAAAA:
+			if ip := net.ParseIP(r); ip == nil || (ip.To4() != nil) != (recordType == provider.RecordTypeA) {
+				return nil, fmt.Errorf("invalid %s record %s", recordType, r)
+			}
+			s.Host = r
+		case provider.RecordTypeCNAME:
+			s.Host = strings.TrimSuffix(r, ".")
+		case provider.RecordTypeTXT:
+			s.Text = r
+		default:
+			return nil, fmt.Errorf("record type %s is not supported by the etcd plugin", recordType)
+		}
+		services[valueKey(path, recordType, i)] = s
+	}
+	return services, nil
+}
+
+// parseValues returns the values of the name path, which are the ones dns-ingress writes under the path
+// and the service stored at the path itself. the other children are left out since they are the subdomains
+// in the skydns layout as well, e.g. the children of the apex.
+func parseValues(path string, kvs []*mvccpb.KeyValue) []value {
+	values := make([]value, 0, len(kvs))
+	for _, kv := range kvs {
+		key := string(kv.Key)
+		recordType := ""
+		if key != path {
+			leaf := strings.TrimPrefix(key, path+"/")
+			m := valueKeyPattern.FindStringSubmatch(leaf)
+			if leaf == key || m == nil {
+				continue
+			}
+			recordType = strings.ToUpper(m[1])
+		}
+
+		s := Service{}
+		if err := json.Unmarshal(kv.Value, &s); err != nil {
+			continue
+		}
+		v := value{key: key, record: s.Host, ttl: int(s.TTL), modRevision: kv.ModRevision}
+		if len(s.Text) > 0 {
+			v.recordType, v.record = provider.RecordTypeTXT, s.Text
+		} else if ip := net.ParseIP(s.Host); ip != nil && ip.To4() != nil {
+			v.recordType = provider.RecordTypeA
+		} else if ip != nil {
+			v.recordType = provider.RecordTypeAAAA
+		} else if len(s.Host) > 0 {
+			v.recordType, v.record = provider.RecordTypeCNAME, strings.TrimSuffix(s.Host, ".")
+		} else {
+			continue
+		}
+		// the type written by dns-ingress is trusted over the inferred one
+		if len(recordType) > 0 && recordType != v.recordType {
+			continue
+		}
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].key < values[j].key })
+	return values
+}
+
+// convertValues converts the values of the type into a recordset, nil if there are none
+func convertValues(name, zoneName, recordType string, values []value) *provider.Domain {
+	records := make([]string, 0, len(values))
+	ttl := 0
+	for _, v := range values {
+		if v.recordType != recordType {
+			continue
+		}
+		if len(records) == 0 {
+			ttl = v.ttl
+		}
+		records = append(records, v.record)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	sort.Strings(records)
+
+	return &provider.Domain{
+		Id:        provider.GenerateRecordSetId(name, recordType),
+		Name:      name,
+		Type:      recordType,
+		Records:   records,
+		TTL:       normalizeTTL(ttl),
+		ZoneId:    zoneName,
+		ZoneName:  zoneName,
+		FQDN:      fmt.Sprintf("%s.", provider.JoinName(name, zoneName)),
+		Activated: true,
+	}
+}
+
+func normalizeTTL(ttl int) int {
+	if ttl <= 0 {
+		return ttlDefault
+	}
+	return ttl
+}
@@ -0,0 +1,198 @@
+package digitalocean
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultAPIUrl = "https://api.digitalocean.com"
+	apiPrefix     = "/v2"
+)
+
+// perPage is the maximum page size of the api
+const perPage = 200
+
+const (
+	// maxRateLimitRetries is the number of the retries of rate-limited requests, which are not processed by the api
+	maxRateLimitRetries = 5
+	// maxRateLimitDelay caps the delay before retrying the rate-limited request
+	maxRateLimitDelay = time.Minute
+)
+
+// Domain is the domain object of the api, which is the zone
+type Domain struct {
+	Name string `json:"name"`
+	TTL  int    `json:"ttl"`
+}
+
+// DomainRecord is a single record of the domain, the name is relative to the domain and @ for the apex
+type DomainRecord struct {
+	Id       int    `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Data     string `json:"data"`
+	Priority *int   `json:"priority"`
+	Port     *int   `json:"port"`
+	TTL      int    `json:"ttl"`
+	Weight   *int   `json:"weight"`
+}
+
+type Account struct {
+	Email  string `json:"email"`
+	Status string `json:"status"`
+}
+
+type accountResponse struct {
+	Account Account `json:"account"`
+}
+
+type domainResponse struct {
+	Domain Domain `json:"domain"`
+}
+
+type domainRecordResponse struct {
+	DomainRecord DomainRecord `json:"domain_record"`
+}
+
+// domainRecordsPage is a page of the records, links.pages.next is set unless it's the last page
+type domainRecordsPage struct {
+	DomainRecords []DomainRecord `json:"domain_records"`
+	Links         struct {
+		Pages struct {
+			Next string `json:"next,omitempty"`
+		} `json:"pages"`
+	} `json:"links"`
+}
+
+// APIError is returned if the api responds with error status
+type APIError struct {
+	StatusCode int
+	Id         string `json:"id"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("digitalocean api error %d: %s", e.StatusCode, e.Message)
+}
+
+func isNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// do sends the request to the api, the response is decoded into out if given.
+// rate-limited requests are retried once the limit is reset.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	u := c.apiUrl + apiPrefix + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var b []byte
+	if in != nil {
+		var err error
+		if b, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("can't encode request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if in != nil {
+			body = bytes.NewReader(b)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, u, body)
+		if err != nil {
+			return fmt.Errorf("can't create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+c.apiToken)
+		req.Header.Set("Accept", "application/json")
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("can't read response: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitRetries {
+			if err := sleepContext(ctx, rateLimitDelay(resp.Header, attempt, time.Now())); err != nil {
+				return fmt.Errorf("operation aborted while rate-limited: %w", err)
+			}
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			apiErr := &APIError{StatusCode: resp.StatusCode}
+			if err := json.Unmarshal(respBody, apiErr); err != nil || len(apiErr.Message) == 0 {
+				apiErr.Message = string(bytes.TrimSpace(respBody))
+			}
+			return apiErr
+		}
+
+		if out == nil || len(respBody) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("can't decode response: %w", err)
+		}
+		return nil
+	}
+}
+
+// rateLimitDelay returns the delay until the rate limit is reset, which is given as unix time in Ratelimit-Reset.
+// Retry-After is preferred if present, and it backs off exponentially from a second if neither is present.
+func rateLimitDelay(header http.Header, attempt int, now time.Time) time.Duration {
+	delay := time.Duration(math.Pow(2, float64(attempt))) * time.Second
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+	} else if reset, err := strconv.ParseInt(header.Get("Ratelimit-Reset"), 10, 64); err == nil {
+		delay = time.Unix(reset, 0).Sub(now)
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	if delay > maxRateLimitDelay {
+		return maxRateLimitDelay
+	}
+	return delay
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func domainPath(zoneId string) string {
+	return fmt.Sprintf("/domains/%s", url.PathEscape(zoneId))
+}
+
+func recordsPath(zoneId string) string {
+	return domainPath(zoneId) + "/records"
+}
+
+func recordPath(zoneId string, recordId int) string {
+	return fmt.Sprintf("%s/%d", recordsPath(zoneId), recordId)
+}
@@ -0,0 +1,352 @@
+package digitalocean
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ProviderKey = "digitalocean"
+
+const (
+	SettingKeyAPIToken = "apiToken"
+	// SettingKeyAPIUrl overrides the api url, which defaults to DefaultAPIUrl
+	SettingKeyAPIUrl = "apiUrl"
+)
+
+// ttlDefault is applied if ttl is not set, the api defaults to the ttl of the domain otherwise
+const ttlDefault = 300
+
+var DefaultHttpClient = &http.Client{Timeout: 30 * time.Second}
+
+func init() {
+	provider.Register(ProviderKey, NewDigitalOceanClientWithSettings)
+}
+
+// Client manages the records of the domains on DigitalOcean.
+// the api has no recordsets, so a recordset is made of the records which have the same name and type.
+// the domain name is the zone id, as the api identifies the domains by name.
+type Client struct {
+	provider.Client
+
+	apiUrl     string
+	apiToken   string
+	httpClient *http.Client
+}
+
+// NewDigitalOceanClientWithSettings creates the client with the provider settings
+func NewDigitalOceanClientWithSettings(settings map[string]string) (provider.Client, error) {
+	apiUrl := settings[SettingKeyAPIUrl]
+	if len(apiUrl) == 0 {
+		apiUrl = DefaultAPIUrl
+	}
+	c, err := NewDigitalOceanClient(apiUrl, settings[SettingKeyAPIToken], DefaultHttpClient)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewDigitalOceanClient creates the client of the api served at the url with the personal access token
+func NewDigitalOceanClient(apiUrl, apiToken string, client *http.Client) (*Client, error) {
+	if len(apiToken) == 0 {
+		return nil, fmt.Errorf("can't create new digitalocean client: %s is required", SettingKeyAPIToken)
+	}
+	u, err := url.Parse(apiUrl)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return nil, fmt.Errorf("can't create new digitalocean client: invalid api url %s", apiUrl)
+	}
+	if client == nil {
+		client = DefaultHttpClient
+	}
+
+	return &Client{
+		apiUrl:     strings.TrimSuffix(strings.TrimSuffix(apiUrl, "/"), apiPrefix),
+		apiToken:   apiToken,
+		httpClient: client,
+	}, nil
+}
+
+// Verify checks the token is accepted
+func (c *Client) Verify(ctx context.Context) error {
+	account := &accountResponse{}
+	if err := c.do(ctx, http.MethodGet, "/account", nil, nil, account); err != nil {
+		return fmt.Errorf("can't verify account: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone, error) {
+	name := strings.ToLower(strings.TrimSuffix(zoneName, "."))
+	if len(name) == 0 {
+		return nil, fmt.Errorf("can't GetZone: zone name is required")
+	}
+
+	domain := &domainResponse{}
+	if err := c.do(ctx, http.MethodGet, domainPath(name), nil, nil, domain); err != nil {
+		if isNotFound(err) {
+			return nil, fmt.Errorf("can't GetZone: cannot find zone %s", zoneName)
+		}
+		return nil, fmt.Errorf("can't GetZone: %w", err)
+	}
+	return &provider.Zone{
+		Id:        domain.Domain.Name,
+		Name:      domain.Domain.Name,
+		Activated: true,
+	}, nil
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	records, err := c.listRecordSet(ctx, zoneId, name, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return convertRecordSet(name, zoneId, records), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Create: no records given for %s", name)
+	}
+
+	// the api creates the record regardless of the others, which would merge the records into the existing recordset
+	current, err := c.listRecordSet(ctx, zoneId, name, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	if len(current) > 0 {
+		return nil, fmt.Errorf("can't Create: recordset %s %s already exists", provider.JoinName(name, zoneId), recordType)
+	}
+
+	created := make([]DomainRecord, 0, len(records))
+	for _, r := range records {
+		record, err := c.createRecord(ctx, zoneId, newDomainRecord(name, recordType, r, ttl))
+		if err != nil {
+			return nil, fmt.Errorf("can't Create: %w", err)
+		}
+		created = append(created, record)
+	}
+	return convertRecordSet(name, zoneId, created), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	name, currentType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	current, err := c.listRecordSet(ctx, zoneId, name, currentType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if len(current) == 0 {
+		return nil, nil
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Update: no records given for %s", name)
+	}
+
+	// if type has been changed, remove the current recordset first since CNAME can't coexist with others
+	if currentType != recordType {
+		for _, r := range current {
+			if err := c.deleteRecord(ctx, zoneId, r.Id); err != nil {
+				return nil, fmt.Errorf("can't Update: %w", err)
+			}
+		}
+		current, err = c.listRecordSet(ctx, zoneId, name, recordType)
+		if err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+
+	// keep the records which have wanted data, the others are stale
+	wanted := map[string]bool{}
+	for _, r := range records {
+		wanted[fromData(recordType, toData(recordType, r))] = true
+	}
+	stale := make([]DomainRecord, 0)
+	synced := make([]DomainRecord, 0, len(records))
+	for _, r := range current {
+		data := fromData(r.Type, r.Data)
+		if !wanted[data] {
+			stale = append(stale, r)
+			continue
+		}
+		delete(wanted, data)
+
+		if r.TTL != normalizeTTL(ttl) {
+			r, err = c.updateRecord(ctx, zoneId, r.Id, newDomainRecord(name, recordType, data, ttl))
+			if err != nil {
+				return nil, fmt.Errorf("can't Update: %w", err)
+			}
+		}
+		synced = append(synced, r)
+	}
+
+	// create the missing records first, then remove the stale ones to avoid resolution gap
+	for _, r := range records {
+		data := fromData(recordType, toData(recordType, r))
+		if !wanted[data] {
+			continue
+		}
+		delete(wanted, data)
+		record, err := c.createRecord(ctx, zoneId, newDomainRecord(name, recordType, r, ttl))
+		if err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+		synced = append(synced, record)
+	}
+
+	for _, r := range stale {
+		if err := c.deleteRecord(ctx, zoneId, r.Id); err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+	return convertRecordSet(name, zoneId, synced), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	records, err := c.listRecordSet(ctx, zoneId, name, recordType)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	for _, r := range records {
+		if err := c.deleteRecord(ctx, zoneId, r.Id); err != nil {
+			return fmt.Errorf("can't Delete: %w", err)
+		}
+	}
+	return nil
+}
+
+// listRecordSet returns the records which have the name and type, through all the pages.
+// the api filters the records by fqdn, while it responds with the names relative to the domain.
+func (c *Client) listRecordSet(ctx context.Context, zoneId, name, recordType string) ([]DomainRecord, error) {
+	query := url.Values{
+		"name":     {provider.JoinName(name, zoneId)},
+		"type":     {recordType},
+		"per_page": {strconv.Itoa(perPage)},
+	}
+
+	matched := make([]DomainRecord, 0)
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		records := &domainRecordsPage{}
+		if err := c.do(ctx, http.MethodGet, recordsPath(zoneId), query, nil, records); err != nil {
+			return nil, err
+		}
+		for _, r := range records.DomainRecords {
+			if strings.EqualFold(r.Name, name) && r.Type == recordType {
+				matched = append(matched, r)
+			}
+		}
+		if len(records.Links.Pages.Next) == 0 {
+			return matched, nil
+		}
+	}
+}
+
+func (c *Client) createRecord(ctx context.Context, zoneId string, record DomainRecord) (DomainRecord, error) {
+	resp := &domainRecordResponse{}
+	if err := c.do(ctx, http.MethodPost, recordsPath(zoneId), nil, record, resp); err != nil {
+		return DomainRecord{}, err
+	}
+	return resp.DomainRecord, nil
+}
+
+func (c *Client) updateRecord(ctx context.Context, zoneId string, recordId int, record DomainRecord) (DomainRecord, error) {
+	resp := &domainRecordResponse{}
+	if err := c.do(ctx, http.MethodPut, recordPath(zoneId, recordId), nil, record, resp); err != nil {
+		return DomainRecord{}, err
+	}
+	return resp.DomainRecord, nil
+}
+
+// deleteRecord deletes the record, which succeeds if the record is already gone
+func (c *Client) deleteRecord(ctx context.Context, zoneId string, recordId int) error {
+	if err := c.do(ctx, http.MethodDelete, recordPath(zoneId, recordId), nil, nil, nil); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func newDomainRecord(name, recordType, record string, ttl int) DomainRecord {
+	return DomainRecord{
+		Type: recordType,
+		Name: name,
+		Data: toData(recordType, record),
+		TTL:  normalizeTTL(ttl),
+	}
+}
+
+// convertRecordSet converts the records of the same name and type into a recordset
+func convertRecordSet(name, zoneId string, records []DomainRecord) *provider.Domain {
+	data := make([]string, 0, len(records))
+	for _, r := range records {
+		data = append(data, fromData(r.Type, r.Data))
+	}
+	sort.Strings(data)
+
+	r := records[0]
+	return &provider.Domain{
+		Id:        provider.GenerateRecordSetId(name, r.Type),
+		Name:      name,
+		Type:      r.Type,
+		Records:   data,
+		TTL:       r.TTL,
+		ZoneId:    zoneId,
+		ZoneName:  zoneId,
+		FQDN:      fmt.Sprintf("%s.", provider.JoinName(name, zoneId)),
+		Activated: true,
+	}
+}
+
+// toData returns the data of the record the api requires, the hostname of CNAME has to be fully-qualified
+func toData(recordType, record string) string {
+	if recordType == provider.RecordTypeCNAME {
+		return fmt.Sprintf("%s.", strings.TrimSuffix(record, "."))
+	}
+	return record
+}
+
+// fromData returns the record in the form given on Create, reverse of toData
+func fromData(recordType, data string) string {
+	if recordType == provider.RecordTypeCNAME {
+		return strings.TrimSuffix(data, ".")
+	}
+	return data
+}
+
+func normalizeTTL(ttl int) int {
+	if ttl <= 0 {
+		return ttlDefault
+	}
+	return ttl
+}
@@ -0,0 +1,241 @@
+package digitalocean
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testZoneName = "example.com"
+
+func newTestClient(t *testing.T) (*fakeServer, *Client) {
+	f := newFakeServer(t, testZoneName)
+	return f, f.newClient(t)
+}
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	z, err := c.GetZone(ctx, "Example.COM.")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: testZoneName, Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: unexpected zone %+v", z)
+	}
+	if _, err := c.GetZone(ctx, "example.org"); err == nil || !strings.Contains(err.Error(), "cannot find zone") {
+		t.Fatalf("GetZone: expected error for unknown zone, got %v", err)
+	}
+
+	d, err := c.Create(ctx, "www", z.Id, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("www", provider.RecordTypeA),
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.0.2.1", "192.0.2.2"},
+		TTL:       ttlDefault,
+		ZoneId:    testZoneName,
+		ZoneName:  testZoneName,
+		FQDN:      "www.example.com.",
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+	if got := f.data(testZoneName, "www", provider.RecordTypeA); !reflect.DeepEqual(got, []string{"192.0.2.1", "192.0.2.2"}) {
+		t.Fatalf("Create: expected a record for each of the records, got %v", got)
+	}
+
+	got, err := c.Get(ctx, d.Id, z.Id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Get: expected %+v, got %+v", expected, got)
+	}
+
+	d, err = c.Update(ctx, d.Id, z.Id, provider.RecordTypeCNAME, []string{"lb.example.net"}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != "www/CNAME" || !reflect.DeepEqual(d.Records, []string{"lb.example.net"}) || d.TTL != 60 {
+		t.Fatalf("Update: unexpected recordset %+v", d)
+	}
+	if got := f.data(testZoneName, "www", provider.RecordTypeCNAME); !reflect.DeepEqual(got, []string{"lb.example.net."}) {
+		t.Fatalf("Update: expected CNAME with fully-qualified hostname, got %v", got)
+	}
+	if got := f.data(testZoneName, "www", provider.RecordTypeA); len(got) > 0 {
+		t.Fatalf("Update: expected A records removed, got %v", got)
+	}
+
+	if err := c.Delete(ctx, d.Id, z.Id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.data(testZoneName, "www", provider.RecordTypeCNAME); len(got) > 0 {
+		t.Fatalf("Delete: expected no records, got %v", got)
+	}
+}
+
+func TestClientUpdateKeepsRecords(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	d, err := c.Create(ctx, "@", testZoneName, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 300, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	ids := func() map[string]int {
+		ids := map[string]int{}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, r := range f.records[testZoneName] {
+			ids[r.Data] = r.Id
+		}
+		return ids
+	}
+	before := ids()
+
+	n := f.requestCount()
+	if _, err := c.Update(ctx, d.Id, testZoneName, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 300, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := f.requestCount() - n; got != 1 {
+		t.Fatalf("Update: expected only the lookup for the recordset in sync, got %d requests", got)
+	}
+
+	d, err = c.Update(ctx, d.Id, testZoneName, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.3"}, 300, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, []string{"192.0.2.2", "192.0.2.3"}) || d.FQDN != "example.com." {
+		t.Fatalf("Update: unexpected recordset %+v", d)
+	}
+	after := ids()
+	if after["192.0.2.2"] != before["192.0.2.2"] {
+		t.Fatalf("Update: expected the record of the kept data untouched, got %v and %v", before, after)
+	}
+	if _, found := after["192.0.2.1"]; found {
+		t.Fatalf("Update: expected the stale record removed, got %v", after)
+	}
+}
+
+func TestClientPagination(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+	f.pageSize = 2
+
+	expected := make([]string, 0)
+	for i := 1; i <= 5; i++ {
+		f.addRecord(testZoneName, DomainRecord{Type: provider.RecordTypeA, Name: "www", Data: fmt.Sprintf("192.0.2.%d", i), TTL: 120})
+		expected = append(expected, fmt.Sprintf("192.0.2.%d", i))
+	}
+	f.addRecord(testZoneName, DomainRecord{Type: provider.RecordTypeTXT, Name: "www", Data: "heritage=dns-ingress", TTL: 120})
+
+	d, err := c.GetByName(ctx, "www", testZoneName, provider.RecordTypeA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, expected) || d.TTL != 120 {
+		t.Fatalf("GetByName: expected records of all the pages, got %+v", d)
+	}
+
+	if err := c.Delete(ctx, d.Id, testZoneName); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.data(testZoneName, "www", provider.RecordTypeA); len(got) > 0 {
+		t.Fatalf("Delete: expected the records of all the pages removed, got %v", got)
+	}
+	if got := f.data(testZoneName, "www", provider.RecordTypeTXT); len(got) != 1 {
+		t.Fatalf("Delete: expected TXT record untouched, got %v", got)
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	f.rateLimited = 2
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+		t.Fatalf("Create: expected rate-limited requests retried, got %v", err)
+	}
+	if got := f.data(testZoneName, "www", provider.RecordTypeA); len(got) != 1 {
+		t.Fatalf("Create: expected the record created once, got %v", got)
+	}
+
+	f.mu.Lock()
+	f.rateLimited = maxRateLimitRetries + 1
+	f.mu.Unlock()
+	_, err := c.GetByName(ctx, "www", testZoneName, provider.RecordTypeA)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("GetByName: expected rate limit error once retries are exhausted, got %v", err)
+	}
+}
+
+func TestRateLimitDelay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for _, tc := range []struct {
+		header   http.Header
+		attempt  int
+		expected time.Duration
+	}{
+		{header: http.Header{"Ratelimit-Reset": {strconv.FormatInt(now.Unix()+3, 10)}}, expected: 3 * time.Second},
+		{header: http.Header{"Ratelimit-Reset": {strconv.FormatInt(now.Unix()-3, 10)}}, expected: 0},
+		{header: http.Header{"Ratelimit-Reset": {strconv.FormatInt(now.Unix()+3600, 10)}}, expected: maxRateLimitDelay},
+		{header: http.Header{"Retry-After": {"5"}, "Ratelimit-Reset": {strconv.FormatInt(now.Unix()+3, 10)}}, expected: 5 * time.Second},
+		{header: http.Header{}, attempt: 2, expected: 4 * time.Second},
+	} {
+		if got := rateLimitDelay(tc.header, tc.attempt, now); got != tc.expected {
+			t.Errorf("rateLimitDelay(%v, %d): expected %v, got %v", tc.header, tc.attempt, tc.expected, got)
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	if err := c.Verify(ctx); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	unauthorized, err := NewDigitalOceanClient(f.URL, "invalid", f.Client())
+	if err != nil {
+		t.Fatalf("NewDigitalOceanClient: %v", err)
+	}
+	if err := unauthorized.Verify(ctx); err == nil || !strings.Contains(err.Error(), "Unable to authenticate you") {
+		t.Fatalf("Verify: expected error of invalid token, got %v", err)
+	}
+
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 10, nil); err == nil || !strings.Contains(err.Error(), "ttl must be at least 30") {
+		t.Fatalf("Create: expected error of the api, got %v", err)
+	}
+	if _, err := c.Create(ctx, "www", "example.org", provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for unknown domain")
+	}
+}
+
+func TestNewDigitalOceanClientWithSettings(t *testing.T) {
+	c, err := NewDigitalOceanClientWithSettings(map[string]string{SettingKeyAPIToken: testAPIToken})
+	if err != nil {
+		t.Fatalf("NewDigitalOceanClientWithSettings: %v", err)
+	}
+	if apiUrl := c.(*Client).apiUrl; apiUrl != DefaultAPIUrl {
+		t.Fatalf("expected default api url, got %s", apiUrl)
+	}
+	for _, invalid := range []map[string]string{{}, {SettingKeyAPIToken: testAPIToken, SettingKeyAPIUrl: "api.digitalocean.com"}} {
+		if _, err := NewDigitalOceanClientWithSettings(invalid); err == nil {
+			t.Fatalf("NewDigitalOceanClientWithSettings: expected error for settings %v", invalid)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package digitalocean
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testAPIToken = "dop_v1_secret"
+
+// fakeServer is a minimal stand-in of the DigitalOcean api serving the domains and their records
+type fakeServer struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// records by domain name, the domains without records are kept as empty
+	records map[string][]DomainRecord
+	nextId  int
+	// pageSize caps per_page of the requests to make the responses paginated
+	pageSize int
+	// rateLimited is the number of the next requests responded with 429
+	rateLimited int
+	// requests counts the requests served, including the rate-limited ones
+	requests int
+}
+
+func newFakeServer(t *testing.T, domains ...string) *fakeServer {
+	f := &fakeServer{records: map[string][]DomainRecord{}, nextId: 1000, pageSize: perPage}
+	for _, d := range domains {
+		f.records[d] = []DomainRecord{}
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeServer) newClient(t *testing.T) *Client {
+	c, err := NewDigitalOceanClient(f.URL, testAPIToken, f.Client())
+	if err != nil {
+		t.Fatalf("NewDigitalOceanClient: %v", err)
+	}
+	return c
+}
+
+// addRecord seeds the domain with the record under the next id
+func (f *fakeServer) addRecord(domain string, r DomainRecord) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextId++
+	r.Id = f.nextId
+	f.records[domain] = append(f.records[domain], r)
+}
+
+// data returns the sorted data of the records which have the name and type
+func (f *fakeServer) data(domain, name, recordType string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data := make([]string, 0)
+	for _, r := range f.records[domain] {
+		if r.Name == name && r.Type == recordType {
+			data = append(data, r.Data)
+		}
+	}
+	sort.Strings(data)
+	return data
+}
+
+func (f *fakeServer) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func (f *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	if r.Header.Get("Authorization") != "Bearer "+testAPIToken {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "Unable to authenticate you")
+		return
+	}
+	if f.rateLimited > 0 {
+		f.rateLimited--
+		w.Header().Set("Ratelimit-Limit", "5000")
+		w.Header().Set("Ratelimit-Remaining", "0")
+		w.Header().Set("Ratelimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+		writeError(w, http.StatusTooManyRequests, "too_many_requests", "API Rate limit exceeded.")
+		return
+	}
+
+	// /v2/account, /v2/domains/{domain}[/records[/{id}]]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, apiPrefix+"/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "account" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, &accountResponse{Account: Account{Email: "sammy@example.com", Status: "active"}})
+		return
+	case len(parts) < 2 || parts[0] != "domains":
+		writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+		return
+	}
+
+	domain := parts[1]
+	records, ok := f.records[domain]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, &domainResponse{Domain: Domain{Name: domain, TTL: 1800}})
+	case len(parts) == 3 && parts[2] == "records" && r.Method == http.MethodGet:
+		f.listRecords(w, r, domain, records)
+	case len(parts) == 3 && parts[2] == "records" && r.Method == http.MethodPost:
+		record := DomainRecord{}
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+		if err := f.validate(domain, 0, record); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "unprocessable_entity", err.Error())
+			return
+		}
+		f.nextId++
+		record.Id = f.nextId
+		f.records[domain] = append(records, record)
+		writeJSON(w, http.StatusCreated, &domainRecordResponse{DomainRecord: record})
+	case len(parts) == 4 && parts[2] == "records":
+		id, _ := strconv.Atoi(parts[3])
+		i := -1
+		for j, record := range records {
+			if record.Id == id {
+				i = j
+			}
+		}
+		if i < 0 {
+			writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			record := DomainRecord{}
+			if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+				writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+				return
+			}
+			if err := f.validate(domain, id, record); err != nil {
+				writeError(w, http.StatusUnprocessableEntity, "unprocessable_entity", err.Error())
+				return
+			}
+			record.Id = id
+			records[i] = record
+			writeJSON(w, http.StatusOK, &domainRecordResponse{DomainRecord: record})
+		case http.MethodDelete:
+			f.records[domain] = append(records[:i:i], records[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Method Not Allowed")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "The resource you were accessing could not be found.")
+	}
+}
+
+// listRecords responds with a page of the records, which are filtered by fqdn and type
+func (f *fakeServer) listRecords(w http.ResponseWriter, r *http.Request, domain string, records []DomainRecord) {
+	query := r.URL.Query()
+	matched := make([]DomainRecord, 0)
+	for _, record := range records {
+		fqdn := domain
+		if record.Name != "@" {
+			fqdn = record.Name + "." + domain
+		}
+		if name := query.Get("name"); len(name) > 0 && fqdn != name {
+			continue
+		}
+		if recordType := query.Get("type"); len(recordType) > 0 && record.Type != recordType {
+			continue
+		}
+		matched = append(matched, record)
+	}
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	size, _ := strconv.Atoi(query.Get("per_page"))
+	if size < 1 || size > f.pageSize {
+		size = f.pageSize
+	}
+
+	resp := &domainRecordsPage{DomainRecords: []DomainRecord{}}
+	if start := (page - 1) * size; start < len(matched) {
+		end := start + size
+		if end > len(matched) {
+			end = len(matched)
+		}
+		resp.DomainRecords = matched[start:end]
+		if end < len(matched) {
+			next := r.URL.Query()
+			next.Set("page", strconv.Itoa(page+1))
+			resp.Links.Pages.Next = fmt.Sprintf("%s%s?%s", f.URL, r.URL.Path, next.Encode())
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// validate checks the record as the api does, the record of the id is the one being updated
+func (f *fakeServer) validate(domain string, id int, record DomainRecord) error {
+	if len(record.Name) == 0 || len(record.Data) == 0 {
+		return fmt.Errorf("name and data are required")
+	}
+	if record.TTL < 30 {
+		return fmt.Errorf("ttl must be at least 30")
+	}
+	if record.Type == "CNAME" && !strings.HasSuffix(record.Data, ".") && record.Data != "@" {
+		return fmt.Errorf("data needs to end with a dot (.)")
+	}
+	for _, r := range f.records[domain] {
+		if r.Id == id || r.Name != record.Name {
+			continue
+		}
+		if (r.Type == "CNAME") != (record.Type == "CNAME") {
+			return fmt.Errorf("CNAME records cannot share a name with other records")
+		}
+		if r.Type == record.Type && r.Data == record.Data {
+			return fmt.Errorf("record already exists")
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, id, message string) {
+	writeJSON(w, status, map[string]string{"id": id, "message": message})
+}
@@ -8,6 +8,7 @@ import (
 )
 
 var (
+	CloudflareAPIToken            *string
 	CloudflareAuthKey             *string
 	CloudflareAuthEmail           *string
 	CloudflareClientDebugMode     *bool
@@ -15,16 +16,39 @@ var (
 	CloudflareClientRetryMaxDelay *int
 	CloudflareClientRetryMinDelay *int
 	CloudflareClientRetryMaxCount *int
+	DefaultDNSProvider            *string
+	DefaultIngressEndpoint        *string
+	DefaultDomainZone             *string
+	ProviderConfigPath            *string
+	OwnerId                       *string
+	OwnershipRegistry             *string
+	BuiltinDNSBindAddress         *string
+	BuiltinDNSZones               *string
+	BuiltinDNSNameservers         *string
+	BuiltinDNSTransferAllowed     *string
 )
 
 func LoadEnvs() {
+	CloudflareAPIToken = getStringEnvOrNil("CLOUDFLARE_API_TOKEN")
 	CloudflareAuthKey = getStringEnvOrNil("CLOUDFLARE_AUTH_KEY")
 	CloudflareAuthEmail = getStringEnvOrNil("CLOUDFLARE_AUTH_EMAIL")
 	CloudflareClientDebugMode = getBoolEnvOrDefault("CLOUDFLARE_CLIENT_DEBUG_MODE", false)
 	CloudflareClientRateLimit = getFloat64EnvOrDefault("CLOUDFLARE_CLIENT_RATE_LIMIT", 4.0)
-	CloudflareClientRetryMaxDelay = getIntEnvOrDefault("CLOUDFLARE_CLIENT_RETRY_MAX_DELAY", 3)
-	CloudflareClientRetryMinDelay = getIntEnvOrDefault("CLOUDFLARE_CLIENT_RETRY_MIN_DELAY", 1)
-	CloudflareClientRetryMaxCount = getIntEnvOrDefault("CLOUDFLARE_CLIENT_RETRY_MAX_COUNT", 30)
+	// retry policy falls back to the client default for each unset value
+	CloudflareClientRetryMaxDelay = getIntEnvOrNil("CLOUDFLARE_CLIENT_RETRY_MAX_DELAY")
+	CloudflareClientRetryMinDelay = getIntEnvOrNil("CLOUDFLARE_CLIENT_RETRY_MIN_DELAY")
+	CloudflareClientRetryMaxCount = getIntEnvOrNil("CLOUDFLARE_CLIENT_RETRY_MAX_COUNT")
+	DefaultDNSProvider = getStringEnvOrDefault("DEFAULT_DNS_PROVIDER", "cloudflare")
+	DefaultIngressEndpoint = getStringEnvOrDefault("DEFAULT_INGRESS_ENDPOINT", "")
+	DefaultDomainZone = getStringEnvOrDefault("DEFAULT_DOMAIN_ZONE", "")
+	ProviderConfigPath = getStringEnvOrDefault("PROVIDER_CONFIG_PATH", "")
+	OwnerId = getStringEnvOrDefault("OWNER_ID", "default")
+	OwnershipRegistry = getStringEnvOrDefault("OWNERSHIP_REGISTRY", "txt")
+	// builtin dns server is disabled if the bind address is empty
+	BuiltinDNSBindAddress = getStringEnvOrDefault("BUILTIN_DNS_BIND_ADDRESS", "")
+	BuiltinDNSZones = getStringEnvOrDefault("BUILTIN_DNS_ZONES", "")
+	BuiltinDNSNameservers = getStringEnvOrDefault("BUILTIN_DNS_NAMESERVERS", "")
+	BuiltinDNSTransferAllowed = getStringEnvOrDefault("BUILTIN_DNS_TRANSFER_ALLOWED", "")
 }
 
 func getEnvOrNil(key string) *string {
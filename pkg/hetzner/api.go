@@ -0,0 +1,214 @@
+package hetzner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultAPIUrl = "https://dns.hetzner.com"
+	apiPrefix     = "/api/v1"
+	apiKeyHeader  = "Auth-API-Token"
+)
+
+// perPage is the page size of the listings
+const perPage = 100
+
+const (
+	// maxRateLimitRetries is the number of the retries of rate-limited requests, which are not processed by the api
+	maxRateLimitRetries = 5
+	// maxRateLimitDelay caps the delay before retrying the rate-limited request
+	maxRateLimitDelay = time.Minute
+)
+
+// Zone is the zone object of the api
+type Zone struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	TTL    int    `json:"ttl,omitempty"`
+	Status string `json:"status,omitempty"`
+	Paused bool   `json:"paused"`
+}
+
+// Record is a single record of the zone, the name is relative to the zone and @ for the apex
+type Record struct {
+	Id     string `json:"id,omitempty"`
+	ZoneId string `json:"zone_id"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	TTL    int    `json:"ttl,omitempty"`
+}
+
+// Pagination is the pagination of the listings, pages start from 1
+type Pagination struct {
+	Page         int `json:"page"`
+	PerPage      int `json:"per_page"`
+	LastPage     int `json:"last_page"`
+	TotalEntries int `json:"total_entries"`
+}
+
+type meta struct {
+	Pagination Pagination `json:"pagination"`
+}
+
+type zonesPage struct {
+	Zones []Zone `json:"zones"`
+	Meta  meta   `json:"meta"`
+}
+
+type zoneResponse struct {
+	Zone Zone `json:"zone"`
+}
+
+type recordsPage struct {
+	Records []Record `json:"records"`
+	Meta    meta     `json:"meta"`
+}
+
+type recordResponse struct {
+	Record Record `json:"record"`
+}
+
+// APIError is returned if the api responds with error status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("hetzner dns api error %d: %s", e.StatusCode, e.Message)
+}
+
+// errorResponse is the body of the errors, the message is either nested in error or at the top level
+type errorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+	Message string `json:"message"`
+}
+
+func isNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// do sends the request to the api, the response is decoded into out if given.
+// rate-limited requests are retried once the limit is reset.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	u := c.apiUrl + apiPrefix + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var b []byte
+	if in != nil {
+		var err error
+		if b, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("can't encode request: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if in != nil {
+			body = bytes.NewReader(b)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, u, body)
+		if err != nil {
+			return fmt.Errorf("can't create request: %w", err)
+		}
+		req.Header.Set(apiKeyHeader, c.apiToken)
+		req.Header.Set("Accept", "application/json")
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("can't read response: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitRetries {
+			if err := sleepContext(ctx, rateLimitDelay(resp.Header, attempt, time.Now())); err != nil {
+				return fmt.Errorf("operation aborted while rate-limited: %w", err)
+			}
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			apiErr := &APIError{StatusCode: resp.StatusCode}
+			errResp := &errorResponse{}
+			if err := json.Unmarshal(respBody, errResp); err == nil {
+				apiErr.Message = errResp.Error.Message
+				if len(apiErr.Message) == 0 {
+					apiErr.Message = errResp.Message
+				}
+			}
+			if len(apiErr.Message) == 0 {
+				apiErr.Message = string(bytes.TrimSpace(respBody))
+			}
+			return apiErr
+		}
+
+		if out == nil || len(respBody) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("can't decode response: %w", err)
+		}
+		return nil
+	}
+}
+
+// rateLimitDelay returns the delay of Retry-After, or until the rate limit is reset which is given as unix time in Ratelimit-Reset.
+// it backs off exponentially from a second if neither is present.
+func rateLimitDelay(header http.Header, attempt int, now time.Time) time.Duration {
+	delay := time.Duration(math.Pow(2, float64(attempt))) * time.Second
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds >= 0 {
+		delay = time.Duration(seconds) * time.Second
+	} else if reset, err := strconv.ParseInt(header.Get("Ratelimit-Reset"), 10, 64); err == nil {
+		delay = time.Unix(reset, 0).Sub(now)
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	if delay > maxRateLimitDelay {
+		return maxRateLimitDelay
+	}
+	return delay
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func zonePath(zoneId string) string {
+	return fmt.Sprintf("/zones/%s", url.PathEscape(zoneId))
+}
+
+func recordPath(recordId string) string {
+	return fmt.Sprintf("/records/%s", url.PathEscape(recordId))
+}
@@ -0,0 +1,338 @@
+package hetzner
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ProviderKey = "hetzner"
+
+const (
+	SettingKeyAPIToken = "apiToken"
+	// SettingKeyAPIUrl overrides the api url, which defaults to DefaultAPIUrl
+	SettingKeyAPIUrl = "apiUrl"
+)
+
+var DefaultHttpClient = &http.Client{Timeout: 30 * time.Second}
+
+func init() {
+	provider.Register(ProviderKey, NewHetznerClientWithSettings)
+}
+
+// Client manages the records of the zones on Hetzner DNS.
+// the api has no recordsets, so a recordset is made of the records which have the same name and type.
+type Client struct {
+	provider.Client
+
+	apiUrl     string
+	apiToken   string
+	httpClient *http.Client
+
+	// zoneNames caches zone name by zone id
+	zoneNames sync.Map
+}
+
+// NewHetznerClientWithSettings creates the client with the provider settings
+func NewHetznerClientWithSettings(settings map[string]string) (provider.Client, error) {
+	apiUrl := settings[SettingKeyAPIUrl]
+	if len(apiUrl) == 0 {
+		apiUrl = DefaultAPIUrl
+	}
+	c, err := NewHetznerClient(apiUrl, settings[SettingKeyAPIToken], DefaultHttpClient)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewHetznerClient creates the client of the api served at the url with the api token
+func NewHetznerClient(apiUrl, apiToken string, client *http.Client) (*Client, error) {
+	if len(apiToken) == 0 {
+		return nil, fmt.Errorf("can't create new hetzner client: %s is required", SettingKeyAPIToken)
+	}
+	u, err := url.Parse(apiUrl)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return nil, fmt.Errorf("can't create new hetzner client: invalid api url %s", apiUrl)
+	}
+	if client == nil {
+		client = DefaultHttpClient
+	}
+
+	return &Client{
+		apiUrl:     strings.TrimSuffix(strings.TrimSuffix(apiUrl, "/"), apiPrefix),
+		apiToken:   apiToken,
+		httpClient: client,
+	}, nil
+}
+
+// Verify checks the token is accepted
+func (c *Client) Verify(ctx context.Context) error {
+	zones := &zonesPage{}
+	if err := c.do(ctx, http.MethodGet, "/zones", url.Values{"per_page": {"1"}}, nil, zones); err != nil {
+		return fmt.Errorf("can't verify api token: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone, error) {
+	name := strings.ToLower(strings.TrimSuffix(zoneName, "."))
+	if len(name) == 0 {
+		return nil, fmt.Errorf("can't GetZone: zone name is required")
+	}
+
+	// the api filters the zones by exact name, and responds with not found if none matches
+	query := url.Values{"name": {name}, "per_page": {strconv.Itoa(perPage)}}
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		zones := &zonesPage{}
+		if err := c.do(ctx, http.MethodGet, "/zones", query, nil, zones); err != nil {
+			if isNotFound(err) {
+				break
+			}
+			return nil, fmt.Errorf("can't GetZone: %w", err)
+		}
+		for _, z := range zones.Zones {
+			if strings.ToLower(z.Name) != name {
+				continue
+			}
+			c.zoneNames.Store(z.Id, name)
+			return &provider.Zone{
+				Id:        z.Id,
+				Name:      name,
+				Activated: !z.Paused,
+			}, nil
+		}
+		if page >= zones.Meta.Pagination.LastPage {
+			break
+		}
+	}
+	return nil, fmt.Errorf("can't GetZone: cannot find zone %s", zoneName)
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+
+	records, err := c.listRecordSet(ctx, zoneId, name, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return convertRecordSet(name, zoneId, zoneName, records), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Create: no records given for %s", name)
+	}
+
+	// the api creates the record regardless of the others, which would merge the records into the existing recordset
+	current, err := c.listRecordSet(ctx, zoneId, name, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	if len(current) > 0 {
+		return nil, fmt.Errorf("can't Create: recordset %s %s already exists", provider.JoinName(name, zoneName), recordType)
+	}
+
+	created := make([]Record, 0, len(records))
+	for _, r := range records {
+		record, err := c.createRecord(ctx, newRecord(zoneId, name, recordType, r, ttl))
+		if err != nil {
+			return nil, fmt.Errorf("can't Create: %w", err)
+		}
+		created = append(created, record)
+	}
+	return convertRecordSet(name, zoneId, zoneName, created), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	name, currentType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	current, err := c.listRecordSet(ctx, zoneId, name, currentType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if len(current) == 0 {
+		return nil, nil
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Update: no records given for %s", name)
+	}
+
+	// if type has been changed, remove the current recordset first since CNAME can't coexist with others
+	if currentType != recordType {
+		for _, r := range current {
+			if err := c.deleteRecord(ctx, r.Id); err != nil {
+				return nil, fmt.Errorf("can't Update: %w", err)
+			}
+		}
+		current, err = c.listRecordSet(ctx, zoneId, name, recordType)
+		if err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+
+	// keep the records which have wanted value, the others are stale
+	wanted := map[string]bool{}
+	for _, r := range records {
+		wanted[fromValue(recordType, toValue(recordType, r))] = true
+	}
+	stale := make([]Record, 0)
+	synced := make([]Record, 0, len(records))
+	for _, r := range current {
+		value := fromValue(r.Type, r.Value)
+		if !wanted[value] {
+			stale = append(stale, r)
+			continue
+		}
+		delete(wanted, value)
+
+		if r.TTL != normalizeTTL(ttl) {
+			r, err = c.updateRecord(ctx, r.Id, newRecord(zoneId, name, recordType, value, ttl))
+			if err != nil {
+				return nil, fmt.Errorf("can't Update: %w", err)
+			}
+		}
+		synced = append(synced, r)
+	}
+
+	// create the missing records first, then remove the stale ones to avoid resolution gap
+	for _, r := range records {
+		value := fromValue(recordType, toValue(recordType, r))
+		if !wanted[value] {
+			continue
+		}
+		delete(wanted, value)
+		record, err := c.createRecord(ctx, newRecord(zoneId, name, recordType, r, ttl))
+		if err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+		synced = append(synced, record)
+	}
+
+	for _, r := range stale {
+		if err := c.deleteRecord(ctx, r.Id); err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+	return convertRecordSet(name, zoneId, zoneName, synced), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	records, err := c.listRecordSet(ctx, zoneId, name, recordType)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	for _, r := range records {
+		if err := c.deleteRecord(ctx, r.Id); err != nil {
+			return fmt.Errorf("can't Delete: %w", err)
+		}
+	}
+	return nil
+}
+
+// getZoneName returns the zone name of the zone id
+func (c *Client) getZoneName(ctx context.Context, zoneId string) (string, error) {
+	if zoneName, ok := c.zoneNames.Load(zoneId); ok {
+		return zoneName.(string), nil
+	}
+
+	z := &zoneResponse{}
+	if err := c.do(ctx, http.MethodGet, zonePath(zoneId), nil, nil, z); err != nil {
+		return "", fmt.Errorf("can't get zone %s: %w", zoneId, err)
+	}
+	zoneName := strings.ToLower(strings.TrimSuffix(z.Zone.Name, "."))
+	c.zoneNames.Store(zoneId, zoneName)
+	return zoneName, nil
+}
+
+// listRecordSet returns the records which have the name and type.
+// the api doesn't filter the records by name, so all the pages of the zone are looked through.
+func (c *Client) listRecordSet(ctx context.Context, zoneId, name, recordType string) ([]Record, error) {
+	query := url.Values{"zone_id": {zoneId}, "per_page": {strconv.Itoa(perPage)}}
+
+	matched := make([]Record, 0)
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		records := &recordsPage{}
+		if err := c.do(ctx, http.MethodGet, "/records", query, nil, records); err != nil {
+			return nil, err
+		}
+		for _, r := range records.Records {
+			if strings.EqualFold(r.Name, name) && r.Type == recordType {
+				matched = append(matched, r)
+			}
+		}
+		if page >= records.Meta.Pagination.LastPage {
+			return matched, nil
+		}
+	}
+}
+
+func (c *Client) createRecord(ctx context.Context, record Record) (Record, error) {
+	resp := &recordResponse{}
+	if err := c.do(ctx, http.MethodPost, "/records", nil, record, resp); err != nil {
+		return Record{}, err
+	}
+	return resp.Record, nil
+}
+
+func (c *Client) updateRecord(ctx context.Context, recordId string, record Record) (Record, error) {
+	resp := &recordResponse{}
+	if err := c.do(ctx, http.MethodPut, recordPath(recordId), nil, record, resp); err != nil {
+		return Record{}, err
+	}
+	return resp.Record, nil
+}
+
+// deleteRecord deletes the record, which succeeds if the record is already gone
+func (c *Client) deleteRecord(ctx context.Context, recordId string) error {
+	if err := c.do(ctx, http.MethodDelete, recordPath(recordId), nil, nil, nil); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package hetzner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testZoneId   = "aKp3ZD8qMVRNc7wgPxN3Lb"
+	testZoneName = "example.com"
+)
+
+func newTestClient(t *testing.T) (*fakeServer, *Client) {
+	f := newFakeServer(t)
+	f.addZone(testZoneId, testZoneName, false)
+	return f, f.newClient(t)
+}
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	z, err := c.GetZone(ctx, "Example.COM.")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: testZoneId, Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: unexpected zone %+v", z)
+	}
+	if _, err := c.GetZone(ctx, "example.org"); err == nil || !strings.Contains(err.Error(), "cannot find zone") {
+		t.Fatalf("GetZone: expected error for unknown zone, got %v", err)
+	}
+
+	d, err := c.Create(ctx, "www", z.Id, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("www", provider.RecordTypeA),
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.0.2.1", "192.0.2.2"},
+		TTL:       ttlDefault,
+		ZoneId:    testZoneId,
+		ZoneName:  testZoneName,
+		FQDN:      "www.example.com.",
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+
+	// the zone name is looked up by a client which didn't get the zone
+	got, err := f.newClient(t).Get(ctx, d.Id, z.Id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Get: expected %+v, got %+v", expected, got)
+	}
+
+	d, err = c.Update(ctx, d.Id, z.Id, provider.RecordTypeCNAME, []string{"lb.example.net"}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != "www/CNAME" || !reflect.DeepEqual(d.Records, []string{"lb.example.net"}) || d.TTL != 60 {
+		t.Fatalf("Update: unexpected recordset %+v", d)
+	}
+	if got := f.values(testZoneId, "www", provider.RecordTypeCNAME); !reflect.DeepEqual(got, []string{"lb.example.net."}) {
+		t.Fatalf("Update: expected CNAME with fully-qualified hostname, got %v", got)
+	}
+	if got := f.values(testZoneId, "www", provider.RecordTypeA); len(got) > 0 {
+		t.Fatalf("Update: expected A records removed, got %v", got)
+	}
+
+	if err := c.Delete(ctx, d.Id, z.Id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.values(testZoneId, "www", provider.RecordTypeCNAME); len(got) > 0 {
+		t.Fatalf("Delete: expected no records, got %v", got)
+	}
+}
+
+func TestClientTXTRecordSet(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	long := strings.Repeat("a", provider.TXTStringMaxLength+10)
+	records := []string{`heritage=dns-ingress,"quoted\"`, long}
+	if _, err := c.Create(ctx, "_owner", testZoneId, provider.RecordTypeTXT, records, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got := f.values(testZoneId, "_owner", provider.RecordTypeTXT); !reflect.DeepEqual(got, []string{
+		`"` + long[:provider.TXTStringMaxLength] + `" "` + long[provider.TXTStringMaxLength:] + `"`,
+		`"heritage=dns-ingress,\"quoted\\\""`,
+	}) {
+		t.Fatalf("Create: expected quoted TXT values, got %v", got)
+	}
+
+	// the value made outside of dns-ingress may be unquoted
+	f.addRecord(Record{ZoneId: testZoneId, Type: provider.RecordTypeTXT, Name: "_other", Value: "v=spf1 -all", TTL: 60})
+	d, err := c.GetByName(ctx, "_other", testZoneId, provider.RecordTypeTXT)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, []string{"v=spf1 -all"}) {
+		t.Fatalf("GetByName: expected unquoted value as is, got %v", d.Records)
+	}
+
+	d, err = c.GetByName(ctx, "_owner", testZoneId, provider.RecordTypeTXT)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if want := []string{long, records[0]}; !reflect.DeepEqual(d.Records, want) {
+		t.Fatalf("GetByName: expected records %v, got %v", want, d.Records)
+	}
+}
+
+func TestClientUpdateKeepsRecords(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	d, err := c.Create(ctx, "@", testZoneId, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 300, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	ids := func() map[string]string {
+		ids := map[string]string{}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, r := range f.records {
+			ids[r.Value] = r.Id
+		}
+		return ids
+	}
+	before := ids()
+
+	n := f.requestCount()
+	if _, err := c.Update(ctx, d.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 300, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := f.requestCount() - n; got != 1 {
+		t.Fatalf("Update: expected only the lookup for the recordset in sync, got %d requests", got)
+	}
+
+	d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.3"}, 600, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, []string{"192.0.2.2", "192.0.2.3"}) || d.TTL != 600 || d.FQDN != "example.com." {
+		t.Fatalf("Update: unexpected recordset %+v", d)
+	}
+	after := ids()
+	if after["192.0.2.2"] != before["192.0.2.2"] {
+		t.Fatalf("Update: expected the record of the kept value updated in place, got %v and %v", before, after)
+	}
+	if _, found := after["192.0.2.1"]; found {
+		t.Fatalf("Update: expected the stale record removed, got %v", after)
+	}
+}
+
+func TestClientPagination(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+	f.pageSize = 2
+	f.addZone("zone-other", "example.org", true)
+
+	expected := make([]string, 0)
+	for i := 1; i <= 5; i++ {
+		f.addRecord(Record{ZoneId: testZoneId, Type: provider.RecordTypeA, Name: "www", Value: fmt.Sprintf("192.0.2.%d", i), TTL: 120})
+		expected = append(expected, fmt.Sprintf("192.0.2.%d", i))
+	}
+	f.addRecord(Record{ZoneId: testZoneId, Type: provider.RecordTypeTXT, Name: "www", Value: "heritage=dns-ingress", TTL: 120})
+	f.addRecord(Record{ZoneId: "zone-other", Type: provider.RecordTypeA, Name: "www", Value: "198.51.100.1", TTL: 120})
+
+	d, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, expected) || d.TTL != 120 {
+		t.Fatalf("GetByName: expected records of all the pages, got %+v", d)
+	}
+
+	z, err := c.GetZone(ctx, "example.org")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if z.Id != "zone-other" || z.Activated {
+		t.Fatalf("GetZone: expected paused zone not activated, got %+v", z)
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	f.rateLimited = 2
+	if _, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+		t.Fatalf("Create: expected rate-limited requests retried, got %v", err)
+	}
+	if got := f.values(testZoneId, "www", provider.RecordTypeA); len(got) != 1 {
+		t.Fatalf("Create: expected the record created once, got %v", got)
+	}
+
+	f.mu.Lock()
+	f.rateLimited = maxRateLimitRetries + 1
+	f.mu.Unlock()
+	_, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeA)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Message != "API rate limit exceeded" {
+		t.Fatalf("GetByName: expected rate limit error once retries are exhausted, got %v", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	if err := c.Verify(ctx); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	unauthorized, err := NewHetznerClient(f.URL, "invalid", f.Client())
+	if err != nil {
+		t.Fatalf("NewHetznerClient: %v", err)
+	}
+	if err := unauthorized.Verify(ctx); err == nil || !strings.Contains(err.Error(), "Invalid authentication credentials") {
+		t.Fatalf("Verify: expected error of invalid token, got %v", err)
+	}
+
+	if _, err := c.Create(ctx, "www", "unknown", provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err == nil || !strings.Contains(err.Error(), "zone not found") {
+		t.Fatalf("Create: expected error for unknown zone, got %v", err)
+	}
+}
+
+func TestNewHetznerClientWithSettings(t *testing.T) {
+	c, err := NewHetznerClientWithSettings(map[string]string{SettingKeyAPIToken: testAPIToken})
+	if err != nil {
+		t.Fatalf("NewHetznerClientWithSettings: %v", err)
+	}
+	if apiUrl := c.(*Client).apiUrl; apiUrl != DefaultAPIUrl {
+		t.Fatalf("expected default api url, got %s", apiUrl)
+	}
+	for _, invalid := range []map[string]string{{}, {SettingKeyAPIToken: testAPIToken, SettingKeyAPIUrl: "dns.hetzner.com"}} {
+		if _, err := NewHetznerClientWithSettings(invalid); err == nil {
+			t.Fatalf("NewHetznerClientWithSettings: expected error for settings %v", invalid)
+		}
+	}
+}
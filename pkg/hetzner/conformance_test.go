@@ -0,0 +1,13 @@
+package hetzner
+
+import (
+	"github.com/sokdak/dns-ingress/pkg/provider/providertest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	providertest.RunConformance(t, func(t *testing.T) providertest.Fixture {
+		_, c := newTestClient(t)
+		return providertest.Fixture{Client: c, ZoneName: testZoneName}
+	})
+}
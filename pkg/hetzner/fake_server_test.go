@@ -0,0 +1,258 @@
+package hetzner
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testAPIToken = "hetzner-dns-token"
+
+// fakeServer is a minimal stand-in of the Hetzner DNS api serving the zones and their records
+type fakeServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	zones []Zone
+	// records in order of creation
+	records []Record
+	nextId  int
+	// pageSize caps per_page of the requests to make the responses paginated
+	pageSize int
+	// rateLimited is the number of the next requests responded with 429
+	rateLimited int
+	// requests counts the requests served, including the rate-limited ones
+	requests int
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	f := &fakeServer{pageSize: perPage}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeServer) newClient(t *testing.T) *Client {
+	c, err := NewHetznerClient(f.URL, testAPIToken, f.Client())
+	if err != nil {
+		t.Fatalf("NewHetznerClient: %v", err)
+	}
+	return c
+}
+
+func (f *fakeServer) addZone(id, name string, paused bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.zones = append(f.zones, Zone{Id: id, Name: name, TTL: 86400, Status: "verified", Paused: paused})
+}
+
+// addRecord seeds the record under the next id, bypassing the api
+func (f *fakeServer) addRecord(r Record) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextId++
+	r.Id = fmt.Sprintf("record-%d", f.nextId)
+	f.records = append(f.records, r)
+}
+
+// values returns the sorted values of the records which have the name and type
+func (f *fakeServer) values(zoneId, name, recordType string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	values := make([]string, 0)
+	for _, r := range f.records {
+		if r.ZoneId == zoneId && r.Name == name && r.Type == recordType {
+			values = append(values, r.Value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+func (f *fakeServer) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func (f *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	if r.Header.Get(apiKeyHeader) != testAPIToken {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Invalid authentication credentials"})
+		return
+	}
+	if f.rateLimited > 0 {
+		f.rateLimited--
+		w.Header().Set("Retry-After", "0")
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"message": "API rate limit exceeded"})
+		return
+	}
+
+	// /api/v1/zones[/{id}], /api/v1/records[/{id}]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, apiPrefix+"/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "zones" && r.Method == http.MethodGet:
+		f.listZones(w, r)
+	case len(parts) == 2 && parts[0] == "zones" && r.Method == http.MethodGet:
+		for _, z := range f.zones {
+			if z.Id == parts[1] {
+				writeJSON(w, http.StatusOK, &zoneResponse{Zone: z})
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "zone not found")
+	case len(parts) == 1 && parts[0] == "records" && r.Method == http.MethodGet:
+		f.listRecords(w, r)
+	case len(parts) == 1 && parts[0] == "records" && r.Method == http.MethodPost:
+		record := Record{}
+		if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := f.validate("", record); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		f.nextId++
+		record.Id = fmt.Sprintf("record-%d", f.nextId)
+		f.records = append(f.records, record)
+		writeJSON(w, http.StatusOK, &recordResponse{Record: record})
+	case len(parts) == 2 && parts[0] == "records":
+		i := -1
+		for j, record := range f.records {
+			if record.Id == parts[1] {
+				i = j
+			}
+		}
+		if i < 0 {
+			writeError(w, http.StatusNotFound, "record not found")
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			record := Record{}
+			if err := json.NewDecoder(r.Body).Decode(&record); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if err := f.validate(parts[1], record); err != nil {
+				writeError(w, http.StatusUnprocessableEntity, err.Error())
+				return
+			}
+			record.Id = parts[1]
+			f.records[i] = record
+			writeJSON(w, http.StatusOK, &recordResponse{Record: record})
+		case http.MethodDelete:
+			f.records = append(f.records[:i:i], f.records[i+1:]...)
+			w.WriteHeader(http.StatusOK)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (f *fakeServer) listZones(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	zones := make([]Zone, 0)
+	for _, z := range f.zones {
+		if len(name) == 0 || z.Name == name {
+			zones = append(zones, z)
+		}
+	}
+	if len(name) > 0 && len(zones) == 0 {
+		writeError(w, http.StatusNotFound, "zone not found")
+		return
+	}
+
+	page, pagination := f.paginate(r, len(zones))
+	writeJSON(w, http.StatusOK, &zonesPage{Zones: zones[page[0]:page[1]], Meta: meta{Pagination: pagination}})
+}
+
+func (f *fakeServer) listRecords(w http.ResponseWriter, r *http.Request) {
+	zoneId := r.URL.Query().Get("zone_id")
+	records := make([]Record, 0)
+	for _, record := range f.records {
+		if len(zoneId) == 0 || record.ZoneId == zoneId {
+			records = append(records, record)
+		}
+	}
+
+	page, pagination := f.paginate(r, len(records))
+	writeJSON(w, http.StatusOK, &recordsPage{Records: records[page[0]:page[1]], Meta: meta{Pagination: pagination}})
+}
+
+// paginate returns the range of the requested page among the entries
+func (f *fakeServer) paginate(r *http.Request, total int) ([2]int, Pagination) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	size, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if size < 1 || size > f.pageSize {
+		size = f.pageSize
+	}
+
+	lastPage := (total + size - 1) / size
+	if lastPage < 1 {
+		lastPage = 1
+	}
+	start, end := (page-1)*size, page*size
+	if start > total {
+		start = total
+	}
+	if end > total {
+		end = total
+	}
+	return [2]int{start, end}, Pagination{Page: page, PerPage: size, LastPage: lastPage, TotalEntries: total}
+}
+
+// validate checks the record as the api does, the record of the id is the one being updated
+func (f *fakeServer) validate(id string, record Record) error {
+	found := false
+	for _, z := range f.zones {
+		found = found || z.Id == record.ZoneId
+	}
+	if !found {
+		return fmt.Errorf("zone not found")
+	}
+	if len(record.Name) == 0 || len(record.Value) == 0 {
+		return fmt.Errorf("invalid record: name and value are required")
+	}
+	if record.Type == "TXT" && len(record.Value) > provider.TXTStringMaxLength && !strings.HasPrefix(record.Value, `"`) {
+		return fmt.Errorf("invalid TXT record: value longer than 255 characters has to be split into quoted strings")
+	}
+	for _, r := range f.records {
+		if r.Id == id || r.ZoneId != record.ZoneId || r.Name != record.Name {
+			continue
+		}
+		if (r.Type == "CNAME") != (record.Type == "CNAME") {
+			return fmt.Errorf("invalid record: CNAME can't coexist with other records")
+		}
+		if r.Type == record.Type && r.Value == record.Value {
+			return fmt.Errorf("record already exists")
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"error": map[string]interface{}{"message": message, "code": status}})
+}
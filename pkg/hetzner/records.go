@@ -0,0 +1,75 @@
+package hetzner
+
+import (
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"sort"
+	"strings"
+)
+
+// ttlDefault is applied if ttl is not set, the api defaults to the ttl of the zone otherwise
+const ttlDefault = 300
+
+func newRecord(zoneId, name, recordType, record string, ttl int) Record {
+	return Record{
+		ZoneId: zoneId,
+		Type:   recordType,
+		Name:   name,
+		Value:  toValue(recordType, record),
+		TTL:    normalizeTTL(ttl),
+	}
+}
+
+// convertRecordSet converts the records of the same name and type into a recordset
+func convertRecordSet(name, zoneId, zoneName string, records []Record) *provider.Domain {
+	values := make([]string, 0, len(records))
+	for _, r := range records {
+		values = append(values, fromValue(r.Type, r.Value))
+	}
+	sort.Strings(values)
+
+	r := records[0]
+	return &provider.Domain{
+		Id:        provider.GenerateRecordSetId(name, r.Type),
+		Name:      name,
+		Type:      r.Type,
+		Records:   values,
+		TTL:       r.TTL,
+		ZoneId:    zoneId,
+		ZoneName:  zoneName,
+		FQDN:      fmt.Sprintf("%s.", provider.JoinName(name, zoneName)),
+		Activated: true,
+	}
+}
+
+// toValue returns the value of the record in the zone file format the api requires,
+// the hostname of CNAME has to be fully-qualified and TXT is quoted
+func toValue(recordType, record string) string {
+	switch recordType {
+	case provider.RecordTypeCNAME:
+		return fmt.Sprintf("%s.", strings.TrimSuffix(record, "."))
+	case provider.RecordTypeTXT:
+		return provider.QuoteTXT(record)
+	default:
+		return record
+	}
+}
+
+// fromValue returns the record in the form given on Create, reverse of toValue
+func fromValue(recordType, value string) string {
+	switch recordType {
+	case provider.RecordTypeCNAME:
+		return strings.TrimSuffix(value, ".")
+	case provider.RecordTypeTXT:
+		return provider.UnquoteTXT(value)
+	default:
+		return value
+	}
+}
+
+func normalizeTTL(ttl int) int {
+	if ttl <= 0 {
+		return ttlDefault
+	}
+	return ttl
+}
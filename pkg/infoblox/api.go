@@ -0,0 +1,274 @@
+package infoblox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultWAPIVersion is the wapi version of NIOS 8.6, which is supported by the later releases as well
+	DefaultWAPIVersion = "2.11"
+	// DefaultView is the dns view of the grid if not configured otherwise
+	DefaultView = "default"
+)
+
+// maxResults is the page size of the listings
+const maxResults = 1000
+
+// wapi object types
+const (
+	objectTypeZoneAuth    = "zone_auth"
+	objectTypeView        = "view"
+	objectTypeRecordA     = "record:a"
+	objectTypeRecordAAAA  = "record:aaaa"
+	objectTypeRecordCNAME = "record:cname"
+	objectTypeRecordTXT   = "record:txt"
+	objectTypeRecordHost  = "record:host"
+	// the addresses of the host record are objects of their own
+	objectTypeHostIpv4Addr = "record:host_ipv4addr"
+	objectTypeHostIpv6Addr = "record:host_ipv6addr"
+)
+
+// codeNotFound is the error code of the references to the objects which don't exist
+const codeNotFound = "Client.Ibap.Data.NotFound"
+
+// ZoneAuth is the authoritative zone object of the view
+type ZoneAuth struct {
+	Ref     string `json:"_ref,omitempty"`
+	FQDN    string `json:"fqdn"`
+	View    string `json:"view"`
+	Disable bool   `json:"disable,omitempty"`
+}
+
+// View is the dns view object
+type View struct {
+	Ref  string `json:"_ref,omitempty"`
+	Name string `json:"name"`
+}
+
+// Record is the union of record:a, record:aaaa, record:cname and record:txt objects, only the field of its type is set.
+// the name is fully-qualified without trailing dot, the ttl of the zone is applied if use_ttl is not set.
+type Record struct {
+	Ref       string `json:"_ref,omitempty"`
+	Name      string `json:"name"`
+	View      string `json:"view,omitempty"`
+	Ipv4Addr  string `json:"ipv4addr,omitempty"`
+	Ipv6Addr  string `json:"ipv6addr,omitempty"`
+	Canonical string `json:"canonical,omitempty"`
+	Text      string `json:"text,omitempty"`
+	TTL       int    `json:"ttl,omitempty"`
+	UseTTL    bool   `json:"use_ttl"`
+	Disable   bool   `json:"disable,omitempty"`
+}
+
+// HostRecord is the record:host object, which registers its addresses in IPAM and serves them as A and AAAA records
+type HostRecord struct {
+	Ref             string        `json:"_ref,omitempty"`
+	Name            string        `json:"name"`
+	View            string        `json:"view,omitempty"`
+	Ipv4Addrs       []HostAddress `json:"ipv4addrs,omitempty"`
+	Ipv6Addrs       []HostAddress `json:"ipv6addrs,omitempty"`
+	ConfigureForDNS bool          `json:"configure_for_dns"`
+	TTL             int           `json:"ttl,omitempty"`
+	UseTTL          bool          `json:"use_ttl"`
+	Disable         bool          `json:"disable,omitempty"`
+}
+
+// HostAddress is an address of the host record, either ipv4addr or ipv6addr is set.
+// host is the name of the host record the address belongs to, which is read-only.
+type HostAddress struct {
+	Ref      string `json:"_ref,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Ipv4Addr string `json:"ipv4addr,omitempty"`
+	Ipv6Addr string `json:"ipv6addr,omitempty"`
+}
+
+// returnFields are the fields of the objects requested from the api, the others are omitted by default
+var returnFields = map[string]string{
+	objectTypeZoneAuth:     "fqdn,view,disable",
+	objectTypeView:         "name",
+	objectTypeRecordA:      "name,view,ipv4addr,ttl,use_ttl,disable",
+	objectTypeRecordAAAA:   "name,view,ipv6addr,ttl,use_ttl,disable",
+	objectTypeRecordCNAME:  "name,view,canonical,ttl,use_ttl,disable",
+	objectTypeRecordTXT:    "name,view,text,ttl,use_ttl,disable",
+	objectTypeRecordHost:   "name,view,ipv4addrs,ipv6addrs,configure_for_dns,ttl,use_ttl,disable",
+	objectTypeHostIpv4Addr: "host,ipv4addr",
+	objectTypeHostIpv6Addr: "host,ipv6addr",
+}
+
+// page is the paged listing of the objects, next_page_id is set until the last page
+type page struct {
+	Result     json.RawMessage `json:"result"`
+	NextPageId string          `json:"next_page_id,omitempty"`
+}
+
+// APIError is returned if the api responds with error status
+type APIError struct {
+	StatusCode int
+	// Code is the error code of the api, e.g. Client.Ibap.Data.Conflict
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("infoblox wapi error %d: %s", e.StatusCode, e.Message)
+}
+
+// errorResponse is the body of the errors, Error is prefixed with the internal error name which text omits
+type errorResponse struct {
+	Error string `json:"Error"`
+	Code  string `json:"code"`
+	Text  string `json:"text"`
+}
+
+func isNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.Code == codeNotFound)
+}
+
+// objectType returns the object type of the reference, e.g. record:a of record:a/ZG5zLmJpbmRfYSQ...:www.example.com/default
+func objectType(ref string) string {
+	if idx := strings.Index(ref, "/"); idx > 0 {
+		return ref[:idx]
+	}
+	return ref
+}
+
+// isObjectRef returns true if the id is a reference to a record object, rather than the synthetic recordset id
+func isObjectRef(id string) bool {
+	return strings.HasPrefix(id, "record:") && strings.Contains(id, "/")
+}
+
+// refPath returns the path of the object reference, each segment is escaped since the reference contains the names
+func refPath(ref string) string {
+	segments := strings.Split(ref, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// do sends the request to the api, the response is decoded into out if given
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	u := c.wapiUrl + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("can't encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return fmt.Errorf("can't create request: %w", err)
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("can't read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		errResp := &errorResponse{}
+		if err := json.Unmarshal(respBody, errResp); err == nil {
+			apiErr.Code = errResp.Code
+			apiErr.Message = errResp.Text
+			if len(apiErr.Message) == 0 {
+				apiErr.Message = errResp.Error
+			}
+		}
+		if len(apiErr.Message) == 0 {
+			apiErr.Message = string(bytes.TrimSpace(respBody))
+		}
+		return apiErr
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("can't decode response: %w", err)
+	}
+	return nil
+}
+
+// listObjects returns the objects of the type which match the query, looking through all the pages
+func listObjects[T any](ctx context.Context, c *Client, objType string, query url.Values) ([]T, error) {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("_return_fields", returnFields[objType])
+	q.Set("_paging", "1")
+	q.Set("_return_as_object", "1")
+	q.Set("_max_results", strconv.Itoa(maxResults))
+
+	objects := make([]T, 0)
+	for {
+		p := &page{}
+		if err := c.do(ctx, http.MethodGet, "/"+objType, q, nil, p); err != nil {
+			return nil, err
+		}
+		result := make([]T, 0)
+		if len(p.Result) > 0 {
+			if err := json.Unmarshal(p.Result, &result); err != nil {
+				return nil, fmt.Errorf("can't decode %s objects: %w", objType, err)
+			}
+		}
+		objects = append(objects, result...)
+		if len(p.NextPageId) == 0 {
+			return objects, nil
+		}
+		q.Set("_page_id", p.NextPageId)
+	}
+}
+
+// getObject gets the object of the reference
+func (c *Client) getObject(ctx context.Context, ref string, out interface{}) error {
+	query := url.Values{"_return_fields": {returnFields[objectType(ref)]}}
+	return c.do(ctx, http.MethodGet, refPath(ref), query, nil, out)
+}
+
+// createObject creates the object of the type, the created object is decoded into out
+func (c *Client) createObject(ctx context.Context, objType string, in, out interface{}) error {
+	query := url.Values{"_return_fields": {returnFields[objType]}}
+	return c.do(ctx, http.MethodPost, "/"+objType, query, in, out)
+}
+
+// updateObject updates the fields of the object given in, the updated object is decoded into out
+func (c *Client) updateObject(ctx context.Context, ref string, in, out interface{}) error {
+	query := url.Values{"_return_fields": {returnFields[objectType(ref)]}}
+	return c.do(ctx, http.MethodPut, refPath(ref), query, in, out)
+}
+
+// deleteObject deletes the object, which succeeds if the object is already gone
+func (c *Client) deleteObject(ctx context.Context, ref string) error {
+	if err := c.do(ctx, http.MethodDelete, refPath(ref), nil, nil, nil); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
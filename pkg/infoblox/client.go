@@ -0,0 +1,548 @@
+package infoblox
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ProviderKey = "infoblox"
+
+const (
+	// SettingKeyURL is the url of the grid master, e.g. https://gm.example.com
+	SettingKeyURL      = "url"
+	SettingKeyUsername = "username"
+	SettingKeyPassword = "password"
+	// SettingKeyWAPIVersion overrides the wapi version, which defaults to DefaultWAPIVersion
+	SettingKeyWAPIVersion = "wapiVersion"
+	// SettingKeyView is the dns view of the zones, which defaults to DefaultView
+	SettingKeyView = "view"
+	// SettingKeyInsecureSkipVerify skips verifying the certificate of the grid master, which is often self-signed
+	SettingKeyInsecureSkipVerify = "insecureSkipVerify"
+)
+
+var DefaultHttpClient = &http.Client{Timeout: 30 * time.Second}
+
+func init() {
+	provider.Register(ProviderKey, NewInfobloxClientWithSettings)
+}
+
+// Client manages the records of the authoritative zones of a dns view on Infoblox NIOS over the wapi.
+// the api has no recordsets, so a recordset is made of the record objects which have the same name and type.
+// the recordset id is the reference of one of its objects, the record or the address of the host record,
+// which resolves to the recordset the object belongs to.
+type Client struct {
+	provider.Client
+
+	wapiUrl    string
+	username   string
+	password   string
+	view       string
+	httpClient *http.Client
+
+	// zones caches zone by the reference of zone_auth
+	zones sync.Map
+}
+
+// zoneInfo is the cached zone, the records of the zone are in the view of the zone
+type zoneInfo struct {
+	name string
+	view string
+}
+
+// NewInfobloxClientWithSettings creates the client with the provider settings
+func NewInfobloxClientWithSettings(settings map[string]string) (provider.Client, error) {
+	version := settings[SettingKeyWAPIVersion]
+	if len(version) == 0 {
+		version = DefaultWAPIVersion
+	}
+	httpClient := DefaultHttpClient
+	if value, ok := settings[SettingKeyInsecureSkipVerify]; ok {
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("can't create new infoblox client: can't parse %s: %w", SettingKeyInsecureSkipVerify, err)
+		}
+		if insecure {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+			httpClient = &http.Client{Timeout: DefaultHttpClient.Timeout, Transport: transport}
+		}
+	}
+
+	wapiUrl := fmt.Sprintf("%s/wapi/v%s", strings.TrimSuffix(settings[SettingKeyURL], "/"), strings.TrimPrefix(version, "v"))
+	c, err := NewInfobloxClient(wapiUrl, settings[SettingKeyUsername], settings[SettingKeyPassword], settings[SettingKeyView], httpClient)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewInfobloxClient creates the client of the wapi served at the url, e.g. https://gm.example.com/wapi/v2.11.
+// the zones are looked up in the view, which defaults to DefaultView.
+func NewInfobloxClient(wapiUrl, username, password, view string, client *http.Client) (*Client, error) {
+	if len(username) == 0 || len(password) == 0 {
+		return nil, fmt.Errorf("can't create new infoblox client: both %s and %s are required", SettingKeyUsername, SettingKeyPassword)
+	}
+	u, err := url.Parse(wapiUrl)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return nil, fmt.Errorf("can't create new infoblox client: invalid url %s", wapiUrl)
+	}
+	if len(view) == 0 {
+		view = DefaultView
+	}
+	if client == nil {
+		client = DefaultHttpClient
+	}
+
+	return &Client{
+		wapiUrl:    strings.TrimSuffix(wapiUrl, "/"),
+		username:   username,
+		password:   password,
+		view:       view,
+		httpClient: client,
+	}, nil
+}
+
+// Verify checks the credentials are accepted and the view exists
+func (c *Client) Verify(ctx context.Context) error {
+	views, err := listObjects[View](ctx, c, objectTypeView, url.Values{"name": {c.view}})
+	if err != nil {
+		return fmt.Errorf("can't verify credentials: %w", err)
+	}
+	if len(views) == 0 {
+		return fmt.Errorf("can't verify view: view %s not found", c.view)
+	}
+	return nil
+}
+
+func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone, error) {
+	name := strings.ToLower(strings.TrimSuffix(zoneName, "."))
+	if len(name) == 0 {
+		return nil, fmt.Errorf("can't GetZone: zone name is required")
+	}
+
+	zones, err := listObjects[ZoneAuth](ctx, c, objectTypeZoneAuth, url.Values{"fqdn": {name}, "view": {c.view}})
+	if err != nil {
+		return nil, fmt.Errorf("can't GetZone: %w", err)
+	}
+	for _, z := range zones {
+		if strings.ToLower(z.FQDN) != name {
+			continue
+		}
+		c.zones.Store(z.Ref, zoneInfo{name: name, view: z.View})
+		return &provider.Zone{
+			Id:        z.Ref,
+			Name:      name,
+			Activated: !z.Disable,
+		}, nil
+	}
+	return nil, fmt.Errorf("can't GetZone: cannot find zone %s in view %s", zoneName, c.view)
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	zone, err := c.getZone(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+
+	rs, err := c.listRecordSet(ctx, zone, name, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	if rs.empty(recordType) {
+		return nil, nil
+	}
+	return convertRecordSet(name, zoneId, zone.name, recordType, rs), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, found, err := c.resolveRecordSetId(ctx, id, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, options map[string]string) (*provider.Domain, error) {
+	zone, err := c.getZone(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	objType, ok := recordObjectTypes[recordType]
+	if !ok {
+		return nil, fmt.Errorf("can't Create: unsupported record type %s", recordType)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Create: no records given for %s", name)
+	}
+	hostRecord, err := c.parseHostRecord(recordType, options)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	// the api creates the record regardless of the others, which would merge the records into the existing recordset
+	current, err := c.listRecordSet(ctx, zone, name, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	if !current.empty(recordType) {
+		return nil, fmt.Errorf("can't Create: recordset %s %s already exists", provider.JoinName(name, zone.name), recordType)
+	}
+
+	created := recordSet{records: make([]Record, 0, len(records))}
+	if hostRecord {
+		if created.host, err = c.setHostAddrs(ctx, zone, name, recordType, current.host, records, ttl); err != nil {
+			return nil, fmt.Errorf("can't Create: %w", err)
+		}
+		return convertRecordSet(name, zoneId, zone.name, recordType, created), nil
+	}
+
+	fqdn := provider.JoinName(name, zone.name)
+	for _, r := range records {
+		record := Record{}
+		if err := c.createObject(ctx, objType, newRecord(fqdn, zone.view, recordType, r, ttl), &record); err != nil {
+			return nil, fmt.Errorf("can't Create: %w", err)
+		}
+		created.records = append(created.records, record)
+	}
+	return convertRecordSet(name, zoneId, zone.name, recordType, created), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, options map[string]string) (*provider.Domain, error) {
+	name, currentType, found, err := c.resolveRecordSetId(ctx, id, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if !found {
+		return nil, nil
+	}
+
+	zone, err := c.getZone(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	current, err := c.listRecordSet(ctx, zone, name, currentType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if current.empty(currentType) {
+		return nil, nil
+	}
+
+	objType, ok := recordObjectTypes[recordType]
+	if !ok {
+		return nil, fmt.Errorf("can't Update: unsupported record type %s", recordType)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Update: no records given for %s", name)
+	}
+	hostRecord, err := c.parseHostRecord(recordType, options)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	// if type has been changed, remove the current recordset first since CNAME can't coexist with others
+	if currentType != recordType {
+		if err := c.deleteRecordSet(ctx, current, currentType); err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+		current, err = c.listRecordSet(ctx, zone, name, recordType)
+		if err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+
+	synced := recordSet{records: make([]Record, 0, len(records))}
+	if hostRecord {
+		// the addresses are moved to the host record, then the records are removed to avoid resolution gap
+		if synced.host, err = c.setHostAddrs(ctx, zone, name, recordType, current.host, records, ttl); err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+		for _, r := range current.records {
+			if err := c.deleteObject(ctx, r.Ref); err != nil {
+				return nil, fmt.Errorf("can't Update: %w", err)
+			}
+		}
+		return convertRecordSet(name, zoneId, zone.name, recordType, synced), nil
+	}
+
+	// keep the records which have wanted value, the others are stale
+	wanted := map[string]bool{}
+	for _, r := range records {
+		wanted[fromValue(recordType, toValue(recordType, r))] = true
+	}
+	stale := make([]Record, 0)
+	for _, r := range current.records {
+		value := recordValue(recordType, r)
+		if !wanted[value] {
+			stale = append(stale, r)
+			continue
+		}
+		delete(wanted, value)
+
+		if wantTTL, useTTL := normalizeTTL(ttl); r.TTL != wantTTL || r.UseTTL != useTTL {
+			updated := Record{}
+			if err := c.updateObject(ctx, r.Ref, ttlFields(ttl), &updated); err != nil {
+				return nil, fmt.Errorf("can't Update: %w", err)
+			}
+			r = updated
+		}
+		synced.records = append(synced.records, r)
+	}
+
+	// create the missing records first, then remove the stale ones to avoid resolution gap
+	fqdn := provider.JoinName(name, zone.name)
+	for _, r := range records {
+		value := fromValue(recordType, toValue(recordType, r))
+		if !wanted[value] {
+			continue
+		}
+		delete(wanted, value)
+		record := Record{}
+		if err := c.createObject(ctx, objType, newRecord(fqdn, zone.view, recordType, r, ttl), &record); err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+		synced.records = append(synced.records, record)
+	}
+
+	for _, r := range stale {
+		if err := c.deleteObject(ctx, r.Ref); err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+	if current.host != nil && len(current.hostAddrs(recordType)) > 0 {
+		if err := c.removeHostAddrs(ctx, current.host, recordType); err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+	return convertRecordSet(name, zoneId, zone.name, recordType, synced), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, found, err := c.resolveRecordSetId(ctx, id, zoneId)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	if !found {
+		return nil
+	}
+
+	zone, err := c.getZone(ctx, zoneId)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	rs, err := c.listRecordSet(ctx, zone, name, recordType)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	if err := c.deleteRecordSet(ctx, rs, recordType); err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	return nil
+}
+
+// parseHostRecord returns the hostRecord option, which is only allowed on A and AAAA recordset
+func (c *Client) parseHostRecord(recordType string, options map[string]string) (bool, error) {
+	hostRecord, err := parseHostRecord(options)
+	if err != nil {
+		return false, err
+	}
+	if hostRecord && recordType != provider.RecordTypeA && recordType != provider.RecordTypeAAAA {
+		return false, fmt.Errorf("only A and AAAA records can be a host record, got %s", recordType)
+	}
+	return hostRecord, nil
+}
+
+// resolveRecordSetId returns name and type of the recordset the object of the reference belongs to.
+// the synthetic id of name and type is accepted as well, which the recordsets had before the references.
+func (c *Client) resolveRecordSetId(ctx context.Context, id, zoneId string) (string, string, bool, error) {
+	if !isObjectRef(id) {
+		name, recordType, err := provider.ParseRecordSetId(id)
+		if err != nil {
+			return "", "", false, err
+		}
+		return name, recordType, true, nil
+	}
+	zone, err := c.getZone(ctx, zoneId)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	// the address of the host record belongs to A or AAAA recordset of the host
+	if objType := objectType(id); objType == objectTypeHostIpv4Addr || objType == objectTypeHostIpv6Addr {
+		addr := &HostAddress{}
+		if err := c.getObject(ctx, id, addr); err != nil {
+			if isNotFound(err) {
+				return "", "", false, nil
+			}
+			return "", "", false, err
+		}
+		recordType := provider.RecordTypeA
+		if objType == objectTypeHostIpv6Addr {
+			recordType = provider.RecordTypeAAAA
+		}
+		return provider.RelativeName(addr.Host, zone.name), recordType, true, nil
+	}
+
+	recordType := ""
+	for t, objType := range recordObjectTypes {
+		if objectType(id) == objType {
+			recordType = t
+		}
+	}
+	if len(recordType) == 0 && objectType(id) != objectTypeRecordHost {
+		return "", "", false, fmt.Errorf("unsupported object reference %s", id)
+	}
+
+	// the host record has fields of the records as well, and is resolved to A recordset unless it has ipv6 addresses only
+	host := &HostRecord{}
+	if err := c.getObject(ctx, id, host); err != nil {
+		if isNotFound(err) {
+			return "", "", false, nil
+		}
+		return "", "", false, err
+	}
+	if len(recordType) == 0 {
+		recordType = provider.RecordTypeA
+		if len(host.Ipv4Addrs) == 0 && len(host.Ipv6Addrs) > 0 {
+			recordType = provider.RecordTypeAAAA
+		}
+	}
+	return provider.RelativeName(host.Name, zone.name), recordType, true, nil
+}
+
+// getZone returns the zone of the zone_auth reference
+func (c *Client) getZone(ctx context.Context, zoneId string) (zoneInfo, error) {
+	if zone, ok := c.zones.Load(zoneId); ok {
+		return zone.(zoneInfo), nil
+	}
+
+	z := &ZoneAuth{}
+	if err := c.getObject(ctx, zoneId, z); err != nil {
+		return zoneInfo{}, fmt.Errorf("can't get zone %s: %w", zoneId, err)
+	}
+	zone := zoneInfo{name: strings.ToLower(strings.TrimSuffix(z.FQDN, ".")), view: z.View}
+	c.zones.Store(zoneId, zone)
+	return zone, nil
+}
+
+// listRecordSet returns the records which have the name and type in the view of the zone, along with the host record of the name
+func (c *Client) listRecordSet(ctx context.Context, zone zoneInfo, name, recordType string) (recordSet, error) {
+	objType, ok := recordObjectTypes[recordType]
+	if !ok {
+		return recordSet{}, fmt.Errorf("unsupported record type %s", recordType)
+	}
+	query := url.Values{"name": {provider.JoinName(name, zone.name)}, "view": {zone.view}}
+
+	records, err := listObjects[Record](ctx, c, objType, query)
+	if err != nil {
+		return recordSet{}, err
+	}
+	rs := recordSet{records: records}
+	if recordType != provider.RecordTypeA && recordType != provider.RecordTypeAAAA {
+		return rs, nil
+	}
+
+	hosts, err := listObjects[HostRecord](ctx, c, objectTypeRecordHost, query)
+	if err != nil {
+		return recordSet{}, err
+	}
+	if len(hosts) > 0 {
+		rs.host = &hosts[0]
+	}
+	return rs, nil
+}
+
+// deleteRecordSet deletes the records of the recordset, and the addresses of the host record which belong to it
+func (c *Client) deleteRecordSet(ctx context.Context, rs recordSet, recordType string) error {
+	for _, r := range rs.records {
+		if err := c.deleteObject(ctx, r.Ref); err != nil {
+			return err
+		}
+	}
+	if rs.host != nil && len(rs.hostAddrs(recordType)) > 0 {
+		return c.removeHostAddrs(ctx, rs.host, recordType)
+	}
+	return nil
+}
+
+// setHostAddrs sets the addresses of the record type on the host record of the name, which is created if not exists.
+// the addresses of the other type are kept, while the ttl is shared by both.
+func (c *Client) setHostAddrs(ctx context.Context, zone zoneInfo, name, recordType string, host *HostRecord, records []string, ttl int) (*HostRecord, error) {
+	if host == nil {
+		host = &HostRecord{Name: provider.JoinName(name, zone.name), View: zone.view, ConfigureForDNS: true}
+		host.TTL, host.UseTTL = normalizeTTL(ttl)
+		if recordType == provider.RecordTypeAAAA {
+			host.Ipv6Addrs = newHostAddrs(recordType, records)
+		} else {
+			host.Ipv4Addrs = newHostAddrs(recordType, records)
+		}
+		created := &HostRecord{}
+		if err := c.createObject(ctx, objectTypeRecordHost, host, created); err != nil {
+			return nil, err
+		}
+		return created, nil
+	}
+
+	current := hostAddrs(host, recordType)
+	wanted := append([]string{}, records...)
+	sort.Strings(current)
+	sort.Strings(wanted)
+	if hostTTL, useTTL := normalizeTTL(ttl); strings.Join(current, ",") == strings.Join(wanted, ",") && host.TTL == hostTTL && host.UseTTL == useTTL {
+		return host, nil
+	}
+
+	fields := ttlFields(ttl)
+	fields[hostAddrsField(recordType)] = newHostAddrs(recordType, records)
+	updated := &HostRecord{}
+	if err := c.updateObject(ctx, host.Ref, fields, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// removeHostAddrs removes the addresses of the record type from the host record, which is deleted if no address is left
+func (c *Client) removeHostAddrs(ctx context.Context, host *HostRecord, recordType string) error {
+	other := provider.RecordTypeAAAA
+	if recordType == provider.RecordTypeAAAA {
+		other = provider.RecordTypeA
+	}
+	if len(hostAddrs(host, other)) == 0 {
+		return c.deleteObject(ctx, host.Ref)
+	}
+	fields := map[string]interface{}{hostAddrsField(recordType): []HostAddress{}}
+	return c.updateObject(ctx, host.Ref, fields, nil)
+}
+
+// hostAddrsField returns the field of the host record which has the addresses of the record type
+func hostAddrsField(recordType string) string {
+	if recordType == provider.RecordTypeAAAA {
+		return "ipv6addrs"
+	}
+	return "ipv4addrs"
+}
+
+// ttlFields returns the fields to update the ttl of the object
+func ttlFields(ttl int) map[string]interface{} {
+	value, useTTL := normalizeTTL(ttl)
+	return map[string]interface{}{"ttl": value, "use_ttl": useTTL}
+}
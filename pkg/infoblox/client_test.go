@@ -0,0 +1,339 @@
+package infoblox
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testZoneName = "example.com"
+
+func newTestClient(t *testing.T) (*fakeServer, *Client, string) {
+	f := newFakeServer(t)
+	zoneId := f.addZone(testZoneName, DefaultView, false)
+	return f, f.newClient(t, ""), zoneId
+}
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f, c, zoneId := newTestClient(t)
+
+	z, err := c.GetZone(ctx, "Example.COM.")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: zoneId, Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: unexpected zone %+v", z)
+	}
+	if _, err := c.GetZone(ctx, "example.org"); err == nil || !strings.Contains(err.Error(), "cannot find zone") {
+		t.Fatalf("GetZone: expected error for unknown zone, got %v", err)
+	}
+
+	d, err := c.Create(ctx, "www", z.Id, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	// the recordset is addressed by the reference of its first record
+	expected := &provider.Domain{
+		Id:        f.records[0].Ref,
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.0.2.1", "192.0.2.2"},
+		ZoneId:    zoneId,
+		ZoneName:  testZoneName,
+		FQDN:      "www.example.com.",
+		Activated: true,
+		Options:   map[string]string{OptionKeyHostRecord: "false"},
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+
+	// the zone is looked up by its reference on a client which didn't get the zone
+	got, err := f.newClient(t, "").Get(ctx, d.Id, z.Id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Get: expected %+v, got %+v", expected, got)
+	}
+
+	aRef := d.Id
+	d, err = c.Update(ctx, d.Id, z.Id, provider.RecordTypeCNAME, []string{"lb.example.net."}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if objectType(d.Id) != objectTypeRecordCNAME || !reflect.DeepEqual(d.Records, []string{"lb.example.net"}) || d.TTL != 60 {
+		t.Fatalf("Update: unexpected recordset %+v", d)
+	}
+	if got := f.values("www.example.com", DefaultView, provider.RecordTypeCNAME); !reflect.DeepEqual(got, []string{"lb.example.net"}) {
+		t.Fatalf("Update: expected canonical name without trailing dot, got %v", got)
+	}
+	if got := f.values("www.example.com", DefaultView, provider.RecordTypeA); len(got) > 0 {
+		t.Fatalf("Update: expected A records removed, got %v", got)
+	}
+	if got, err := c.Get(ctx, aRef, z.Id); err != nil || got != nil {
+		t.Fatalf("Get: expected nothing for the reference of the removed recordset, got %+v, %v", got, err)
+	}
+
+	if err := c.Delete(ctx, d.Id, z.Id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.values("www.example.com", DefaultView, provider.RecordTypeCNAME); len(got) > 0 {
+		t.Fatalf("Delete: expected no records, got %v", got)
+	}
+}
+
+func TestClientObjectReference(t *testing.T) {
+	ctx := context.Background()
+	f, c, zoneId := newTestClient(t)
+
+	f.addRecord(objectTypeRecordA, Record{Name: "api.example.com", View: DefaultView, Ipv4Addr: "192.0.2.1", TTL: 120, UseTTL: true})
+	f.addRecord(objectTypeRecordA, Record{Name: "api.example.com", View: DefaultView, Ipv4Addr: "192.0.2.2", TTL: 120, UseTTL: true})
+	ref := f.records[0].Ref
+
+	// the reference of a record resolves to the recordset the record belongs to
+	d, err := c.Get(ctx, ref, zoneId)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if d == nil || d.Id != ref || !reflect.DeepEqual(d.Records, []string{"192.0.2.1", "192.0.2.2"}) || d.TTL != 120 {
+		t.Fatalf("Get: unexpected recordset %+v", d)
+	}
+
+	d, err = c.Update(ctx, ref, zoneId, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.3"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, []string{"192.0.2.2", "192.0.2.3"}) {
+		t.Fatalf("Update: unexpected recordset %+v", d)
+	}
+	if d, err := c.Get(ctx, ref, zoneId); err != nil || d != nil {
+		t.Fatalf("Get: expected nothing for the reference of the removed record, got %+v, %v", d, err)
+	}
+	if err := c.Delete(ctx, ref, zoneId); err != nil {
+		t.Fatalf("Delete: expected the reference of the removed record ignored, got %v", err)
+	}
+
+	if err := c.Delete(ctx, f.records[0].Ref, zoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.values("api.example.com", DefaultView, provider.RecordTypeA); len(got) > 0 {
+		t.Fatalf("Delete: expected the recordset of the reference removed, got %v", got)
+	}
+}
+
+func TestClientHostRecord(t *testing.T) {
+	ctx := context.Background()
+	f, c, zoneId := newTestClient(t)
+	hostOptions := map[string]string{OptionKeyHostRecord: "true"}
+
+	d, err := c.Create(ctx, "app", zoneId, provider.RecordTypeA, []string{"10.0.0.2", "10.0.0.1"}, 300, hostOptions)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, []string{"10.0.0.1", "10.0.0.2"}) || d.TTL != 300 || !reflect.DeepEqual(d.Options, hostOptions) {
+		t.Fatalf("Create: unexpected recordset %+v", d)
+	}
+	host := f.host("app.example.com", DefaultView)
+	if host == nil || len(host.Ipv4Addrs) != 2 || !host.ConfigureForDNS {
+		t.Fatalf("Create: expected host record with the addresses, got %+v", host)
+	}
+	if got := f.values("app.example.com", DefaultView, provider.RecordTypeA); len(got) > 0 {
+		t.Fatalf("Create: expected no A record objects, got %v", got)
+	}
+	if _, err := c.Create(ctx, "app", zoneId, provider.RecordTypeA, []string{"10.0.0.3"}, 300, nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Create: expected error for the addresses of the host record, got %v", err)
+	}
+
+	// AAAA recordset shares the host record of the name, and is addressed by the reference of its address
+	aaaa, err := c.Create(ctx, "app", zoneId, provider.RecordTypeAAAA, []string{"2001:db8::1"}, 300, hostOptions)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	host = f.host("app.example.com", DefaultView)
+	if host == nil || len(host.Ipv4Addrs) != 2 || len(host.Ipv6Addrs) != 1 {
+		t.Fatalf("Create: expected host record with both addresses, got %+v", host)
+	}
+	if aaaa.Id != host.Ipv6Addrs[0].Ref {
+		t.Fatalf("Create: expected the reference of the ipv6 address as id, got %s", aaaa.Id)
+	}
+	if got, err := c.Get(ctx, aaaa.Id, zoneId); err != nil || got == nil || got.Type != provider.RecordTypeAAAA {
+		t.Fatalf("Get: expected AAAA recordset of the host record, got %+v, %v", got, err)
+	}
+
+	n := f.requestCount()
+	if _, err := c.Update(ctx, d.Id, zoneId, provider.RecordTypeA, []string{"10.0.0.1", "10.0.0.2"}, 300, hostOptions); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := f.requestCount() - n; got != 3 {
+		t.Fatalf("Update: expected only the lookups of the reference and the host record in sync, got %d requests", got)
+	}
+
+	// the addresses are moved to A record objects if not a host record anymore
+	d, err = c.Update(ctx, d.Id, zoneId, provider.RecordTypeA, []string{"10.0.0.1"}, 300, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, []string{"10.0.0.1"}) || d.Options[OptionKeyHostRecord] != "false" {
+		t.Fatalf("Update: unexpected recordset %+v", d)
+	}
+	if got := f.values("app.example.com", DefaultView, provider.RecordTypeA); !reflect.DeepEqual(got, []string{"10.0.0.1"}) {
+		t.Fatalf("Update: expected A record objects, got %v", got)
+	}
+	host = f.host("app.example.com", DefaultView)
+	if host == nil || len(host.Ipv4Addrs) != 0 || len(host.Ipv6Addrs) != 1 {
+		t.Fatalf("Update: expected host record with ipv6 address only, got %+v", host)
+	}
+
+	aaaa, err = c.GetByName(ctx, "app", zoneId, provider.RecordTypeAAAA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(aaaa.Records, []string{"2001:db8::1"}) || aaaa.Options[OptionKeyHostRecord] != "true" {
+		t.Fatalf("GetByName: unexpected recordset %+v", aaaa)
+	}
+	if err := c.Delete(ctx, f.host("app.example.com", DefaultView).Ref, zoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if host := f.host("app.example.com", DefaultView); host != nil {
+		t.Fatalf("Delete: expected host record removed with its last address, got %+v", host)
+	}
+
+	if _, err := c.Create(ctx, "txt", zoneId, provider.RecordTypeTXT, []string{"v"}, 0, hostOptions); err == nil {
+		t.Fatalf("Create: expected error for TXT host record")
+	}
+}
+
+func TestClientTXTRecordSet(t *testing.T) {
+	ctx := context.Background()
+	f, c, zoneId := newTestClient(t)
+
+	long := strings.Repeat("a", provider.TXTStringMaxLength+10)
+	records := []string{`heritage=dns-ingress,"quoted\"`, long}
+	if _, err := c.Create(ctx, "_owner", zoneId, provider.RecordTypeTXT, records, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got := f.values("_owner.example.com", DefaultView, provider.RecordTypeTXT); !reflect.DeepEqual(got, []string{
+		`"` + long[:provider.TXTStringMaxLength] + `" "` + long[provider.TXTStringMaxLength:] + `"`,
+		`"heritage=dns-ingress,\"quoted\\\""`,
+	}) {
+		t.Fatalf("Create: expected quoted TXT values, got %v", got)
+	}
+
+	// the value made outside of dns-ingress may be unquoted
+	f.addRecord(objectTypeRecordTXT, Record{Name: "_other.example.com", View: DefaultView, Text: "v=spf1 -all"})
+	d, err := c.GetByName(ctx, "_other", zoneId, provider.RecordTypeTXT)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, []string{"v=spf1 -all"}) || d.TTL != 0 {
+		t.Fatalf("GetByName: expected unquoted value with ttl of the zone, got %+v", d)
+	}
+}
+
+func TestClientViewsAndPaging(t *testing.T) {
+	ctx := context.Background()
+	f, c, _ := newTestClient(t)
+	f.pageSize = 2
+	f.addView("internal")
+	internalZoneId := f.addZone(testZoneName, "internal", true)
+
+	for i := 1; i <= 5; i++ {
+		f.addRecord(objectTypeRecordA, Record{Name: "www.example.com", View: "internal", Ipv4Addr: fmt.Sprintf("10.0.0.%d", i)})
+	}
+	f.addRecord(objectTypeRecordA, Record{Name: "www.example.com", View: DefaultView, Ipv4Addr: "192.0.2.1"})
+
+	internal := f.newClient(t, "internal")
+	if err := internal.Verify(ctx); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	z, err := internal.GetZone(ctx, testZoneName)
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if z.Id != internalZoneId || z.Activated {
+		t.Fatalf("GetZone: expected disabled zone of the view, got %+v", z)
+	}
+
+	d, err := internal.GetByName(ctx, "www", z.Id, provider.RecordTypeA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if len(d.Records) != 5 || d.Records[0] != "10.0.0.1" {
+		t.Fatalf("GetByName: expected records of the view on all the pages, got %+v", d)
+	}
+
+	if _, err := c.Create(ctx, "api", internalZoneId, provider.RecordTypeA, []string{"10.0.1.1"}, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got := f.values("api.example.com", "internal", provider.RecordTypeA); !reflect.DeepEqual(got, []string{"10.0.1.1"}) {
+		t.Fatalf("Create: expected record created in the view of the zone, got %v", got)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	f, c, _ := newTestClient(t)
+
+	if err := c.Verify(ctx); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := f.newClient(t, "external").Verify(ctx); err == nil || !strings.Contains(err.Error(), "view external not found") {
+		t.Fatalf("Verify: expected error of unknown view, got %v", err)
+	}
+	unauthorized, err := NewInfobloxClient(f.URL+testWAPIPath, testUsername, "invalid", "", f.Client())
+	if err != nil {
+		t.Fatalf("NewInfobloxClient: %v", err)
+	}
+	if err := unauthorized.Verify(ctx); err == nil || !strings.Contains(err.Error(), "Authorization Required") {
+		t.Fatalf("Verify: expected error of invalid credentials, got %v", err)
+	}
+
+	if _, err := c.Create(ctx, "www", "zone_auth/unknown:example.org/default", provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("Create: expected error for unknown zone, got %v", err)
+	}
+}
+
+func TestNewInfobloxClientWithSettings(t *testing.T) {
+	c, err := NewInfobloxClientWithSettings(map[string]string{
+		SettingKeyURL:      "https://gm.example.com/",
+		SettingKeyUsername: testUsername,
+		SettingKeyPassword: testPassword,
+	})
+	if err != nil {
+		t.Fatalf("NewInfobloxClientWithSettings: %v", err)
+	}
+	if client := c.(*Client); client.wapiUrl != "https://gm.example.com/wapi/v2.11" || client.view != DefaultView {
+		t.Fatalf("expected default wapi version and view, got %s and %s", client.wapiUrl, client.view)
+	}
+
+	c, err = NewInfobloxClientWithSettings(map[string]string{
+		SettingKeyURL:                "https://gm.example.com",
+		SettingKeyUsername:           testUsername,
+		SettingKeyPassword:           testPassword,
+		SettingKeyWAPIVersion:        "v2.12",
+		SettingKeyView:               "internal",
+		SettingKeyInsecureSkipVerify: "true",
+	})
+	if err != nil {
+		t.Fatalf("NewInfobloxClientWithSettings: %v", err)
+	}
+	client := c.(*Client)
+	if client.wapiUrl != "https://gm.example.com/wapi/v2.12" || client.view != "internal" || client.httpClient == DefaultHttpClient {
+		t.Fatalf("unexpected client %+v", client)
+	}
+
+	for _, invalid := range []map[string]string{
+		{SettingKeyURL: "https://gm.example.com"},
+		{SettingKeyUsername: testUsername, SettingKeyPassword: testPassword},
+		{SettingKeyURL: "https://gm.example.com", SettingKeyUsername: testUsername, SettingKeyPassword: testPassword, SettingKeyInsecureSkipVerify: "maybe"},
+	} {
+		if _, err := NewInfobloxClientWithSettings(invalid); err == nil {
+			t.Fatalf("NewInfobloxClientWithSettings: expected error for settings %v", invalid)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package infoblox
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testUsername = "admin"
+	testPassword = "infoblox"
+	testWAPIPath = "/wapi/v" + DefaultWAPIVersion
+)
+
+// fakeRecord is the record object stored in the fake along with its object type
+type fakeRecord struct {
+	objType string
+	Record
+}
+
+// fakeServer is a minimal stand-in of the wapi serving the views, authoritative zones and their records
+type fakeServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	views []View
+	zones []ZoneAuth
+	// records in order of creation
+	records []fakeRecord
+	hosts   []HostRecord
+	nextId  int
+	// pageSize caps _max_results of the requests to make the responses paged
+	pageSize int
+	// requests counts the requests served
+	requests int
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	f := &fakeServer{pageSize: maxResults}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	f.addView(DefaultView)
+	return f
+}
+
+func (f *fakeServer) newClient(t *testing.T, view string) *Client {
+	c, err := NewInfobloxClient(f.URL+testWAPIPath, testUsername, testPassword, view, f.Client())
+	if err != nil {
+		t.Fatalf("NewInfobloxClient: %v", err)
+	}
+	return c
+}
+
+// ref returns a reference of the object in the form of the wapi
+func (f *fakeServer) ref(objType, name, view string) string {
+	f.nextId++
+	return fmt.Sprintf("%s/ZG5zLm9iamVjdCQ%d:%s/%s", objType, f.nextId, name, view)
+}
+
+func (f *fakeServer) addView(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.views = append(f.views, View{Ref: f.ref(objectTypeView, name, "false"), Name: name})
+}
+
+// addZone adds the zone to the view, and returns the reference of the zone
+func (f *fakeServer) addZone(fqdn, view string, disable bool) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	z := ZoneAuth{Ref: f.ref(objectTypeZoneAuth, fqdn, view), FQDN: fqdn, View: view, Disable: disable}
+	f.zones = append(f.zones, z)
+	return z.Ref
+}
+
+// addRecord seeds the view with the WAPI object under a generated _ref, bypassing the handlers
+func (f *fakeServer) addRecord(objType string, r Record) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r.Ref = f.ref(objType, r.Name, r.View)
+	f.records = append(f.records, fakeRecord{objType: objType, Record: r})
+}
+
+// values returns the sorted values of the record objects which have the name and type in the view
+func (f *fakeServer) values(name, view, recordType string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	values := make([]string, 0)
+	for _, r := range f.records {
+		if r.Name != name || r.View != view || r.objType != recordObjectTypes[recordType] {
+			continue
+		}
+		values = append(values, r.Ipv4Addr+r.Ipv6Addr+r.Canonical+r.Text)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// host returns the host record of the name in the view, nil if not exists
+func (f *fakeServer) host(name, view string) *HostRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, h := range f.hosts {
+		if h.Name == name && h.View == view {
+			return &h
+		}
+	}
+	return nil
+}
+
+func (f *fakeServer) requestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func (f *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	if username, password, ok := r.BasicAuth(); !ok || username != testUsername || password != testPassword {
+		writeError(w, http.StatusUnauthorized, "Client.Ibap.Auth.Failed", "Authorization Required")
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, testWAPIPath+"/") {
+		writeError(w, http.StatusNotFound, "Client.Ibap.Proto", "Unknown WAPI version")
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, testWAPIPath+"/")
+
+	// /{object type} or /{reference}
+	if !strings.Contains(path, "/") {
+		switch r.Method {
+		case http.MethodGet:
+			f.list(w, r, path)
+		case http.MethodPost:
+			f.create(w, r, path)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Client.Ibap.Proto", "method not allowed")
+		}
+		return
+	}
+
+	obj, update, remove := f.lookup(path)
+	if obj == nil {
+		writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("Reference %s not found", path))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, obj)
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Client.Ibap.Proto", err.Error())
+			return
+		}
+		updated, err := update(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Client.Ibap.Data", err.Error())
+			return
+		}
+		writeObject(w, r, updated, path)
+	case http.MethodDelete:
+		remove()
+		writeJSON(w, http.StatusOK, path)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Client.Ibap.Proto", "method not allowed")
+	}
+}
+
+// lookup returns the object of the reference, along with the functions to update and remove it
+func (f *fakeServer) lookup(ref string) (interface{}, func([]byte) (interface{}, error), func()) {
+	for _, z := range f.zones {
+		if z.Ref == ref {
+			return z, nil, nil
+		}
+	}
+	for i := range f.records {
+		if f.records[i].Ref != ref {
+			continue
+		}
+		update := func(body []byte) (interface{}, error) {
+			r := f.records[i].Record
+			if err := json.Unmarshal(body, &r); err != nil {
+				return nil, err
+			}
+			f.records[i].Record = r
+			return r, nil
+		}
+		remove := func() {
+			f.records = append(f.records[:i:i], f.records[i+1:]...)
+		}
+		return f.records[i].Record, update, remove
+	}
+	for i := range f.hosts {
+		if f.hosts[i].Ref != ref {
+			continue
+		}
+		update := func(body []byte) (interface{}, error) {
+			h := f.hosts[i]
+			if err := json.Unmarshal(body, &h); err != nil {
+				return nil, err
+			}
+			if len(h.Ipv4Addrs) == 0 && len(h.Ipv6Addrs) == 0 {
+				return nil, fmt.Errorf("host record requires at least one address")
+			}
+			f.refHostAddrs(&h)
+			f.hosts[i] = h
+			return h, nil
+		}
+		remove := func() {
+			f.hosts = append(f.hosts[:i:i], f.hosts[i+1:]...)
+		}
+		return f.hosts[i], update, remove
+	}
+	for _, h := range f.hosts {
+		for _, a := range append(append([]HostAddress{}, h.Ipv4Addrs...), h.Ipv6Addrs...) {
+			if a.Ref == ref {
+				return a, nil, nil
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// refHostAddrs sets the references of the new addresses of the host record, as the api replaces the addresses given
+func (f *fakeServer) refHostAddrs(h *HostRecord) {
+	for i, a := range h.Ipv4Addrs {
+		if len(a.Ref) == 0 {
+			h.Ipv4Addrs[i].Ref = f.ref(objectTypeHostIpv4Addr, a.Ipv4Addr, h.View)
+		}
+		h.Ipv4Addrs[i].Host = h.Name
+	}
+	for i, a := range h.Ipv6Addrs {
+		if len(a.Ref) == 0 {
+			h.Ipv6Addrs[i].Ref = f.ref(objectTypeHostIpv6Addr, a.Ipv6Addr, h.View)
+		}
+		h.Ipv6Addrs[i].Host = h.Name
+	}
+}
+
+func (f *fakeServer) list(w http.ResponseWriter, r *http.Request, objType string) {
+	query := r.URL.Query()
+	matches := func(field, value string) bool {
+		want, ok := query[field]
+		return !ok || strings.EqualFold(want[0], value)
+	}
+
+	objects := make([]interface{}, 0)
+	switch objType {
+	case objectTypeView:
+		for _, v := range f.views {
+			if matches("name", v.Name) {
+				objects = append(objects, v)
+			}
+		}
+	case objectTypeZoneAuth:
+		for _, z := range f.zones {
+			if matches("fqdn", z.FQDN) && matches("view", z.View) {
+				objects = append(objects, z)
+			}
+		}
+	case objectTypeRecordHost:
+		for _, h := range f.hosts {
+			if matches("name", h.Name) && matches("view", h.View) {
+				objects = append(objects, h)
+			}
+		}
+	default:
+		for _, record := range f.records {
+			if record.objType == objType && matches("name", record.Name) && matches("view", record.View) {
+				objects = append(objects, record.Record)
+			}
+		}
+	}
+
+	if query.Get("_paging") != "1" {
+		writeJSON(w, http.StatusOK, objects)
+		return
+	}
+	if query.Get("_return_as_object") != "1" {
+		writeError(w, http.StatusBadRequest, "Client.Ibap.Proto", "_return_as_object is required for paging")
+		return
+	}
+	size, _ := strconv.Atoi(query.Get("_max_results"))
+	if size < 1 || size > f.pageSize {
+		size = f.pageSize
+	}
+	start, _ := strconv.Atoi(strings.TrimPrefix(query.Get("_page_id"), "page:"))
+	if start > len(objects) {
+		start = len(objects)
+	}
+	end := start + size
+	resp := map[string]interface{}{}
+	if end < len(objects) {
+		resp["next_page_id"] = fmt.Sprintf("page:%d", end)
+	} else {
+		end = len(objects)
+	}
+	resp["result"] = objects[start:end]
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (f *fakeServer) create(w http.ResponseWriter, r *http.Request, objType string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Client.Ibap.Proto", err.Error())
+		return
+	}
+
+	if objType == objectTypeRecordHost {
+		host := HostRecord{}
+		if err := json.Unmarshal(body, &host); err != nil {
+			writeError(w, http.StatusBadRequest, "Client.Ibap.Proto", err.Error())
+			return
+		}
+		if err := f.validate(objType, host.Name, host.View, ""); err != nil {
+			writeError(w, http.StatusBadRequest, "Client.Ibap.Data.Conflict", err.Error())
+			return
+		}
+		if len(host.Ipv4Addrs) == 0 && len(host.Ipv6Addrs) == 0 {
+			writeError(w, http.StatusBadRequest, "Client.Ibap.Data", "host record requires at least one address")
+			return
+		}
+		host.Ref = f.ref(objType, host.Name, host.View)
+		f.refHostAddrs(&host)
+		f.hosts = append(f.hosts, host)
+		writeObject(w, r, host, host.Ref)
+		return
+	}
+
+	if _, ok := returnFields[objType]; !ok || !strings.HasPrefix(objType, "record:") {
+		writeError(w, http.StatusBadRequest, "Client.Ibap.Proto", fmt.Sprintf("Unknown object type (%s)", objType))
+		return
+	}
+	record := Record{}
+	if err := json.Unmarshal(body, &record); err != nil {
+		writeError(w, http.StatusBadRequest, "Client.Ibap.Proto", err.Error())
+		return
+	}
+	value := record.Ipv4Addr + record.Ipv6Addr + record.Canonical + record.Text
+	if len(value) == 0 {
+		writeError(w, http.StatusBadRequest, "Client.Ibap.Proto", "field for the record value is required")
+		return
+	}
+	if objType == objectTypeRecordTXT && len(value) > provider.TXTStringMaxLength && !strings.HasPrefix(value, `"`) {
+		writeError(w, http.StatusBadRequest, "Client.Ibap.Data", "TXT substring longer than 255 bytes has to be quoted")
+		return
+	}
+	if err := f.validate(objType, record.Name, record.View, value); err != nil {
+		writeError(w, http.StatusBadRequest, "Client.Ibap.Data.Conflict", err.Error())
+		return
+	}
+	record.Ref = f.ref(objType, record.Name, record.View)
+	f.records = append(f.records, fakeRecord{objType: objType, Record: record})
+	writeObject(w, r, record, record.Ref)
+}
+
+// validate checks the new object as the api does
+func (f *fakeServer) validate(objType, name, view, value string) error {
+	found := false
+	for _, z := range f.zones {
+		found = found || (z.View == view && (name == z.FQDN || strings.HasSuffix(name, "."+z.FQDN)))
+	}
+	if !found {
+		return fmt.Errorf("The action is not allowed. A parent was not found")
+	}
+
+	isCNAME := objType == objectTypeRecordCNAME
+	for _, h := range f.hosts {
+		if h.Name != name || h.View != view {
+			continue
+		}
+		if isCNAME || objType == objectTypeRecordHost {
+			return fmt.Errorf("The record '%s' already exists", name)
+		}
+	}
+	for _, r := range f.records {
+		if r.Name != name || r.View != view {
+			continue
+		}
+		if isCNAME || r.objType == objectTypeRecordCNAME {
+			return fmt.Errorf("The record '%s' already exists", name)
+		}
+		if r.objType == objType && r.Ipv4Addr+r.Ipv6Addr+r.Canonical+r.Text == value {
+			return fmt.Errorf("The record '%s' already exists", name)
+		}
+	}
+	return nil
+}
+
+// writeObject responds with the object if _return_fields is requested, or with the reference otherwise
+func writeObject(w http.ResponseWriter, r *http.Request, obj interface{}, ref string) {
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+	if _, ok := r.URL.Query()["_return_fields"]; ok {
+		writeJSON(w, status, obj)
+		return
+	}
+	writeJSON(w, status, ref)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, text string) {
+	writeJSON(w, status, map[string]string{"Error": "AdmConProtoError: " + text, "code": code, "text": text})
+}
@@ -0,0 +1,48 @@
+package infoblox
+
+import (
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"strconv"
+)
+
+// OptionKeyHostRecord serves the A or AAAA recordset as the addresses of a host record, which registers them in IPAM
+const OptionKeyHostRecord = "hostRecord"
+
+// ttlMax is the maximum ttl of the records, the ttl of the zone is inherited if not set
+const ttlMax = 2147483647
+
+// NormalizeOptions validates the options with the host record constraints of infoblox
+func (c *Client) NormalizeOptions(recordType string, ttl int, options map[string]string) (map[string]string, error) {
+	for key := range options {
+		if key != OptionKeyHostRecord {
+			return nil, fmt.Errorf("unknown infoblox option %s", key)
+		}
+	}
+
+	hostRecord, err := parseHostRecord(options)
+	if err != nil {
+		return nil, err
+	}
+	if hostRecord && recordType != provider.RecordTypeA && recordType != provider.RecordTypeAAAA {
+		return nil, fmt.Errorf("only A and AAAA records can be a host record, got %s", recordType)
+	}
+	if ttl < 0 || ttl > ttlMax {
+		return nil, fmt.Errorf("ttl must be between 0 and %d, got %d", ttlMax, ttl)
+	}
+
+	return map[string]string{OptionKeyHostRecord: strconv.FormatBool(hostRecord)}, nil
+}
+
+// parseHostRecord returns the hostRecord option, which is false if not set
+func parseHostRecord(options map[string]string) (bool, error) {
+	value, ok := options[OptionKeyHostRecord]
+	if !ok {
+		return false, nil
+	}
+	hostRecord, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("can't parse infoblox option %s: %w", OptionKeyHostRecord, err)
+	}
+	return hostRecord, nil
+}
@@ -0,0 +1,51 @@
+package infoblox
+
+import (
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClientNormalizeOptions(t *testing.T) {
+	c := &Client{}
+	tests := []struct {
+		name       string
+		recordType string
+		ttl        int
+		options    map[string]string
+		want       map[string]string
+		wantErr    string
+	}{
+		{name: "defaults to records", recordType: provider.RecordTypeTXT, ttl: 300,
+			want: map[string]string{OptionKeyHostRecord: "false"}},
+		{name: "host record of A", recordType: provider.RecordTypeA,
+			options: map[string]string{OptionKeyHostRecord: "1"}, want: map[string]string{OptionKeyHostRecord: "true"}},
+		{name: "host record of AAAA", recordType: provider.RecordTypeAAAA, ttl: 60,
+			options: map[string]string{OptionKeyHostRecord: "true"}, want: map[string]string{OptionKeyHostRecord: "true"}},
+		{name: "host record of CNAME", recordType: provider.RecordTypeCNAME,
+			options: map[string]string{OptionKeyHostRecord: "true"}, wantErr: "only A and AAAA records can be a host record"},
+		{name: "invalid host record", recordType: provider.RecordTypeA,
+			options: map[string]string{OptionKeyHostRecord: "yes"}, wantErr: "can't parse infoblox option"},
+		{name: "unknown option", recordType: provider.RecordTypeA,
+			options: map[string]string{"proxied": "true"}, wantErr: "unknown infoblox option proxied"},
+		{name: "negative ttl", recordType: provider.RecordTypeA, ttl: -1, wantErr: "ttl must be between"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.NormalizeOptions(tt.recordType, tt.ttl, tt.options)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeOptions: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
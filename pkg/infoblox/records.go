@@ -0,0 +1,200 @@
+package infoblox
+
+import (
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// recordObjectTypes are the object types of the record types
+var recordObjectTypes = map[string]string{
+	provider.RecordTypeA:     objectTypeRecordA,
+	provider.RecordTypeAAAA:  objectTypeRecordAAAA,
+	provider.RecordTypeCNAME: objectTypeRecordCNAME,
+	provider.RecordTypeTXT:   objectTypeRecordTXT,
+}
+
+// recordSet is the records of the same name and type.
+// the addresses of the host record of the name are part of A and AAAA recordset as well.
+type recordSet struct {
+	records []Record
+	host    *HostRecord
+}
+
+// hostAddrs returns the addresses of the host record which belong to the recordset of the type
+func (rs recordSet) hostAddrs(recordType string) []string {
+	if rs.host == nil {
+		return nil
+	}
+	return hostAddrs(rs.host, recordType)
+}
+
+func (rs recordSet) empty(recordType string) bool {
+	return len(rs.records) == 0 && len(rs.hostAddrs(recordType)) == 0
+}
+
+// hostAddrs returns the addresses of the host record of the record type
+func hostAddrs(host *HostRecord, recordType string) []string {
+	addrs := make([]string, 0)
+	switch recordType {
+	case provider.RecordTypeA:
+		for _, a := range host.Ipv4Addrs {
+			addrs = append(addrs, a.Ipv4Addr)
+		}
+	case provider.RecordTypeAAAA:
+		for _, a := range host.Ipv6Addrs {
+			addrs = append(addrs, a.Ipv6Addr)
+		}
+	}
+	return addrs
+}
+
+// newHostAddrs returns the addresses of the host record of the record type, in the form of the api
+func newHostAddrs(recordType string, records []string) []HostAddress {
+	addrs := make([]HostAddress, 0, len(records))
+	for _, r := range records {
+		if recordType == provider.RecordTypeAAAA {
+			addrs = append(addrs, HostAddress{Ipv6Addr: r})
+		} else {
+			addrs = append(addrs, HostAddress{Ipv4Addr: r})
+		}
+	}
+	return addrs
+}
+
+func newRecord(fqdn, view, recordType, record string, ttl int) Record {
+	r := Record{Name: fqdn, View: view}
+	r.TTL, r.UseTTL = normalizeTTL(ttl)
+	value := toValue(recordType, record)
+	switch recordType {
+	case provider.RecordTypeA:
+		r.Ipv4Addr = value
+	case provider.RecordTypeAAAA:
+		r.Ipv6Addr = value
+	case provider.RecordTypeCNAME:
+		r.Canonical = value
+	case provider.RecordTypeTXT:
+		r.Text = value
+	}
+	return r
+}
+
+// recordValue returns the record in the form given on Create
+func recordValue(recordType string, r Record) string {
+	switch recordType {
+	case provider.RecordTypeA:
+		return r.Ipv4Addr
+	case provider.RecordTypeAAAA:
+		return r.Ipv6Addr
+	case provider.RecordTypeCNAME:
+		return fromValue(recordType, r.Canonical)
+	case provider.RecordTypeTXT:
+		return fromValue(recordType, r.Text)
+	default:
+		return ""
+	}
+}
+
+// convertRecordSet converts the records of the same name and type into a recordset
+func convertRecordSet(name, zoneId, zoneName, recordType string, rs recordSet) *provider.Domain {
+	values := make([]string, 0, len(rs.records))
+	ttl := 0
+	activated := true
+	for _, r := range rs.records {
+		values = append(values, recordValue(recordType, r))
+		ttl = recordTTL(r.TTL, r.UseTTL)
+		activated = activated && !r.Disable
+	}
+	hostRecord := false
+	if addrs := rs.hostAddrs(recordType); len(addrs) > 0 {
+		values = append(values, addrs...)
+		ttl = recordTTL(rs.host.TTL, rs.host.UseTTL)
+		activated = activated && !rs.host.Disable
+		hostRecord = len(rs.records) == 0
+	}
+	sort.Strings(values)
+
+	return &provider.Domain{
+		Id:        recordSetId(name, recordType, rs),
+		Name:      name,
+		Type:      recordType,
+		Records:   values,
+		TTL:       ttl,
+		ZoneId:    zoneId,
+		ZoneName:  zoneName,
+		FQDN:      fmt.Sprintf("%s.", provider.JoinName(name, zoneName)),
+		Activated: activated,
+		Options:   map[string]string{OptionKeyHostRecord: strconv.FormatBool(hostRecord)},
+	}
+}
+
+// recordSetId returns the reference of an object of the recordset, the first one in order of the references to be stable.
+// the synthetic id is returned only if the api didn't give the references, which resolves to the same recordset.
+func recordSetId(name, recordType string, rs recordSet) string {
+	refs := make([]string, 0, len(rs.records))
+	for _, r := range rs.records {
+		refs = append(refs, r.Ref)
+	}
+	if rs.host != nil {
+		addrs := make([]HostAddress, 0)
+		switch recordType {
+		case provider.RecordTypeA:
+			addrs = rs.host.Ipv4Addrs
+		case provider.RecordTypeAAAA:
+			addrs = rs.host.Ipv6Addrs
+		}
+		for _, a := range addrs {
+			refs = append(refs, a.Ref)
+		}
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		if len(ref) > 0 {
+			return ref
+		}
+	}
+	return provider.GenerateRecordSetId(name, recordType)
+}
+
+// normalizeTTL returns the ttl and use_ttl of the object, the ttl of the zone is inherited if not set
+func normalizeTTL(ttl int) (int, bool) {
+	if ttl <= 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// recordTTL returns the ttl of the object, which is 0 if inherited from the zone
+func recordTTL(ttl int, useTTL bool) int {
+	if !useTTL {
+		return 0
+	}
+	return ttl
+}
+
+// toValue returns the value of the record the api requires,
+// the hostname of CNAME has no trailing dot and TXT is quoted
+func toValue(recordType, record string) string {
+	switch recordType {
+	case provider.RecordTypeCNAME:
+		return strings.TrimSuffix(record, ".")
+	case provider.RecordTypeTXT:
+		return provider.QuoteTXT(record)
+	default:
+		return record
+	}
+}
+
+// fromValue returns the record in the form given on Create, reverse of toValue
+func fromValue(recordType, value string) string {
+	switch recordType {
+	case provider.RecordTypeCNAME:
+		return strings.TrimSuffix(value, ".")
+	case provider.RecordTypeTXT:
+		return provider.UnquoteTXT(value)
+	default:
+		return value
+	}
+}
@@ -0,0 +1,303 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const ProviderKey = "memory"
+
+const (
+	// SettingKeyZones are the comma-separated names of the zones the provider serves
+	SettingKeyZones = "zones"
+)
+
+// ttlDefault is applied if ttl is not set
+const ttlDefault = 300
+
+func init() {
+	provider.Register(ProviderKey, NewMemoryClientWithSettings)
+}
+
+// Client keeps the recordsets in memory, which is the reference of the semantics of provider.Client.
+// it serves the tests and the clusters trying dns-ingress out without any dns backend.
+type Client struct {
+	provider.Client
+
+	mu sync.RWMutex
+	// zones by zone id
+	zones map[string]*zone
+	// zoneIds by zone name
+	zoneIds map[string]string
+}
+
+type zone struct {
+	name string
+	// recordSets by recordset id
+	recordSets map[string]provider.Domain
+}
+
+// NewMemoryClientWithSettings creates the client serving the zones of the settings
+func NewMemoryClientWithSettings(settings map[string]string) (provider.Client, error) {
+	var zoneNames []string
+	for _, name := range strings.Split(settings[SettingKeyZones], ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			zoneNames = append(zoneNames, name)
+		}
+	}
+	c, err := NewMemoryClient(zoneNames...)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewMemoryClient creates the client serving the zones of the names, which are empty
+func NewMemoryClient(zoneNames ...string) (*Client, error) {
+	c := &Client{
+		zones:   map[string]*zone{},
+		zoneIds: map[string]string{},
+	}
+	for _, name := range zoneNames {
+		if _, err := c.AddZone(name); err != nil {
+			return nil, fmt.Errorf("can't create new memory client: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// AddZone adds the empty zone of the name, the zone id is opaque as the other providers
+func (c *Client) AddZone(zoneName string) (*provider.Zone, error) {
+	name := normalizeName(zoneName)
+	if len(name) == 0 {
+		return nil, fmt.Errorf("zone name is required")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, found := c.zoneIds[name]; found {
+		return nil, fmt.Errorf("zone %s already exists", name)
+	}
+	id := fmt.Sprintf("zone-%d", len(c.zones)+1)
+	c.zones[id] = &zone{name: name, recordSets: map[string]provider.Domain{}}
+	c.zoneIds[name] = id
+	return &provider.Zone{Id: id, Name: name, Activated: true}, nil
+}
+
+// List returns the recordsets of the zone in order of id
+func (c *Client) List(zoneId string) ([]provider.Domain, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	z, found := c.zones[zoneId]
+	if !found {
+		return nil, fmt.Errorf("can't List: unknown zone id %s", zoneId)
+	}
+
+	recordSets := make([]provider.Domain, 0, len(z.recordSets))
+	for _, d := range z.recordSets {
+		recordSets = append(recordSets, copyDomain(d))
+	}
+	sort.Slice(recordSets, func(i, j int) bool {
+		return recordSets[i].Id < recordSets[j].Id
+	})
+	return recordSets, nil
+}
+
+func (c *Client) GetZone(_ context.Context, zoneName string) (*provider.Zone, error) {
+	name := normalizeName(zoneName)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	id, found := c.zoneIds[name]
+	if !found {
+		return nil, fmt.Errorf("can't GetZone: cannot find zone %s", zoneName)
+	}
+	return &provider.Zone{Id: id, Name: name, Activated: true}, nil
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	d, err := c.Get(ctx, provider.GenerateRecordSetId(name, recordType), zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Get(_ context.Context, id, zoneId string) (*provider.Domain, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	z, found := c.zones[zoneId]
+	if !found {
+		return nil, fmt.Errorf("can't Get: unknown zone id %s", zoneId)
+	}
+
+	d, found := z.recordSets[id]
+	if !found {
+		return nil, nil
+	}
+	d = copyDomain(d)
+	return &d, nil
+}
+
+func (c *Client) Create(_ context.Context, name, zoneId, recordType string, records []string, ttl int, options map[string]string) (*provider.Domain, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	z, found := c.zones[zoneId]
+	if !found {
+		return nil, fmt.Errorf("can't Create: unknown zone id %s", zoneId)
+	}
+
+	id := provider.GenerateRecordSetId(name, recordType)
+	if _, found := z.recordSets[id]; found {
+		return nil, fmt.Errorf("can't Create: recordset %s %s already exists", provider.JoinName(name, z.name), recordType)
+	}
+	d, err := z.newRecordSet(name, zoneId, recordType, records, ttl, options, "")
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	z.recordSets[id] = d
+	d = copyDomain(d)
+	return &d, nil
+}
+
+func (c *Client) Update(_ context.Context, id, zoneId, recordType string, records []string, ttl int, options map[string]string) (*provider.Domain, error) {
+	name, _, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	z, found := c.zones[zoneId]
+	if !found {
+		return nil, fmt.Errorf("can't Update: unknown zone id %s", zoneId)
+	}
+	if _, found := z.recordSets[id]; !found {
+		return nil, nil
+	}
+
+	// the current recordset is replaced, which is excluded from the conflicts of the new one
+	d, err := z.newRecordSet(name, zoneId, recordType, records, ttl, options, id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	delete(z.recordSets, id)
+	z.recordSets[d.Id] = d
+	d = copyDomain(d)
+	return &d, nil
+}
+
+func (c *Client) Delete(_ context.Context, id, zoneId string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	z, found := c.zones[zoneId]
+	if !found {
+		return fmt.Errorf("can't Delete: unknown zone id %s", zoneId)
+	}
+	delete(z.recordSets, id)
+	return nil
+}
+
+// newRecordSet validates the records as a dns server would, the recordset of the replaced id is ignored on conflicts
+func (z *zone) newRecordSet(name, zoneId, recordType string, records []string, ttl int, options map[string]string, replacedId string) (provider.Domain, error) {
+	if len(name) == 0 {
+		return provider.Domain{}, fmt.Errorf("record name is required")
+	}
+	if len(records) == 0 {
+		return provider.Domain{}, fmt.Errorf("no records given for %s", name)
+	}
+
+	normalized := make([]string, 0, len(records))
+	seen := map[string]bool{}
+	for _, r := range records {
+		r, err := normalizeRecord(recordType, r)
+		if err != nil {
+			return provider.Domain{}, err
+		}
+		// a recordset is a set, the duplicated records are merged
+		if !seen[r] {
+			seen[r] = true
+			normalized = append(normalized, r)
+		}
+	}
+	sort.Strings(normalized)
+
+	// CNAME can't coexist with the other records of the name
+	for id, d := range z.recordSets {
+		if id == replacedId || d.Name != name || d.Type == recordType {
+			continue
+		}
+		if recordType == provider.RecordTypeCNAME || d.Type == provider.RecordTypeCNAME {
+			return provider.Domain{}, fmt.Errorf("%s record of %s conflicts with the existing %s record", recordType, name, d.Type)
+		}
+	}
+	if recordType == provider.RecordTypeCNAME && len(normalized) > 1 {
+		return provider.Domain{}, fmt.Errorf("CNAME record of %s can't have multiple records", name)
+	}
+
+	if ttl <= 0 {
+		ttl = ttlDefault
+	}
+	d := provider.Domain{
+		Id:        provider.GenerateRecordSetId(name, recordType),
+		Name:      name,
+		Type:      recordType,
+		Records:   normalized,
+		TTL:       ttl,
+		ZoneId:    zoneId,
+		ZoneName:  z.name,
+		FQDN:      fmt.Sprintf("%s.", provider.JoinName(name, z.name)),
+		Activated: true,
+	}
+	if len(options) > 0 {
+		d.Options = map[string]string{}
+		for k, v := range options {
+			d.Options[k] = v
+		}
+	}
+	return d, nil
+}
+
+// normalizeRecord validates the record of the type, CNAME is returned without trailing dot as the other providers do
+func normalizeRecord(recordType, record string) (string, error) {
+	switch recordType {
+	case provider.RecordTypeA:
+		if ip := net.ParseIP(record); ip == nil || ip.To4() == nil {
+			return "", fmt.Errorf("invalid A record %s", record)
+		}
+	case provider.RecordTypeAAAA:
+		if ip := net.ParseIP(record); ip == nil || ip.To4() != nil {
+			return "", fmt.Errorf("invalid AAAA record %s", record)
+		}
+	case provider.RecordTypeCNAME:
+		record = strings.TrimSuffix(record, ".")
+		if len(record) == 0 {
+			return "", fmt.Errorf("invalid CNAME record, target is empty")
+		}
+	}
+	return record, nil
+}
+
+// copyDomain copies the domain not to share the records and options with the caller
+func copyDomain(d provider.Domain) provider.Domain {
+	d.Records = append([]string{}, d.Records...)
+	if d.Options != nil {
+		options := make(map[string]string, len(d.Options))
+		for k, v := range d.Options {
+			options[k] = v
+		}
+		d.Options = options
+	}
+	return d
+}
+
+// normalizeName returns the name in lower case without trailing dot
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"context"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testZoneName = "example.com"
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemoryClient(testZoneName, "example.org")
+	if err != nil {
+		t.Fatalf("NewMemoryClient: %v", err)
+	}
+
+	z, err := c.GetZone(ctx, "Example.COM.")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: "zone-1", Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: unexpected zone %+v", z)
+	}
+	if _, err := c.GetZone(ctx, "example.net"); err == nil {
+		t.Fatalf("GetZone: expected error for unknown zone")
+	}
+
+	d, err := c.Create(ctx, "www", z.Id, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1", "192.0.2.2"}, 0, map[string]string{"proxied": "true"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("www", provider.RecordTypeA),
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.0.2.1", "192.0.2.2"},
+		TTL:       ttlDefault,
+		ZoneId:    z.Id,
+		ZoneName:  testZoneName,
+		FQDN:      "www.example.com.",
+		Options:   map[string]string{"proxied": "true"},
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+
+	// the returned recordset is a copy, which doesn't change the stored one
+	d.Records[0] = "198.51.100.1"
+	d.Options["proxied"] = "false"
+	got, err := c.Get(ctx, expected.Id, z.Id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Get: expected %+v, got %+v", expected, got)
+	}
+
+	other, err := c.GetZone(ctx, "example.org")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if d, err := c.Get(ctx, expected.Id, other.Id); err != nil || d != nil {
+		t.Fatalf("Get: expected recordset isolated by zone, got %v, %v", d, err)
+	}
+
+	recordSets, err := c.List(z.Id)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(recordSets) != 1 || !reflect.DeepEqual(&recordSets[0], expected) {
+		t.Fatalf("List: expected %+v, got %+v", expected, recordSets)
+	}
+}
+
+func TestClientValidation(t *testing.T) {
+	ctx := context.Background()
+	c, err := NewMemoryClient(testZoneName)
+	if err != nil {
+		t.Fatalf("NewMemoryClient: %v", err)
+	}
+	z, err := c.GetZone(ctx, testZoneName)
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+
+	for _, tc := range []struct {
+		recordType string
+		records    []string
+	}{
+		{recordType: provider.RecordTypeA, records: nil},
+		{recordType: provider.RecordTypeA, records: []string{"2001:db8::1"}},
+		{recordType: provider.RecordTypeAAAA, records: []string{"192.0.2.1"}},
+		{recordType: provider.RecordTypeCNAME, records: []string{"a.example.net", "b.example.net"}},
+	} {
+		if _, err := c.Create(ctx, "invalid", z.Id, tc.recordType, tc.records, 0, nil); err == nil {
+			t.Errorf("Create: expected error for %s records %v", tc.recordType, tc.records)
+		}
+	}
+
+	// CNAME can't coexist with the other records of the name
+	a, err := c.Create(ctx, "app", z.Id, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := c.Create(ctx, "app", z.Id, provider.RecordTypeCNAME, []string{"lb.example.net"}, 0, nil); err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Fatalf("Create: expected conflict of CNAME, got %v", err)
+	}
+	if _, err := c.Update(ctx, a.Id, z.Id, provider.RecordTypeCNAME, []string{"lb.example.net."}, 0, nil); err != nil {
+		t.Fatalf("Update: expected CNAME replacing the A recordset, got %v", err)
+	}
+	if _, err := c.Create(ctx, "app", z.Id, provider.RecordTypeTXT, []string{"owner=default"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected conflict with CNAME")
+	}
+
+	if _, err := c.Get(ctx, a.Id, "zone-9"); err == nil {
+		t.Fatalf("Get: expected error for unknown zone id")
+	}
+}
+
+func TestNewMemoryClientWithSettings(t *testing.T) {
+	c, err := NewMemoryClientWithSettings(map[string]string{SettingKeyZones: "example.com, example.org."})
+	if err != nil {
+		t.Fatalf("NewMemoryClientWithSettings: %v", err)
+	}
+	for _, zoneName := range []string{"example.com", "example.org"} {
+		if _, err := c.GetZone(context.Background(), zoneName); err != nil {
+			t.Fatalf("GetZone: %v", err)
+		}
+	}
+
+	if _, err := NewMemoryClientWithSettings(map[string]string{SettingKeyZones: "example.com,Example.com."}); err == nil {
+		t.Fatalf("NewMemoryClientWithSettings: expected error for duplicated zones")
+	}
+}
@@ -0,0 +1,16 @@
+package memory
+
+import (
+	"github.com/sokdak/dns-ingress/pkg/provider/providertest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	providertest.RunConformance(t, func(t *testing.T) providertest.Fixture {
+		c, err := NewMemoryClient(testZoneName)
+		if err != nil {
+			t.Fatalf("NewMemoryClient: %v", err)
+		}
+		return providertest.Fixture{Client: c, ZoneName: testZoneName}
+	})
+}
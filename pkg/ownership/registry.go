@@ -0,0 +1,80 @@
+package ownership
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+// Owner identifies who manages a recordset
+type Owner struct {
+	// Id is the owner id of the dns-ingress instance
+	Id string
+	// Resource is the resource the recordset is managed by, e.g. domain/default/www
+	Resource string
+}
+
+func (o Owner) String() string {
+	return fmt.Sprintf("%s (%s)", o.Id, o.Resource)
+}
+
+// Registry keeps track of the owners of the recordsets on the provider
+type Registry interface {
+	// Owner returns the owner of the recordset, nil if nobody owns it
+	Owner(ctx context.Context, zoneId, name, recordType string) (*Owner, error)
+	// Claim records the owner of the recordset, overwriting the existing one
+	Claim(ctx context.Context, zoneId, name, recordType string, owner Owner) error
+	// Release removes the owner of the recordset, no-op if nobody owns it
+	Release(ctx context.Context, zoneId, name, recordType string) error
+}
+
+// Factory creates the registry which stores the owners on the provider
+type Factory func(c provider.Client) Registry
+
+// ConflictError is returned if the recordset can't be managed by the owner
+type ConflictError struct {
+	Message string
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+// IsConflict reports whether the error is caused by the ownership conflict
+func IsConflict(err error) bool {
+	var conflictErr *ConflictError
+	return errors.As(err, &conflictErr)
+}
+
+// Claim claims the recordset for the owner if nobody else owns it.
+// the unowned recordset which already exists is claimed only if adopt is set.
+func Claim(ctx context.Context, r Registry, zoneId, name, recordType string, owner Owner, exists, adopt bool) error {
+	current, err := r.Owner(ctx, zoneId, name, recordType)
+	if err != nil {
+		return fmt.Errorf("can't get owner: %w", err)
+	}
+
+	switch {
+	case current != nil && *current == owner:
+		return nil
+	case current != nil:
+		return &ConflictError{Message: fmt.Sprintf("recordset %s/%s is owned by %s", name, recordType, current)}
+	case exists && !adopt:
+		return &ConflictError{Message: fmt.Sprintf("recordset %s/%s exists without owner, adoption is not allowed", name, recordType)}
+	}
+
+	if err := r.Claim(ctx, zoneId, name, recordType, owner); err != nil {
+		return fmt.Errorf("can't claim owner: %w", err)
+	}
+	return nil
+}
+
+// IsOwnedBy reports whether the recordset is owned by the owner
+func IsOwnedBy(ctx context.Context, r Registry, zoneId, name, recordType string, owner Owner) (bool, error) {
+	current, err := r.Owner(ctx, zoneId, name, recordType)
+	if err != nil {
+		return false, fmt.Errorf("can't get owner: %w", err)
+	}
+	return current != nil && *current == owner, nil
+}
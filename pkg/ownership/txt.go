@@ -0,0 +1,117 @@
+package ownership
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"strings"
+)
+
+// DefaultTXTPrefix is prepended to the companion txt record name with the lowercased record type,
+// so the companion never collides with the recordset itself, e.g. _dns-ingress-a.www for A record of www
+const DefaultTXTPrefix = "_dns-ingress-"
+
+const (
+	txtHeritage          = "heritage=dns-ingress"
+	txtAttributeOwner    = "dns-ingress/owner"
+	txtAttributeResource = "dns-ingress/resource"
+)
+
+// TXTRegistry stores the owner of the recordset as a companion txt record on the same provider
+type TXTRegistry struct {
+	client provider.Client
+	prefix string
+}
+
+func NewTXTRegistry(client provider.Client, prefix string) *TXTRegistry {
+	return &TXTRegistry{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// NewTXTRegistryFactory returns the factory of TXTRegistry with the prefix
+func NewTXTRegistryFactory(prefix string) Factory {
+	return func(c provider.Client) Registry {
+		return NewTXTRegistry(c, prefix)
+	}
+}
+
+func (r *TXTRegistry) Owner(ctx context.Context, zoneId, name, recordType string) (*Owner, error) {
+	rs, err := r.client.GetByName(ctx, r.companionName(name, recordType), zoneId, provider.RecordTypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	if rs == nil {
+		return nil, nil
+	}
+
+	for _, value := range rs.Records {
+		if owner, ok := parseTXTValue(value); ok {
+			return owner, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *TXTRegistry) Claim(ctx context.Context, zoneId, name, recordType string, owner Owner) error {
+	companionName := r.companionName(name, recordType)
+	rs, err := r.client.GetByName(ctx, companionName, zoneId, provider.RecordTypeTXT)
+	if err != nil {
+		return err
+	}
+
+	records := []string{formatTXTValue(owner)}
+	if rs != nil {
+		_, err = r.client.Update(ctx, rs.Id, zoneId, provider.RecordTypeTXT, records, 0, nil)
+		return err
+	}
+	_, err = r.client.Create(ctx, companionName, zoneId, provider.RecordTypeTXT, records, 0, nil)
+	return err
+}
+
+func (r *TXTRegistry) Release(ctx context.Context, zoneId, name, recordType string) error {
+	rs, err := r.client.GetByName(ctx, r.companionName(name, recordType), zoneId, provider.RecordTypeTXT)
+	if err != nil {
+		return err
+	}
+	if rs == nil {
+		return nil
+	}
+	return r.client.Delete(ctx, rs.Id, zoneId)
+}
+
+// companionName returns the name of the companion txt record of the recordset
+func (r *TXTRegistry) companionName(name, recordType string) string {
+	label := r.prefix + strings.ToLower(recordType)
+	if name == provider.RecordNameApex {
+		return label
+	}
+	return fmt.Sprintf("%s.%s", label, name)
+}
+
+// formatTXTValue formats the owner in the form of heritage=dns-ingress,dns-ingress/owner=id,dns-ingress/resource=resource
+func formatTXTValue(owner Owner) string {
+	return fmt.Sprintf("%s,%s=%s,%s=%s", txtHeritage, txtAttributeOwner, owner.Id, txtAttributeResource, owner.Resource)
+}
+
+// parseTXTValue parses the owner from the txt value, ignores the values without heritage
+func parseTXTValue(value string) (*Owner, bool) {
+	value = strings.Trim(value, `"`)
+	attributes := strings.Split(value, ",")
+	if len(attributes) == 0 || attributes[0] != txtHeritage {
+		return nil, false
+	}
+
+	owner := &Owner{}
+	for _, attribute := range attributes[1:] {
+		key, val, _ := strings.Cut(attribute, "=")
+		switch key {
+		case txtAttributeOwner:
+			owner.Id = val
+		case txtAttributeResource:
+			owner.Resource = val
+		}
+	}
+	return owner, len(owner.Id) > 0
+}
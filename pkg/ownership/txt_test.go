@@ -0,0 +1,162 @@
+package ownership
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+const testZoneId = "zone"
+
+// fakeClient keeps the recordsets in memory by the synthetic recordset id
+type fakeClient struct {
+	provider.Client
+	recordSets map[string]*provider.Domain
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{recordSets: map[string]*provider.Domain{}}
+}
+
+func (c *fakeClient) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	return c.recordSets[provider.GenerateRecordSetId(name, recordType)], nil
+}
+
+func (c *fakeClient) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, options map[string]string) (*provider.Domain, error) {
+	d := &provider.Domain{Id: provider.GenerateRecordSetId(name, recordType), Name: name, Type: recordType, Records: records, ZoneId: zoneId}
+	c.recordSets[d.Id] = d
+	return d, nil
+}
+
+func (c *fakeClient) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, options map[string]string) (*provider.Domain, error) {
+	d, ok := c.recordSets[id]
+	if !ok {
+		return nil, nil
+	}
+	d.Records = records
+	return d, nil
+}
+
+func (c *fakeClient) Delete(ctx context.Context, id, zoneId string) error {
+	delete(c.recordSets, id)
+	return nil
+}
+
+func TestTXTRegistry(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient()
+	r := NewTXTRegistry(c, DefaultTXTPrefix)
+	owner := Owner{Id: "cluster-a", Resource: "domain/default/www"}
+
+	got, err := r.Owner(ctx, testZoneId, "www", provider.RecordTypeA)
+	if err != nil || got != nil {
+		t.Fatalf("Owner: expected nobody, got %v, %v", got, err)
+	}
+
+	if err := r.Claim(ctx, testZoneId, "www", provider.RecordTypeA, owner); err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	companion := c.recordSets[provider.GenerateRecordSetId("_dns-ingress-a.www", provider.RecordTypeTXT)]
+	if companion == nil {
+		t.Fatalf("Claim: expected companion txt record, got %v", c.recordSets)
+	}
+	want := []string{"heritage=dns-ingress,dns-ingress/owner=cluster-a,dns-ingress/resource=domain/default/www"}
+	if !reflect.DeepEqual(companion.Records, want) {
+		t.Fatalf("Claim: expected %v, got %v", want, companion.Records)
+	}
+
+	got, err = r.Owner(ctx, testZoneId, "www", provider.RecordTypeA)
+	if err != nil || got == nil || *got != owner {
+		t.Fatalf("Owner: expected %v, got %v, %v", owner, got, err)
+	}
+
+	// owners are tracked per record type
+	got, err = r.Owner(ctx, testZoneId, "www", provider.RecordTypeAAAA)
+	if err != nil || got != nil {
+		t.Fatalf("Owner: expected nobody for AAAA, got %v, %v", got, err)
+	}
+
+	if err := r.Release(ctx, testZoneId, "www", provider.RecordTypeA); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if len(c.recordSets) != 0 {
+		t.Fatalf("Release: expected no records, got %v", c.recordSets)
+	}
+	if err := r.Release(ctx, testZoneId, "www", provider.RecordTypeA); err != nil {
+		t.Fatalf("Release: expected no-op for the released recordset, got %v", err)
+	}
+}
+
+func TestTXTRegistryApexAndForeignValues(t *testing.T) {
+	ctx := context.Background()
+	c := newFakeClient()
+	r := NewTXTRegistry(c, DefaultTXTPrefix)
+
+	// txt values without heritage are not the owner
+	_, _ = c.Create(ctx, "_dns-ingress-cname", testZoneId, provider.RecordTypeTXT, []string{"v=spf1 -all"}, 0, nil)
+	got, err := r.Owner(ctx, testZoneId, provider.RecordNameApex, provider.RecordTypeCNAME)
+	if err != nil || got != nil {
+		t.Fatalf("Owner: expected nobody, got %v, %v", got, err)
+	}
+
+	// quoted values are accepted
+	_, _ = c.Update(ctx, provider.GenerateRecordSetId("_dns-ingress-cname", provider.RecordTypeTXT), testZoneId, provider.RecordTypeTXT,
+		[]string{`"heritage=dns-ingress,dns-ingress/owner=cluster-b,dns-ingress/resource=domain/default/apex"`}, 0, nil)
+	got, err = r.Owner(ctx, testZoneId, provider.RecordNameApex, provider.RecordTypeCNAME)
+	if err != nil || got == nil || got.Id != "cluster-b" {
+		t.Fatalf("Owner: expected cluster-b, got %v, %v", got, err)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	ctx := context.Background()
+	self := Owner{Id: "cluster-a", Resource: "domain/default/www"}
+	other := Owner{Id: "cluster-b", Resource: "domain/default/www"}
+
+	tests := []struct {
+		name         string
+		current      *Owner
+		exists       bool
+		adopt        bool
+		wantConflict bool
+	}{
+		{name: "new recordset", current: nil},
+		{name: "owned by self", current: &self, exists: true},
+		{name: "owned by others", current: &other, exists: true, wantConflict: true},
+		{name: "owned by others with adopt", current: &other, exists: true, adopt: true, wantConflict: true},
+		{name: "owned by another resource of self", current: &Owner{Id: self.Id, Resource: "domain/default/other"}, exists: true, wantConflict: true},
+		{name: "unowned recordset", exists: true, wantConflict: true},
+		{name: "unowned recordset with adopt", exists: true, adopt: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewTXTRegistry(newFakeClient(), DefaultTXTPrefix)
+			if tt.current != nil {
+				if err := r.Claim(ctx, testZoneId, "www", provider.RecordTypeA, *tt.current); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := Claim(ctx, r, testZoneId, "www", provider.RecordTypeA, self, tt.exists, tt.adopt)
+			if tt.wantConflict {
+				if !IsConflict(err) {
+					t.Fatalf("expected conflict, got %v", err)
+				}
+				owned, _ := IsOwnedBy(ctx, r, testZoneId, "www", provider.RecordTypeA, self)
+				if owned {
+					t.Fatalf("expected the owner not to be changed on conflict")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Claim: %v", err)
+			}
+			owned, err := IsOwnedBy(ctx, r, testZoneId, "www", provider.RecordTypeA, self)
+			if err != nil || !owned {
+				t.Fatalf("expected the recordset to be owned, got %v, %v", owned, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package pihole
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const (
+	apiPrefix = "/api"
+	// sidHeader carries the session id of the authenticated requests
+	sidHeader = "X-FTL-SID"
+)
+
+// paths of the local dns entries, each entry is addressed by its value
+const (
+	hostsPath        = "/config/dns/hosts"
+	cnameRecordsPath = "/config/dns/cnameRecords"
+)
+
+type authRequest struct {
+	Password string `json:"password"`
+}
+
+// Session is the session of the api, sid is empty if the web interface has no password
+type Session struct {
+	Valid    bool   `json:"valid"`
+	SID      string `json:"sid"`
+	Validity int    `json:"validity"`
+	Message  string `json:"message"`
+}
+
+type authResponse struct {
+	Session Session `json:"session"`
+}
+
+// DNSConfig is the local dns entries of the configuration.
+// hosts are "<ip> <hostname>..." and cnameRecords are "<domain>,<target>[,<ttl>]".
+type DNSConfig struct {
+	Hosts        []string `json:"hosts"`
+	CNAMERecords []string `json:"cnameRecords"`
+}
+
+type configResponse struct {
+	Config struct {
+		DNS DNSConfig `json:"dns"`
+	} `json:"config"`
+}
+
+// APIError is returned if the api responds with error status
+type APIError struct {
+	StatusCode int
+	// Key is the error key of the api, e.g. bad_request
+	Key     string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("pi-hole api error %d: %s", e.StatusCode, e.Message)
+}
+
+type errorResponse struct {
+	Error struct {
+		Key     string `json:"key"`
+		Message string `json:"message"`
+		Hint    string `json:"hint"`
+	} `json:"error"`
+}
+
+func isNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+func isUnauthorized(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized
+}
+
+// do sends the authenticated request to the api, the response is decoded into out if given.
+// the session is created on the first request, and once again if it has expired.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	sid, err := c.session(ctx, false)
+	if err != nil {
+		return err
+	}
+	err = c.send(ctx, method, path, sid, in, out)
+	if !isUnauthorized(err) {
+		return err
+	}
+
+	if sid, err = c.session(ctx, true); err != nil {
+		return err
+	}
+	return c.send(ctx, method, path, sid, in, out)
+}
+
+// session returns the id of the current session, which is created if not exists or renew is set
+func (c *Client) session(ctx context.Context, renew bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.authenticated && !renew {
+		return c.sid, nil
+	}
+
+	resp := &authResponse{}
+	if err := c.send(ctx, http.MethodPost, "/auth", "", &authRequest{Password: c.password}, resp); err != nil {
+		return "", fmt.Errorf("can't authenticate: %w", err)
+	}
+	if !resp.Session.Valid {
+		return "", fmt.Errorf("can't authenticate: %s", resp.Session.Message)
+	}
+	c.sid, c.authenticated = resp.Session.SID, true
+	return c.sid, nil
+}
+
+func (c *Client) send(ctx context.Context, method, path, sid string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("can't encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.apiUrl+apiPrefix+path, body)
+	if err != nil {
+		return fmt.Errorf("can't create request: %w", err)
+	}
+	if len(sid) > 0 {
+		req.Header.Set(sidHeader, sid)
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("can't read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		errResp := &errorResponse{}
+		if err := json.Unmarshal(respBody, errResp); err == nil {
+			apiErr.Key = errResp.Error.Key
+			apiErr.Message = errResp.Error.Message
+			if len(errResp.Error.Hint) > 0 {
+				apiErr.Message = fmt.Sprintf("%s: %s", apiErr.Message, errResp.Error.Hint)
+			}
+		}
+		if len(apiErr.Message) == 0 {
+			apiErr.Message = string(bytes.TrimSpace(respBody))
+		}
+		return apiErr
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("can't decode response: %w", err)
+	}
+	return nil
+}
+
+// getDNSConfig returns the local dns entries
+func (c *Client) getDNSConfig(ctx context.Context) (DNSConfig, error) {
+	resp := &configResponse{}
+	if err := c.do(ctx, http.MethodGet, "/config/dns", nil, resp); err != nil {
+		return DNSConfig{}, err
+	}
+	return resp.Config.DNS, nil
+}
+
+// addEntry adds the entry to the array of the path, e.g. hosts
+func (c *Client) addEntry(ctx context.Context, path, entry string) error {
+	return c.do(ctx, http.MethodPut, entryPath(path, entry), nil, nil)
+}
+
+// deleteEntry deletes the entry from the array of the path, which succeeds if the entry is already gone
+func (c *Client) deleteEntry(ctx context.Context, path, entry string) error {
+	if err := c.do(ctx, http.MethodDelete, entryPath(path, entry), nil, nil); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func entryPath(path, entry string) string {
+	return fmt.Sprintf("%s/%s", path, url.PathEscape(entry))
+}
@@ -0,0 +1,430 @@
+package pihole
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ProviderKey = "pihole"
+
+const (
+	// SettingKeyURL is the url of the web interface, e.g. http://pi.hole
+	SettingKeyURL = "url"
+	// SettingKeyPassword is the password of the web interface, or an app password
+	SettingKeyPassword = "password"
+)
+
+var DefaultHttpClient = &http.Client{Timeout: 30 * time.Second}
+
+func init() {
+	provider.Register(ProviderKey, NewPiholeClientWithSettings)
+}
+
+// Client manages the local dns entries of Pi-hole, A and AAAA records are the hosts and CNAME records are the cnameRecords.
+// pi-hole has no zones, so any zone is served and the zone id is the zone name.
+// TXT records are not supported, so the ownership of the records is not tracked on it.
+type Client struct {
+	provider.Client
+
+	apiUrl     string
+	password   string
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	sid           string
+	authenticated bool
+}
+
+// NewPiholeClientWithSettings creates the client with the provider settings
+func NewPiholeClientWithSettings(settings map[string]string) (provider.Client, error) {
+	c, err := NewPiholeClient(settings[SettingKeyURL], settings[SettingKeyPassword], DefaultHttpClient)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewPiholeClient creates the client of the api of the web interface served at the url
+func NewPiholeClient(apiUrl, password string, client *http.Client) (*Client, error) {
+	if len(password) == 0 {
+		return nil, fmt.Errorf("can't create new pihole client: %s is required", SettingKeyPassword)
+	}
+	u, err := url.Parse(apiUrl)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return nil, fmt.Errorf("can't create new pihole client: invalid url %s", apiUrl)
+	}
+	if client == nil {
+		client = DefaultHttpClient
+	}
+
+	return &Client{
+		apiUrl:     strings.TrimSuffix(strings.TrimSuffix(apiUrl, "/"), apiPrefix),
+		password:   password,
+		httpClient: client,
+	}, nil
+}
+
+// OwnershipUnsupported tells the ownership can't be tracked, as the TXT companions can't be stored
+func (c *Client) OwnershipUnsupported() bool {
+	return true
+}
+
+// Verify checks the password is accepted
+func (c *Client) Verify(ctx context.Context) error {
+	if _, err := c.getDNSConfig(ctx); err != nil {
+		return fmt.Errorf("can't verify password: %w", err)
+	}
+	return nil
+}
+
+// GetZone returns the synthetic zone of the name, since pi-hole serves any name
+func (c *Client) GetZone(_ context.Context, zoneName string) (*provider.Zone, error) {
+	name := normalizeName(zoneName)
+	if len(name) == 0 {
+		return nil, fmt.Errorf("can't GetZone: zone name is required")
+	}
+	return &provider.Zone{Id: name, Name: name, Activated: true}, nil
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	if !supportedRecordTypes[recordType] {
+		return nil, nil
+	}
+
+	entries, err := c.listRecordSet(ctx, provider.JoinName(name, normalizeName(zoneId)), recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return convertRecordSet(name, zoneId, recordType, entries), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	if err := validateRecordSet(recordType, records); err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	fqdn := provider.JoinName(name, normalizeName(zoneId))
+
+	// the api adds the entry regardless of the others, which would merge the records into the existing recordset
+	current, err := c.listRecordSet(ctx, fqdn, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	if len(current) > 0 {
+		return nil, fmt.Errorf("can't Create: recordset %s %s already exists", fqdn, recordType)
+	}
+
+	created := make([]entry, 0, len(records))
+	for _, r := range records {
+		e := newEntry(fqdn, recordType, r, ttl)
+		if err := c.addEntry(ctx, entriesPath(recordType), e.raw); err != nil {
+			return nil, fmt.Errorf("can't Create: %w", err)
+		}
+		created = append(created, e)
+	}
+	return convertRecordSet(name, zoneId, recordType, created), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	name, currentType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if !supportedRecordTypes[currentType] {
+		return nil, nil
+	}
+	fqdn := provider.JoinName(name, normalizeName(zoneId))
+
+	current, err := c.listRecordSet(ctx, fqdn, currentType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if len(current) == 0 {
+		return nil, nil
+	}
+
+	if err := validateRecordSet(recordType, records); err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	// if type has been changed, remove the current recordset first since CNAME can't coexist with others
+	if currentType != recordType {
+		for _, e := range current {
+			if err := c.removeEntry(ctx, fqdn, currentType, e); err != nil {
+				return nil, fmt.Errorf("can't Update: %w", err)
+			}
+		}
+		current, err = c.listRecordSet(ctx, fqdn, recordType)
+		if err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+
+	// keep the entries which have wanted value and ttl, the others are stale
+	wanted := map[string]bool{}
+	for _, r := range records {
+		wanted[newEntry(fqdn, recordType, r, ttl).value] = true
+	}
+	stale := make([]entry, 0)
+	synced := make([]entry, 0, len(records))
+	for _, e := range current {
+		if !wanted[e.value] || e.ttl != normalizeTTL(recordType, ttl) {
+			stale = append(stale, e)
+			continue
+		}
+		delete(wanted, e.value)
+		synced = append(synced, e)
+	}
+
+	// the cname of a domain is replaced as it can't have two targets,
+	// otherwise the missing entries are added first, then the stale ones are removed to avoid resolution gap
+	if recordType == provider.RecordTypeCNAME {
+		for _, e := range stale {
+			if err := c.removeEntry(ctx, fqdn, recordType, e); err != nil {
+				return nil, fmt.Errorf("can't Update: %w", err)
+			}
+		}
+		stale = nil
+	}
+	for _, r := range records {
+		e := newEntry(fqdn, recordType, r, ttl)
+		if !wanted[e.value] {
+			continue
+		}
+		delete(wanted, e.value)
+		if err := c.addEntry(ctx, entriesPath(recordType), e.raw); err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+		synced = append(synced, e)
+	}
+	for _, e := range stale {
+		if err := c.removeEntry(ctx, fqdn, recordType, e); err != nil {
+			return nil, fmt.Errorf("can't Update: %w", err)
+		}
+	}
+	return convertRecordSet(name, zoneId, recordType, synced), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	if !supportedRecordTypes[recordType] {
+		return nil
+	}
+	fqdn := provider.JoinName(name, normalizeName(zoneId))
+
+	entries, err := c.listRecordSet(ctx, fqdn, recordType)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	for _, e := range entries {
+		if err := c.removeEntry(ctx, fqdn, recordType, e); err != nil {
+			return fmt.Errorf("can't Delete: %w", err)
+		}
+	}
+	return nil
+}
+
+// listRecordSet returns the entries of the domain and type
+func (c *Client) listRecordSet(ctx context.Context, fqdn, recordType string) ([]entry, error) {
+	config, err := c.getDNSConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entry, 0)
+	if recordType == provider.RecordTypeCNAME {
+		for _, raw := range config.CNAMERecords {
+			if e, ok := parseCNAMEEntry(raw); ok && strings.EqualFold(e.hostnames[0], fqdn) {
+				entries = append(entries, e)
+			}
+		}
+		return entries, nil
+	}
+	for _, raw := range config.Hosts {
+		e, ok := parseHostEntry(raw)
+		if !ok || addressType(e.value) != recordType {
+			continue
+		}
+		for _, h := range e.hostnames {
+			if strings.EqualFold(h, fqdn) {
+				entries = append(entries, e)
+				break
+			}
+		}
+	}
+	return entries, nil
+}
+
+// removeEntry removes the domain from the entry, the entry which has the other hostnames is added back with them
+func (c *Client) removeEntry(ctx context.Context, fqdn, recordType string, e entry) error {
+	if err := c.deleteEntry(ctx, entriesPath(recordType), e.raw); err != nil {
+		return err
+	}
+	others := make([]string, 0, len(e.hostnames))
+	for _, h := range e.hostnames {
+		if !strings.EqualFold(h, fqdn) {
+			others = append(others, h)
+		}
+	}
+	if recordType == provider.RecordTypeCNAME || len(others) == 0 {
+		return nil
+	}
+	return c.addEntry(ctx, hostsPath, fmt.Sprintf("%s %s", e.value, strings.Join(others, " ")))
+}
+
+// entry is a local dns entry, value is the address of hosts or the target of cnameRecords
+type entry struct {
+	raw       string
+	value     string
+	hostnames []string
+	ttl       int
+}
+
+func newEntry(fqdn, recordType, record string, ttl int) entry {
+	if recordType != provider.RecordTypeCNAME {
+		return entry{raw: fmt.Sprintf("%s %s", record, fqdn), value: record, hostnames: []string{fqdn}}
+	}
+	target := strings.TrimSuffix(record, ".")
+	raw := fmt.Sprintf("%s,%s", fqdn, target)
+	if ttl > 0 {
+		raw = fmt.Sprintf("%s,%d", raw, ttl)
+	}
+	return entry{raw: raw, value: target, hostnames: []string{fqdn}, ttl: normalizeTTL(recordType, ttl)}
+}
+
+// parseHostEntry parses the entry of hosts, "<ip> <hostname>..."
+func parseHostEntry(raw string) (entry, bool) {
+	fields := strings.Fields(raw)
+	if len(fields) < 2 {
+		return entry{}, false
+	}
+	return entry{raw: raw, value: fields[0], hostnames: fields[1:]}, true
+}
+
+// parseCNAMEEntry parses the entry of cnameRecords, "<domain>,<target>[,<ttl>]"
+func parseCNAMEEntry(raw string) (entry, bool) {
+	fields := strings.Split(raw, ",")
+	if len(fields) < 2 || len(fields) > 3 {
+		return entry{}, false
+	}
+	e := entry{raw: raw, value: strings.TrimSuffix(strings.TrimSpace(fields[1]), "."), hostnames: []string{strings.TrimSpace(fields[0])}}
+	if len(fields) == 3 {
+		ttl, err := strconv.Atoi(strings.TrimSpace(fields[2]))
+		if err != nil {
+			return entry{}, false
+		}
+		e.ttl = ttl
+	}
+	return e, true
+}
+
+// convertRecordSet converts the entries of the same domain and type into a recordset
+func convertRecordSet(name, zoneId, recordType string, entries []entry) *provider.Domain {
+	values := make([]string, 0, len(entries))
+	for _, e := range entries {
+		values = append(values, e.value)
+	}
+	sort.Strings(values)
+
+	zoneName := normalizeName(zoneId)
+	return &provider.Domain{
+		Id:        provider.GenerateRecordSetId(name, recordType),
+		Name:      name,
+		Type:      recordType,
+		Records:   values,
+		TTL:       entries[0].ttl,
+		ZoneId:    zoneId,
+		ZoneName:  zoneName,
+		FQDN:      fmt.Sprintf("%s.", provider.JoinName(name, zoneName)),
+		Activated: true,
+	}
+}
+
+// supportedRecordTypes are the record types of the local dns entries
+var supportedRecordTypes = map[string]bool{
+	provider.RecordTypeA:     true,
+	provider.RecordTypeAAAA:  true,
+	provider.RecordTypeCNAME: true,
+}
+
+// validateRecordSet checks the records can be the local dns entries of the type
+func validateRecordSet(recordType string, records []string) error {
+	if !supportedRecordTypes[recordType] {
+		return fmt.Errorf("%s records are not supported by pi-hole", recordType)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no records given")
+	}
+	if recordType == provider.RecordTypeCNAME {
+		if len(records) > 1 {
+			return fmt.Errorf("CNAME recordset can have only one record, got %d", len(records))
+		}
+		return nil
+	}
+	for _, r := range records {
+		if addressType(r) != recordType {
+			return fmt.Errorf("invalid %s record %s", recordType, r)
+		}
+	}
+	return nil
+}
+
+// addressType returns the record type of the address, empty if it's not an ip address
+func addressType(address string) string {
+	ip := net.ParseIP(address)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return provider.RecordTypeA
+	default:
+		return provider.RecordTypeAAAA
+	}
+}
+
+// entriesPath returns the path of the entries of the record type
+func entriesPath(recordType string) string {
+	if recordType == provider.RecordTypeCNAME {
+		return cnameRecordsPath
+	}
+	return hostsPath
+}
+
+// normalizeTTL returns the ttl of the entry, only cnameRecords have ttl
+func normalizeTTL(recordType string, ttl int) int {
+	if recordType != provider.RecordTypeCNAME || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
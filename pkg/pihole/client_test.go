@@ -0,0 +1,208 @@
+package pihole
+
+import (
+	"context"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testZoneName = "home.arpa"
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	c := f.newClient(t)
+
+	z, err := c.GetZone(ctx, "Home.ARPA.")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: testZoneName, Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: unexpected zone %+v", z)
+	}
+
+	d, err := c.Create(ctx, "nas", z.Id, provider.RecordTypeA, []string{"192.168.1.20", "192.168.1.10"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("nas", provider.RecordTypeA),
+		Name:      "nas",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.168.1.10", "192.168.1.20"},
+		ZoneId:    testZoneName,
+		ZoneName:  testZoneName,
+		FQDN:      "nas.home.arpa.",
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+	if got := f.hosts(); !reflect.DeepEqual(got, []string{"192.168.1.20 nas.home.arpa", "192.168.1.10 nas.home.arpa"}) {
+		t.Fatalf("Create: unexpected hosts %v", got)
+	}
+
+	got, err := f.newClient(t).Get(ctx, d.Id, z.Id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Get: expected %+v, got %+v", expected, got)
+	}
+
+	d, err = c.Update(ctx, d.Id, z.Id, provider.RecordTypeCNAME, []string{"ingress.home.arpa."}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != "nas/CNAME" || !reflect.DeepEqual(d.Records, []string{"ingress.home.arpa"}) || d.TTL != 60 {
+		t.Fatalf("Update: unexpected recordset %+v", d)
+	}
+	if got := f.cnameRecords(); !reflect.DeepEqual(got, []string{"nas.home.arpa,ingress.home.arpa,60"}) {
+		t.Fatalf("Update: unexpected cnameRecords %v", got)
+	}
+	if got := f.hosts(); len(got) > 0 {
+		t.Fatalf("Update: expected hosts removed, got %v", got)
+	}
+
+	// the cname is replaced as the domain can't have two targets
+	if _, err := c.Update(ctx, d.Id, z.Id, provider.RecordTypeCNAME, []string{"lb.home.arpa"}, 0, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := f.cnameRecords(); !reflect.DeepEqual(got, []string{"nas.home.arpa,lb.home.arpa"}) {
+		t.Fatalf("Update: unexpected cnameRecords %v", got)
+	}
+
+	if err := c.Delete(ctx, d.Id, z.Id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.cnameRecords(); len(got) > 0 {
+		t.Fatalf("Delete: expected no cnameRecords, got %v", got)
+	}
+}
+
+func TestClientSharedHostsEntry(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	c := f.newClient(t)
+
+	// the entries made on the web interface may have several hostnames and both address families
+	f.addHost("192.168.1.5 router.home.arpa NAS.home.arpa")
+	f.addHost("fd00::5 nas.home.arpa")
+
+	d, err := c.GetByName(ctx, "nas", testZoneName, provider.RecordTypeA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if d == nil || !reflect.DeepEqual(d.Records, []string{"192.168.1.5"}) {
+		t.Fatalf("GetByName: unexpected recordset %+v", d)
+	}
+
+	if _, err := c.Update(ctx, d.Id, testZoneName, provider.RecordTypeA, []string{"192.168.1.6"}, 0, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got := f.hosts()
+	sort.Strings(got)
+	if want := []string{"192.168.1.5 router.home.arpa", "192.168.1.6 nas.home.arpa", "fd00::5 nas.home.arpa"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Update: expected the other hostnames kept, got %v", got)
+	}
+
+	if err := c.Delete(ctx, "nas/AAAA", testZoneName); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.hosts(); len(got) != 2 {
+		t.Fatalf("Delete: expected only AAAA entry removed, got %v", got)
+	}
+}
+
+func TestClientUnsupportedRecords(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	c := f.newClient(t)
+
+	if d, err := c.GetByName(ctx, "_owner", testZoneName, provider.RecordTypeTXT); err != nil || d != nil {
+		t.Fatalf("GetByName: expected no TXT recordset, got %+v, %v", d, err)
+	}
+	if _, err := c.Create(ctx, "_owner", testZoneName, provider.RecordTypeTXT, []string{"heritage=dns-ingress"}, 0, nil); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Fatalf("Create: expected error for TXT records, got %v", err)
+	}
+	if err := c.Delete(ctx, "_owner/TXT", testZoneName); err != nil {
+		t.Fatalf("Delete: expected no error for TXT records, got %v", err)
+	}
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeCNAME, []string{"a.home.arpa", "b.home.arpa"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for CNAME of several targets")
+	}
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"fd00::1"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for ipv6 address of A record")
+	}
+}
+
+func TestClientSession(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	c := f.newClient(t)
+
+	if err := c.Verify(ctx); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if _, err := c.GetByName(ctx, "nas", testZoneName, provider.RecordTypeA); err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if got := f.loginCount(); got != 1 {
+		t.Fatalf("expected the session reused, got %d logins", got)
+	}
+
+	f.expireSessions()
+	if _, err := c.Create(ctx, "nas", testZoneName, provider.RecordTypeA, []string{"192.168.1.10"}, 0, nil); err != nil {
+		t.Fatalf("Create: expected the expired session renewed, got %v", err)
+	}
+	if got := f.loginCount(); got != 2 {
+		t.Fatalf("expected a new session, got %d logins", got)
+	}
+
+	invalid, err := NewPiholeClient(f.URL, "invalid", f.Client())
+	if err != nil {
+		t.Fatalf("NewPiholeClient: %v", err)
+	}
+	if err := invalid.Verify(ctx); err == nil || !strings.Contains(err.Error(), "can't authenticate") {
+		t.Fatalf("Verify: expected error of invalid password, got %v", err)
+	}
+}
+
+func TestClientNormalizeOptions(t *testing.T) {
+	c := &Client{}
+	if options, err := c.NormalizeOptions(provider.RecordTypeCNAME, 60, nil); err != nil || options != nil {
+		t.Fatalf("NormalizeOptions: expected CNAME with ttl allowed, got %v, %v", options, err)
+	}
+	for _, tt := range []struct {
+		recordType string
+		ttl        int
+		options    map[string]string
+		wantErr    string
+	}{
+		{recordType: provider.RecordTypeA, ttl: 300, wantErr: "ttl of A record follows the pi-hole settings"},
+		{recordType: provider.RecordTypeTXT, wantErr: "TXT records are not supported"},
+		{recordType: provider.RecordTypeA, options: map[string]string{"proxied": "true"}, wantErr: "unknown pihole option proxied"},
+	} {
+		if _, err := c.NormalizeOptions(tt.recordType, tt.ttl, tt.options); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("NormalizeOptions: expected error containing %q, got %v", tt.wantErr, err)
+		}
+	}
+}
+
+func TestNewPiholeClientWithSettings(t *testing.T) {
+	c, err := NewPiholeClientWithSettings(map[string]string{SettingKeyURL: "http://pi.hole/api/", SettingKeyPassword: testPassword})
+	if err != nil {
+		t.Fatalf("NewPiholeClientWithSettings: %v", err)
+	}
+	if apiUrl := c.(*Client).apiUrl; apiUrl != "http://pi.hole" {
+		t.Fatalf("expected api url without api prefix, got %s", apiUrl)
+	}
+	for _, invalid := range []map[string]string{{SettingKeyURL: "http://pi.hole"}, {SettingKeyURL: "pi.hole", SettingKeyPassword: testPassword}} {
+		if _, err := NewPiholeClientWithSettings(invalid); err == nil {
+			t.Fatalf("NewPiholeClientWithSettings: expected error for settings %v", invalid)
+		}
+	}
+}
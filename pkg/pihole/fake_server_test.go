@@ -0,0 +1,169 @@
+package pihole
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testPassword = "pihole-app-password"
+
+// fakeServer is a minimal stand-in of the pi-hole api serving the local dns entries
+type fakeServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	config DNSConfig
+	// sessions are the valid session ids
+	sessions map[string]bool
+	nextSID  int
+	// logins counts the sessions created
+	logins int
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	f := &fakeServer{config: DNSConfig{Hosts: []string{}, CNAMERecords: []string{}}, sessions: map[string]bool{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeServer) newClient(t *testing.T) *Client {
+	c, err := NewPiholeClient(f.URL, testPassword, f.Client())
+	if err != nil {
+		t.Fatalf("NewPiholeClient: %v", err)
+	}
+	return c
+}
+
+// addHost adds the entry of hosts directly, as if it is made on the web interface
+func (f *fakeServer) addHost(entry string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config.Hosts = append(f.config.Hosts, entry)
+}
+
+func (f *fakeServer) hosts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.config.Hosts...)
+}
+
+func (f *fakeServer) cnameRecords() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.config.CNAMERecords...)
+}
+
+// expireSessions invalidates the sessions, as if they have timed out
+func (f *fakeServer) expireSessions() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions = map[string]bool{}
+}
+
+func (f *fakeServer) loginCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.logins
+}
+
+func (f *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == apiPrefix+"/auth" && r.Method == http.MethodPost {
+		req := &authRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+		if req.Password != testPassword {
+			writeJSON(w, http.StatusUnauthorized, &authResponse{Session: Session{Valid: false, Message: "password incorrect"}})
+			return
+		}
+		f.nextSID++
+		f.logins++
+		sid := fmt.Sprintf("sid-%d", f.nextSID)
+		f.sessions[sid] = true
+		writeJSON(w, http.StatusOK, &authResponse{Session: Session{Valid: true, SID: sid, Validity: 1800, Message: "app-password correct"}})
+		return
+	}
+	if !f.sessions[r.Header.Get(sidHeader)] {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "Unauthorized")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+	switch {
+	case path == "/config/dns" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"config": map[string]interface{}{"dns": f.config}, "took": 0.001})
+	case strings.HasPrefix(path, hostsPath+"/"):
+		f.serveEntry(w, r, &f.config.Hosts, strings.TrimPrefix(path, hostsPath+"/"), validateHost)
+	case strings.HasPrefix(path, cnameRecordsPath+"/"):
+		f.serveEntry(w, r, &f.config.CNAMERecords, strings.TrimPrefix(path, cnameRecordsPath+"/"), validateCNAME)
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "Not found")
+	}
+}
+
+// serveEntry adds or deletes the entry of the array
+func (f *fakeServer) serveEntry(w http.ResponseWriter, r *http.Request, entries *[]string, entry string, validate func(string) error) {
+	i := -1
+	for j, e := range *entries {
+		if e == entry {
+			i = j
+		}
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if i >= 0 {
+			writeError(w, http.StatusBadRequest, "bad_request", "Item already present")
+			return
+		}
+		if err := validate(entry); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+		*entries = append(*entries, entry)
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"took": 0.001})
+	case http.MethodDelete:
+		if i < 0 {
+			writeError(w, http.StatusNotFound, "not_found", "Item not found")
+			return
+		}
+		*entries = append((*entries)[:i:i], (*entries)[i+1:]...)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "bad_request", "method not allowed")
+	}
+}
+
+func validateHost(entry string) error {
+	if e, ok := parseHostEntry(entry); !ok || len(addressType(e.value)) == 0 {
+		return fmt.Errorf("Invalid hosts entry: %s", entry)
+	}
+	return nil
+}
+
+func validateCNAME(entry string) error {
+	if _, ok := parseCNAMEEntry(entry); !ok {
+		return fmt.Errorf("Invalid CNAME record: %s", entry)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, key, message string) {
+	writeJSON(w, status, map[string]interface{}{"error": map[string]interface{}{"key": key, "message": message, "hint": nil}, "took": 0.001})
+}
@@ -0,0 +1,25 @@
+package pihole
+
+import (
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+// NormalizeOptions validates the recordset can be served by the local dns entries, pi-hole has no options.
+// the hosts are served with the ttl of the pi-hole settings, so ttl is only allowed on CNAME records.
+func (c *Client) NormalizeOptions(recordType string, ttl int, options map[string]string) (map[string]string, error) {
+	for key := range options {
+		return nil, fmt.Errorf("unknown pihole option %s", key)
+	}
+
+	if !supportedRecordTypes[recordType] {
+		return nil, fmt.Errorf("%s records are not supported by pi-hole", recordType)
+	}
+	if ttl < 0 {
+		return nil, fmt.Errorf("ttl must not be negative, got %d", ttl)
+	}
+	if ttl > 0 && recordType != provider.RecordTypeCNAME {
+		return nil, fmt.Errorf("ttl of %s record follows the pi-hole settings, got %d", recordType, ttl)
+	}
+	return nil, nil
+}
@@ -0,0 +1,220 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/common"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ProviderKey = "plugin"
+
+const (
+	SettingKeyEndpoint = "endpoint"
+)
+
+// noContextTimeout bounds the calls made without context, e.g. NormalizeOptions
+const noContextTimeout = 10 * time.Second
+
+func init() {
+	provider.Register(ProviderKey, NewPluginClientWithSettings)
+}
+
+// Client calls the provider plugin over grpc, which is usually a sidecar listening on a unix socket
+type Client struct {
+	provider.Client
+	conn *grpc.ClientConn
+	rpc  ProviderClient
+
+	mu sync.Mutex
+	// capabilities are asked once the plugin is reachable
+	capabilities *Capabilities
+}
+
+// NewPluginClientWithSettings creates the client of the plugin on the endpoint of the settings
+func NewPluginClientWithSettings(settings map[string]string) (provider.Client, error) {
+	endpoint := settings[SettingKeyEndpoint]
+	if len(endpoint) == 0 {
+		return nil, fmt.Errorf("can't generate plugin client using settings, %s is required", SettingKeyEndpoint)
+	}
+	c, err := NewPluginClient(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewPluginClient creates the client of the plugin on the endpoint, which is a unix socket path or a grpc target.
+// the connection is made lazily, so the plugin may start after the client.
+func NewPluginClient(endpoint string, opts ...grpc.DialOption) (*Client, error) {
+	target := endpoint
+	if strings.HasPrefix(endpoint, "/") {
+		target = unixScheme + endpoint
+	}
+
+	opts = append([]grpc.DialOption{
+		// the plugin is local to the pod, so the connection is not encrypted
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, opts...)
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("can't create new plugin client: %w", err)
+	}
+	return &Client{
+		conn: conn,
+		rpc:  NewProviderClient(conn),
+	}, nil
+}
+
+// Close closes the connection to the plugin
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Capabilities returns the capabilities of the plugin, which are cached once asked
+func (c *Client) Capabilities(ctx context.Context) (*Capabilities, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capabilities != nil {
+		return c.capabilities, nil
+	}
+
+	capabilities, err := c.rpc.GetCapabilities(ctx, &GetCapabilitiesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("can't get plugin capabilities: %w", err)
+	}
+	c.capabilities = capabilities
+	return capabilities, nil
+}
+
+// Verify checks the plugin is reachable, and verifies the provider of the plugin if it's able to
+func (c *Client) Verify(ctx context.Context) error {
+	capabilities, err := c.Capabilities(ctx)
+	if err != nil {
+		return fmt.Errorf("can't verify plugin: %w", err)
+	}
+	if !capabilities.Verify {
+		return nil
+	}
+	if _, err := c.rpc.Verify(ctx, &VerifyRequest{}); err != nil {
+		return fmt.Errorf("can't verify plugin %s: %w", capabilities.Kind, err)
+	}
+	return nil
+}
+
+// NormalizeOptions normalizes the options by the plugin, no option is allowed if the plugin doesn't accept options
+func (c *Client) NormalizeOptions(recordType string, ttl int, options map[string]string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), noContextTimeout)
+	defer cancel()
+
+	capabilities, err := c.Capabilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !capabilities.NormalizeOptions {
+		if len(options) > 0 {
+			return nil, fmt.Errorf("provider options are not supported by plugin %s", capabilities.Kind)
+		}
+		return nil, nil
+	}
+
+	req := &NormalizeOptionsRequest{Type: recordType, Ttl: int32(ttl), Options: options}
+	resp, err := c.rpc.NormalizeOptions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetOptions(), nil
+}
+
+// OwnershipUnsupported tells the ownership can't be tracked if the plugin doesn't manage TXT records.
+// the ownership is assumed supported while the plugin is unreachable, so the claims fail until it is back.
+func (c *Client) OwnershipUnsupported() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), noContextTimeout)
+	defer cancel()
+
+	capabilities, err := c.Capabilities(ctx)
+	if err != nil {
+		return false
+	}
+	return len(capabilities.RecordTypes) > 0 && !common.ContainsString(capabilities.RecordTypes, provider.RecordTypeTXT)
+}
+
+func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone, error) {
+	resp, err := c.rpc.GetZone(ctx, &GetZoneRequest{ZoneName: zoneName})
+	if err != nil {
+		return nil, fmt.Errorf("can't GetZone: %w", err)
+	}
+	if resp.GetZone() == nil {
+		return nil, fmt.Errorf("can't GetZone: plugin returned no zone for %s", zoneName)
+	}
+	return zoneFromProto(resp.GetZone()), nil
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	resp, err := c.rpc.GetByName(ctx, &GetByNameRequest{Name: name, ZoneId: zoneId, Type: recordType})
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	return domainFromProto(resp.GetDomain()), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	resp, err := c.rpc.Get(ctx, &GetRequest{Id: id, ZoneId: zoneId})
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return domainFromProto(resp.GetDomain()), nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, options map[string]string) (*provider.Domain, error) {
+	if err := c.checkRecordType(ctx, recordType); err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	req := &CreateRequest{Name: name, ZoneId: zoneId, Type: recordType, Records: records, Ttl: int32(ttl), Options: options}
+	resp, err := c.rpc.Create(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	if resp.GetDomain() == nil {
+		return nil, fmt.Errorf("can't Create: plugin returned no recordset for %s", name)
+	}
+	return domainFromProto(resp.GetDomain()), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, options map[string]string) (*provider.Domain, error) {
+	if err := c.checkRecordType(ctx, recordType); err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	req := &UpdateRequest{Id: id, ZoneId: zoneId, Type: recordType, Records: records, Ttl: int32(ttl), Options: options}
+	resp, err := c.rpc.Update(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	return domainFromProto(resp.GetDomain()), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	if _, err := c.rpc.Delete(ctx, &DeleteRequest{Id: id, ZoneId: zoneId}); err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	return nil
+}
+
+// checkRecordType fails early on the record type the plugin doesn't manage
+func (c *Client) checkRecordType(ctx context.Context, recordType string) error {
+	capabilities, err := c.Capabilities(ctx)
+	if err != nil {
+		return err
+	}
+	if len(capabilities.RecordTypes) > 0 && !common.ContainsString(capabilities.RecordTypes, recordType) {
+		return fmt.Errorf("record type %s is not supported by plugin %s", recordType, capabilities.Kind)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	p := newFakeProvider()
+	c := newTestPlugin(t, p, nil)
+
+	z, err := c.GetZone(ctx, "example.com.")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: testZoneId, Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: unexpected zone %+v", z)
+	}
+
+	d, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeA)
+	if err != nil || d != nil {
+		t.Fatalf("GetByName: expected nothing before create, got %v, %v", d, err)
+	}
+
+	d, err = c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 0, map[string]string{testOptionKey: "10"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        "www/A",
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.0.2.1", "192.0.2.2"},
+		TTL:       300,
+		ZoneId:    testZoneId,
+		ZoneName:  testZoneName,
+		FQDN:      "www.example.com.",
+		Options:   map[string]string{testOptionKey: "10"},
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+
+	got, err := c.Get(ctx, d.Id, testZoneId)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, d) {
+		t.Fatalf("Get: expected %+v, got %+v", d, got)
+	}
+
+	d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeCNAME, []string{"lb.example.net."}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != "www/CNAME" || !reflect.DeepEqual(d.Records, []string{"lb.example.net"}) || d.TTL != 60 || d.Options != nil {
+		t.Fatalf("Update: unexpected recordset %+v", d)
+	}
+
+	if err := c.Delete(ctx, d.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if n := p.count(); n != 0 {
+		t.Fatalf("Delete: expected no recordset left, got %d", n)
+	}
+	if d, err := c.Update(ctx, d.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil || d != nil {
+		t.Fatalf("Update: expected nothing for missing recordset, got %v, %v", d, err)
+	}
+	if err := c.Delete(ctx, d.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: expected no error for missing recordset, got %v", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	c := newTestPlugin(t, newFakeProvider(), nil)
+
+	// errors of the provider are told to the client
+	if _, err := c.GetZone(ctx, "example.org"); err == nil || !strings.Contains(err.Error(), "cannot find zone example.org") {
+		t.Fatalf("GetZone: expected error of the provider, got %v", err)
+	}
+	if _, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Create: expected error of the provider, got %v", err)
+	}
+
+	// the requests missing the required fields are rejected by the server
+	_, err := c.Get(ctx, "", testZoneId)
+	if s, ok := status.FromError(errors.Unwrap(err)); !ok || s.Code() != codes.InvalidArgument {
+		t.Fatalf("Get: expected invalid argument, got %v", err)
+	}
+}
+
+func TestClientCapabilities(t *testing.T) {
+	ctx := context.Background()
+	p := newFakeProvider()
+	c := newTestPlugin(t, p, []string{provider.RecordTypeA, provider.RecordTypeTXT})
+
+	capabilities, err := c.Capabilities(ctx)
+	if err != nil {
+		t.Fatalf("Capabilities: %v", err)
+	}
+	expected := &Capabilities{Kind: "fake", Verify: true, NormalizeOptions: true, RecordTypes: []string{provider.RecordTypeA, provider.RecordTypeTXT}}
+	if !proto.Equal(capabilities, expected) {
+		t.Fatalf("Capabilities: expected %v, got %v", expected, capabilities)
+	}
+
+	if !provider.SupportsOwnership(c) {
+		t.Fatalf("SupportsOwnership: expected ownership supported by the plugin managing TXT records")
+	}
+	if _, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeAAAA, []string{"2001:db8::1"}, 0, nil); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Fatalf("Create: expected error on record type the plugin doesn't manage, got %v", err)
+	}
+	if n := p.count(); n != 0 {
+		t.Fatalf("Create: expected no call to the provider, got %d recordsets", n)
+	}
+
+	options, err := provider.NormalizeOptions(c, provider.RecordTypeA, 0, map[string]string{testOptionKey: "10"})
+	if err != nil || !reflect.DeepEqual(options, map[string]string{testOptionKey: "10"}) {
+		t.Fatalf("NormalizeOptions: expected options normalized by the provider, got %v, %v", options, err)
+	}
+	if _, err := provider.NormalizeOptions(c, provider.RecordTypeA, 0, map[string]string{"proxied": "true"}); err == nil {
+		t.Fatalf("NormalizeOptions: expected error of the provider on unknown option")
+	}
+
+	if err := provider.Verify(ctx, c); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	p.verifyErr = errors.New("token expired")
+	if err := provider.Verify(ctx, c); err == nil || !strings.Contains(err.Error(), "token expired") {
+		t.Fatalf("Verify: expected error of the provider, got %v", err)
+	}
+}
+
+func TestClientMinimalProvider(t *testing.T) {
+	ctx := context.Background()
+	c := newTestPlugin(t, &minimalProvider{Client: newFakeProvider()}, nil)
+
+	capabilities, err := c.Capabilities(ctx)
+	if err != nil {
+		t.Fatalf("Capabilities: %v", err)
+	}
+	if capabilities.Verify || capabilities.NormalizeOptions || len(capabilities.RecordTypes) > 0 {
+		t.Fatalf("Capabilities: expected nothing optional, got %+v", capabilities)
+	}
+
+	if err := provider.Verify(ctx, c); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if options, err := provider.NormalizeOptions(c, provider.RecordTypeA, 0, nil); err != nil || options != nil {
+		t.Fatalf("NormalizeOptions: expected no options, got %v, %v", options, err)
+	}
+	if _, err := provider.NormalizeOptions(c, provider.RecordTypeA, 0, map[string]string{testOptionKey: "10"}); err == nil {
+		t.Fatalf("NormalizeOptions: expected error on options the plugin doesn't accept")
+	}
+	if _, err := c.Create(ctx, "mail", testZoneId, "MX", []string{"10 mail.example.com."}, 0, nil); err != nil {
+		t.Fatalf("Create: expected any record type allowed, got %v", err)
+	}
+}
+
+func TestClientUnreachablePlugin(t *testing.T) {
+	c, err := NewPluginClient(t.TempDir() + "/missing.sock")
+	if err != nil {
+		t.Fatalf("NewPluginClient: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTestTimeout)
+	defer cancel()
+	if err := c.Verify(ctx); err == nil {
+		t.Fatalf("Verify: expected error on unreachable plugin")
+	}
+}
+
+func TestNewPluginClientWithSettings(t *testing.T) {
+	if _, err := NewPluginClientWithSettings(map[string]string{}); err == nil {
+		t.Fatalf("expected error without %s", SettingKeyEndpoint)
+	}
+	c, err := NewPluginClientWithSettings(map[string]string{SettingKeyEndpoint: "/var/run/dns-ingress/cloudflare.sock"})
+	if err != nil {
+		t.Fatalf("NewPluginClientWithSettings: %v", err)
+	}
+	_ = c.(*Client).Close()
+
+	if _, err := Listen("relative.sock"); err == nil {
+		t.Fatalf("Listen: expected error on relative socket path")
+	}
+}
+
+func TestClientOwnershipUnsupported(t *testing.T) {
+	c := newTestPlugin(t, newFakeProvider(), []string{provider.RecordTypeA, provider.RecordTypeCNAME})
+	if provider.SupportsOwnership(c) {
+		t.Fatalf("SupportsOwnership: expected ownership unsupported by the plugin not managing TXT records")
+	}
+}
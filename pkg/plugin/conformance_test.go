@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"context"
+	"github.com/sokdak/dns-ingress/pkg/provider/providertest"
+	"os"
+	"testing"
+	"time"
+)
+
+// the conformance test runs against the plugin on the endpoint of the env if set, e.g.
+//
+//	DNS_INGRESS_PLUGIN_ENDPOINT=/var/run/dns-ingress/cloudflare.sock DNS_INGRESS_PLUGIN_ZONE=example.com go test ./pkg/plugin -run Conformance
+//
+// which creates and deletes the recordsets of random names in the zone
+const (
+	envPluginEndpoint = "DNS_INGRESS_PLUGIN_ENDPOINT"
+	envPluginZone     = "DNS_INGRESS_PLUGIN_ZONE"
+)
+
+const defaultTestTimeout = 30 * time.Second
+
+func TestConformance(t *testing.T) {
+	providertest.RunConformance(t, func(t *testing.T) providertest.Fixture {
+		return providertest.Fixture{Client: newTestPlugin(t, newFakeProvider(), nil), ZoneName: testZoneName}
+	})
+}
+
+func TestConformanceEndpoint(t *testing.T) {
+	endpoint, zoneName := os.Getenv(envPluginEndpoint), os.Getenv(envPluginZone)
+	if len(endpoint) == 0 || len(zoneName) == 0 {
+		t.Skipf("both %s and %s are required to run against the plugin", envPluginEndpoint, envPluginZone)
+	}
+
+	c, err := NewPluginClient(endpoint)
+	if err != nil {
+		t.Fatalf("NewPluginClient: %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTestTimeout)
+	defer cancel()
+	capabilities, err := c.Capabilities(ctx)
+	if err != nil {
+		t.Fatalf("Capabilities: %v", err)
+	}
+	t.Logf("running conformance against plugin %s", capabilities.Kind)
+	if err := c.Verify(ctx); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	providertest.RunConformance(t, func(t *testing.T) providertest.Fixture {
+		return providertest.Fixture{Client: c, ZoneName: zoneName}
+	})
+}
@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+// Capabilities tells what the plugin supports, which is the response of GetCapabilities
+type Capabilities = GetCapabilitiesResponse
+
+// zoneToProto converts the zone into the Zone message
+func zoneToProto(z *provider.Zone) *Zone {
+	if z == nil {
+		return nil
+	}
+	return &Zone{Id: z.Id, Name: z.Name, Activated: z.Activated}
+}
+
+// zoneFromProto converts the Zone message into the zone
+func zoneFromProto(z *Zone) *provider.Zone {
+	if z == nil {
+		return nil
+	}
+	return &provider.Zone{Id: z.GetId(), Name: z.GetName(), Activated: z.GetActivated()}
+}
+
+// domainToProto converts the recordset into the Domain message
+func domainToProto(d *provider.Domain) *Domain {
+	if d == nil {
+		return nil
+	}
+	return &Domain{
+		Id:        d.Id,
+		Name:      d.Name,
+		Type:      d.Type,
+		Records:   d.Records,
+		Ttl:       int32(d.TTL),
+		ZoneId:    d.ZoneId,
+		ZoneName:  d.ZoneName,
+		Fqdn:      d.FQDN,
+		Activated: d.Activated,
+		Proxied:   d.Proxied,
+		Options:   d.Options,
+	}
+}
+
+// domainFromProto converts the Domain message into the recordset
+func domainFromProto(d *Domain) *provider.Domain {
+	if d == nil {
+		return nil
+	}
+	return &provider.Domain{
+		Id:        d.GetId(),
+		Name:      d.GetName(),
+		Type:      d.GetType(),
+		Records:   d.GetRecords(),
+		TTL:       int(d.GetTtl()),
+		ZoneId:    d.GetZoneId(),
+		ZoneName:  d.GetZoneName(),
+		FQDN:      d.GetFqdn(),
+		Activated: d.GetActivated(),
+		Proxied:   d.Proxied,
+		Options:   d.GetOptions(),
+	}
+}
@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"github.com/sokdak/dns-ingress/pkg/common"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"google.golang.org/protobuf/proto"
+	"reflect"
+	"testing"
+)
+
+func TestDomainRoundTrip(t *testing.T) {
+	for _, d := range []*provider.Domain{
+		{
+			Id: "www/A", Name: "www", Type: provider.RecordTypeA, Records: []string{"192.0.2.1", "192.0.2.2"}, TTL: 300,
+			ZoneId: "zone-1", ZoneName: "example.com", FQDN: "www.example.com.", Activated: true,
+			Proxied: common.BoolPointer(false), Options: map[string]string{"proxied": "false", "weight": ""},
+		},
+		{Id: "_owner/TXT", Name: "_owner", Type: provider.RecordTypeTXT, Records: []string{""}, TTL: -1},
+	} {
+		b, err := proto.Marshal(&DomainResponse{Domain: domainToProto(d)})
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		got := &DomainResponse{}
+		if err := proto.Unmarshal(b, got); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		if !reflect.DeepEqual(domainFromProto(got.GetDomain()), d) {
+			t.Fatalf("expected %+v, got %+v", d, domainFromProto(got.GetDomain()))
+		}
+	}
+
+	if got := domainFromProto((&DomainResponse{}).GetDomain()); got != nil {
+		t.Fatalf("expected no domain of the empty response, got %+v", got)
+	}
+}
+
+func TestZoneRoundTrip(t *testing.T) {
+	z := &provider.Zone{Id: "zone-1", Name: "example.com", Activated: true}
+	b, err := proto.Marshal(zoneToProto(z))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	got := &Zone{}
+	if err := proto.Unmarshal(b, got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(zoneFromProto(got), z) {
+		t.Fatalf("expected %+v, got %+v", z, zoneFromProto(got))
+	}
+}
@@ -0,0 +1,89 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/memory"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testZoneName = "example.com"
+	// testZoneId is the id the memory provider gives to its first zone
+	testZoneId = "zone-1"
+	// testOptionKey is the only option the fake provider accepts
+	testOptionKey = "weight"
+)
+
+// fakeProvider is the in-memory provider served by the plugin in the tests, which also implements the optional interfaces
+type fakeProvider struct {
+	*memory.Client
+
+	// verifyErr is returned by Verify
+	verifyErr error
+}
+
+func newFakeProvider() *fakeProvider {
+	c, err := memory.NewMemoryClient(testZoneName)
+	if err != nil {
+		panic(err)
+	}
+	return &fakeProvider{Client: c}
+}
+
+// newTestPlugin serves the provider as a plugin on a unix socket, and returns the client of the plugin
+func newTestPlugin(t *testing.T, p provider.Client, recordTypes []string) *Client {
+	lis, err := Listen(filepath.Join(t.TempDir(), "plugin.sock"))
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	return newTestPluginOn(t, lis, p, recordTypes)
+}
+
+func newTestPluginOn(t *testing.T, lis net.Listener, p provider.Client, recordTypes []string) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- NewServer("fake", p, recordTypes).Serve(ctx, lis)
+	}()
+
+	c, err := NewPluginClient(lis.Addr().String())
+	if err != nil {
+		t.Fatalf("NewPluginClient: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = c.Close()
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+	return c
+}
+
+func (p *fakeProvider) Verify(context.Context) error {
+	return p.verifyErr
+}
+
+func (p *fakeProvider) NormalizeOptions(_ string, _ int, options map[string]string) (map[string]string, error) {
+	for k := range options {
+		if k != testOptionKey {
+			return nil, fmt.Errorf("unknown option %s", k)
+		}
+	}
+	return options, nil
+}
+
+// count returns the number of the recordsets
+func (p *fakeProvider) count() int {
+	recordSets, _ := p.List(testZoneId)
+	return len(recordSets)
+}
+
+// minimalProvider is a provider which is neither of Verifier nor OptionsNormalizer
+type minimalProvider struct {
+	provider.Client
+}
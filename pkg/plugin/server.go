@@ -0,0 +1,166 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net"
+	"os"
+	"strings"
+)
+
+const unixScheme = "unix://"
+
+// Server serves the provider client as a plugin
+type Server struct {
+	UnimplementedProviderServer
+
+	client       provider.Client
+	capabilities *Capabilities
+}
+
+// NewServer creates the plugin server of the provider client of the kind, recordTypes are the record types it manages, any if empty
+func NewServer(kind string, client provider.Client, recordTypes []string) *Server {
+	_, verify := client.(provider.Verifier)
+	_, normalizeOptions := client.(provider.OptionsNormalizer)
+	return &Server{
+		client: client,
+		capabilities: &Capabilities{
+			Kind:             kind,
+			Verify:           verify,
+			NormalizeOptions: normalizeOptions,
+			RecordTypes:      recordTypes,
+		},
+	}
+}
+
+// Register registers the plugin service into the grpc server
+func (s *Server) Register(g *grpc.Server) {
+	RegisterProviderServer(g, s)
+}
+
+// Serve serves the plugin on the listener until the context is done, then stops gracefully
+func (s *Server) Serve(ctx context.Context, lis net.Listener) error {
+	g := grpc.NewServer()
+	s.Register(g)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			g.GracefulStop()
+		case <-done:
+		}
+	}()
+
+	if err := g.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return fmt.Errorf("can't serve plugin: %w", err)
+	}
+	return nil
+}
+
+// Listen listens on the unix socket of the endpoint, which is either unix:///path or /path.
+// the stale socket left by the previous run is removed.
+func Listen(endpoint string) (net.Listener, error) {
+	path := strings.TrimPrefix(endpoint, unixScheme)
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("can't listen on %s: endpoint must be the absolute path of unix socket", endpoint)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("can't remove stale socket %s: %w", path, err)
+	}
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("can't listen on %s: %w", endpoint, err)
+	}
+	return lis, nil
+}
+
+func (s *Server) GetCapabilities(context.Context, *GetCapabilitiesRequest) (*GetCapabilitiesResponse, error) {
+	return s.capabilities, nil
+}
+
+func (s *Server) Verify(ctx context.Context, _ *VerifyRequest) (*VerifyResponse, error) {
+	if err := provider.Verify(ctx, s.client); err != nil {
+		return nil, err
+	}
+	return &VerifyResponse{}, nil
+}
+
+func (s *Server) NormalizeOptions(_ context.Context, req *NormalizeOptionsRequest) (*NormalizeOptionsResponse, error) {
+	options, err := provider.NormalizeOptions(s.client, req.GetType(), int(req.GetTtl()), req.GetOptions())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &NormalizeOptionsResponse{Options: options}, nil
+}
+
+func (s *Server) GetZone(ctx context.Context, req *GetZoneRequest) (*GetZoneResponse, error) {
+	if len(req.GetZoneName()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "zone_name is required")
+	}
+	z, err := s.client.GetZone(ctx, req.GetZoneName())
+	if err != nil {
+		return nil, err
+	}
+	return &GetZoneResponse{Zone: zoneToProto(z)}, nil
+}
+
+func (s *Server) GetByName(ctx context.Context, req *GetByNameRequest) (*DomainResponse, error) {
+	if len(req.GetName()) == 0 || len(req.GetZoneId()) == 0 || len(req.GetType()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "name, zone_id and type are required")
+	}
+	d, err := s.client.GetByName(ctx, req.GetName(), req.GetZoneId(), req.GetType())
+	if err != nil {
+		return nil, err
+	}
+	return &DomainResponse{Domain: domainToProto(d)}, nil
+}
+
+func (s *Server) Get(ctx context.Context, req *GetRequest) (*DomainResponse, error) {
+	if len(req.GetId()) == 0 || len(req.GetZoneId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id and zone_id are required")
+	}
+	d, err := s.client.Get(ctx, req.GetId(), req.GetZoneId())
+	if err != nil {
+		return nil, err
+	}
+	return &DomainResponse{Domain: domainToProto(d)}, nil
+}
+
+func (s *Server) Create(ctx context.Context, req *CreateRequest) (*DomainResponse, error) {
+	if len(req.GetName()) == 0 || len(req.GetZoneId()) == 0 || len(req.GetType()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "name, zone_id and type are required")
+	}
+	d, err := s.client.Create(ctx, req.GetName(), req.GetZoneId(), req.GetType(), req.GetRecords(), int(req.GetTtl()), req.GetOptions())
+	if err != nil {
+		return nil, err
+	}
+	return &DomainResponse{Domain: domainToProto(d)}, nil
+}
+
+func (s *Server) Update(ctx context.Context, req *UpdateRequest) (*DomainResponse, error) {
+	if len(req.GetId()) == 0 || len(req.GetZoneId()) == 0 || len(req.GetType()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id, zone_id and type are required")
+	}
+	d, err := s.client.Update(ctx, req.GetId(), req.GetZoneId(), req.GetType(), req.GetRecords(), int(req.GetTtl()), req.GetOptions())
+	if err != nil {
+		return nil, err
+	}
+	return &DomainResponse{Domain: domainToProto(d)}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	if len(req.GetId()) == 0 || len(req.GetZoneId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id and zone_id are required")
+	}
+	if err := s.client.Delete(ctx, req.GetId(), req.GetZoneId()); err != nil {
+		return nil, err
+	}
+	return &DeleteResponse{}, nil
+}
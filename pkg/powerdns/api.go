@@ -0,0 +1,140 @@
+package powerdns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	apiPrefix    = "/api/v1"
+)
+
+const (
+	changeTypeReplace = "REPLACE"
+	changeTypeDelete  = "DELETE"
+)
+
+// kinds of the zones which are replicated from elsewhere, thus read-only
+const (
+	zoneKindSlave    = "Slave"
+	zoneKindConsumer = "Consumer"
+)
+
+// Server is the server object of the api
+type Server struct {
+	Id         string `json:"id"`
+	DaemonType string `json:"daemon_type"`
+	Version    string `json:"version"`
+}
+
+// Zone is the zone object of the api, rrsets are listed only if requested
+type Zone struct {
+	Id     string  `json:"id"`
+	Name   string  `json:"name"`
+	Kind   string  `json:"kind"`
+	Serial uint32  `json:"serial,omitempty"`
+	RRSets []RRSet `json:"rrsets,omitempty"`
+}
+
+// RRSet is the recordset of the api, identified by name and type
+type RRSet struct {
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	TTL        int       `json:"ttl,omitempty"`
+	ChangeType string    `json:"changetype,omitempty"`
+	Records    []Record  `json:"records"`
+	Comments   []Comment `json:"comments,omitempty"`
+}
+
+type Record struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type Comment struct {
+	Content string `json:"content"`
+	Account string `json:"account"`
+}
+
+// rrsetsPatch is the body of the zone PATCH request, all the rrsets are changed in a single transaction
+type rrsetsPatch struct {
+	RRSets []RRSet `json:"rrsets"`
+}
+
+// APIError is returned if the api responds with error status
+type APIError struct {
+	StatusCode int
+	Message    string `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("powerdns api error %d: %s", e.StatusCode, e.Message)
+}
+
+// do sends the request to the api, the response is decoded into out if given
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	u := c.apiUrl + apiPrefix + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("can't encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return fmt.Errorf("can't create request: %w", err)
+	}
+	req.Header.Set(apiKeyHeader, c.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("can't read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		// the api responds with plain text on some errors, e.g. unauthorized
+		if err := json.Unmarshal(b, apiErr); err != nil || len(apiErr.Message) == 0 {
+			apiErr.Message = string(bytes.TrimSpace(b))
+		}
+		return apiErr
+	}
+
+	if out == nil || len(b) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("can't decode response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) serverPath() string {
+	return fmt.Sprintf("/servers/%s", url.PathEscape(c.serverId))
+}
+
+func (c *Client) zonePath(zoneId string) string {
+	return fmt.Sprintf("%s/zones/%s", c.serverPath(), url.PathEscape(zoneId))
+}
@@ -0,0 +1,259 @@
+package powerdns
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ProviderKey = "powerdns"
+
+const (
+	SettingKeyAPIUrl   = "apiUrl"
+	SettingKeyAPIKey   = "apiKey"
+	SettingKeyServerId = "serverId"
+)
+
+// defaultServerId is the id of the server which the api is served by
+const defaultServerId = "localhost"
+
+const (
+	recordComment        = "created and managed by dns-ingress.io"
+	recordCommentAccount = "dns-ingress"
+)
+
+var DefaultHttpClient = &http.Client{Timeout: 30 * time.Second}
+
+func init() {
+	provider.Register(ProviderKey, NewPowerDNSClientWithSettings)
+}
+
+type Client struct {
+	provider.Client
+
+	apiUrl     string
+	apiKey     string
+	serverId   string
+	httpClient *http.Client
+
+	// zoneNames caches zone name by zone id
+	zoneNames sync.Map
+}
+
+// NewPowerDNSClientWithSettings creates the client with the provider settings
+func NewPowerDNSClientWithSettings(settings map[string]string) (provider.Client, error) {
+	c, err := NewPowerDNSClient(settings[SettingKeyAPIUrl], settings[SettingKeyAPIKey], settings[SettingKeyServerId], DefaultHttpClient)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewPowerDNSClient creates the client of the api served at the url, server id defaults to localhost
+func NewPowerDNSClient(apiUrl, apiKey, serverId string, client *http.Client) (*Client, error) {
+	if len(apiUrl) == 0 || len(apiKey) == 0 {
+		return nil, fmt.Errorf("can't create new powerdns client: both %s and %s are required", SettingKeyAPIUrl, SettingKeyAPIKey)
+	}
+	u, err := url.Parse(apiUrl)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return nil, fmt.Errorf("can't create new powerdns client: invalid api url %s", apiUrl)
+	}
+	if len(serverId) == 0 {
+		serverId = defaultServerId
+	}
+	if client == nil {
+		client = DefaultHttpClient
+	}
+
+	return &Client{
+		// the api is served under /api/v1 of the webserver
+		apiUrl:     strings.TrimSuffix(strings.TrimSuffix(apiUrl, "/"), apiPrefix),
+		apiKey:     apiKey,
+		serverId:   serverId,
+		httpClient: client,
+	}, nil
+}
+
+// Verify checks the api key is accepted and the server exists
+func (c *Client) Verify(ctx context.Context) error {
+	server := &Server{}
+	if err := c.do(ctx, http.MethodGet, c.serverPath(), nil, nil, server); err != nil {
+		return fmt.Errorf("can't verify server %s: %w", c.serverId, err)
+	}
+	return nil
+}
+
+func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone, error) {
+	canonicalName := fmt.Sprintf("%s.", strings.ToLower(strings.TrimSuffix(zoneName, ".")))
+
+	zones := make([]Zone, 0)
+	if err := c.do(ctx, http.MethodGet, c.serverPath()+"/zones", url.Values{"zone": {canonicalName}}, nil, &zones); err != nil {
+		return nil, fmt.Errorf("can't GetZone: %w", err)
+	}
+
+	for _, z := range zones {
+		if strings.ToLower(z.Name) != canonicalName {
+			continue
+		}
+		name := strings.TrimSuffix(canonicalName, ".")
+		c.zoneNames.Store(z.Id, name)
+		return &provider.Zone{
+			Id:        z.Id,
+			Name:      name,
+			Activated: z.Kind != zoneKindSlave && z.Kind != zoneKindConsumer,
+		}, nil
+	}
+	return nil, fmt.Errorf("can't GetZone: cannot find zone %s", zoneName)
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+
+	rrset, err := c.getRRSet(ctx, zoneId, fqdnOf(name, zoneName), recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	if rrset == nil {
+		return nil, nil
+	}
+	return convertRRSet(name, zoneId, zoneName, *rrset), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Create: no records given for %s", name)
+	}
+
+	// REPLACE overwrites the existing rrset, which has to be refused as the other providers do
+	fqdn := fqdnOf(name, zoneName)
+	current, err := c.getRRSet(ctx, zoneId, fqdn, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	if current != nil {
+		return nil, fmt.Errorf("can't Create: recordset %s %s already exists", fqdn, recordType)
+	}
+
+	rrset := newRRSet(fqdn, recordType, records, ttl)
+	if err := c.patchRRSets(ctx, zoneId, rrset); err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	return convertRRSet(name, zoneId, zoneName, rrset), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	name, currentType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	fqdn := fqdnOf(name, zoneName)
+	current, err := c.getRRSet(ctx, zoneId, fqdn, currentType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if current == nil {
+		return nil, nil
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Update: no records given for %s", name)
+	}
+
+	// all the records of the rrset are replaced at once, the current rrset is deleted in the same transaction if type has been changed
+	rrset := newRRSet(fqdn, recordType, records, ttl)
+	changes := []RRSet{rrset}
+	if currentType != recordType {
+		changes = []RRSet{{Name: fqdn, Type: currentType, ChangeType: changeTypeDelete}, rrset}
+	}
+	if err := c.patchRRSets(ctx, zoneId, changes...); err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	return convertRRSet(name, zoneId, zoneName, rrset), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	// deleting the rrset which doesn't exist succeeds
+	if err := c.patchRRSets(ctx, zoneId, RRSet{Name: fqdnOf(name, zoneName), Type: recordType, ChangeType: changeTypeDelete}); err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	return nil
+}
+
+// getZoneName returns the zone name of the zone id
+func (c *Client) getZoneName(ctx context.Context, zoneId string) (string, error) {
+	if zoneName, ok := c.zoneNames.Load(zoneId); ok {
+		return zoneName.(string), nil
+	}
+
+	z := &Zone{}
+	if err := c.do(ctx, http.MethodGet, c.zonePath(zoneId), url.Values{"rrsets": {"false"}}, nil, z); err != nil {
+		return "", fmt.Errorf("can't get zone %s: %w", zoneId, err)
+	}
+	zoneName := strings.ToLower(strings.TrimSuffix(z.Name, "."))
+	c.zoneNames.Store(zoneId, zoneName)
+	return zoneName, nil
+}
+
+// getRRSet returns the rrset which has the name and type.
+// the api filters the rrsets since 4.8, the others are filtered out here for the older versions.
+func (c *Client) getRRSet(ctx context.Context, zoneId, fqdn, recordType string) (*RRSet, error) {
+	z := &Zone{}
+	query := url.Values{"rrset_name": {fqdn}, "rrset_type": {recordType}}
+	if err := c.do(ctx, http.MethodGet, c.zonePath(zoneId), query, nil, z); err != nil {
+		return nil, err
+	}
+
+	for _, rrset := range z.RRSets {
+		if strings.ToLower(rrset.Name) == fqdn && rrset.Type == recordType && len(rrset.Records) > 0 {
+			return &rrset, nil
+		}
+	}
+	return nil, nil
+}
+
+// patchRRSets changes the rrsets, the changes are applied in a single transaction
+func (c *Client) patchRRSets(ctx context.Context, zoneId string, rrsets ...RRSet) error {
+	return c.do(ctx, http.MethodPatch, c.zonePath(zoneId), nil, &rrsetsPatch{RRSets: rrsets}, nil)
+}
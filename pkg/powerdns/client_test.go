@@ -0,0 +1,288 @@
+package powerdns
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+const (
+	testZoneName = "example.com"
+	testZoneId   = "example.com."
+)
+
+func newTestClient(t *testing.T) (*fakeServer, *Client) {
+	f := newFakeServer(t)
+	f.addZone(testServerId, testZoneName, "Native")
+	return f, f.newClient(t, testServerId)
+}
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	z, err := c.GetZone(ctx, testZoneName)
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: testZoneId, Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: expected zone %s of id %s, got %+v", testZoneName, testZoneId, z)
+	}
+
+	d, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeA)
+	if err != nil || d != nil {
+		t.Fatalf("GetByName: expected nothing before create, got %v, %v", d, err)
+	}
+
+	d, err = c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("www", provider.RecordTypeA),
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.0.2.1", "192.0.2.2"},
+		TTL:       ttlDefault,
+		ZoneId:    testZoneId,
+		ZoneName:  testZoneName,
+		FQDN:      "www.example.com.",
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+	if rrset := f.rrset(testZoneId, "www.example.com.", provider.RecordTypeA); rrset == nil || len(rrset.Comments) != 1 || rrset.Comments[0].Content != recordComment {
+		t.Fatalf("Create: expected rrset commented, got %+v", rrset)
+	}
+
+	// a fresh client has to resolve the zone name by itself
+	for _, get := range []func() (*provider.Domain, error){
+		func() (*provider.Domain, error) { return c.GetByName(ctx, "www", testZoneId, provider.RecordTypeA) },
+		func() (*provider.Domain, error) { return f.newClient(t, testServerId).Get(ctx, d.Id, testZoneId) },
+	} {
+		got, err := get()
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Get: expected %+v, got %+v", expected, got)
+		}
+	}
+
+	// all the records are replaced in a single patch
+	patches := f.patches
+	d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if want := []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}; !reflect.DeepEqual(d.Records, want) || d.TTL != 60 {
+		t.Fatalf("Update: expected records %v with ttl 60, got %v with ttl %d", want, d.Records, d.TTL)
+	}
+	if got := f.contents(testZoneId, "www.example.com.", provider.RecordTypeA); !reflect.DeepEqual(got, d.Records) {
+		t.Fatalf("Update: expected remote records %v, got %v", d.Records, got)
+	}
+	if f.patches != patches+1 {
+		t.Fatalf("Update: expected a single patch, got %d", f.patches-patches)
+	}
+
+	if err := c.Delete(ctx, d.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if rrset := f.rrset(testZoneId, "www.example.com.", provider.RecordTypeA); rrset != nil {
+		t.Fatalf("Delete: expected no remote rrset, got %+v", rrset)
+	}
+
+	d, err = c.Get(ctx, d.Id, testZoneId)
+	if err != nil || d != nil {
+		t.Fatalf("Get: expected nothing after delete, got %v, %v", d, err)
+	}
+	if err := c.Delete(ctx, expected.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: expected no error for missing recordset, got %v", err)
+	}
+	if d, err := c.Update(ctx, expected.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil || d != nil {
+		t.Fatalf("Update: expected nothing for missing recordset, got %v, %v", d, err)
+	}
+}
+
+func TestClientRecordSetIsolatedByType(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	f.addRRSet(testServerId, testZoneId, RRSet{Name: "www.example.com.", Type: provider.RecordTypeAAAA, TTL: 120,
+		Records: []Record{{Content: "2001:db8::1"}, {Content: "2001:db8::2", Disabled: true}}})
+	a, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	aaaa, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeAAAA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(aaaa.Records, []string{"2001:db8::1", "2001:db8::2"}) || aaaa.TTL != 120 || !aaaa.Activated {
+		t.Fatalf("GetByName: expected only AAAA records, got %+v", aaaa)
+	}
+
+	if _, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeAAAA, []string{"2001:db8::3"}, 0, nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Create: expected error for existing recordset, got %v", err)
+	}
+
+	if err := c.Delete(ctx, a.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.contents(testZoneId, "www.example.com.", provider.RecordTypeAAAA); !reflect.DeepEqual(got, []string{"2001:db8::1", "2001:db8::2"}) {
+		t.Fatalf("Delete: expected AAAA records untouched, got %v", got)
+	}
+}
+
+func TestClientUpdateChangesType(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	d, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// CNAME conflicts with A records, which is rejected unless they are replaced in the same patch
+	d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeCNAME, []string{"lb.example.net"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != provider.GenerateRecordSetId("www", provider.RecordTypeCNAME) {
+		t.Fatalf("Update: expected id of the CNAME recordset, got %s", d.Id)
+	}
+	if rrset := f.rrset(testZoneId, "www.example.com.", provider.RecordTypeA); rrset != nil {
+		t.Fatalf("Update: expected A rrset removed, got %+v", rrset)
+	}
+	if got := f.contents(testZoneId, "www.example.com.", provider.RecordTypeCNAME); !reflect.DeepEqual(got, []string{"lb.example.net."}) {
+		t.Fatalf("Update: expected CNAME record, got %v", got)
+	}
+
+	got, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeCNAME)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(got, d) {
+		t.Fatalf("GetByName: expected %+v, got %+v", d, got)
+	}
+
+	if _, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for A records conflicting with CNAME")
+	}
+}
+
+func TestClientTXTRecordSet(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	long := strings.Repeat("a", provider.TXTStringMaxLength+10)
+	records := []string{`heritage=dns-ingress,"quoted\"`, long}
+	d, err := c.Create(ctx, "_owner", testZoneId, provider.RecordTypeTXT, records, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	want := []string{
+		`"` + long[:provider.TXTStringMaxLength] + `" "aaaaaaaaaa"`,
+		`"heritage=dns-ingress,\"quoted\\\""`,
+	}
+	if got := f.contents(testZoneId, "_owner.example.com.", provider.RecordTypeTXT); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Create: expected quoted remote records %v, got %v", want, got)
+	}
+
+	// powerdns reports the non-printable characters in decimal escapes
+	f.addRRSet(testServerId, testZoneId, RRSet{Name: "_escaped.example.com.", Type: provider.RecordTypeTXT, TTL: 300,
+		Records: []Record{{Content: `"tab\009separated"`}}})
+	for name, want := range map[string][]string{"_owner": {long, records[0]}, "_escaped": {"tab\tseparated"}} {
+		got, err := c.GetByName(ctx, name, testZoneId, provider.RecordTypeTXT)
+		if err != nil {
+			t.Fatalf("GetByName: %v", err)
+		}
+		if !reflect.DeepEqual(got.Records, want) {
+			t.Fatalf("GetByName: expected records %v, got %v", want, got.Records)
+		}
+	}
+	if !reflect.DeepEqual(d.Records, []string{long, records[0]}) {
+		t.Fatalf("Create: expected records %v, got %v", records, d.Records)
+	}
+}
+
+func TestClientGetZone(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	f.addZone(testServerId, testZoneName, "Master")
+	f.addZone(testServerId, "example.net", zoneKindSlave)
+	f.addZone("secondary", "example.org", "Native")
+	c := f.newClient(t, "")
+
+	if z, err := c.GetZone(ctx, "Example.COM."); err != nil || z.Id != testZoneId || !z.Activated {
+		t.Fatalf("GetZone: expected zone %s, got %v, %v", testZoneId, z, err)
+	}
+	z, err := c.GetZone(ctx, "example.net")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if z.Activated {
+		t.Fatalf("GetZone: expected slave zone not activated, got %+v", z)
+	}
+	if _, err := c.Create(ctx, "www", z.Id, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for slave zone")
+	}
+
+	for _, zoneName := range []string{"example.org", "sub.example.com"} {
+		if z, err := c.GetZone(ctx, zoneName); err == nil {
+			t.Fatalf("GetZone: expected error for %s, got %+v", zoneName, z)
+		}
+	}
+
+	// zones are looked up in the server of the server id
+	if z, err := f.newClient(t, "secondary").GetZone(ctx, "example.org"); err != nil || z.Id != "example.org." {
+		t.Fatalf("GetZone: expected zone of the secondary server, got %v, %v", z, err)
+	}
+}
+
+func TestNewPowerDNSClientWithSettings(t *testing.T) {
+	f, _ := newTestClient(t)
+
+	for _, apiUrl := range []string{f.URL, f.URL + "/", f.URL + apiPrefix} {
+		c, err := NewPowerDNSClientWithSettings(map[string]string{SettingKeyAPIUrl: apiUrl, SettingKeyAPIKey: testAPIKey})
+		if err != nil {
+			t.Fatalf("NewPowerDNSClientWithSettings: %v", err)
+		}
+		if c.(*Client).serverId != defaultServerId {
+			t.Fatalf("expected server id %s, got %s", defaultServerId, c.(*Client).serverId)
+		}
+		if err := provider.Verify(context.Background(), c); err != nil {
+			t.Fatalf("Verify: expected api url %s accepted, got %v", apiUrl, err)
+		}
+	}
+
+	for _, settings := range []map[string]string{
+		{SettingKeyAPIUrl: f.URL, SettingKeyAPIKey: "wrong"},
+		{SettingKeyAPIUrl: f.URL, SettingKeyAPIKey: testAPIKey, SettingKeyServerId: "unknown"},
+	} {
+		c, err := NewPowerDNSClientWithSettings(settings)
+		if err != nil {
+			t.Fatalf("NewPowerDNSClientWithSettings: %v", err)
+		}
+		if err := provider.Verify(context.Background(), c); err == nil {
+			t.Fatalf("Verify: expected error for settings %v", settings)
+		}
+	}
+
+	for _, invalid := range []map[string]string{
+		{},
+		{SettingKeyAPIUrl: f.URL},
+		{SettingKeyAPIKey: testAPIKey},
+		{SettingKeyAPIUrl: "localhost:8081", SettingKeyAPIKey: testAPIKey},
+	} {
+		if _, err := NewPowerDNSClientWithSettings(invalid); err == nil {
+			t.Fatalf("NewPowerDNSClientWithSettings: expected error for settings %v", invalid)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package powerdns
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testAPIKey   = "secret-api-key"
+	testServerId = "localhost"
+)
+
+// fakeServer is a minimal stand-in of the powerdns authoritative http api serving zones and rrsets
+type fakeServer struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// zones by server id and zone id
+	zones map[string]map[string]*Zone
+	// patches counts the patches applied
+	patches int
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	f := &fakeServer{zones: map[string]map[string]*Zone{testServerId: {}}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// newClient returns the client which talks to the fake server of the server id
+func (f *fakeServer) newClient(t *testing.T, serverId string) *Client {
+	c, err := NewPowerDNSClient(f.URL, testAPIKey, serverId, f.Client())
+	if err != nil {
+		t.Fatalf("can't create client: %v", err)
+	}
+	return c
+}
+
+// addZone adds the zone to the server, the zone id is the canonical zone name as powerdns does
+func (f *fakeServer) addZone(serverId, name, kind string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.zones[serverId] == nil {
+		f.zones[serverId] = map[string]*Zone{}
+	}
+	id := fmt.Sprintf("%s.", name)
+	f.zones[serverId][id] = &Zone{Id: id, Name: id, Kind: kind, Serial: 1, RRSets: []RRSet{}}
+	return id
+}
+
+// addRRSet seeds the zone with the rrset, bypassing the PATCH handler
+func (f *fakeServer) addRRSet(serverId, zoneId string, rrset RRSet) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	z := f.zones[serverId][zoneId]
+	z.RRSets = append(z.RRSets, rrset)
+}
+
+// rrset returns the rrset which has the fqdn and type
+func (f *fakeServer) rrset(zoneId, fqdn, rrType string) *RRSet {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, rrset := range f.zones[testServerId][zoneId].RRSets {
+		if rrset.Name == fqdn && rrset.Type == rrType {
+			return &rrset
+		}
+	}
+	return nil
+}
+
+// contents returns the sorted contents of the rrset
+func (f *fakeServer) contents(zoneId, fqdn, rrType string) []string {
+	rrset := f.rrset(zoneId, fqdn, rrType)
+	if rrset == nil {
+		return nil
+	}
+	contents := make([]string, 0, len(rrset.Records))
+	for _, r := range rrset.Records {
+		contents = append(contents, r.Content)
+	}
+	sort.Strings(contents)
+	return contents
+}
+
+func (f *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(apiKeyHeader) != testAPIKey {
+		// the webserver responds with plain text
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// /api/v1/servers/{server}[/zones[/{zone}]]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, apiPrefix+"/"), "/")
+	if len(parts) < 2 || parts[0] != "servers" {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	zones, ok := f.zones[parts[1]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, &Server{Id: parts[1], DaemonType: "authoritative", Version: "4.8.3"})
+	case len(parts) == 3 && parts[2] == "zones" && r.Method == http.MethodGet:
+		f.listZones(w, r, zones)
+	case len(parts) == 4 && parts[2] == "zones":
+		z, ok := zones[parts[3]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Could not find domain '"+parts[3]+"'")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			f.getZone(w, r, z)
+		case http.MethodPatch:
+			f.patchZone(w, r, z)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (f *fakeServer) listZones(w http.ResponseWriter, r *http.Request, zones map[string]*Zone) {
+	name := r.URL.Query().Get("zone")
+	list := make([]Zone, 0, len(zones))
+	for _, z := range zones {
+		if len(name) > 0 && z.Name != name {
+			continue
+		}
+		// rrsets are not listed with the zones
+		list = append(list, Zone{Id: z.Id, Name: z.Name, Kind: z.Kind, Serial: z.Serial})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (f *fakeServer) getZone(w http.ResponseWriter, r *http.Request, z *Zone) {
+	query := r.URL.Query()
+	resp := Zone{Id: z.Id, Name: z.Name, Kind: z.Kind, Serial: z.Serial, RRSets: []RRSet{}}
+	if query.Get("rrsets") != "false" {
+		for _, rrset := range z.RRSets {
+			if name := query.Get("rrset_name"); len(name) > 0 && rrset.Name != name {
+				continue
+			}
+			if rrType := query.Get("rrset_type"); len(rrType) > 0 && rrset.Type != rrType {
+				continue
+			}
+			resp.RRSets = append(resp.RRSets, rrset)
+		}
+	}
+	writeJSON(w, http.StatusOK, &resp)
+}
+
+// patchZone applies all the rrset changes or none of them
+func (f *fakeServer) patchZone(w http.ResponseWriter, r *http.Request, z *Zone) {
+	if z.Kind == zoneKindSlave || z.Kind == zoneKindConsumer {
+		writeError(w, http.StatusUnprocessableEntity, "Modifying RRsets in "+z.Kind+" zones is unsupported")
+		return
+	}
+
+	patch := &rrsetsPatch{}
+	if err := json.NewDecoder(r.Body).Decode(patch); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rrsets := append([]RRSet{}, z.RRSets...)
+	for _, change := range patch.RRSets {
+		if !strings.HasSuffix(change.Name, ".") {
+			writeError(w, http.StatusUnprocessableEntity, "Not in expected format (parsed as '"+change.Name+"')")
+			return
+		}
+		if change.Name != z.Name && !strings.HasSuffix(change.Name, "."+z.Name) {
+			writeError(w, http.StatusUnprocessableEntity, "RRset "+change.Name+" IN "+change.Type+": Name is out of zone")
+			return
+		}
+
+		kept := make([]RRSet, 0, len(rrsets))
+		for _, rrset := range rrsets {
+			if rrset.Name != change.Name || rrset.Type != change.Type {
+				kept = append(kept, rrset)
+			}
+		}
+		rrsets = kept
+
+		switch change.ChangeType {
+		case changeTypeDelete:
+		case changeTypeReplace:
+			if change.TTL <= 0 {
+				writeError(w, http.StatusUnprocessableEntity, "TTL is required")
+				return
+			}
+			// replacing with no records deletes the rrset
+			if len(change.Records) > 0 {
+				change.ChangeType = ""
+				rrsets = append(rrsets, change)
+			}
+		default:
+			writeError(w, http.StatusUnprocessableEntity, "Changetype not understood")
+			return
+		}
+	}
+
+	// CNAME can't coexist with the other rrsets of the same name
+	types := map[string]int{}
+	cnames := map[string]bool{}
+	for _, rrset := range rrsets {
+		types[rrset.Name]++
+		cnames[rrset.Name] = cnames[rrset.Name] || rrset.Type == "CNAME"
+	}
+	for name := range cnames {
+		if cnames[name] && types[name] > 1 {
+			writeError(w, http.StatusUnprocessableEntity, "RRset "+name+" IN CNAME: Conflicts with pre-existing RRset")
+			return
+		}
+	}
+
+	z.RRSets = rrsets
+	z.Serial++
+	f.patches++
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,86 @@
+package powerdns
+
+import (
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"sort"
+	"strings"
+)
+
+// ttlDefault is applied if ttl is not set, the api requires ttl on every rrset
+const ttlDefault = 300
+
+// newRRSet builds the rrset which replaces the current one, records are in presentation format
+func newRRSet(fqdn, recordType string, records []string, ttl int) RRSet {
+	rrset := RRSet{
+		Name:       fqdn,
+		Type:       recordType,
+		TTL:        normalizeTTL(ttl),
+		ChangeType: changeTypeReplace,
+		Records:    make([]Record, 0, len(records)),
+		Comments:   []Comment{{Content: recordComment, Account: recordCommentAccount}},
+	}
+	for _, r := range records {
+		rrset.Records = append(rrset.Records, Record{Content: toContent(recordType, r)})
+	}
+	return rrset
+}
+
+// convertRRSet converts the rrset into a recordset, which is activated if any record is enabled
+func convertRRSet(name, zoneId, zoneName string, rrset RRSet) *provider.Domain {
+	records := make([]string, 0, len(rrset.Records))
+	activated := false
+	for _, r := range rrset.Records {
+		records = append(records, fromContent(rrset.Type, r.Content))
+		activated = activated || !r.Disabled
+	}
+	sort.Strings(records)
+
+	return &provider.Domain{
+		Id:        provider.GenerateRecordSetId(name, rrset.Type),
+		Name:      name,
+		Type:      rrset.Type,
+		Records:   records,
+		TTL:       rrset.TTL,
+		ZoneId:    zoneId,
+		ZoneName:  zoneName,
+		FQDN:      fqdnOf(name, zoneName),
+		Activated: activated,
+	}
+}
+
+// toContent returns the record in presentation format the api requires
+func toContent(recordType, record string) string {
+	switch recordType {
+	case provider.RecordTypeCNAME:
+		return fmt.Sprintf("%s.", strings.TrimSuffix(record, "."))
+	case provider.RecordTypeTXT:
+		return provider.QuoteTXT(record)
+	default:
+		return record
+	}
+}
+
+// fromContent returns the record in the form given on Create, reverse of toContent
+func fromContent(recordType, content string) string {
+	switch recordType {
+	case provider.RecordTypeCNAME:
+		return strings.TrimSuffix(content, ".")
+	case provider.RecordTypeTXT:
+		return provider.UnquoteTXT(content)
+	default:
+		return content
+	}
+}
+
+// fqdnOf returns the fully-qualified name with trailing dot, which the api identifies rrsets by
+func fqdnOf(name, zoneName string) string {
+	return fmt.Sprintf("%s.", strings.ToLower(provider.JoinName(name, zoneName)))
+}
+
+func normalizeTTL(ttl int) int {
+	if ttl <= 0 {
+		return ttlDefault
+	}
+	return ttl
+}
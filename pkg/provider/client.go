@@ -1,13 +1,88 @@
 package provider
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"io"
+)
 
+// Client manages the recordsets on the dns provider.
+// a recordset is identified by name and type, and may have multiple records.
+// options are the provider-specific options, which are validated by OptionsNormalizer beforehand.
+// GetByName, Get and Update return nil without error if the recordset is not found.
+//
 //go:generate mockery --name Client --case underscore --inpackage
 type Client interface {
 	GetZone(ctx context.Context, zoneName string) (*Zone, error)
-	GetByName(ctx context.Context, name, zoneId string) (*Domain, error)
+	GetByName(ctx context.Context, name, zoneId, recordType string) (*Domain, error)
 	Get(ctx context.Context, id, zoneId string) (*Domain, error)
-	Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int) (*Domain, error)
-	Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int) (*Domain, error)
+	Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, options map[string]string) (*Domain, error)
+	Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, options map[string]string) (*Domain, error)
 	Delete(ctx context.Context, id, zoneId string) error
 }
+
+// Verifier is implemented by the clients which can check their credentials and permissions before use
+type Verifier interface {
+	Verify(ctx context.Context) error
+}
+
+// Verify verifies the client if it implements Verifier
+func Verify(ctx context.Context, c Client) error {
+	if v, ok := c.(Verifier); ok {
+		return v.Verify(ctx)
+	}
+	return nil
+}
+
+// Close closes the client if it implements io.Closer, e.g. the plugin client holding the connection
+func Close(c Client) error {
+	if closer, ok := c.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// OwnershipUnsupported is implemented by the clients which can't keep the ownership of their recordsets,
+// e.g. the ones which can't store the TXT companions. the ownership is not tracked on them whatever is configured.
+type OwnershipUnsupported interface {
+	OwnershipUnsupported() bool
+}
+
+// SupportsOwnership reports whether the ownership of the recordsets can be tracked on the client
+func SupportsOwnership(c Client) bool {
+	if u, ok := c.(OwnershipUnsupported); ok {
+		return !u.OwnershipUnsupported()
+	}
+	return true
+}
+
+// OptionsNormalizer is implemented by the clients which accept provider-specific options.
+// it validates the options against the recordset, and returns them in the form reported on Domain.Options.
+type OptionsNormalizer interface {
+	NormalizeOptions(recordType string, ttl int, options map[string]string) (map[string]string, error)
+}
+
+// NormalizeOptions normalizes the options if the client implements OptionsNormalizer,
+// otherwise no option is allowed
+func NormalizeOptions(c Client, recordType string, ttl int, options map[string]string) (map[string]string, error) {
+	if n, ok := c.(OptionsNormalizer); ok {
+		return n.NormalizeOptions(recordType, ttl, options)
+	}
+	if len(options) > 0 {
+		return nil, fmt.Errorf("provider options are not supported")
+	}
+	return nil, nil
+}
+
+// OptionsEqual reports whether the options have the same entries, nil and empty options are equal
+func OptionsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package provider
+
+import "testing"
+
+func TestNormalizeOptionsWithoutNormalizer(t *testing.T) {
+	options, err := NormalizeOptions(&MockClient{}, RecordTypeA, 0, nil)
+	if err != nil || options != nil {
+		t.Fatalf("expected no options, got %v, %v", options, err)
+	}
+	if _, err := NormalizeOptions(&MockClient{}, RecordTypeA, 0, map[string]string{"proxied": "true"}); err == nil {
+		t.Fatalf("expected error on options the provider doesn't support")
+	}
+}
+
+// txtlessClient is the client which can't store the TXT companions
+type txtlessClient struct {
+	MockClient
+}
+
+func (c *txtlessClient) OwnershipUnsupported() bool {
+	return true
+}
+
+func TestSupportsOwnership(t *testing.T) {
+	if !SupportsOwnership(&MockClient{}) {
+		t.Fatalf("expected ownership supported by default")
+	}
+	if SupportsOwnership(&txtlessClient{}) {
+		t.Fatalf("expected ownership unsupported by the client telling so")
+	}
+}
+
+func TestOptionsEqual(t *testing.T) {
+	tests := []struct {
+		a, b map[string]string
+		want bool
+	}{
+		{a: nil, b: map[string]string{}, want: true},
+		{a: map[string]string{"proxied": "true"}, b: map[string]string{"proxied": "true"}, want: true},
+		{a: map[string]string{"proxied": "true"}, b: map[string]string{"proxied": "false"}, want: false},
+		{a: map[string]string{"proxied": "true"}, b: nil, want: false},
+		{a: map[string]string{"proxied": "true"}, b: map[string]string{"weight": "true"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := OptionsEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("OptionsEqual(%v, %v): expected %v, got %v", tt.a, tt.b, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the manager-level provider configuration
+type Config struct {
+	Providers []ProviderConfig `json:"providers"`
+}
+
+// ProviderConfig enables the provider of the kind by name, which is referred by DomainSpec.Provider
+type ProviderConfig struct {
+	Name     string            `json:"name"`
+	Kind     string            `json:"kind"`
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// LoadConfig reads the provider configuration from the yaml file
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read provider config: %w", err)
+	}
+
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(b, config); err != nil {
+		return nil, fmt.Errorf("can't parse provider config: %w", err)
+	}
+
+	names := map[string]bool{}
+	for _, p := range config.Providers {
+		if len(p.Name) == 0 || len(p.Kind) == 0 {
+			return nil, fmt.Errorf("can't parse provider config: name and kind are required")
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("can't parse provider config: duplicated provider name %s", p.Name)
+		}
+		names[p.Name] = true
+	}
+	return config, nil
+}
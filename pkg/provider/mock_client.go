@@ -13,25 +13,25 @@ type MockClient struct {
 	mock.Mock
 }
 
-// Create provides a mock function with given fields: ctx, name, zoneId, recordType, records, ttl
-func (_m *MockClient) Create(ctx context.Context, name string, zoneId string, recordType string, records []string, ttl int) (*Domain, error) {
-	ret := _m.Called(ctx, name, zoneId, recordType, records, ttl)
+// Create provides a mock function with given fields: ctx, name, zoneId, recordType, records, ttl, options
+func (_m *MockClient) Create(ctx context.Context, name string, zoneId string, recordType string, records []string, ttl int, options map[string]string) (*Domain, error) {
+	ret := _m.Called(ctx, name, zoneId, recordType, records, ttl, options)
 
 	var r0 *Domain
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, []string, int) (*Domain, error)); ok {
-		return rf(ctx, name, zoneId, recordType, records, ttl)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, []string, int, map[string]string) (*Domain, error)); ok {
+		return rf(ctx, name, zoneId, recordType, records, ttl, options)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, []string, int) *Domain); ok {
-		r0 = rf(ctx, name, zoneId, recordType, records, ttl)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, []string, int, map[string]string) *Domain); ok {
+		r0 = rf(ctx, name, zoneId, recordType, records, ttl, options)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*Domain)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, []string, int) error); ok {
-		r1 = rf(ctx, name, zoneId, recordType, records, ttl)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, []string, int, map[string]string) error); ok {
+		r1 = rf(ctx, name, zoneId, recordType, records, ttl, options)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -79,25 +79,25 @@ func (_m *MockClient) Get(ctx context.Context, id string, zoneId string) (*Domai
 	return r0, r1
 }
 
-// GetByName provides a mock function with given fields: ctx, name, zoneId
-func (_m *MockClient) GetByName(ctx context.Context, name string, zoneId string) (*Domain, error) {
-	ret := _m.Called(ctx, name, zoneId)
+// GetByName provides a mock function with given fields: ctx, name, zoneId, recordType
+func (_m *MockClient) GetByName(ctx context.Context, name string, zoneId string, recordType string) (*Domain, error) {
+	ret := _m.Called(ctx, name, zoneId, recordType)
 
 	var r0 *Domain
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) (*Domain, error)); ok {
-		return rf(ctx, name, zoneId)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) (*Domain, error)); ok {
+		return rf(ctx, name, zoneId, recordType)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, string) *Domain); ok {
-		r0 = rf(ctx, name, zoneId)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) *Domain); ok {
+		r0 = rf(ctx, name, zoneId, recordType)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*Domain)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
-		r1 = rf(ctx, name, zoneId)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string) error); ok {
+		r1 = rf(ctx, name, zoneId, recordType)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -131,25 +131,25 @@ func (_m *MockClient) GetZone(ctx context.Context, zoneName string) (*Zone, erro
 	return r0, r1
 }
 
-// Update provides a mock function with given fields: ctx, id, zoneId, recordType, records, ttl
-func (_m *MockClient) Update(ctx context.Context, id string, zoneId string, recordType string, records []string, ttl int) (*Domain, error) {
-	ret := _m.Called(ctx, id, zoneId, recordType, records, ttl)
+// Update provides a mock function with given fields: ctx, id, zoneId, recordType, records, ttl, options
+func (_m *MockClient) Update(ctx context.Context, id string, zoneId string, recordType string, records []string, ttl int, options map[string]string) (*Domain, error) {
+	ret := _m.Called(ctx, id, zoneId, recordType, records, ttl, options)
 
 	var r0 *Domain
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, []string, int) (*Domain, error)); ok {
-		return rf(ctx, id, zoneId, recordType, records, ttl)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, []string, int, map[string]string) (*Domain, error)); ok {
+		return rf(ctx, id, zoneId, recordType, records, ttl, options)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, []string, int) *Domain); ok {
-		r0 = rf(ctx, id, zoneId, recordType, records, ttl)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, []string, int, map[string]string) *Domain); ok {
+		r0 = rf(ctx, id, zoneId, recordType, records, ttl, options)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*Domain)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, []string, int) error); ok {
-		r1 = rf(ctx, id, zoneId, recordType, records, ttl)
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, []string, int, map[string]string) error); ok {
+		r1 = rf(ctx, id, zoneId, recordType, records, ttl, options)
 	} else {
 		r1 = ret.Error(1)
 	}
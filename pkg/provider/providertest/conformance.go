@@ -0,0 +1,379 @@
+// Package providertest is the conformance test suite of provider.Client, which every provider has to pass.
+package providertest
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/common"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const conformanceTimeout = 30 * time.Second
+
+// Fixture is the client under test and the zone it serves, which is usually backed by a fake of the dns backend
+type Fixture struct {
+	Client   provider.Client
+	ZoneName string
+	// RecordTypes are the record types the backend serves, the cases which need the others are skipped.
+	// all the record types are served if empty.
+	RecordTypes []string
+	// NoTTL is set if the backend serves the records with its own ttl, then the ttl of the recordsets is not checked
+	NoTTL bool
+}
+
+// conformanceCase checks a contract of provider.Client against the zone of the fixture.
+// the records are created with the name given to the case, which is unique in the zone.
+type conformanceCase struct {
+	name string
+	// recordTypes are the record types the case creates
+	recordTypes []string
+	run         func(t *testing.T, ctx context.Context, f Fixture, z *provider.Zone, name string)
+}
+
+// RunConformance runs the conformance cases as subtests, each of them against a new fixture
+func RunConformance(t *testing.T, newFixture func(t *testing.T) Fixture) {
+	for _, tc := range conformanceCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), conformanceTimeout)
+			defer cancel()
+
+			f := newFixture(t)
+			if missing := f.unsupported(tc.recordTypes); len(missing) > 0 {
+				t.Skipf("%s records are not served by the backend", strings.Join(missing, ", "))
+			}
+			z, err := f.Client.GetZone(ctx, f.ZoneName)
+			if err != nil {
+				t.Fatalf("GetZone: %v", err)
+			}
+			if len(z.Id) == 0 || z.Name != strings.TrimSuffix(strings.ToLower(f.ZoneName), ".") {
+				t.Fatalf("GetZone: expected zone %s with id, got %+v", f.ZoneName, z)
+			}
+
+			// the name is random as the fixture may share the zone with the other runs, e.g. a plugin endpoint
+			name := fmt.Sprintf("conformance-%s", common.GenerateRandomRunes(8))
+			t.Cleanup(func() {
+				cleanup(f.Client, z.Id, name)
+			})
+			tc.run(t, ctx, f, z, name)
+		})
+	}
+}
+
+// unsupported returns the record types which are not served by the backend among the given ones
+func (f Fixture) unsupported(recordTypes []string) []string {
+	if len(f.RecordTypes) == 0 {
+		return nil
+	}
+	served := map[string]bool{}
+	for _, t := range f.RecordTypes {
+		served[t] = true
+	}
+	missing := make([]string, 0)
+	for _, t := range recordTypes {
+		if !served[t] {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
+
+// cleanup deletes the recordsets a case may leave behind if it fails in the middle.
+// the recordsets are looked up by name since the ids are opaque to the suite.
+func cleanup(c provider.Client, zoneId, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), conformanceTimeout)
+	defer cancel()
+	for _, n := range []string{name, "sub." + name} {
+		for _, recordType := range []string{provider.RecordTypeA, provider.RecordTypeAAAA, provider.RecordTypeCNAME, provider.RecordTypeTXT} {
+			if d, err := c.GetByName(ctx, n, zoneId, recordType); err == nil && d != nil {
+				_ = c.Delete(ctx, d.Id, zoneId)
+			}
+		}
+	}
+}
+
+var conformanceCases = []conformanceCase{
+	{
+		name:        "NotFound",
+		recordTypes: []string{provider.RecordTypeA},
+		run: func(t *testing.T, ctx context.Context, f Fixture, z *provider.Zone, name string) {
+			if d, err := f.Client.GetByName(ctx, name, z.Id, provider.RecordTypeA); err != nil || d != nil {
+				t.Fatalf("GetByName: expected nothing, got %v, %v", d, err)
+			}
+
+			// the id of a deleted recordset refers to nothing, whatever form the provider gives it
+			d, err := f.Client.Create(ctx, name, z.Id, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if err := f.Client.Delete(ctx, d.Id, z.Id); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			id := d.Id
+			if d, err := f.Client.Get(ctx, id, z.Id); err != nil || d != nil {
+				t.Fatalf("Get: expected nothing, got %v, %v", d, err)
+			}
+			if d, err := f.Client.Update(ctx, id, z.Id, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil || d != nil {
+				t.Fatalf("Update: expected nothing, got %v, %v", d, err)
+			}
+			if err := f.Client.Delete(ctx, id, z.Id); err != nil {
+				t.Fatalf("Delete: expected no error, got %v", err)
+			}
+			if d, err := f.Client.GetByName(ctx, name, z.Id, provider.RecordTypeA); err != nil || d != nil {
+				t.Fatalf("GetByName: expected nothing after update of missing recordset, got %v, %v", d, err)
+			}
+		},
+	},
+	{
+		name:        "CreateMultiValue",
+		recordTypes: []string{provider.RecordTypeA},
+		run: func(t *testing.T, ctx context.Context, f Fixture, z *provider.Zone, name string) {
+			records := []string{"192.0.2.2", "192.0.2.1", "192.0.2.3"}
+			d, err := f.Client.Create(ctx, name, z.Id, provider.RecordTypeA, records, 600, nil)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			checkDomain(t, f, "Create", d, z, name, provider.RecordTypeA, records, 600)
+
+			got, err := f.Client.GetByName(ctx, name, z.Id, provider.RecordTypeA)
+			if err != nil {
+				t.Fatalf("GetByName: %v", err)
+			}
+			checkDomain(t, f, "GetByName", got, z, name, provider.RecordTypeA, records, 600)
+			if got.Id != d.Id {
+				t.Fatalf("GetByName: expected id %s of the created recordset, got %s", d.Id, got.Id)
+			}
+
+			got, err = f.Client.Get(ctx, d.Id, z.Id)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			checkDomain(t, f, "Get", got, z, name, provider.RecordTypeA, records, 600)
+		},
+	},
+	{
+		name:        "CreateExisting",
+		recordTypes: []string{provider.RecordTypeA},
+		run: func(t *testing.T, ctx context.Context, f Fixture, z *provider.Zone, name string) {
+			if _, err := f.Client.Create(ctx, name, z.Id, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if _, err := f.Client.Create(ctx, name, z.Id, provider.RecordTypeA, []string{"192.0.2.2"}, 0, nil); err == nil {
+				t.Fatalf("Create: expected error for existing recordset")
+			}
+			got, err := f.Client.GetByName(ctx, name, z.Id, provider.RecordTypeA)
+			if err != nil {
+				t.Fatalf("GetByName: %v", err)
+			}
+			checkDomain(t, f, "GetByName", got, z, name, provider.RecordTypeA, []string{"192.0.2.1"}, 0)
+		},
+	},
+	{
+		name:        "Update",
+		recordTypes: []string{provider.RecordTypeA},
+		run: func(t *testing.T, ctx context.Context, f Fixture, z *provider.Zone, name string) {
+			d, err := f.Client.Create(ctx, name, z.Id, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 300, nil)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			records := []string{"192.0.2.3", "192.0.2.2"}
+			d, err = f.Client.Update(ctx, d.Id, z.Id, provider.RecordTypeA, records, 600, nil)
+			if err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			checkDomain(t, f, "Update", d, z, name, provider.RecordTypeA, records, 600)
+
+			got, err := f.Client.Get(ctx, d.Id, z.Id)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			checkDomain(t, f, "Get", got, z, name, provider.RecordTypeA, records, 600)
+		},
+	},
+	{
+		name:        "UpdateIdempotent",
+		recordTypes: []string{provider.RecordTypeA},
+		run: func(t *testing.T, ctx context.Context, f Fixture, z *provider.Zone, name string) {
+			records := []string{"192.0.2.1", "192.0.2.2"}
+			d, err := f.Client.Create(ctx, name, z.Id, provider.RecordTypeA, records, 300, nil)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			for i := 0; i < 2; i++ {
+				d, err = f.Client.Update(ctx, d.Id, z.Id, provider.RecordTypeA, records, 300, nil)
+				if err != nil {
+					t.Fatalf("Update #%d: %v", i, err)
+				}
+				checkDomain(t, f, fmt.Sprintf("Update #%d", i), d, z, name, provider.RecordTypeA, records, 300)
+			}
+		},
+	},
+	{
+		name:        "UpdateChangesType",
+		recordTypes: []string{provider.RecordTypeA, provider.RecordTypeCNAME},
+		run: func(t *testing.T, ctx context.Context, f Fixture, z *provider.Zone, name string) {
+			d, err := f.Client.Create(ctx, name, z.Id, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			records := []string{"lb.example.net"}
+			cname, err := f.Client.Update(ctx, d.Id, z.Id, provider.RecordTypeCNAME, records, 0, nil)
+			if err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			checkDomain(t, f, "Update", cname, z, name, provider.RecordTypeCNAME, records, 0)
+
+			if got, err := f.Client.Get(ctx, d.Id, z.Id); err != nil || got != nil {
+				t.Fatalf("Get: expected A recordset replaced, got %v, %v", got, err)
+			}
+			got, err := f.Client.GetByName(ctx, name, z.Id, provider.RecordTypeCNAME)
+			if err != nil {
+				t.Fatalf("GetByName: %v", err)
+			}
+			checkDomain(t, f, "GetByName", got, z, name, provider.RecordTypeCNAME, records, 0)
+		},
+	},
+	{
+		name:        "Delete",
+		recordTypes: []string{provider.RecordTypeA},
+		run: func(t *testing.T, ctx context.Context, f Fixture, z *provider.Zone, name string) {
+			d, err := f.Client.Create(ctx, name, z.Id, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 0, nil)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			for i := 0; i < 2; i++ {
+				if err := f.Client.Delete(ctx, d.Id, z.Id); err != nil {
+					t.Fatalf("Delete #%d: %v", i, err)
+				}
+				if got, err := f.Client.Get(ctx, d.Id, z.Id); err != nil || got != nil {
+					t.Fatalf("Get: expected nothing after delete #%d, got %v, %v", i, got, err)
+				}
+			}
+
+			// the name can be reused once deleted
+			if _, err := f.Client.Create(ctx, name, z.Id, provider.RecordTypeA, []string{"192.0.2.3"}, 0, nil); err != nil {
+				t.Fatalf("Create: expected the deleted recordset created again, got %v", err)
+			}
+		},
+	},
+	{
+		name:        "IsolatedByType",
+		recordTypes: []string{provider.RecordTypeA, provider.RecordTypeAAAA},
+		run: func(t *testing.T, ctx context.Context, f Fixture, z *provider.Zone, name string) {
+			a, err := f.Client.Create(ctx, name, z.Id, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			aaaa, err := f.Client.Create(ctx, name, z.Id, provider.RecordTypeAAAA, []string{"2001:db8::1", "2001:db8::2"}, 0, nil)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if a.Id == aaaa.Id {
+				t.Fatalf("Create: expected ids differ by type, got %s", a.Id)
+			}
+
+			// the id may change on update, e.g. if it refers to a record object of the backend
+			if a, err = f.Client.Update(ctx, a.Id, z.Id, provider.RecordTypeA, []string{"192.0.2.2"}, 0, nil); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			if err := f.Client.Delete(ctx, a.Id, z.Id); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			got, err := f.Client.GetByName(ctx, name, z.Id, provider.RecordTypeAAAA)
+			if err != nil {
+				t.Fatalf("GetByName: %v", err)
+			}
+			checkDomain(t, f, "GetByName", got, z, name, provider.RecordTypeAAAA, []string{"2001:db8::1", "2001:db8::2"}, 0)
+		},
+	},
+	{
+		name:        "IsolatedByName",
+		recordTypes: []string{provider.RecordTypeA},
+		run: func(t *testing.T, ctx context.Context, f Fixture, z *provider.Zone, name string) {
+			parent, err := f.Client.Create(ctx, name, z.Id, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if _, err := f.Client.Create(ctx, "sub."+name, z.Id, provider.RecordTypeA, []string{"192.0.2.2"}, 0, nil); err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			if err := f.Client.Delete(ctx, parent.Id, z.Id); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			got, err := f.Client.GetByName(ctx, "sub."+name, z.Id, provider.RecordTypeA)
+			if err != nil {
+				t.Fatalf("GetByName: %v", err)
+			}
+			checkDomain(t, f, "GetByName", got, z, "sub."+name, provider.RecordTypeA, []string{"192.0.2.2"}, 0)
+		},
+	},
+	{
+		name:        "TXT",
+		recordTypes: []string{provider.RecordTypeTXT},
+		run: func(t *testing.T, ctx context.Context, f Fixture, z *provider.Zone, name string) {
+			records := []string{
+				"heritage=dns-ingress,dns-ingress/owner=default",
+				`v=spf1 include:_spf.example.com ~all`,
+				// longer than a character-string, which the provider has to split and join
+				strings.Repeat("0123456789", 30),
+			}
+			d, err := f.Client.Create(ctx, name, z.Id, provider.RecordTypeTXT, records, 0, nil)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			checkDomain(t, f, "Create", d, z, name, provider.RecordTypeTXT, records, 0)
+
+			got, err := f.Client.Get(ctx, d.Id, z.Id)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			checkDomain(t, f, "Get", got, z, name, provider.RecordTypeTXT, records, 0)
+		},
+	},
+}
+
+// checkDomain checks the recordset has the records regardless of order, ttl is checked only if set and the backend keeps it.
+// the id is opaque to the suite, e.g. the provider may give the id of its own backend object, so it only has to be set
+func checkDomain(t *testing.T, f Fixture, op string, d *provider.Domain, z *provider.Zone, name, recordType string, records []string, ttl int) {
+	t.Helper()
+	if d == nil {
+		t.Fatalf("%s: expected recordset %s %s, got nothing", op, name, recordType)
+	}
+
+	if d.Name != name || d.Type != recordType || d.ZoneId != z.Id {
+		t.Fatalf("%s: expected recordset %s %s of zone %s, got %+v", op, name, recordType, z.Id, d)
+	}
+	if len(d.Id) == 0 {
+		t.Fatalf("%s: expected id of %s %s, got none", op, name, recordType)
+	}
+	if fqdn := provider.JoinName(name, z.Name) + "."; !strings.EqualFold(d.FQDN, fqdn) {
+		t.Fatalf("%s: expected fqdn %s, got %s", op, fqdn, d.FQDN)
+	}
+	if expected, got := normalizeRecords(recordType, records), normalizeRecords(recordType, d.Records); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("%s: expected records %v, got %v", op, expected, got)
+	}
+	if ttl > 0 && !f.NoTTL && d.TTL != ttl {
+		t.Fatalf("%s: expected ttl %d, got %d", op, ttl, d.TTL)
+	}
+}
+
+// normalizeRecords sorts the records, the trailing dot of CNAME is up to the provider
+func normalizeRecords(recordType string, records []string) []string {
+	normalized := make([]string, 0, len(records))
+	for _, r := range records {
+		if recordType == provider.RecordTypeCNAME {
+			r = strings.TrimSuffix(r, ".")
+		}
+		normalized = append(normalized, r)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
@@ -0,0 +1,126 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory creates the provider client with the settings
+type Factory func(settings map[string]string) (Client, error)
+
+// Registry holds the provider factories by kind and the enabled provider clients by name
+type Registry struct {
+	mu        sync.RWMutex
+	factories map[string]Factory
+	clients   map[string]Client
+}
+
+// DefaultRegistry is the registry which providers register their factories into on init
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: map[string]Factory{},
+		clients:   map[string]Client{},
+	}
+}
+
+// Register registers the provider factory of the kind into DefaultRegistry
+func Register(kind string, factory Factory) {
+	DefaultRegistry.Register(kind, factory)
+}
+
+// Register registers the provider factory of the kind, panics if the kind is already registered
+func (r *Registry) Register(kind string, factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, found := r.factories[kind]; found {
+		panic(fmt.Sprintf("provider kind %s is already registered", kind))
+	}
+	r.factories[kind] = factory
+}
+
+// Kinds returns the registered provider kinds in order
+func (r *Registry) Kinds() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	kinds := make([]string, 0, len(r.factories))
+	for k := range r.factories {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Build creates the client of the kind with the settings, which is not enabled until Put
+func (r *Registry) Build(kind string, settings map[string]string) (Client, error) {
+	r.mu.RLock()
+	factory, found := r.factories[kind]
+	r.mu.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("unknown provider kind %s", kind)
+	}
+	return factory(settings)
+}
+
+// Put makes the client available by name, replaces and closes the existing one
+func (r *Registry) Put(name string, client Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, found := r.clients[name]; found && old != client {
+		// the replaced client is not used anymore, nothing to do on the failure
+		_ = Close(old)
+	}
+	r.clients[name] = client
+}
+
+// Enable creates the client of the kind and makes it available by name, replaces the existing one
+func (r *Registry) Enable(name, kind string, settings map[string]string) error {
+	client, err := r.Build(kind, settings)
+	if err != nil {
+		return fmt.Errorf("can't enable provider %s: %w", name, err)
+	}
+	r.Put(name, client)
+	return nil
+}
+
+// Disable removes and closes the client by name
+func (r *Registry) Disable(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, found := r.clients[name]; found {
+		_ = Close(old)
+	}
+	delete(r.clients, name)
+}
+
+// Load enables all the providers of the config
+func (r *Registry) Load(config *Config) error {
+	for _, p := range config.Providers {
+		if err := r.Enable(p.Name, p.Kind, p.Settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the enabled client by name
+func (r *Registry) Get(name string) (Client, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	client, found := r.clients[name]
+	return client, found
+}
+
+// Names returns the names of the enabled providers in order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.clients))
+	for n := range r.clients {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
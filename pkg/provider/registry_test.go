@@ -0,0 +1,169 @@
+package provider
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRegistryEnable(t *testing.T) {
+	r := NewRegistry()
+	var gotSettings map[string]string
+	r.Register("fake", func(settings map[string]string) (Client, error) {
+		gotSettings = settings
+		return &MockClient{}, nil
+	})
+	r.Register("broken", func(settings map[string]string) (Client, error) {
+		return nil, errors.New("missing credentials")
+	})
+
+	if err := r.Enable("primary", "fake", map[string]string{"token": "secret"}); err != nil {
+		t.Fatalf("Enable: %v", err)
+	}
+	if !reflect.DeepEqual(gotSettings, map[string]string{"token": "secret"}) {
+		t.Fatalf("Enable: expected settings passed to the factory, got %v", gotSettings)
+	}
+	if _, found := r.Get("primary"); !found {
+		t.Fatalf("Get: expected provider primary enabled")
+	}
+	if _, found := r.Get("fake"); found {
+		t.Fatalf("Get: expected provider looked up by name, not by kind")
+	}
+
+	if err := r.Enable("secondary", "unknown", nil); err == nil {
+		t.Fatalf("Enable: expected error for unknown kind")
+	}
+	if err := r.Enable("secondary", "broken", nil); err == nil {
+		t.Fatalf("Enable: expected error from the factory")
+	}
+	if names := r.Names(); !reflect.DeepEqual(names, []string{"primary"}) {
+		t.Fatalf("Names: expected only primary enabled, got %v", names)
+	}
+
+	r.Disable("primary")
+	if _, found := r.Get("primary"); found {
+		t.Fatalf("Get: expected provider primary disabled")
+	}
+}
+
+func TestRegistryBuildAndPut(t *testing.T) {
+	r := NewRegistry()
+	r.Register("fake", func(settings map[string]string) (Client, error) { return &MockClient{}, nil })
+
+	client, err := r.Build("fake", nil)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if _, found := r.Get("primary"); found {
+		t.Fatalf("Get: expected built client not enabled before Put")
+	}
+	if _, err := r.Build("unknown", nil); err == nil {
+		t.Fatalf("Build: expected error for unknown kind")
+	}
+
+	r.Put("primary", client)
+	if got, found := r.Get("primary"); !found || got != client {
+		t.Fatalf("Get: expected the client put, got %v", got)
+	}
+}
+
+// closingClient counts the Close calls, as the plugin client closing its connection
+type closingClient struct {
+	MockClient
+	closed int
+}
+
+func (c *closingClient) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestRegistryClosesReplacedClient(t *testing.T) {
+	r := NewRegistry()
+	first, second := &closingClient{}, &closingClient{}
+
+	r.Put("primary", first)
+	r.Put("primary", first)
+	if first.closed != 0 {
+		t.Fatalf("Put: expected the same client not closed, closed %d times", first.closed)
+	}
+	r.Put("primary", second)
+	if first.closed != 1 {
+		t.Fatalf("Put: expected the replaced client closed once, closed %d times", first.closed)
+	}
+	r.Disable("primary")
+	if second.closed != 1 {
+		t.Fatalf("Disable: expected the disabled client closed once, closed %d times", second.closed)
+	}
+	r.Disable("primary")
+	if second.closed != 1 {
+		t.Fatalf("Disable: expected the client closed once, closed %d times", second.closed)
+	}
+}
+
+func TestRegistryRegisterDuplicatedKind(t *testing.T) {
+	r := NewRegistry()
+	factory := func(settings map[string]string) (Client, error) { return &MockClient{}, nil }
+	r.Register("fake", factory)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Register: expected panic for duplicated kind")
+		}
+	}()
+	r.Register("fake", factory)
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.yaml")
+	content := `providers:
+- name: internal
+  kind: fake
+  settings:
+    server: ns1.example.com
+- name: external
+  kind: fake
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("can't write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	expected := &Config{Providers: []ProviderConfig{
+		{Name: "internal", Kind: "fake", Settings: map[string]string{"server": "ns1.example.com"}},
+		{Name: "external", Kind: "fake"},
+	}}
+	if !reflect.DeepEqual(config, expected) {
+		t.Fatalf("LoadConfig: expected %+v, got %+v", expected, config)
+	}
+
+	r := NewRegistry()
+	r.Register("fake", func(settings map[string]string) (Client, error) { return &MockClient{}, nil })
+	if err := r.Load(config); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if names := r.Names(); !reflect.DeepEqual(names, []string{"external", "internal"}) {
+		t.Fatalf("Names: expected all providers enabled, got %v", names)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"missing kind":    "providers:\n- name: internal\n",
+		"duplicated name": "providers:\n- name: a\n  kind: fake\n- name: a\n  kind: fake\n",
+		"unknown field":   "providers:\n- name: a\n  kind: fake\n  token: secret\n",
+	} {
+		path := filepath.Join(t.TempDir(), "providers.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("can't write config: %v", err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("LoadConfig: expected error for %s", name)
+		}
+	}
+}
@@ -10,6 +10,10 @@ type Domain struct {
 	ZoneName  string
 	FQDN      string
 	Activated bool
+	// Proxied is set if the provider serves the records behind its proxy
+	Proxied *bool
+	// Options are the provider-specific options applied on the recordset, normalized by the provider
+	Options map[string]string
 }
 
 type Zone struct {
@@ -17,3 +21,10 @@ type Zone struct {
 	Name      string
 	Activated bool
 }
+
+const (
+	RecordTypeA     = "A"
+	RecordTypeAAAA  = "AAAA"
+	RecordTypeCNAME = "CNAME"
+	RecordTypeTXT   = "TXT"
+)
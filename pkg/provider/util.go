@@ -1 +1,170 @@
 package provider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RecordNameApex is the record name that points to the zone itself
+const RecordNameApex = "@"
+
+// GenerateRecordSetId generates the synthetic id of the record set, which is identified by name and type
+func GenerateRecordSetId(name, recordType string) string {
+	return fmt.Sprintf("%s/%s", name, recordType)
+}
+
+// ParseRecordSetId parses the synthetic id generated by GenerateRecordSetId into name and type
+func ParseRecordSetId(id string) (string, string, error) {
+	idx := strings.LastIndex(id, "/")
+	if idx <= 0 || idx == len(id)-1 {
+		return "", "", fmt.Errorf("can't parse recordset id %s", id)
+	}
+	return id[:idx], id[idx+1:], nil
+}
+
+// JoinName joins the record name and the zone name into the fully-qualified name without trailing dot
+func JoinName(name, zoneName string) string {
+	zoneName = strings.TrimSuffix(zoneName, ".")
+	if len(name) == 0 || name == RecordNameApex {
+		return zoneName
+	}
+	return fmt.Sprintf("%s.%s", name, zoneName)
+}
+
+// RelativeName returns the record name relative to the zone, reverse of JoinName
+func RelativeName(fqdn, zoneName string) string {
+	fqdn = strings.TrimSuffix(fqdn, ".")
+	zoneName = strings.TrimSuffix(zoneName, ".")
+	if fqdn == zoneName {
+		return RecordNameApex
+	}
+	return strings.TrimSuffix(fqdn, "."+zoneName)
+}
+
+// TXTStringMaxLength is the maximum length of a character-string in TXT record
+const TXTStringMaxLength = 255
+
+// SplitTXT splits the TXT value into character-strings of the maximum length
+func SplitTXT(value string) []string {
+	chunks := make([]string, 0, len(value)/TXTStringMaxLength+1)
+	for len(value) > TXTStringMaxLength {
+		chunks = append(chunks, value[:TXTStringMaxLength])
+		value = value[TXTStringMaxLength:]
+	}
+	return append(chunks, value)
+}
+
+// SplitEscapedTXT splits the TXT value as SplitTXT does,
+// escaping the character-strings in presentation format as the TXT record of dns library holds
+func SplitEscapedTXT(value string) []string {
+	chunks := SplitTXT(value)
+	for i := range chunks {
+		chunks[i] = EscapeTXT(chunks[i])
+	}
+	return chunks
+}
+
+// EscapeTXT escapes the quotes and backslashes of the character-string in presentation format
+func EscapeTXT(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+// UnescapeTXT reverses the presentation format escapes, e.g. \" and \DDD in decimal as RFC 1035 defines
+func UnescapeTXT(s string) string {
+	return unescape(s, 10)
+}
+
+// UnescapeOctal is UnescapeTXT for the strings whose numeric escapes are octal, as route53 returns the names
+func UnescapeOctal(s string) string {
+	return unescape(s, 8)
+}
+
+func unescape(s string, base int) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		if v, ok := parseEscape(s[i+1:], base); ok {
+			b.WriteByte(v)
+			i += 3
+			continue
+		}
+		i++
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// QuoteTXT quotes the TXT value in presentation format, splitting it into character-strings of the maximum length
+func QuoteTXT(value string) string {
+	chunks := SplitEscapedTXT(value)
+	for i := range chunks {
+		chunks[i] = fmt.Sprintf(`"%s"`, chunks[i])
+	}
+	return strings.Join(chunks, " ")
+}
+
+// UnquoteTXT joins the quoted character-strings of the TXT value, reverse of QuoteTXT.
+// The numeric escapes are decimal, the value made outside of dns-ingress may be unquoted and is returned as is
+func UnquoteTXT(value string) string {
+	return unquoteTXT(value, 10)
+}
+
+// UnquoteOctalTXT is UnquoteTXT for the values whose numeric escapes are octal, as route53 returns them
+func UnquoteOctalTXT(value string) string {
+	return unquoteTXT(value, 8)
+}
+
+func unquoteTXT(value string, base int) string {
+	if !strings.HasPrefix(value, `"`) {
+		return value
+	}
+
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		switch {
+		case ch == '"':
+			quoted = !quoted
+		case !quoted:
+			// separator between the character-strings
+		case ch == '\\' && i+1 < len(value):
+			if v, ok := parseEscape(value[i+1:], base); ok {
+				b.WriteByte(v)
+				i += 3
+				continue
+			}
+			i++
+			b.WriteByte(value[i])
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// parseEscape parses the leading three digits of the numeric escape in the given base into a byte
+func parseEscape(s string, base int) (byte, bool) {
+	if len(s) < 3 {
+		return 0, false
+	}
+	var v int
+	for _, ch := range s[:3] {
+		if ch < '0' || int(ch-'0') >= base {
+			return 0, false
+		}
+		v = v*base + int(ch-'0')
+	}
+	if v > 0xff {
+		return 0, false
+	}
+	return byte(v), true
+}
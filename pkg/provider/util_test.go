@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitTXT(t *testing.T) {
+	long := strings.Repeat("a", TXTStringMaxLength+10)
+	if got := SplitTXT(long); !reflect.DeepEqual(got, []string{long[:TXTStringMaxLength], "aaaaaaaaaa"}) {
+		t.Fatalf("SplitTXT: unexpected character-strings %q", got)
+	}
+	if got := SplitTXT(""); !reflect.DeepEqual(got, []string{""}) {
+		t.Fatalf("SplitTXT: expected one empty character-string, got %q", got)
+	}
+	if got := SplitEscapedTXT(`say "hi" \o/`); !reflect.DeepEqual(got, []string{`say \"hi\" \\o/`}) {
+		t.Fatalf("SplitEscapedTXT: unexpected character-strings %q", got)
+	}
+}
+
+func TestUnescapeTXT(t *testing.T) {
+	tests := map[string]string{
+		`plain`:               "plain",
+		`say \"hi\" \\o/`:     `say "hi" \o/`,
+		`semi\059colon`:       "semi;colon",
+		`not\99digits`:        "not99digits",
+		`out\256of range`:     "out256of range",
+		`trailing backslash\`: `trailing backslash\`,
+	}
+	for s, want := range tests {
+		if got := UnescapeTXT(s); got != want {
+			t.Errorf("UnescapeTXT(%q): expected %q, got %q", s, want, got)
+		}
+	}
+}
+
+func TestUnescapeOctal(t *testing.T) {
+	tests := map[string]string{
+		`\052.example.com.`: "*.example.com.",
+		`semi\073colon`:     "semi;colon",
+		`not\089octal`:      "not089octal",
+	}
+	for s, want := range tests {
+		if got := UnescapeOctal(s); got != want {
+			t.Errorf("UnescapeOctal(%q): expected %q, got %q", s, want, got)
+		}
+	}
+}
+
+func TestQuoteTXT(t *testing.T) {
+	long := strings.Repeat("a", TXTStringMaxLength+10)
+	values := []string{"", "v=spf1 -all", `say "hi" \o/`, long}
+	for _, value := range values {
+		quoted := QuoteTXT(value)
+		if got := UnquoteTXT(quoted); got != value {
+			t.Errorf("UnquoteTXT(QuoteTXT(%q)): got %q", value, got)
+		}
+		if got := UnquoteOctalTXT(quoted); got != value {
+			t.Errorf("UnquoteOctalTXT(QuoteTXT(%q)): got %q", value, got)
+		}
+	}
+	if got := QuoteTXT(long); got != `"`+long[:TXTStringMaxLength]+`" "aaaaaaaaaa"` {
+		t.Fatalf("QuoteTXT: unexpected value %s", got)
+	}
+}
+
+func TestUnquoteTXT(t *testing.T) {
+	if got := UnquoteTXT(`"first" "second"`); got != "firstsecond" {
+		t.Fatalf("UnquoteTXT: expected the character-strings joined, got %q", got)
+	}
+	if got := UnquoteTXT("unquoted value"); got != "unquoted value" {
+		t.Fatalf("UnquoteTXT: expected the unquoted value as is, got %q", got)
+	}
+	if got := UnquoteTXT(`"semi\059colon"`); got != "semi;colon" {
+		t.Fatalf("UnquoteTXT: expected decimal escape parsed, got %q", got)
+	}
+	if got := UnquoteOctalTXT(`"semi\073colon"`); got != "semi;colon" {
+		t.Fatalf("UnquoteOctalTXT: expected octal escape parsed, got %q", got)
+	}
+	if got := UnquoteOctalTXT(`"not\089octal"`); got != "not089octal" {
+		t.Fatalf("UnquoteOctalTXT: expected invalid octal escape kept as literal, got %q", got)
+	}
+}
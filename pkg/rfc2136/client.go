@@ -0,0 +1,336 @@
+package rfc2136
+
+import (
+	"context"
+	"fmt"
+	"github.com/miekg/dns"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"strings"
+	"time"
+)
+
+const ProviderKey = "rfc2136"
+
+const (
+	SettingKeyServer    = "server"
+	SettingKeyTransport = "transport"
+	SettingKeyLookup    = "lookup"
+
+	SettingKeyTSIGKeyName   = "tsigKeyName"
+	SettingKeyTSIGSecret    = "tsigSecret"
+	SettingKeyTSIGAlgorithm = "tsigAlgorithm"
+)
+
+const (
+	// timeout of a single exchange with the name server
+	timeout = 10 * time.Second
+	// tsigFudge is the allowed clock skew in seconds between the client and the name server
+	tsigFudge = 300
+)
+
+func init() {
+	provider.Register(ProviderKey, NewRFC2136ClientWithSettings)
+}
+
+// Client manages the recordsets on the name server with dynamic updates.
+// zones are identified by their names since the name server has no zone id.
+type Client struct {
+	Config Config
+	provider.Client
+}
+
+// NewRFC2136ClientWithSettings creates the client with the provider settings
+func NewRFC2136ClientWithSettings(settings map[string]string) (provider.Client, error) {
+	config := Config{
+		Server:    settings[SettingKeyServer],
+		Transport: settings[SettingKeyTransport],
+		Lookup:    settings[SettingKeyLookup],
+	}
+	if len(settings[SettingKeyTSIGKeyName]) > 0 || len(settings[SettingKeyTSIGSecret]) > 0 {
+		config.TSIG = &TSIGKey{
+			Name:      settings[SettingKeyTSIGKeyName],
+			Secret:    settings[SettingKeyTSIGSecret],
+			Algorithm: settings[SettingKeyTSIGAlgorithm],
+		}
+	}
+
+	c, err := NewRFC2136Client(config)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func NewRFC2136Client(config Config) (*Client, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("can't create new rfc2136 client: %w", err)
+	}
+	return &Client{
+		Config: config,
+	}, nil
+}
+
+func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone, error) {
+	zone := dns.CanonicalName(zoneName)
+
+	m := newQuery(zone, dns.TypeSOA)
+	resp, err := c.exchange(ctx, m)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetZone: %w", err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("can't GetZone: cannot find zone %s: %s", zoneName, dns.RcodeToString[resp.Rcode])
+	}
+
+	// the name server has to be authoritative for the zone to accept the updates
+	for _, rr := range resp.Answer {
+		if _, ok := rr.(*dns.SOA); ok && dns.CanonicalName(rr.Header().Name) == zone {
+			name := strings.TrimSuffix(zone, ".")
+			return &provider.Zone{
+				Id:        name,
+				Name:      name,
+				Activated: true,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("can't GetZone: cannot find zone %s", zoneName)
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	rrType, ok := dns.StringToType[recordType]
+	if !ok {
+		return nil, fmt.Errorf("can't GetByName: unknown record type %s", recordType)
+	}
+
+	rrs, err := c.lookupRecordSet(ctx, zoneId, fqdnOf(name, zoneId), rrType)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	if len(rrs) == 0 {
+		return nil, nil
+	}
+	return convertRecordSet(name, zoneId, rrs), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Create: no records given for %s", name)
+	}
+
+	rrs, err := newRecordSet(fqdnOf(name, zoneId), recordType, records, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	// the update is rejected if the recordset exists, as the other providers do
+	m := newUpdate(zoneId)
+	m.RRsetNotUsed(rrs[:1])
+	m.Insert(rrs)
+	if err := c.update(ctx, m); err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	return convertRecordSet(name, zoneId, rrs), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	name, currentType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, currentType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if d == nil {
+		return nil, nil
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Update: no records given for %s", name)
+	}
+	rrs, err := newRecordSet(fqdnOf(name, zoneId), recordType, records, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	// replace the current recordset in a single update, which is applied atomically
+	current := []dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: fqdnOf(name, zoneId), Rrtype: dns.StringToType[currentType]}}}
+	m := newUpdate(zoneId)
+	m.RRsetUsed(current)
+	m.RemoveRRset(current)
+	m.Insert(rrs)
+	if err := c.update(ctx, m); err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	return convertRecordSet(name, zoneId, rrs), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	rrType, ok := dns.StringToType[recordType]
+	if !ok {
+		return fmt.Errorf("can't Delete: unknown record type %s", recordType)
+	}
+
+	// deleting the recordset which doesn't exist is not an error of dynamic update
+	m := newUpdate(zoneId)
+	m.RemoveRRset([]dns.RR{&dns.ANY{Hdr: dns.RR_Header{Name: fqdnOf(name, zoneId), Rrtype: rrType}}})
+	if err := c.update(ctx, m); err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	return nil
+}
+
+// lookupRecordSet returns the resource records which have the name and type
+func (c *Client) lookupRecordSet(ctx context.Context, zoneId, fqdn string, rrType uint16) ([]dns.RR, error) {
+	var candidates []dns.RR
+	if c.Config.Lookup == LookupAXFR {
+		rrs, err := c.transfer(ctx, zoneId)
+		if err != nil {
+			return nil, err
+		}
+		candidates = rrs
+	} else {
+		resp, err := c.exchange(ctx, newQuery(fqdn, rrType))
+		if err != nil {
+			return nil, err
+		}
+		switch resp.Rcode {
+		case dns.RcodeSuccess:
+			candidates = resp.Answer
+		case dns.RcodeNameError:
+			return nil, nil
+		default:
+			return nil, fmt.Errorf("query of %s is rejected with %s", fqdn, dns.RcodeToString[resp.Rcode])
+		}
+	}
+
+	matched := make([]dns.RR, 0)
+	for _, rr := range candidates {
+		if dns.CanonicalName(rr.Header().Name) == fqdn && rr.Header().Rrtype == rrType {
+			matched = append(matched, rr)
+		}
+	}
+	return matched, nil
+}
+
+// transfer returns all the resource records of the zone with AXFR
+func (c *Client) transfer(ctx context.Context, zoneId string) ([]dns.RR, error) {
+	m := new(dns.Msg)
+	m.SetAxfr(dns.Fqdn(zoneId))
+	c.sign(m)
+
+	t := &dns.Transfer{
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+		TsigSecret:   c.tsigSecret(),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		t.ReadTimeout = time.Until(deadline)
+	}
+	envelopes, err := t.In(m, c.Config.Server)
+	if err != nil {
+		return nil, fmt.Errorf("can't transfer zone %s: %w", zoneId, err)
+	}
+
+	rrs := make([]dns.RR, 0)
+	for e := range envelopes {
+		if e.Error != nil {
+			return nil, fmt.Errorf("can't transfer zone %s: %w", zoneId, e.Error)
+		}
+		rrs = append(rrs, e.RR...)
+	}
+	return rrs, nil
+}
+
+// update sends the dynamic update, which succeeds only if all the prerequisites are satisfied
+func (c *Client) update(ctx context.Context, m *dns.Msg) error {
+	resp, err := c.exchange(ctx, m)
+	if err != nil {
+		return err
+	}
+	switch resp.Rcode {
+	case dns.RcodeSuccess:
+		return nil
+	case dns.RcodeYXRrset:
+		return fmt.Errorf("recordset already exists")
+	case dns.RcodeNXRrset:
+		return fmt.Errorf("recordset doesn't exist")
+	default:
+		return fmt.Errorf("update is rejected with %s", dns.RcodeToString[resp.Rcode])
+	}
+}
+
+// exchange sends the message, udp exchange is retried over tcp if the response is truncated.
+// the message which doesn't fit in a udp message without edns, e.g. update of long TXT records, is sent over tcp.
+func (c *Client) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	c.sign(m)
+
+	transport := c.Config.Transport
+	if transport == TransportUDP && m.Len() > dns.MinMsgSize {
+		transport = TransportTCP
+	}
+	resp, _, err := c.dnsClient(transport).ExchangeContext(ctx, m, c.Config.Server)
+	if err == nil && resp.Truncated && c.Config.Transport == TransportUDP {
+		resp, _, err = c.dnsClient(TransportTCP).ExchangeContext(ctx, m, c.Config.Server)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't exchange with %s: %w", c.Config.Server, err)
+	}
+	return resp, nil
+}
+
+func (c *Client) dnsClient(transport string) *dns.Client {
+	return &dns.Client{
+		Net:        transport,
+		Timeout:    timeout,
+		TsigSecret: c.tsigSecret(),
+	}
+}
+
+// sign adds the tsig record to the message, which is signed on exchange
+func (c *Client) sign(m *dns.Msg) {
+	if c.Config.TSIG == nil || m.IsTsig() != nil {
+		return
+	}
+	m.SetTsig(c.Config.TSIG.Name, tsigAlgorithms[c.Config.TSIG.Algorithm], tsigFudge, time.Now().Unix())
+}
+
+func (c *Client) tsigSecret() map[string]string {
+	if c.Config.TSIG == nil {
+		return nil
+	}
+	return map[string]string{c.Config.TSIG.Name: c.Config.TSIG.Secret}
+}
+
+func newQuery(fqdn string, rrType uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(fqdn, rrType)
+	// ask the name server itself, which is authoritative for the zone
+	m.RecursionDesired = false
+	return m
+}
+
+func newUpdate(zoneId string) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetUpdate(dns.Fqdn(zoneId))
+	return m
+}
@@ -0,0 +1,311 @@
+package rfc2136
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+const testZoneName = "example.com"
+
+func testConfig(lookup string) Config {
+	return Config{
+		Lookup: lookup,
+		TSIG:   &TSIGKey{Name: testTSIGKeyName, Secret: testTSIGSecret, Algorithm: TSIGAlgorithmHmacSHA256},
+	}
+}
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	for _, lookup := range []string{LookupQuery, LookupAXFR} {
+		t.Run(lookup, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFakeServer(t, testZoneName)
+			c := f.newClient(t, testConfig(lookup))
+
+			z, err := c.GetZone(ctx, testZoneName)
+			if err != nil {
+				t.Fatalf("GetZone: %v", err)
+			}
+			if z.Id != testZoneName || z.Name != testZoneName {
+				t.Fatalf("GetZone: expected zone %s, got %+v", testZoneName, z)
+			}
+
+			d, err := c.GetByName(ctx, "www", z.Id, provider.RecordTypeA)
+			if err != nil || d != nil {
+				t.Fatalf("GetByName: expected nothing before create, got %v, %v", d, err)
+			}
+
+			d, err = c.Create(ctx, "www", z.Id, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 0, nil)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			expected := &provider.Domain{
+				Id:        provider.GenerateRecordSetId("www", provider.RecordTypeA),
+				Name:      "www",
+				Type:      provider.RecordTypeA,
+				Records:   []string{"192.0.2.1", "192.0.2.2"},
+				TTL:       ttlDefault,
+				ZoneId:    testZoneName,
+				ZoneName:  testZoneName,
+				FQDN:      "www.example.com.",
+				Activated: true,
+			}
+			if !reflect.DeepEqual(d, expected) {
+				t.Fatalf("Create: expected %+v, got %+v", expected, d)
+			}
+
+			for _, get := range []func() (*provider.Domain, error){
+				func() (*provider.Domain, error) { return c.GetByName(ctx, "www", z.Id, provider.RecordTypeA) },
+				func() (*provider.Domain, error) { return c.Get(ctx, d.Id, z.Id) },
+			} {
+				got, err := get()
+				if err != nil {
+					t.Fatalf("Get: %v", err)
+				}
+				if !reflect.DeepEqual(got, expected) {
+					t.Fatalf("Get: expected %+v, got %+v", expected, got)
+				}
+			}
+
+			d, err = c.Update(ctx, d.Id, z.Id, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.3"}, 60, nil)
+			if err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			if want := []string{"192.0.2.2", "192.0.2.3"}; !reflect.DeepEqual(d.Records, want) || d.TTL != 60 {
+				t.Fatalf("Update: expected records %v with ttl 60, got %v with ttl %d", want, d.Records, d.TTL)
+			}
+			if got := f.records("www.example.com", dns.TypeA); !reflect.DeepEqual(got, d.Records) {
+				t.Fatalf("Update: expected remote records %v, got %v", d.Records, got)
+			}
+
+			if err := c.Delete(ctx, d.Id, z.Id); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if got := f.records("www.example.com", dns.TypeA); len(got) != 0 {
+				t.Fatalf("Delete: expected no remote records, got %v", got)
+			}
+
+			d, err = c.Get(ctx, d.Id, z.Id)
+			if err != nil || d != nil {
+				t.Fatalf("Get: expected nothing after delete, got %v, %v", d, err)
+			}
+			if err := c.Delete(ctx, expected.Id, z.Id); err != nil {
+				t.Fatalf("Delete: expected no error for missing recordset, got %v", err)
+			}
+			if d, err := c.Update(ctx, expected.Id, z.Id, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil || d != nil {
+				t.Fatalf("Update: expected nothing for missing recordset, got %v, %v", d, err)
+			}
+		})
+	}
+}
+
+func TestClientRecordSetIsolatedByType(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t, testZoneName)
+	c := f.newClient(t, testConfig(LookupQuery))
+
+	f.addRecord(t, "www.example.com. 120 IN AAAA 2001:db8::1")
+	a, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	aaaa, err := c.GetByName(ctx, "www", testZoneName, provider.RecordTypeAAAA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(aaaa.Records, []string{"2001:db8::1"}) || aaaa.TTL != 120 {
+		t.Fatalf("GetByName: expected only AAAA records, got %+v", aaaa)
+	}
+
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeAAAA, []string{"2001:db8::2"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for existing recordset")
+	}
+
+	if err := c.Delete(ctx, a.Id, testZoneName); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got := f.records("www.example.com", dns.TypeAAAA); !reflect.DeepEqual(got, []string{"2001:db8::1"}) {
+		t.Fatalf("Delete: expected AAAA records untouched, got %v", got)
+	}
+}
+
+func TestClientUpdateChangesType(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t, testZoneName)
+	c := f.newClient(t, testConfig(LookupQuery))
+
+	d, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// CNAME conflicts with A records, which is rejected unless they are replaced in the same update
+	d, err = c.Update(ctx, d.Id, testZoneName, provider.RecordTypeCNAME, []string{"lb.example.net"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != provider.GenerateRecordSetId("www", provider.RecordTypeCNAME) {
+		t.Fatalf("Update: expected id of the CNAME recordset, got %s", d.Id)
+	}
+	if got := f.records("www.example.com", dns.TypeA); len(got) != 0 {
+		t.Fatalf("Update: expected A records removed, got %v", got)
+	}
+	if got := f.records("www.example.com", dns.TypeCNAME); !reflect.DeepEqual(got, []string{"lb.example.net"}) {
+		t.Fatalf("Update: expected CNAME record, got %v", got)
+	}
+
+	got, err := c.GetByName(ctx, "www", testZoneName, provider.RecordTypeCNAME)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(got, d) {
+		t.Fatalf("GetByName: expected %+v, got %+v", d, got)
+	}
+}
+
+func TestClientTXTRecordSet(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t, testZoneName)
+	c := f.newClient(t, testConfig(LookupAXFR))
+
+	long := strings.Repeat("a", provider.TXTStringMaxLength+10)
+	records := []string{`heritage=dns-ingress,"quoted\"`, long}
+	d, err := c.Create(ctx, "_owner", testZoneName, provider.RecordTypeTXT, records, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	got, err := c.GetByName(ctx, "_owner", testZoneName, provider.RecordTypeTXT)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if want := []string{long, records[0]}; !reflect.DeepEqual(got.Records, want) || !reflect.DeepEqual(d.Records, want) {
+		t.Fatalf("GetByName: expected records %v, got %v", want, got.Records)
+	}
+}
+
+func TestClientRetriesTruncatedOverTCP(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t, testZoneName)
+	c := f.newClient(t, Config{})
+
+	records := make([]string, 0)
+	for i := 1; i <= 50; i++ {
+		records = append(records, fmt.Sprintf("192.0.2.%d", i))
+		f.addRecord(t, fmt.Sprintf("many.example.com. 300 IN A 192.0.2.%d", i))
+	}
+
+	d, err := c.GetByName(ctx, "many", testZoneName, provider.RecordTypeA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if len(d.Records) != len(records) {
+		t.Fatalf("GetByName: expected %d records, got %d", len(records), len(d.Records))
+	}
+}
+
+func TestClientSendsLargeUpdateOverTCP(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t, testZoneName)
+	c := f.newClient(t, testConfig(LookupQuery))
+
+	records := []string{strings.Repeat("a", provider.TXTStringMaxLength), strings.Repeat("b", provider.TXTStringMaxLength), strings.Repeat("c", provider.TXTStringMaxLength)}
+	d, err := c.Create(ctx, "large", testZoneName, provider.RecordTypeTXT, records, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: expected update which doesn't fit in udp accepted, got %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, records) {
+		t.Fatalf("Create: expected records %v, got %v", records, d.Records)
+	}
+}
+
+func TestClientTSIG(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t, testZoneName)
+
+	for _, algorithm := range []string{TSIGAlgorithmHmacSHA256, "HMAC-SHA512"} {
+		c := f.newClient(t, Config{TSIG: &TSIGKey{Name: "dns-ingress", Secret: testTSIGSecret, Algorithm: algorithm}})
+		name := strings.ToLower(strings.ReplaceAll(algorithm, "-", ""))
+		if _, err := c.Create(ctx, name, testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+			t.Fatalf("Create: expected update signed with %s accepted, got %v", algorithm, err)
+		}
+	}
+
+	for _, config := range []Config{
+		{},
+		{TSIG: &TSIGKey{Name: testTSIGKeyName, Secret: "d3Jvbmctc2VjcmV0"}},
+		{TSIG: &TSIGKey{Name: "unknown", Secret: testTSIGSecret}},
+	} {
+		if _, err := f.newClient(t, config).Create(ctx, "denied", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err == nil {
+			t.Fatalf("Create: expected update denied with tsig key %+v", config.TSIG)
+		}
+	}
+	if got := f.records("denied.example.com", dns.TypeA); len(got) != 0 {
+		t.Fatalf("Create: expected no records of denied updates, got %v", got)
+	}
+
+	c := f.newClient(t, Config{TSIG: &TSIGKey{Name: testTSIGKeyName, Secret: testTSIGSecret}, Lookup: LookupAXFR, Transport: TransportTCP})
+	if d, err := c.GetByName(ctx, "hmacsha256", testZoneName, provider.RecordTypeA); err != nil || d == nil {
+		t.Fatalf("GetByName: expected signed zone transfer over tcp, got %v, %v", d, err)
+	}
+
+	f.allowTransfer = false
+	if _, err := f.newClient(t, testConfig(LookupAXFR)).GetByName(ctx, "www", testZoneName, provider.RecordTypeA); err == nil {
+		t.Fatalf("GetByName: expected error if zone transfer is refused")
+	}
+}
+
+func TestClientGetZone(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t, testZoneName)
+	c := f.newClient(t, Config{})
+
+	if z, err := c.GetZone(ctx, "Example.COM."); err != nil || z.Id != testZoneName {
+		t.Fatalf("GetZone: expected zone %s, got %v, %v", testZoneName, z, err)
+	}
+	for _, zoneName := range []string{"example.org", "sub.example.com"} {
+		if z, err := c.GetZone(ctx, zoneName); err == nil {
+			t.Fatalf("GetZone: expected error for %s, got %+v", zoneName, z)
+		}
+	}
+}
+
+func TestNewRFC2136ClientWithSettings(t *testing.T) {
+	c, err := NewRFC2136ClientWithSettings(map[string]string{
+		SettingKeyServer:        "ns1.example.com",
+		SettingKeyTSIGKeyName:   "Dns-Ingress",
+		SettingKeyTSIGSecret:    testTSIGSecret,
+		SettingKeyTSIGAlgorithm: "HMAC-SHA512",
+	})
+	if err != nil {
+		t.Fatalf("NewRFC2136ClientWithSettings: %v", err)
+	}
+	expected := Config{
+		Server:    "ns1.example.com:53",
+		Transport: TransportUDP,
+		Lookup:    LookupQuery,
+		TSIG:      &TSIGKey{Name: testTSIGKeyName, Secret: testTSIGSecret, Algorithm: TSIGAlgorithmHmacSHA512},
+	}
+	if got := c.(*Client).Config; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected config %+v, got %+v", expected, got)
+	}
+
+	for _, invalid := range []map[string]string{
+		{},
+		{SettingKeyServer: "127.0.0.1", SettingKeyTransport: "quic"},
+		{SettingKeyServer: "127.0.0.1", SettingKeyLookup: "ixfr"},
+		{SettingKeyServer: "127.0.0.1", SettingKeyTSIGKeyName: testTSIGKeyName},
+		{SettingKeyServer: "127.0.0.1", SettingKeyTSIGKeyName: testTSIGKeyName, SettingKeyTSIGSecret: "not base64"},
+		{SettingKeyServer: "127.0.0.1", SettingKeyTSIGKeyName: testTSIGKeyName, SettingKeyTSIGSecret: testTSIGSecret, SettingKeyTSIGAlgorithm: "hmac-md5"},
+	} {
+		if _, err := NewRFC2136ClientWithSettings(invalid); err == nil {
+			t.Fatalf("NewRFC2136ClientWithSettings: expected error for settings %v", invalid)
+		}
+	}
+}
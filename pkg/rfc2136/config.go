@@ -0,0 +1,100 @@
+package rfc2136
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+)
+
+const (
+	TransportUDP = "udp"
+	TransportTCP = "tcp"
+)
+
+const (
+	// LookupQuery looks up the recordset by querying the name and type
+	LookupQuery = "query"
+	// LookupAXFR looks up the recordset by transferring the zone, for the servers which hide records from queries
+	LookupAXFR = "axfr"
+)
+
+const (
+	TSIGAlgorithmHmacSHA256 = "hmac-sha256"
+	TSIGAlgorithmHmacSHA512 = "hmac-sha512"
+)
+
+const defaultPort = "53"
+
+// tsigAlgorithms maps the algorithm names to the ones of the tsig record
+var tsigAlgorithms = map[string]string{
+	TSIGAlgorithmHmacSHA256: dns.HmacSHA256,
+	TSIGAlgorithmHmacSHA512: dns.HmacSHA512,
+}
+
+// Config is the connection config of the name server which accepts the dynamic updates
+type Config struct {
+	// Server is the address of the primary name server, port defaults to 53
+	Server string
+	// Transport is either udp or tcp, udp queries are retried over tcp if truncated
+	Transport string
+	// Lookup is either query or axfr
+	Lookup string
+	// TSIG signs the messages if set
+	TSIG *TSIGKey
+}
+
+// TSIGKey is the shared secret to sign the messages
+type TSIGKey struct {
+	Name string
+	// Secret is base64 encoded
+	Secret    string
+	Algorithm string
+}
+
+// Validate checks the config and fills the defaults
+func (c *Config) Validate() error {
+	if len(c.Server) == 0 {
+		return fmt.Errorf("server is required")
+	}
+	if _, _, err := net.SplitHostPort(c.Server); err != nil {
+		c.Server = net.JoinHostPort(c.Server, defaultPort)
+	}
+
+	switch c.Transport {
+	case "":
+		c.Transport = TransportUDP
+	case TransportUDP, TransportTCP:
+	default:
+		return fmt.Errorf("unknown transport %s", c.Transport)
+	}
+
+	switch c.Lookup {
+	case "":
+		c.Lookup = LookupQuery
+	case LookupQuery, LookupAXFR:
+	default:
+		return fmt.Errorf("unknown lookup %s", c.Lookup)
+	}
+
+	if c.TSIG == nil {
+		return nil
+	}
+	if len(c.TSIG.Name) == 0 || len(c.TSIG.Secret) == 0 {
+		return fmt.Errorf("both name and secret of tsig key are required")
+	}
+	if _, err := base64.StdEncoding.DecodeString(c.TSIG.Secret); err != nil {
+		return fmt.Errorf("can't decode tsig secret: %w", err)
+	}
+	if len(c.TSIG.Algorithm) == 0 {
+		c.TSIG.Algorithm = TSIGAlgorithmHmacSHA256
+	}
+	if _, ok := tsigAlgorithms[strings.ToLower(c.TSIG.Algorithm)]; !ok {
+		return fmt.Errorf("unsupported tsig algorithm %s", c.TSIG.Algorithm)
+	}
+	c.TSIG.Algorithm = strings.ToLower(c.TSIG.Algorithm)
+	// the key name is compared in canonical form
+	c.TSIG.Name = dns.CanonicalName(c.TSIG.Name)
+	return nil
+}
@@ -0,0 +1,18 @@
+package rfc2136
+
+import (
+	"testing"
+
+	"github.com/sokdak/dns-ingress/pkg/provider/providertest"
+)
+
+func TestConformance(t *testing.T) {
+	for _, lookup := range []string{LookupQuery, LookupAXFR} {
+		lookup := lookup
+		t.Run(lookup, func(t *testing.T) {
+			providertest.RunConformance(t, func(t *testing.T) providertest.Fixture {
+				return providertest.Fixture{Client: newFakeServer(t, testZoneName).newClient(t, testConfig(lookup)), ZoneName: testZoneName}
+			})
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package rfc2136
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	testTSIGKeyName = "dns-ingress."
+	// testTSIGSecret is base64 encoded
+	testTSIGSecret = "c2VjcmV0LWtleS1vZi1kbnMtaW5ncmVzcw=="
+	// tsigReservedSize is the room of the tsig record in the udp response, enough for hmac-sha512
+	tsigReservedSize = 128
+)
+
+// fakeServer is a minimal in-process stand-in of the primary name server,
+// which answers queries and zone transfers, and applies signed dynamic updates
+type fakeServer struct {
+	addr    string
+	servers []*dns.Server
+
+	mu    sync.Mutex
+	zones map[string][]dns.RR
+	// allowTransfer allows AXFR of the zones
+	allowTransfer bool
+	// updates counts the updates applied
+	updates int
+}
+
+func newFakeServer(t *testing.T, zones ...string) *fakeServer {
+	f := &fakeServer{
+		zones:         map[string][]dns.RR{},
+		allowTransfer: true,
+	}
+	for _, z := range zones {
+		f.zones[dns.Fqdn(z)] = []dns.RR{}
+	}
+
+	pc, l := listen(t)
+	f.addr = pc.LocalAddr().String()
+	tsigSecret := map[string]string{testTSIGKeyName: testTSIGSecret}
+	for _, srv := range []*dns.Server{
+		{PacketConn: pc, Handler: f, TsigSecret: tsigSecret},
+		{Listener: l, Handler: f, TsigSecret: tsigSecret},
+	} {
+		// updates are rejected by the default accept func
+		srv.MsgAcceptFunc = acceptMsg
+		started := make(chan struct{})
+		srv.NotifyStartedFunc = func() { close(started) }
+		go func(srv *dns.Server) { _ = srv.ActivateAndServe() }(srv)
+		<-started
+		f.servers = append(f.servers, srv)
+	}
+	t.Cleanup(func() {
+		for _, srv := range f.servers {
+			_ = srv.Shutdown()
+		}
+	})
+	return f
+}
+
+func acceptMsg(dh dns.Header) dns.MsgAcceptAction {
+	if opcode := int(dh.Bits>>11) & 0xF; opcode == dns.OpcodeUpdate {
+		return dns.MsgAccept
+	}
+	return dns.DefaultMsgAcceptFunc(dh)
+}
+
+// listen listens udp and tcp on the same port of loopback
+func listen(t *testing.T) (net.PacketConn, net.Listener) {
+	for i := 0; i < 10; i++ {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("can't listen udp: %v", err)
+		}
+		l, err := net.Listen("tcp", pc.LocalAddr().String())
+		if err == nil {
+			return pc, l
+		}
+		_ = pc.Close()
+	}
+	t.Fatalf("can't listen tcp and udp on the same port")
+	return nil, nil
+}
+
+// newClient returns the client which talks to the fake server with the tsig key
+func (f *fakeServer) newClient(t *testing.T, config Config) *Client {
+	config.Server = f.addr
+	c, err := NewRFC2136Client(config)
+	if err != nil {
+		t.Fatalf("can't create client: %v", err)
+	}
+	return c
+}
+
+// addRecord parses the RR in presentation format into the zone, bypassing the UPDATE handler
+func (f *fakeServer) addRecord(t *testing.T, s string) {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatalf("can't parse record %s: %v", s, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	zone := f.zoneOf(rr.Header().Name)
+	f.zones[zone] = append(f.zones[zone], rr)
+}
+
+// records returns the sorted rdata of the records which have the name and type
+func (f *fakeServer) records(name string, rrType uint16) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	records := make([]string, 0)
+	for _, rr := range f.zones[f.zoneOf(name)] {
+		if rr.Header().Name == dns.CanonicalName(name) && rr.Header().Rrtype == rrType {
+			records = append(records, rdata(rr))
+		}
+	}
+	sort.Strings(records)
+	return records
+}
+
+func (f *fakeServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	tsig := req.IsTsig()
+	defer func() {
+		if _, udp := w.LocalAddr().(*net.UDPAddr); udp {
+			// Truncate doesn't reserve the room of the tsig record signing the response, which the real servers do
+			if tsig != nil && resp.Len()+tsigReservedSize > dns.MinMsgSize {
+				resp.Answer, resp.Ns, resp.Extra = nil, nil, nil
+				resp.Truncated = true
+			}
+			resp.Truncate(dns.MinMsgSize)
+		}
+		if tsig != nil && w.TsigStatus() == nil {
+			resp.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsigFudge, time.Now().Unix())
+		}
+		_ = w.WriteMsg(resp)
+	}()
+
+	zone := f.zoneOf(req.Question[0].Name)
+	if len(zone) == 0 {
+		resp.Rcode = dns.RcodeRefused
+		return
+	}
+
+	switch {
+	case req.Opcode == dns.OpcodeUpdate:
+		if tsig == nil || w.TsigStatus() != nil {
+			resp.Rcode = dns.RcodeNotAuth
+			return
+		}
+		resp.Rcode = f.update(zone, req)
+	case req.Question[0].Qtype == dns.TypeAXFR:
+		if !f.allowTransfer || tsig == nil || w.TsigStatus() != nil {
+			resp.Rcode = dns.RcodeRefused
+			return
+		}
+		resp.Answer = append([]dns.RR{f.soa(zone)}, f.zones[zone]...)
+		resp.Answer = append(resp.Answer, f.soa(zone))
+	default:
+		q := req.Question[0]
+		resp.Authoritative = true
+		if q.Qtype == dns.TypeSOA && dns.CanonicalName(q.Name) == zone {
+			resp.Answer = append(resp.Answer, f.soa(zone))
+			return
+		}
+		exists := false
+		for _, rr := range f.zones[zone] {
+			if rr.Header().Name != dns.CanonicalName(q.Name) {
+				continue
+			}
+			exists = true
+			if rr.Header().Rrtype == q.Qtype {
+				resp.Answer = append(resp.Answer, dns.Copy(rr))
+			}
+		}
+		if !exists {
+			resp.Rcode = dns.RcodeNameError
+		}
+	}
+}
+
+// update applies the update section if all the prerequisites are satisfied, see RFC 2136 section 3
+func (f *fakeServer) update(zone string, req *dns.Msg) int {
+	records := f.zones[zone]
+	for _, prereq := range req.Answer {
+		h := prereq.Header()
+		exists := false
+		for _, rr := range records {
+			if rr.Header().Name == dns.CanonicalName(h.Name) && (h.Rrtype == dns.TypeANY || rr.Header().Rrtype == h.Rrtype) {
+				exists = true
+			}
+		}
+		switch {
+		case h.Class == dns.ClassANY && !exists:
+			return dns.RcodeNXRrset
+		case h.Class == dns.ClassNONE && exists:
+			return dns.RcodeYXRrset
+		}
+	}
+
+	updated := make([]dns.RR, 0, len(records))
+	for _, rr := range records {
+		updated = append(updated, dns.Copy(rr))
+	}
+	for _, u := range req.Ns {
+		h := u.Header()
+		switch h.Class {
+		case dns.ClassANY:
+			kept := make([]dns.RR, 0, len(updated))
+			for _, rr := range updated {
+				if rr.Header().Name != dns.CanonicalName(h.Name) || (h.Rrtype != dns.TypeANY && rr.Header().Rrtype != h.Rrtype) {
+					kept = append(kept, rr)
+				}
+			}
+			updated = kept
+		case dns.ClassINET:
+			rr := dns.Copy(u)
+			rr.Header().Name = dns.CanonicalName(h.Name)
+			// the ttl of the recordset follows the last added record
+			for _, r := range updated {
+				if r.Header().Name == rr.Header().Name && r.Header().Rrtype == rr.Header().Rrtype {
+					r.Header().Ttl = rr.Header().Ttl
+				}
+			}
+			duplicated := false
+			for _, r := range updated {
+				if dns.IsDuplicate(r, rr) {
+					duplicated = true
+				}
+			}
+			if !duplicated {
+				updated = append(updated, rr)
+			}
+		default:
+			return dns.RcodeNotImplemented
+		}
+	}
+
+	// CNAME can't coexist with the other records of the same name
+	types := map[string]map[uint16]bool{}
+	for _, rr := range updated {
+		if types[rr.Header().Name] == nil {
+			types[rr.Header().Name] = map[uint16]bool{}
+		}
+		types[rr.Header().Name][rr.Header().Rrtype] = true
+	}
+	for _, ts := range types {
+		if ts[dns.TypeCNAME] && len(ts) > 1 {
+			return dns.RcodeRefused
+		}
+	}
+
+	f.zones[zone] = updated
+	f.updates++
+	return dns.RcodeSuccess
+}
+
+// zoneOf returns the zone which the name belongs to
+func (f *fakeServer) zoneOf(name string) string {
+	name = dns.CanonicalName(name)
+	for zone := range f.zones {
+		if dns.IsSubDomain(zone, name) {
+			return zone
+		}
+	}
+	return ""
+}
+
+func (f *fakeServer) soa(zone string) dns.RR {
+	rr, _ := dns.NewRR(fmt.Sprintf("%s 3600 IN SOA ns1.%s hostmaster.%s %d 7200 3600 1209600 300", zone, zone, zone, f.updates+1))
+	return rr
+}
@@ -0,0 +1,109 @@
+package rfc2136
+
+import (
+	"fmt"
+	"github.com/miekg/dns"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ttlDefault is applied if ttl is not set
+const ttlDefault = 300
+
+// newRecordSet builds the resource records of the recordset
+func newRecordSet(fqdn, recordType string, records []string, ttl int) ([]dns.RR, error) {
+	rrType, ok := dns.StringToType[recordType]
+	if !ok {
+		return nil, fmt.Errorf("unknown record type %s", recordType)
+	}
+	hdr := dns.RR_Header{Name: fqdn, Rrtype: rrType, Class: dns.ClassINET, Ttl: uint32(normalizeTTL(ttl))}
+
+	rrs := make([]dns.RR, 0, len(records))
+	for _, r := range records {
+		var rr dns.RR
+		switch rrType {
+		case dns.TypeA:
+			ip := net.ParseIP(r)
+			if ip == nil || ip.To4() == nil {
+				return nil, fmt.Errorf("invalid A record %s", r)
+			}
+			rr = &dns.A{Hdr: hdr, A: ip.To4()}
+		case dns.TypeAAAA:
+			ip := net.ParseIP(r)
+			if ip == nil || ip.To4() != nil {
+				return nil, fmt.Errorf("invalid AAAA record %s", r)
+			}
+			rr = &dns.AAAA{Hdr: hdr, AAAA: ip}
+		case dns.TypeCNAME:
+			rr = &dns.CNAME{Hdr: hdr, Target: dns.Fqdn(r)}
+		case dns.TypeTXT:
+			rr = &dns.TXT{Hdr: hdr, Txt: provider.SplitEscapedTXT(r)}
+		default:
+			parsed, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", fqdn, hdr.Ttl, recordType, r))
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s record %s: %w", recordType, r, err)
+			}
+			rr = parsed
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs, nil
+}
+
+// convertRecordSet converts the resource records of the same name and type into a recordset
+func convertRecordSet(name, zoneName string, rrs []dns.RR) *provider.Domain {
+	records := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		records = append(records, rdata(rr))
+	}
+	sort.Strings(records)
+
+	hdr := rrs[0].Header()
+	recordType := dns.TypeToString[hdr.Rrtype]
+	return &provider.Domain{
+		Id:        provider.GenerateRecordSetId(name, recordType),
+		Name:      name,
+		Type:      recordType,
+		Records:   records,
+		TTL:       int(hdr.Ttl),
+		ZoneId:    zoneName,
+		ZoneName:  zoneName,
+		FQDN:      fqdnOf(name, zoneName),
+		Activated: true,
+	}
+}
+
+// rdata returns the record content in the form given on Create
+func rdata(rr dns.RR) string {
+	switch r := rr.(type) {
+	case *dns.A:
+		return r.A.String()
+	case *dns.AAAA:
+		return r.AAAA.String()
+	case *dns.CNAME:
+		return strings.TrimSuffix(r.Target, ".")
+	case *dns.TXT:
+		value := ""
+		for _, s := range r.Txt {
+			value += provider.UnescapeTXT(s)
+		}
+		return value
+	default:
+		return strings.TrimPrefix(rr.String(), rr.Header().String())
+	}
+}
+
+// fqdnOf returns the canonical fully-qualified name with trailing dot
+func fqdnOf(name, zoneName string) string {
+	return dns.CanonicalName(provider.JoinName(name, zoneName))
+}
+
+// normalizeTTL returns the ttl applied on the records
+func normalizeTTL(ttl int) int {
+	if ttl <= 0 {
+		return ttlDefault
+	}
+	return ttl
+}
@@ -0,0 +1,434 @@
+package route53
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"strings"
+	"sync"
+)
+
+const ProviderKey = "route53"
+
+const (
+	SettingKeyAccessKeyId     = "accessKeyId"
+	SettingKeySecretAccessKey = "secretAccessKey"
+	SettingKeySessionToken    = "sessionToken"
+	SettingKeyRegion          = "region"
+	SettingKeyEndpoint        = "endpoint"
+
+	SettingKeyZoneType = "zoneType"
+	SettingKeyVPCId    = "vpcId"
+)
+
+const (
+	ZoneTypePublic  = "public"
+	ZoneTypePrivate = "private"
+)
+
+const changeComment = "created and managed by dns-ingress.io"
+
+// defaultRegion is used to sign the requests, route53 is a global service
+const defaultRegion = "us-east-1"
+
+// hostedZoneIdPrefix is the prefix of the hosted zone id returned by route53
+const hostedZoneIdPrefix = "/hostedzone/"
+
+// errorCodePriorRequestNotComplete is returned while the previous change of the recordset is being applied
+const errorCodePriorRequestNotComplete = "PriorRequestNotComplete"
+
+func init() {
+	provider.Register(ProviderKey, NewRoute53ClientWithSettings)
+}
+
+// ZoneFilter picks the hosted zone among the ones which have the same name.
+// public and private hosted zones may have the same name, and private ones may be associated with different vpcs.
+type ZoneFilter struct {
+	// Type is either public or private, any type is allowed if empty
+	Type string
+	// VPCId requires the private hosted zone to be associated with the vpc
+	VPCId string
+}
+
+type Client struct {
+	Api *route53.Client
+	provider.Client
+
+	zoneFilter ZoneFilter
+	// zoneNames caches zone name by zone id
+	zoneNames sync.Map
+}
+
+// NewRoute53ClientWithSettings creates the client with the provider settings,
+// falls back to the default credential chain of aws sdk, e.g. envs, web identity and instance profile, if credentials are not set
+func NewRoute53ClientWithSettings(settings map[string]string) (provider.Client, error) {
+	region := settings[SettingKeyRegion]
+	if len(region) == 0 {
+		region = defaultRegion
+	}
+	opts := []func(*config.LoadOptions) error{
+		config.WithRegion(region),
+		config.WithRetryer(func() aws.Retryer {
+			return retry.AddWithErrorCodes(retry.NewStandard(), errorCodePriorRequestNotComplete)
+		}),
+	}
+
+	accessKeyId, secretAccessKey := settings[SettingKeyAccessKeyId], settings[SettingKeySecretAccessKey]
+	if len(accessKeyId) > 0 || len(secretAccessKey) > 0 {
+		if len(accessKeyId) == 0 || len(secretAccessKey) == 0 {
+			return nil, fmt.Errorf("can't generate route53 client using settings, both %s and %s are required",
+				SettingKeyAccessKeyId, SettingKeySecretAccessKey)
+		}
+		opts = append(opts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(accessKeyId, secretAccessKey, settings[SettingKeySessionToken])))
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("can't load aws config: %w", err)
+	}
+
+	c, err := NewRoute53Client(cfg, settings[SettingKeyEndpoint], ZoneFilter{
+		Type:  settings[SettingKeyZoneType],
+		VPCId: settings[SettingKeyVPCId],
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewRoute53Client creates the client with the aws config, requests are sent to the endpoint if set
+func NewRoute53Client(cfg aws.Config, endpoint string, zoneFilter ZoneFilter) (*Client, error) {
+	switch zoneFilter.Type {
+	case "", ZoneTypePublic, ZoneTypePrivate:
+	default:
+		return nil, fmt.Errorf("can't create new route53 client: unknown zone type %s", zoneFilter.Type)
+	}
+	if len(zoneFilter.VPCId) > 0 && zoneFilter.Type == ZoneTypePublic {
+		return nil, fmt.Errorf("can't create new route53 client: vpc can't be set for public zone")
+	}
+
+	api := route53.NewFromConfig(cfg, func(o *route53.Options) {
+		if len(endpoint) > 0 {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+	return &Client{
+		Api:        api,
+		zoneFilter: zoneFilter,
+	}, nil
+}
+
+// Verify checks the credentials are accepted by route53
+func (c *Client) Verify(ctx context.Context) error {
+	if _, err := c.Api.GetHostedZoneCount(ctx, &route53.GetHostedZoneCountInput{}); err != nil {
+		return fmt.Errorf("can't verify aws credentials: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone, error) {
+	dnsName := fmt.Sprintf("%s.", strings.ToLower(strings.TrimSuffix(zoneName, ".")))
+
+	// hosted zones are listed in order of name, the ones which have the same name come in a row
+	matchedZones := make([]types.HostedZone, 0)
+	input := &route53.ListHostedZonesByNameInput{DNSName: aws.String(dnsName)}
+	for {
+		out, err := c.Api.ListHostedZonesByName(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("can't GetZone: %w", err)
+		}
+
+		passed := false
+		for _, z := range out.HostedZones {
+			if unescapeName(aws.ToString(z.Name)) != dnsName {
+				passed = true
+				break
+			}
+			matched, err := c.matchZone(ctx, z)
+			if err != nil {
+				return nil, fmt.Errorf("can't GetZone: %w", err)
+			}
+			if matched {
+				matchedZones = append(matchedZones, z)
+			}
+		}
+		if passed || !out.IsTruncated {
+			break
+		}
+		input.DNSName, input.HostedZoneId = out.NextDNSName, out.NextHostedZoneId
+	}
+
+	if len(matchedZones) == 0 {
+		return nil, fmt.Errorf("can't GetZone: cannot find zone %s", zoneName)
+	}
+	if len(matchedZones) > 1 {
+		return nil, fmt.Errorf("can't GetZone: %d hosted zones are found for %s, set %s or %s to pick one",
+			len(matchedZones), zoneName, SettingKeyZoneType, SettingKeyVPCId)
+	}
+
+	z := matchedZones[0]
+	zoneId := strings.TrimPrefix(aws.ToString(z.Id), hostedZoneIdPrefix)
+	name := strings.TrimSuffix(unescapeName(aws.ToString(z.Name)), ".")
+	c.zoneNames.Store(zoneId, name)
+
+	return &provider.Zone{
+		Id:        zoneId,
+		Name:      name,
+		Activated: true,
+	}, nil
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+
+	rrset, err := c.lookupRecordSet(ctx, zoneId, zoneName, name, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	if rrset == nil {
+		return nil, nil
+	}
+	return convertRecordSet(name, zoneId, zoneName, *rrset), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, options map[string]string) (*provider.Domain, error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Create: no records given for %s", name)
+	}
+
+	rrset, err := newRecordSet(fqdnOf(name, zoneName), recordType, records, ttl, opts)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	if err := c.changeRecordSets(ctx, zoneId, types.Change{Action: types.ChangeActionCreate, ResourceRecordSet: &rrset}); err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	return convertRecordSet(name, zoneId, zoneName, rrset), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, options map[string]string) (*provider.Domain, error) {
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	name, currentType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	current, err := c.lookupRecordSet(ctx, zoneId, zoneName, name, currentType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if current == nil {
+		return nil, nil
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Update: no records given for %s", name)
+	}
+	rrset, err := newRecordSet(fqdnOf(name, zoneName), recordType, records, ttl, opts)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	// replace the current recordset in a single change batch if type has been changed, which is applied atomically
+	changes := []types.Change{{Action: types.ChangeActionUpsert, ResourceRecordSet: &rrset}}
+	if current.Type != rrset.Type {
+		changes = []types.Change{
+			{Action: types.ChangeActionDelete, ResourceRecordSet: current},
+			{Action: types.ChangeActionCreate, ResourceRecordSet: &rrset},
+		}
+	}
+	if err := c.changeRecordSets(ctx, zoneId, changes...); err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	return convertRecordSet(name, zoneId, zoneName, rrset), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	zoneName, err := c.getZoneName(ctx, zoneId)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	current, err := c.lookupRecordSet(ctx, zoneId, zoneName, name, recordType)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	if current == nil {
+		return nil
+	}
+
+	// route53 requires the exact recordset to delete it
+	if err := c.changeRecordSets(ctx, zoneId, types.Change{Action: types.ChangeActionDelete, ResourceRecordSet: current}); err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	return nil
+}
+
+// matchZone reports whether the hosted zone satisfies the zone filter
+func (c *Client) matchZone(ctx context.Context, z types.HostedZone) (bool, error) {
+	private := z.Config != nil && z.Config.PrivateZone
+	switch {
+	case c.zoneFilter.Type == ZoneTypePublic && private:
+		return false, nil
+	case (c.zoneFilter.Type == ZoneTypePrivate || len(c.zoneFilter.VPCId) > 0) && !private:
+		return false, nil
+	case len(c.zoneFilter.VPCId) == 0:
+		return true, nil
+	}
+
+	out, err := c.Api.GetHostedZone(ctx, &route53.GetHostedZoneInput{Id: z.Id})
+	if err != nil {
+		return false, fmt.Errorf("can't get hosted zone %s: %w", aws.ToString(z.Id), err)
+	}
+	for _, vpc := range out.VPCs {
+		if aws.ToString(vpc.VPCId) == c.zoneFilter.VPCId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getZoneName returns the zone name of the zone id
+func (c *Client) getZoneName(ctx context.Context, zoneId string) (string, error) {
+	if zoneName, ok := c.zoneNames.Load(zoneId); ok {
+		return zoneName.(string), nil
+	}
+
+	out, err := c.Api.GetHostedZone(ctx, &route53.GetHostedZoneInput{Id: aws.String(zoneId)})
+	if err != nil {
+		return "", fmt.Errorf("can't get hosted zone %s: %w", zoneId, err)
+	}
+	zoneName := strings.TrimSuffix(unescapeName(aws.ToString(out.HostedZone.Name)), ".")
+	c.zoneNames.Store(zoneId, zoneName)
+	return zoneName, nil
+}
+
+// lookupRecordSet returns the route53 recordset which the recordset of the name and type is built into.
+// CNAME recordset may be built as an alias A record, and alias A record is not an A recordset.
+func (c *Client) lookupRecordSet(ctx context.Context, zoneId, zoneName, name, recordType string) (*types.ResourceRecordSet, error) {
+	fqdn := fqdnOf(name, zoneName)
+
+	rrset, err := c.getRecordSet(ctx, zoneId, fqdn, types.RRType(recordType))
+	if err != nil {
+		return nil, err
+	}
+	if rrset != nil && rrset.AliasTarget != nil {
+		return nil, nil
+	}
+	if rrset != nil || recordType != provider.RecordTypeCNAME {
+		return rrset, nil
+	}
+
+	rrset, err = c.getRecordSet(ctx, zoneId, fqdn, types.RRTypeA)
+	if err != nil {
+		return nil, err
+	}
+	if rrset != nil && rrset.AliasTarget != nil {
+		return rrset, nil
+	}
+	return nil, nil
+}
+
+// getRecordSet returns the route53 recordset which has the name and type.
+// recordsets with routing policy, which have set identifier, are not managed and skipped.
+func (c *Client) getRecordSet(ctx context.Context, zoneId, fqdn string, recordType types.RRType) (*types.ResourceRecordSet, error) {
+	input := &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(zoneId),
+		StartRecordName: aws.String(fqdn),
+		StartRecordType: recordType,
+	}
+	for {
+		out, err := c.Api.ListResourceRecordSets(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		// recordsets are listed from the name and type, the first one of another name or type ends the lookup
+		for _, rrset := range out.ResourceRecordSets {
+			if unescapeName(aws.ToString(rrset.Name)) != fqdn || rrset.Type != recordType {
+				return nil, nil
+			}
+			if rrset.SetIdentifier == nil {
+				return &rrset, nil
+			}
+		}
+		if !out.IsTruncated {
+			return nil, nil
+		}
+		input.StartRecordName = out.NextRecordName
+		input.StartRecordType = out.NextRecordType
+		input.StartRecordIdentifier = out.NextRecordIdentifier
+	}
+}
+
+func (c *Client) changeRecordSets(ctx context.Context, zoneId string, changes ...types.Change) error {
+	_, err := c.Api.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneId),
+		ChangeBatch: &types.ChangeBatch{
+			Changes: changes,
+			Comment: aws.String(changeComment),
+		},
+	})
+	if err != nil {
+		var invalidChangeBatch *types.InvalidChangeBatch
+		if errors.As(err, &invalidChangeBatch) {
+			return fmt.Errorf("invalid change batch: %s", strings.Join(invalidChangeBatch.Messages, ", "))
+		}
+		return err
+	}
+	return nil
+}
+
+// fqdnOf returns the lowercase fully-qualified name with trailing dot, which route53 reports
+func fqdnOf(name, zoneName string) string {
+	return fmt.Sprintf("%s.", strings.ToLower(provider.JoinName(name, zoneName)))
+}
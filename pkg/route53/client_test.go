@@ -0,0 +1,361 @@
+package route53
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+const (
+	testZoneId   = "Z0123456789ABCDEFGHIJ"
+	testZoneName = "example.com"
+)
+
+func newTestClient(t *testing.T) (*fakeServer, *Client) {
+	f := newFakeServer(t)
+	f.addZone(testZoneId, testZoneName)
+	return f, f.newClient(t, ZoneFilter{})
+}
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	z, err := c.GetZone(ctx, testZoneName)
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if z.Id != testZoneId || z.Name != testZoneName {
+		t.Fatalf("GetZone: expected zone %s of id %s, got %+v", testZoneName, testZoneId, z)
+	}
+
+	d, err := c.GetByName(ctx, "www", testZoneId, provider.RecordTypeA)
+	if err != nil || d != nil {
+		t.Fatalf("GetByName: expected nothing before create, got %v, %v", d, err)
+	}
+
+	d, err = c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("www", provider.RecordTypeA),
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.0.2.1", "192.0.2.2"},
+		TTL:       ttlDefault,
+		ZoneId:    testZoneId,
+		ZoneName:  testZoneName,
+		FQDN:      "www.example.com.",
+		Activated: true,
+		Options:   map[string]string{OptionKeyAlias: "false"},
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+
+	// a fresh client has to resolve the zone name by itself
+	for _, get := range []func() (*provider.Domain, error){
+		func() (*provider.Domain, error) { return c.GetByName(ctx, "www", testZoneId, provider.RecordTypeA) },
+		func() (*provider.Domain, error) { return f.newClient(t, ZoneFilter{}).Get(ctx, d.Id, testZoneId) },
+	} {
+		got, err := get()
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Get: expected %+v, got %+v", expected, got)
+		}
+	}
+
+	d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if want := []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}; !reflect.DeepEqual(d.Records, want) || d.TTL != 60 {
+		t.Fatalf("Update: expected records %v with ttl 60, got %v with ttl %d", want, d.Records, d.TTL)
+	}
+	if got := f.values(testZoneId, "www.example.com", provider.RecordTypeA); !reflect.DeepEqual(got, d.Records) {
+		t.Fatalf("Update: expected remote records %v, got %v", d.Records, got)
+	}
+
+	if err := c.Delete(ctx, d.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if rrset := f.recordSet(testZoneId, "www.example.com", provider.RecordTypeA); rrset != nil {
+		t.Fatalf("Delete: expected no remote recordset, got %+v", rrset)
+	}
+
+	d, err = c.Get(ctx, d.Id, testZoneId)
+	if err != nil || d != nil {
+		t.Fatalf("Get: expected nothing after delete, got %v, %v", d, err)
+	}
+	if err := c.Delete(ctx, expected.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: expected no error for missing recordset, got %v", err)
+	}
+	if d, err := c.Update(ctx, expected.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil || d != nil {
+		t.Fatalf("Update: expected nothing for missing recordset, got %v, %v", d, err)
+	}
+}
+
+func TestClientCreateExistingRecordSet(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	f.addRecordSet(testZoneId, xmlResourceRecordSet{Name: "www.example.com", Type: provider.RecordTypeA, TTL: aws.Int64(300),
+		ResourceRecords: []xmlResourceRecord{{Value: "192.0.2.1"}}})
+	_, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.2"}, 0, nil)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Create: expected invalid change batch error, got %v", err)
+	}
+	if got := f.values(testZoneId, "www.example.com", provider.RecordTypeA); !reflect.DeepEqual(got, []string{"192.0.2.1"}) {
+		t.Fatalf("Create: expected existing records untouched, got %v", got)
+	}
+}
+
+func TestClientUpdateChangesType(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	d, err := c.Create(ctx, "www", testZoneId, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// CNAME conflicts with A records, which is rejected unless they are replaced in the same change batch
+	d, err = c.Update(ctx, d.Id, testZoneId, provider.RecordTypeCNAME, []string{"lb.example.net"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != provider.GenerateRecordSetId("www", provider.RecordTypeCNAME) {
+		t.Fatalf("Update: expected id of the CNAME recordset, got %s", d.Id)
+	}
+	if rrset := f.recordSet(testZoneId, "www.example.com", provider.RecordTypeA); rrset != nil {
+		t.Fatalf("Update: expected A recordset removed, got %+v", rrset)
+	}
+	if got := f.values(testZoneId, "www.example.com", provider.RecordTypeCNAME); !reflect.DeepEqual(got, []string{"lb.example.net"}) {
+		t.Fatalf("Update: expected CNAME record, got %v", got)
+	}
+}
+
+func TestClientAliasToLoadBalancer(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	const target = "my-lb-1234567890.us-west-2.elb.amazonaws.com"
+	options := map[string]string{OptionKeyAlias: "true", OptionKeyEvaluateTargetHealth: "true"}
+	d, err := c.Create(ctx, "@", testZoneId, provider.RecordTypeCNAME, []string{target}, 0, options)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("@", provider.RecordTypeCNAME),
+		Name:      "@",
+		Type:      provider.RecordTypeCNAME,
+		Records:   []string{target},
+		ZoneId:    testZoneId,
+		ZoneName:  testZoneName,
+		FQDN:      "example.com.",
+		Activated: true,
+		Options:   options,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+
+	rrset := f.recordSet(testZoneId, "example.com", provider.RecordTypeA)
+	if rrset == nil || rrset.AliasTarget == nil {
+		t.Fatalf("Create: expected alias A recordset, got %+v", rrset)
+	}
+	if want := (xmlAliasTarget{HostedZoneId: "Z1H1FL5HABSF5", DNSName: target, EvaluateTargetHealth: true}); *rrset.AliasTarget != want {
+		t.Fatalf("Create: expected alias target %+v, got %+v", want, *rrset.AliasTarget)
+	}
+
+	// alias A record is the CNAME recordset, not an A recordset
+	if d, err := c.GetByName(ctx, "@", testZoneId, provider.RecordTypeA); err != nil || d != nil {
+		t.Fatalf("GetByName: expected no A recordset, got %v, %v", d, err)
+	}
+	got, err := c.Get(ctx, expected.Id, testZoneId)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Get: expected %+v, got %+v", expected, got)
+	}
+
+	// route53 reports the alias target with trailing dot
+	f.addRecordSet(testZoneId, xmlResourceRecordSet{Name: "nlb.example.com", Type: provider.RecordTypeA,
+		AliasTarget: &xmlAliasTarget{HostedZoneId: "Z18D5FSROUN65G", DNSName: "nlb-1.elb.us-west-2.amazonaws.com."}})
+	got, err = c.GetByName(ctx, "nlb", testZoneId, provider.RecordTypeCNAME)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(got.Records, []string{"nlb-1.elb.us-west-2.amazonaws.com"}) {
+		t.Fatalf("GetByName: expected alias target as record, got %v", got.Records)
+	}
+
+	d, err = c.Update(ctx, expected.Id, testZoneId, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if rrset := f.recordSet(testZoneId, "example.com", provider.RecordTypeA); rrset == nil || rrset.AliasTarget != nil {
+		t.Fatalf("Update: expected alias replaced with A records, got %+v", rrset)
+	}
+	if d.Id != provider.GenerateRecordSetId("@", provider.RecordTypeA) || !reflect.DeepEqual(d.Options, map[string]string{OptionKeyAlias: "false"}) {
+		t.Fatalf("Update: expected plain A recordset, got %+v", d)
+	}
+
+	_, err = c.Create(ctx, "app", testZoneId, provider.RecordTypeCNAME, []string{"lb.example.net"}, 0, map[string]string{OptionKeyAlias: "true"})
+	if err == nil || !strings.Contains(err.Error(), "not a known load balancer") {
+		t.Fatalf("Create: expected error for unknown alias target, got %v", err)
+	}
+}
+
+func TestClientTXTRecordSet(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+
+	long := strings.Repeat("a", provider.TXTStringMaxLength+10)
+	records := []string{`heritage=dns-ingress,"quoted"`, long}
+	d, err := c.Create(ctx, "_owner", testZoneId, provider.RecordTypeTXT, records, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, []string{long, records[0]}) {
+		t.Fatalf("Create: expected records %v, got %v", records, d.Records)
+	}
+
+	want := []string{
+		`"` + long[:provider.TXTStringMaxLength] + `" "aaaaaaaaaa"`,
+		`"heritage=dns-ingress,\"quoted\""`,
+	}
+	if got := f.values(testZoneId, "_owner.example.com", provider.RecordTypeTXT); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Create: expected quoted remote records %v, got %v", want, got)
+	}
+
+	got, err := c.GetByName(ctx, "_owner", testZoneId, provider.RecordTypeTXT)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(got.Records, d.Records) {
+		t.Fatalf("GetByName: expected records %v, got %v", d.Records, got.Records)
+	}
+}
+
+func TestClientSkipsRoutingPolicyRecordSets(t *testing.T) {
+	ctx := context.Background()
+	f, c := newTestClient(t)
+	f.pageSize = 1
+
+	for _, id := range []string{"blue", "green"} {
+		f.addRecordSet(testZoneId, xmlResourceRecordSet{Name: "*.example.com", Type: provider.RecordTypeA, SetIdentifier: id,
+			Weight: aws.Int64(1), TTL: aws.Int64(60), ResourceRecords: []xmlResourceRecord{{Value: "192.0.2.1"}}})
+	}
+
+	d, err := c.GetByName(ctx, "*", testZoneId, provider.RecordTypeA)
+	if err != nil || d != nil {
+		t.Fatalf("GetByName: expected weighted recordsets skipped, got %v, %v", d, err)
+	}
+
+	f.addRecordSet(testZoneId, xmlResourceRecordSet{Name: "*.example.com", Type: provider.RecordTypeA, TTL: aws.Int64(60),
+		ResourceRecords: []xmlResourceRecord{{Value: "192.0.2.2"}}})
+	d, err = c.GetByName(ctx, "*", testZoneId, provider.RecordTypeA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if d == nil || !reflect.DeepEqual(d.Records, []string{"192.0.2.2"}) || d.FQDN != "*.example.com." {
+		t.Fatalf("GetByName: expected the wildcard recordset without set identifier, got %+v", d)
+	}
+
+	if err := c.Delete(ctx, d.Id, testZoneId); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	f.pageSize = 100
+	if got := len(f.zones[testZoneId].RecordSets); got != 2 {
+		t.Fatalf("Delete: expected weighted recordsets untouched, got %d recordsets", got)
+	}
+}
+
+func TestClientGetZonePublicAndPrivate(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	f.addZone("ZPUBLIC", testZoneName)
+	f.addZone("ZPRIVATEA", testZoneName, "vpc-a")
+	f.addZone("ZPRIVATEB", testZoneName, "vpc-b")
+	f.addZone("ZOTHER", "example.net")
+
+	tests := []struct {
+		name       string
+		zoneFilter ZoneFilter
+		expected   string
+		err        bool
+	}{
+		{name: "ambiguous without filter", zoneFilter: ZoneFilter{}, err: true},
+		{name: "public", zoneFilter: ZoneFilter{Type: ZoneTypePublic}, expected: "ZPUBLIC"},
+		{name: "ambiguous private", zoneFilter: ZoneFilter{Type: ZoneTypePrivate}, err: true},
+		{name: "private of vpc", zoneFilter: ZoneFilter{Type: ZoneTypePrivate, VPCId: "vpc-b"}, expected: "ZPRIVATEB"},
+		{name: "vpc implies private", zoneFilter: ZoneFilter{VPCId: "vpc-a"}, expected: "ZPRIVATEA"},
+		{name: "unknown vpc", zoneFilter: ZoneFilter{VPCId: "vpc-c"}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			z, err := f.newClient(t, tt.zoneFilter).GetZone(ctx, testZoneName+".")
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got zone %+v", z)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetZone: %v", err)
+			}
+			if z.Id != tt.expected || z.Name != testZoneName {
+				t.Fatalf("expected zone %s, got %+v", tt.expected, z)
+			}
+		})
+	}
+
+	if _, err := f.newClient(t, ZoneFilter{}).GetZone(ctx, "example.org"); err == nil {
+		t.Fatalf("GetZone: expected error for missing zone")
+	}
+}
+
+func TestNewRoute53ClientWithSettings(t *testing.T) {
+	f := newFakeServer(t)
+	f.addZone(testZoneId, testZoneName)
+
+	settings := map[string]string{
+		SettingKeyAccessKeyId:     testAccessKeyId,
+		SettingKeySecretAccessKey: testSecretAccessKey,
+		SettingKeyEndpoint:        f.URL,
+		SettingKeyZoneType:        ZoneTypePublic,
+	}
+	c, err := NewRoute53ClientWithSettings(settings)
+	if err != nil {
+		t.Fatalf("NewRoute53ClientWithSettings: %v", err)
+	}
+	if err := provider.Verify(context.Background(), c); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if z, err := c.GetZone(context.Background(), testZoneName); err != nil || z.Id != testZoneId {
+		t.Fatalf("GetZone: expected zone %s, got %v, %v", testZoneId, z, err)
+	}
+
+	if err := provider.Verify(context.Background(), f.newClientWithCredentials(t, "AKIDINVALID", "secret", ZoneFilter{})); err == nil {
+		t.Fatalf("Verify: expected error for invalid credentials")
+	}
+
+	for _, invalid := range []map[string]string{
+		{SettingKeyAccessKeyId: testAccessKeyId},
+		{SettingKeyZoneType: "internal"},
+		{SettingKeyZoneType: ZoneTypePublic, SettingKeyVPCId: "vpc-a"},
+	} {
+		if _, err := NewRoute53ClientWithSettings(invalid); err == nil {
+			t.Fatalf("NewRoute53ClientWithSettings: expected error for settings %v", invalid)
+		}
+	}
+}
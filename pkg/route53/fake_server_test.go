@@ -0,0 +1,431 @@
+package route53
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+const (
+	testAccessKeyId     = "AKIDEXAMPLE"
+	testSecretAccessKey = "secret"
+)
+
+const apiVersionPrefix = "/2013-04-01/"
+
+// fakeServer is a minimal stand-in of the route53 xml api serving hosted zones and recordsets
+type fakeServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	zones map[string]*fakeZone
+	// pageSize is the maximum number of recordsets listed at once
+	pageSize int
+	// changes counts the change batches applied
+	changes int
+}
+
+type fakeZone struct {
+	HostedZone xmlHostedZone
+	VPCs       []xmlVPC
+	RecordSets []xmlResourceRecordSet
+}
+
+type xmlHostedZone struct {
+	Id                     string
+	Name                   string
+	CallerReference        string
+	Config                 xmlHostedZoneConfig
+	ResourceRecordSetCount int
+}
+
+type xmlHostedZoneConfig struct {
+	PrivateZone bool
+}
+
+type xmlVPC struct {
+	VPCRegion string
+	VPCId     string
+}
+
+type xmlResourceRecordSet struct {
+	Name            string
+	Type            string
+	SetIdentifier   string              `xml:",omitempty"`
+	Weight          *int64              `xml:",omitempty"`
+	TTL             *int64              `xml:",omitempty"`
+	ResourceRecords []xmlResourceRecord `xml:"ResourceRecords>ResourceRecord,omitempty"`
+	AliasTarget     *xmlAliasTarget     `xml:",omitempty"`
+}
+
+type xmlResourceRecord struct {
+	Value string
+}
+
+type xmlAliasTarget struct {
+	HostedZoneId         string
+	DNSName              string
+	EvaluateTargetHealth bool
+}
+
+type xmlChange struct {
+	Action            string
+	ResourceRecordSet xmlResourceRecordSet
+}
+
+type xmlChangeResourceRecordSetsRequest struct {
+	XMLName xml.Name    `xml:"ChangeResourceRecordSetsRequest"`
+	Changes []xmlChange `xml:"ChangeBatch>Changes>Change"`
+}
+
+type xmlListHostedZonesByNameResponse struct {
+	XMLName     xml.Name        `xml:"ListHostedZonesByNameResponse"`
+	HostedZones []xmlHostedZone `xml:"HostedZones>HostedZone"`
+	IsTruncated bool
+	MaxItems    int
+}
+
+type xmlGetHostedZoneResponse struct {
+	XMLName    xml.Name `xml:"GetHostedZoneResponse"`
+	HostedZone xmlHostedZone
+	VPCs       []xmlVPC `xml:"VPCs>VPC,omitempty"`
+}
+
+type xmlGetHostedZoneCountResponse struct {
+	XMLName         xml.Name `xml:"GetHostedZoneCountResponse"`
+	HostedZoneCount int
+}
+
+type xmlListResourceRecordSetsResponse struct {
+	XMLName              xml.Name               `xml:"ListResourceRecordSetsResponse"`
+	ResourceRecordSets   []xmlResourceRecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	IsTruncated          bool
+	MaxItems             int
+	NextRecordName       string `xml:",omitempty"`
+	NextRecordType       string `xml:",omitempty"`
+	NextRecordIdentifier string `xml:",omitempty"`
+}
+
+type xmlChangeResourceRecordSetsResponse struct {
+	XMLName    xml.Name `xml:"ChangeResourceRecordSetsResponse"`
+	ChangeInfo struct {
+		Id          string
+		Status      string
+		SubmittedAt string
+	}
+}
+
+type xmlInvalidChangeBatch struct {
+	XMLName  xml.Name `xml:"InvalidChangeBatch"`
+	Messages []string `xml:"Messages>Message"`
+}
+
+type xmlErrorResponse struct {
+	XMLName xml.Name `xml:"ErrorResponse"`
+	Error   struct {
+		Type    string
+		Code    string
+		Message string
+	}
+	RequestId string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	f := &fakeServer{
+		zones:    map[string]*fakeZone{},
+		pageSize: 100,
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// newClient returns the client which talks to the fake server
+func (f *fakeServer) newClient(t *testing.T, zoneFilter ZoneFilter) *Client {
+	return f.newClientWithCredentials(t, testAccessKeyId, testSecretAccessKey, zoneFilter)
+}
+
+// newClientWithCredentials returns the client which talks to the fake server with the credentials
+func (f *fakeServer) newClientWithCredentials(t *testing.T, accessKeyId, secretAccessKey string, zoneFilter ZoneFilter) *Client {
+	cfg := aws.Config{
+		Region:           defaultRegion,
+		Credentials:      credentials.NewStaticCredentialsProvider(accessKeyId, secretAccessKey, ""),
+		HTTPClient:       f.Client(),
+		RetryMaxAttempts: 1,
+	}
+	c, err := NewRoute53Client(cfg, f.URL, zoneFilter)
+	if err != nil {
+		t.Fatalf("can't create client: %v", err)
+	}
+	return c
+}
+
+// addZone adds the hosted zone, private zone is associated with the vpcs
+func (f *fakeServer) addZone(id, name string, vpcIds ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	z := &fakeZone{
+		HostedZone: xmlHostedZone{
+			Id:              hostedZoneIdPrefix + id,
+			Name:            name + ".",
+			CallerReference: id,
+			Config:          xmlHostedZoneConfig{PrivateZone: len(vpcIds) > 0},
+		},
+	}
+	for _, vpcId := range vpcIds {
+		z.VPCs = append(z.VPCs, xmlVPC{VPCRegion: defaultRegion, VPCId: vpcId})
+	}
+	f.zones[id] = z
+}
+
+// addRecordSet seeds the hosted zone with the recordset, e.g. the alias and weighted ones
+func (f *fakeServer) addRecordSet(zoneId string, rrset xmlResourceRecordSet) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	z := f.zones[zoneId]
+	rrset.Name = escapeName(rrset.Name)
+	z.RecordSets = append(z.RecordSets, rrset)
+	sortRecordSets(z.RecordSets)
+}
+
+// recordSet returns the recordset which has the name and type without set identifier
+func (f *fakeServer) recordSet(zoneId, name, recordType string) *xmlResourceRecordSet {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, rrset := range f.zones[zoneId].RecordSets {
+		if rrset.Name == escapeName(name) && rrset.Type == recordType && len(rrset.SetIdentifier) == 0 {
+			rrset := rrset
+			return &rrset
+		}
+	}
+	return nil
+}
+
+// values returns the sorted values of the recordset which has the name and type
+func (f *fakeServer) values(zoneId, name, recordType string) []string {
+	rrset := f.recordSet(zoneId, name, recordType)
+	if rrset == nil {
+		return nil
+	}
+	values := make([]string, 0, len(rrset.ResourceRecords))
+	for _, r := range rrset.ResourceRecords {
+		values = append(values, r.Value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+func (f *fakeServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.Contains(req.Header.Get("Authorization"), "Credential="+testAccessKeyId+"/") {
+		writeError(w, http.StatusForbidden, "InvalidClientTokenId", "The security token included in the request is invalid.")
+		return
+	}
+
+	paths := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, apiVersionPrefix), "/"), "/")
+	switch {
+	case len(paths) == 1 && paths[0] == "hostedzonecount" && req.Method == http.MethodGet:
+		writeXML(w, xmlGetHostedZoneCountResponse{HostedZoneCount: len(f.zones)})
+	case len(paths) == 1 && paths[0] == "hostedzonesbyname" && req.Method == http.MethodGet:
+		f.listHostedZonesByName(w, req)
+	case len(paths) == 2 && paths[0] == "hostedzone" && req.Method == http.MethodGet:
+		z, ok := f.zones[paths[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchHostedZone", "No hosted zone found with ID: "+paths[1])
+			return
+		}
+		writeXML(w, xmlGetHostedZoneResponse{HostedZone: z.HostedZone, VPCs: z.VPCs})
+	case len(paths) == 3 && paths[0] == "hostedzone" && paths[2] == "rrset":
+		z, ok := f.zones[paths[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchHostedZone", "No hosted zone found with ID: "+paths[1])
+			return
+		}
+		switch req.Method {
+		case http.MethodGet:
+			f.listResourceRecordSets(w, req, z)
+		case http.MethodPost:
+			f.changeResourceRecordSets(w, req, z)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeServer) listHostedZonesByName(w http.ResponseWriter, req *http.Request) {
+	dnsName := req.URL.Query().Get("dnsname")
+	zones := make([]xmlHostedZone, 0, len(f.zones))
+	for _, z := range f.zones {
+		if z.HostedZone.Name >= dnsName {
+			zones = append(zones, z.HostedZone)
+		}
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		if zones[i].Name != zones[j].Name {
+			return zones[i].Name < zones[j].Name
+		}
+		return zones[i].Id < zones[j].Id
+	})
+	writeXML(w, xmlListHostedZonesByNameResponse{HostedZones: zones, MaxItems: 100})
+}
+
+func (f *fakeServer) listResourceRecordSets(w http.ResponseWriter, req *http.Request, z *fakeZone) {
+	query := req.URL.Query()
+	start := xmlResourceRecordSet{Name: query.Get("name"), Type: query.Get("type"), SetIdentifier: query.Get("identifier")}
+
+	resp := xmlListResourceRecordSetsResponse{MaxItems: f.pageSize}
+	for _, rrset := range z.RecordSets {
+		if len(start.Name) > 0 && recordSetLess(rrset, start) {
+			continue
+		}
+		if len(resp.ResourceRecordSets) == f.pageSize {
+			resp.IsTruncated = true
+			resp.NextRecordName, resp.NextRecordType, resp.NextRecordIdentifier = rrset.Name, rrset.Type, rrset.SetIdentifier
+			break
+		}
+		resp.ResourceRecordSets = append(resp.ResourceRecordSets, rrset)
+	}
+	writeXML(w, resp)
+}
+
+// changeResourceRecordSets applies the changes atomically, the whole batch is rejected if any change is invalid
+func (f *fakeServer) changeResourceRecordSets(w http.ResponseWriter, req *http.Request, z *fakeZone) {
+	batch := xmlChangeResourceRecordSetsRequest{}
+	if err := xml.NewDecoder(req.Body).Decode(&batch); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidInput", err.Error())
+		return
+	}
+
+	recordSets := append([]xmlResourceRecordSet{}, z.RecordSets...)
+	for _, change := range batch.Changes {
+		rrset := change.ResourceRecordSet
+		rrset.Name = escapeName(rrset.Name)
+		if rrset.AliasTarget == nil && rrset.TTL == nil {
+			writeInvalidChangeBatch(w, fmt.Sprintf("Invalid request: Expected exactly one of [AliasTarget, all of [TTL, and ResourceRecords]], but found none in Change with [Action=%s, Name=%s, Type=%s]", change.Action, rrset.Name, rrset.Type))
+			return
+		}
+
+		idx := -1
+		for i, r := range recordSets {
+			if r.Name == rrset.Name && r.Type == rrset.Type && r.SetIdentifier == rrset.SetIdentifier {
+				idx = i
+			}
+		}
+		switch change.Action {
+		case "CREATE":
+			if idx >= 0 {
+				writeInvalidChangeBatch(w, fmt.Sprintf("Tried to create resource record set [name='%s', type='%s'] but it already exists", rrset.Name, rrset.Type))
+				return
+			}
+			recordSets = append(recordSets, rrset)
+		case "UPSERT":
+			if idx >= 0 {
+				recordSets[idx] = rrset
+			} else {
+				recordSets = append(recordSets, rrset)
+			}
+		case "DELETE":
+			if idx < 0 || !recordSetEqual(recordSets[idx], rrset) {
+				writeInvalidChangeBatch(w, fmt.Sprintf("Tried to delete resource record set [name='%s', type='%s'] but it was not found", rrset.Name, rrset.Type))
+				return
+			}
+			recordSets = append(recordSets[:idx], recordSets[idx+1:]...)
+		default:
+			writeError(w, http.StatusBadRequest, "InvalidInput", "unknown action "+change.Action)
+			return
+		}
+	}
+
+	// CNAME can't coexist with the other recordsets of the same name
+	types := map[string][]string{}
+	for _, r := range recordSets {
+		types[r.Name] = append(types[r.Name], r.Type)
+	}
+	for name, ts := range types {
+		for _, t := range ts {
+			if t == "CNAME" && len(ts) > 1 {
+				writeInvalidChangeBatch(w, fmt.Sprintf("RRSet of type CNAME with DNS name %s is not permitted as it conflicts with other records with the same DNS name in zone", name))
+				return
+			}
+		}
+	}
+
+	sortRecordSets(recordSets)
+	z.RecordSets = recordSets
+	f.changes++
+
+	resp := xmlChangeResourceRecordSetsResponse{}
+	resp.ChangeInfo.Id = fmt.Sprintf("/change/C%d", f.changes)
+	resp.ChangeInfo.Status = "PENDING"
+	resp.ChangeInfo.SubmittedAt = "2023-10-01T00:00:00Z"
+	writeXML(w, resp)
+}
+
+func recordSetLess(a, b xmlResourceRecordSet) bool {
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	return a.SetIdentifier < b.SetIdentifier
+}
+
+func recordSetEqual(a, b xmlResourceRecordSet) bool {
+	if aws.ToInt64(a.TTL) != aws.ToInt64(b.TTL) || len(a.ResourceRecords) != len(b.ResourceRecords) {
+		return false
+	}
+	for i := range a.ResourceRecords {
+		if a.ResourceRecords[i] != b.ResourceRecords[i] {
+			return false
+		}
+	}
+	if (a.AliasTarget == nil) != (b.AliasTarget == nil) {
+		return false
+	}
+	return a.AliasTarget == nil || *a.AliasTarget == *b.AliasTarget
+}
+
+func sortRecordSets(recordSets []xmlResourceRecordSet) {
+	sort.Slice(recordSets, func(i, j int) bool { return recordSetLess(recordSets[i], recordSets[j]) })
+}
+
+// escapeName returns the name in the form route53 reports, lowercase with trailing dot and escaped wildcard
+func escapeName(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return strings.ReplaceAll(name, "*", fmt.Sprintf(`\%03o`, '*'))
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "text/xml")
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	resp := xmlErrorResponse{RequestId: "request"}
+	resp.Error.Type, resp.Error.Code, resp.Error.Message = "Sender", code, message
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	_ = xml.NewEncoder(w).Encode(resp)
+}
+
+func writeInvalidChangeBatch(w http.ResponseWriter, messages ...string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = xml.NewEncoder(w).Encode(xmlInvalidChangeBatch{Messages: messages})
+}
@@ -0,0 +1,88 @@
+package route53
+
+import (
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"strconv"
+)
+
+const (
+	// OptionKeyAlias serves the CNAME recordset as an alias A record to the load balancer instead
+	OptionKeyAlias = "alias"
+	// OptionKeyEvaluateTargetHealth makes the alias record answer only if the load balancer is healthy
+	OptionKeyEvaluateTargetHealth = "evaluateTargetHealth"
+)
+
+// ttl constraints of route53, default ttl is applied if not set
+const (
+	ttlDefault = 300
+	ttlMax     = 2147483647
+)
+
+// aliasOptions are the parsed provider options
+type aliasOptions struct {
+	alias                bool
+	evaluateTargetHealth bool
+}
+
+// NormalizeOptions validates the options with the alias constraints of route53
+func (c *Client) NormalizeOptions(recordType string, ttl int, options map[string]string) (map[string]string, error) {
+	for key := range options {
+		if key != OptionKeyAlias && key != OptionKeyEvaluateTargetHealth {
+			return nil, fmt.Errorf("unknown route53 option %s", key)
+		}
+	}
+
+	opts, err := parseOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	if ttl < 0 || ttl > ttlMax {
+		return nil, fmt.Errorf("ttl must be between 0 and %d, got %d", ttlMax, ttl)
+	}
+	if !opts.alias {
+		if _, ok := options[OptionKeyEvaluateTargetHealth]; ok {
+			return nil, fmt.Errorf("option %s is only allowed on alias record", OptionKeyEvaluateTargetHealth)
+		}
+		return opts.toMap(), nil
+	}
+
+	if recordType != provider.RecordTypeCNAME {
+		return nil, fmt.Errorf("only CNAME record can be an alias, got %s", recordType)
+	}
+	if ttl != 0 {
+		return nil, fmt.Errorf("ttl of alias record follows the load balancer, got %d", ttl)
+	}
+	return opts.toMap(), nil
+}
+
+// parseOptions returns the options, which are false if not set
+func parseOptions(options map[string]string) (aliasOptions, error) {
+	opts := aliasOptions{}
+	for key, value := range map[string]*bool{
+		OptionKeyAlias:                &opts.alias,
+		OptionKeyEvaluateTargetHealth: &opts.evaluateTargetHealth,
+	} {
+		s, ok := options[key]
+		if !ok {
+			continue
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return aliasOptions{}, fmt.Errorf("can't parse route53 option %s: %w", key, err)
+		}
+		*value = b
+	}
+	return opts, nil
+}
+
+// toMap returns the options in the form reported on Domain.Options
+func (o aliasOptions) toMap() map[string]string {
+	if !o.alias {
+		return map[string]string{OptionKeyAlias: "false"}
+	}
+	return map[string]string{
+		OptionKeyAlias:                "true",
+		OptionKeyEvaluateTargetHealth: strconv.FormatBool(o.evaluateTargetHealth),
+	}
+}
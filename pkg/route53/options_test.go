@@ -0,0 +1,77 @@
+package route53
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+func TestNormalizeOptions(t *testing.T) {
+	c := &Client{}
+	tests := []struct {
+		name       string
+		recordType string
+		ttl        int
+		options    map[string]string
+		expected   map[string]string
+		err        bool
+	}{
+		{name: "no options", recordType: provider.RecordTypeA, expected: map[string]string{OptionKeyAlias: "false"}},
+		{name: "not alias", recordType: provider.RecordTypeCNAME, ttl: 60, options: map[string]string{OptionKeyAlias: "false"},
+			expected: map[string]string{OptionKeyAlias: "false"}},
+		{name: "alias", recordType: provider.RecordTypeCNAME, options: map[string]string{OptionKeyAlias: "true"},
+			expected: map[string]string{OptionKeyAlias: "true", OptionKeyEvaluateTargetHealth: "false"}},
+		{name: "alias evaluating target health", recordType: provider.RecordTypeCNAME,
+			options:  map[string]string{OptionKeyAlias: "1", OptionKeyEvaluateTargetHealth: "true"},
+			expected: map[string]string{OptionKeyAlias: "true", OptionKeyEvaluateTargetHealth: "true"}},
+		{name: "alias of A record", recordType: provider.RecordTypeA, options: map[string]string{OptionKeyAlias: "true"}, err: true},
+		{name: "alias with ttl", recordType: provider.RecordTypeCNAME, ttl: 60, options: map[string]string{OptionKeyAlias: "true"}, err: true},
+		{name: "evaluate target health without alias", recordType: provider.RecordTypeCNAME,
+			options: map[string]string{OptionKeyEvaluateTargetHealth: "true"}, err: true},
+		{name: "invalid alias", recordType: provider.RecordTypeCNAME, options: map[string]string{OptionKeyAlias: "yes"}, err: true},
+		{name: "unknown option", recordType: provider.RecordTypeA, options: map[string]string{"proxied": "true"}, err: true},
+		{name: "negative ttl", recordType: provider.RecordTypeA, ttl: -1, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := provider.NormalizeOptions(c, tt.recordType, tt.ttl, tt.options)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestELBHostedZoneId(t *testing.T) {
+	tests := []struct {
+		hostname string
+		expected string
+		ok       bool
+	}{
+		{hostname: "my-lb-1234567890.us-east-1.elb.amazonaws.com", expected: "Z35SXDOTRQ7X7K", ok: true},
+		{hostname: "internal-my-lb-1234567890.eu-west-1.elb.amazonaws.com.", expected: "Z32O12XQLNTSW2", ok: true},
+		{hostname: "dualstack.my-lb-1234567890.ap-northeast-2.elb.amazonaws.com", expected: "ZWKZPGTI48KDX", ok: true},
+		{hostname: "my-nlb-0123456789abcdef.elb.ap-northeast-2.amazonaws.com", expected: "ZIBE1TIR4HY56", ok: true},
+		{hostname: "my-lb-1234567890.eu-north-1.elb.amazonaws.com", expected: "Z23TAZ6LKFMNIO", ok: true},
+		{hostname: "my-lb-1234567890.me-south-1.elb.amazonaws.com", expected: "ZS929ML54UICD", ok: true},
+		{hostname: "my-nlb-0123456789abcdef.elb.il-central-1.amazonaws.com", expected: "Z0313266YDI6ZRHTGQY4", ok: true},
+		{hostname: "my-lb-1234567890.mars-north-1.elb.amazonaws.com"},
+		{hostname: "lb.example.com"},
+	}
+	for _, tt := range tests {
+		got, ok := elbHostedZoneId(tt.hostname)
+		if got != tt.expected || ok != tt.ok {
+			t.Fatalf("%s: expected %s, %v, got %s, %v", tt.hostname, tt.expected, tt.ok, got, ok)
+		}
+	}
+}
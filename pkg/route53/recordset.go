@@ -0,0 +1,93 @@
+package route53
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"sort"
+	"strings"
+)
+
+// newRecordSet builds the route53 recordset of the recordset.
+// CNAME recordset with alias option is built as an alias A record to the load balancer.
+func newRecordSet(fqdn, recordType string, records []string, ttl int, opts aliasOptions) (types.ResourceRecordSet, error) {
+	if opts.alias {
+		if len(records) != 1 {
+			return types.ResourceRecordSet{}, fmt.Errorf("alias record must have a single target, got %d", len(records))
+		}
+		hostedZoneId, ok := elbHostedZoneId(records[0])
+		if !ok {
+			return types.ResourceRecordSet{}, fmt.Errorf("alias target %s is not a known load balancer hostname", records[0])
+		}
+		return types.ResourceRecordSet{
+			Name: aws.String(fqdn),
+			Type: types.RRTypeA,
+			AliasTarget: &types.AliasTarget{
+				DNSName:              aws.String(records[0]),
+				HostedZoneId:         aws.String(hostedZoneId),
+				EvaluateTargetHealth: opts.evaluateTargetHealth,
+			},
+		}, nil
+	}
+
+	values := make([]types.ResourceRecord, 0, len(records))
+	for _, r := range records {
+		if recordType == provider.RecordTypeTXT {
+			r = provider.QuoteTXT(r)
+		}
+		values = append(values, types.ResourceRecord{Value: aws.String(r)})
+	}
+	return types.ResourceRecordSet{
+		Name:            aws.String(fqdn),
+		Type:            types.RRType(recordType),
+		TTL:             aws.Int64(int64(normalizeTTL(ttl))),
+		ResourceRecords: values,
+	}, nil
+}
+
+// convertRecordSet converts the route53 recordset into a recordset.
+// alias A record is reported as the CNAME recordset with alias option, which it is built from.
+func convertRecordSet(name, zoneId, zoneName string, rrset types.ResourceRecordSet) *provider.Domain {
+	d := &provider.Domain{
+		Name:      name,
+		ZoneId:    zoneId,
+		ZoneName:  zoneName,
+		FQDN:      fmt.Sprintf("%s.", provider.JoinName(name, zoneName)),
+		Activated: true,
+	}
+
+	if rrset.AliasTarget != nil {
+		d.Type = provider.RecordTypeCNAME
+		d.Records = []string{strings.TrimSuffix(aws.ToString(rrset.AliasTarget.DNSName), ".")}
+		d.Options = aliasOptions{alias: true, evaluateTargetHealth: rrset.AliasTarget.EvaluateTargetHealth}.toMap()
+	} else {
+		d.Type = string(rrset.Type)
+		d.Records = make([]string, 0, len(rrset.ResourceRecords))
+		for _, r := range rrset.ResourceRecords {
+			value := aws.ToString(r.Value)
+			if d.Type == provider.RecordTypeTXT {
+				value = provider.UnquoteOctalTXT(value)
+			}
+			d.Records = append(d.Records, value)
+		}
+		sort.Strings(d.Records)
+		d.TTL = int(aws.ToInt64(rrset.TTL))
+		d.Options = aliasOptions{}.toMap()
+	}
+	d.Id = provider.GenerateRecordSetId(name, d.Type)
+	return d
+}
+
+// unescapeName returns the record name without the octal escapes route53 applies, e.g. \052 for wildcard
+func unescapeName(name string) string {
+	return strings.ToLower(provider.UnescapeOctal(name))
+}
+
+// normalizeTTL returns the ttl route53 accepts, which is required on non-alias records
+func normalizeTTL(ttl int) int {
+	if ttl <= 0 {
+		return ttlDefault
+	}
+	return ttl
+}
@@ -0,0 +1,181 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// mediaTypeVersion1 is the media type of the webhook protocol, which is negotiated on the root path
+const mediaTypeVersion1 = "application/external.dns.webhook+json;version=1"
+
+const (
+	pathNegotiate       = "/"
+	pathRecords         = "/records"
+	pathAdjustEndpoints = "/adjustendpoints"
+)
+
+// Endpoint is the recordset of the protocol, which is the endpoint of external-dns
+type Endpoint struct {
+	DNSName          string             `json:"dnsName"`
+	Targets          []string           `json:"targets"`
+	RecordType       string             `json:"recordType"`
+	SetIdentifier    string             `json:"setIdentifier,omitempty"`
+	RecordTTL        int64              `json:"recordTTL,omitempty"`
+	Labels           map[string]string  `json:"labels,omitempty"`
+	ProviderSpecific []ProviderProperty `json:"providerSpecific,omitempty"`
+}
+
+type ProviderProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Changes is the body of the records POST request, UpdateOld and UpdateNew are paired by index
+type Changes struct {
+	Create    []*Endpoint `json:"Create"`
+	UpdateOld []*Endpoint `json:"UpdateOld"`
+	UpdateNew []*Endpoint `json:"UpdateNew"`
+	Delete    []*Endpoint `json:"Delete"`
+}
+
+// DomainFilter is the domain filter the webhook responds with on negotiation, the domains are matched by suffix
+type DomainFilter struct {
+	Include      []string `json:"include,omitempty"`
+	Exclude      []string `json:"exclude,omitempty"`
+	RegexInclude string   `json:"regexInclude,omitempty"`
+	RegexExclude string   `json:"regexExclude,omitempty"`
+}
+
+// Match reports whether the domain is managed by the webhook.
+// the regex filters take precedence over the others as external-dns does.
+func (f *DomainFilter) Match(domain string) (bool, error) {
+	domain = normalizeName(domain)
+	if len(f.RegexInclude) > 0 || len(f.RegexExclude) > 0 {
+		if len(f.RegexInclude) > 0 {
+			include, err := regexp.Compile(f.RegexInclude)
+			if err != nil {
+				return false, fmt.Errorf("invalid regexInclude of domain filter: %w", err)
+			}
+			if !include.MatchString(domain) {
+				return false, nil
+			}
+		}
+		if len(f.RegexExclude) > 0 {
+			exclude, err := regexp.Compile(f.RegexExclude)
+			if err != nil {
+				return false, fmt.Errorf("invalid regexExclude of domain filter: %w", err)
+			}
+			if exclude.MatchString(domain) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	if len(f.Include) > 0 && !matchDomains(f.Include, domain) {
+		return false, nil
+	}
+	return !matchDomains(f.Exclude, domain), nil
+}
+
+// Overlaps reports whether any domain of the zone can be managed by the webhook,
+// which is the case if the zone is under an included domain, or an included domain is under the zone
+func (f *DomainFilter) Overlaps(zoneName string) bool {
+	zoneName = normalizeName(zoneName)
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, filter := range f.Include {
+		filter = normalizeName(filter)
+		if matchDomain(filter, zoneName) || matchDomain(zoneName, strings.TrimPrefix(filter, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDomain reports whether the domain is under the filter, the filter with leading dot matches only the subdomains
+func matchDomain(filter, domain string) bool {
+	filter = normalizeName(filter)
+	if len(filter) == 0 {
+		return true
+	}
+	if strings.HasPrefix(filter, ".") {
+		return strings.HasSuffix(domain, filter)
+	}
+	return domain == filter || strings.HasSuffix(domain, "."+filter)
+}
+
+func matchDomains(filters []string, domain string) bool {
+	for _, filter := range filters {
+		if matchDomain(filter, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeName returns the name in lower case without trailing dot
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
+
+// APIError is returned if the webhook responds with error status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("webhook error %d: %s", e.StatusCode, e.Message)
+}
+
+// do sends the request to the webhook, the response is decoded into out if given
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("can't encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, body)
+	if err != nil {
+		return fmt.Errorf("can't create request: %w", err)
+	}
+	req.Header.Set("Accept", mediaTypeVersion1)
+	if in != nil {
+		req.Header.Set("Content-Type", mediaTypeVersion1)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("can't read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// the webhooks respond with plain text on errors
+		return &APIError{StatusCode: resp.StatusCode, Message: string(bytes.TrimSpace(b))}
+	}
+
+	if out == nil || len(b) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("can't decode response: %w", err)
+	}
+	return nil
+}
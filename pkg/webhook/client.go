@@ -0,0 +1,305 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ProviderKey = "webhook"
+
+const (
+	SettingKeyURL = "url"
+)
+
+var DefaultHttpClient = &http.Client{Timeout: 30 * time.Second}
+
+func init() {
+	provider.Register(ProviderKey, NewWebhookClientWithSettings)
+}
+
+// Client talks to the external-dns webhook provider, which is usually a sidecar serving on localhost.
+// the webhook has no zones, so the zones are synthesized from the zone names and checked against the domain filter.
+type Client struct {
+	provider.Client
+
+	url        string
+	httpClient *http.Client
+
+	mu sync.Mutex
+	// domainFilter is negotiated once the webhook is reachable
+	domainFilter *DomainFilter
+}
+
+// NewWebhookClientWithSettings creates the client with the provider settings
+func NewWebhookClientWithSettings(settings map[string]string) (provider.Client, error) {
+	c, err := NewWebhookClient(settings[SettingKeyURL], DefaultHttpClient)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewWebhookClient creates the client of the webhook served at the url
+func NewWebhookClient(webhookUrl string, client *http.Client) (*Client, error) {
+	if len(webhookUrl) == 0 {
+		return nil, fmt.Errorf("can't create new webhook client: %s is required", SettingKeyURL)
+	}
+	u, err := url.Parse(webhookUrl)
+	if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return nil, fmt.Errorf("can't create new webhook client: invalid url %s", webhookUrl)
+	}
+	if client == nil {
+		client = DefaultHttpClient
+	}
+
+	return &Client{
+		url:        strings.TrimSuffix(webhookUrl, "/"),
+		httpClient: client,
+	}, nil
+}
+
+// Verify negotiates with the webhook again, which checks the webhook is reachable and refreshes the domain filter
+func (c *Client) Verify(ctx context.Context) error {
+	c.mu.Lock()
+	c.domainFilter = nil
+	c.mu.Unlock()
+
+	if _, err := c.getDomainFilter(ctx); err != nil {
+		return fmt.Errorf("can't verify webhook: %w", err)
+	}
+	return nil
+}
+
+// GetZone returns the zone of the name as is, if any domain of the zone is managed by the webhook
+func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone, error) {
+	filter, err := c.getDomainFilter(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetZone: %w", err)
+	}
+
+	name := normalizeName(zoneName)
+	if len(name) == 0 {
+		return nil, fmt.Errorf("can't GetZone: zone name is required")
+	}
+	if !filter.Overlaps(name) {
+		return nil, fmt.Errorf("can't GetZone: zone %s is out of the domain filter of the webhook", zoneName)
+	}
+	return &provider.Zone{
+		Id:        name,
+		Name:      name,
+		Activated: true,
+	}, nil
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	ep, err := c.getEndpoint(ctx, provider.JoinName(name, zoneId), recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	if ep == nil {
+		return nil, nil
+	}
+	return convertEndpoint(name, zoneId, ep), nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Create: no records given for %s", name)
+	}
+
+	dnsName := provider.JoinName(name, zoneId)
+	if err := c.checkDomainFilter(ctx, dnsName); err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+
+	// the webhook creates the endpoint regardless of the existing one, which has to be refused as the other providers do
+	current, err := c.getEndpoint(ctx, dnsName, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	if current != nil {
+		return nil, fmt.Errorf("can't Create: recordset %s %s already exists", dnsName, recordType)
+	}
+
+	ep, err := c.adjustEndpoint(ctx, newEndpoint(dnsName, recordType, records, ttl))
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	if err := c.applyChanges(ctx, &Changes{Create: []*Endpoint{ep}}); err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	return convertEndpoint(name, zoneId, ep), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	name, currentType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	dnsName := provider.JoinName(name, zoneId)
+	current, err := c.getEndpoint(ctx, dnsName, currentType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	if current == nil {
+		return nil, nil
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Update: no records given for %s", name)
+	}
+	if err := c.checkDomainFilter(ctx, dnsName); err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	ep, err := c.adjustEndpoint(ctx, newEndpoint(dnsName, recordType, records, ttl))
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	// the endpoint of the other type is deleted and created in the same changes, which the webhook applies as a batch
+	changes := &Changes{UpdateOld: []*Endpoint{current}, UpdateNew: []*Endpoint{ep}}
+	if currentType != recordType {
+		changes = &Changes{Delete: []*Endpoint{current}, Create: []*Endpoint{ep}}
+	}
+	if err := c.applyChanges(ctx, changes); err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	return convertEndpoint(name, zoneId, ep), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+
+	// the webhook requires the current endpoint to delete, it's looked up first
+	current, err := c.getEndpoint(ctx, provider.JoinName(name, zoneId), recordType)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	if current == nil {
+		return nil
+	}
+
+	if err := c.applyChanges(ctx, &Changes{Delete: []*Endpoint{current}}); err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	return nil
+}
+
+// getDomainFilter negotiates the domain filter with the webhook, which is cached once negotiated
+func (c *Client) getDomainFilter(ctx context.Context) (*DomainFilter, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.domainFilter != nil {
+		return c.domainFilter, nil
+	}
+
+	filter := &DomainFilter{}
+	if err := c.do(ctx, http.MethodGet, pathNegotiate, nil, filter); err != nil {
+		return nil, fmt.Errorf("can't negotiate with webhook: %w", err)
+	}
+	c.domainFilter = filter
+	return filter, nil
+}
+
+// checkDomainFilter fails if the domain is not managed by the webhook, which would ignore the changes of the domain
+func (c *Client) checkDomainFilter(ctx context.Context, dnsName string) error {
+	filter, err := c.getDomainFilter(ctx)
+	if err != nil {
+		return err
+	}
+	match, err := filter.Match(dnsName)
+	if err != nil {
+		return err
+	}
+	if !match {
+		return fmt.Errorf("%s is out of the domain filter of the webhook", dnsName)
+	}
+	return nil
+}
+
+// getEndpoint returns the endpoint which has the name and type, the endpoints with set identifier are not managed
+func (c *Client) getEndpoint(ctx context.Context, dnsName, recordType string) (*Endpoint, error) {
+	endpoints := make([]*Endpoint, 0)
+	if err := c.do(ctx, http.MethodGet, pathRecords, nil, &endpoints); err != nil {
+		return nil, err
+	}
+
+	dnsName = normalizeName(dnsName)
+	for _, ep := range endpoints {
+		if normalizeName(ep.DNSName) == dnsName && ep.RecordType == recordType && len(ep.SetIdentifier) == 0 && len(ep.Targets) > 0 {
+			return ep, nil
+		}
+	}
+	return nil, nil
+}
+
+// adjustEndpoint lets the webhook adjust the endpoint before it's applied, as external-dns does before planning
+func (c *Client) adjustEndpoint(ctx context.Context, ep *Endpoint) (*Endpoint, error) {
+	adjusted := make([]*Endpoint, 0)
+	if err := c.do(ctx, http.MethodPost, pathAdjustEndpoints, []*Endpoint{ep}, &adjusted); err != nil {
+		return nil, fmt.Errorf("can't adjust endpoint: %w", err)
+	}
+	if len(adjusted) != 1 {
+		return nil, fmt.Errorf("can't adjust endpoint: webhook returned %d endpoints", len(adjusted))
+	}
+	return adjusted[0], nil
+}
+
+func (c *Client) applyChanges(ctx context.Context, changes *Changes) error {
+	if err := c.do(ctx, http.MethodPost, pathRecords, changes, nil); err != nil {
+		return fmt.Errorf("can't apply changes: %w", err)
+	}
+	return nil
+}
+
+func newEndpoint(dnsName, recordType string, records []string, ttl int) *Endpoint {
+	targets := append([]string{}, records...)
+	sort.Strings(targets)
+	return &Endpoint{
+		DNSName:    dnsName,
+		Targets:    targets,
+		RecordType: recordType,
+		// zero ttl is left to the webhook, which applies the default of the provider
+		RecordTTL: int64(ttl),
+	}
+}
+
+func convertEndpoint(name, zoneId string, ep *Endpoint) *provider.Domain {
+	records := append([]string{}, ep.Targets...)
+	sort.Strings(records)
+	return &provider.Domain{
+		Id:        provider.GenerateRecordSetId(name, ep.RecordType),
+		Name:      name,
+		Type:      ep.RecordType,
+		Records:   records,
+		TTL:       int(ep.RecordTTL),
+		ZoneId:    zoneId,
+		ZoneName:  zoneId,
+		FQDN:      fmt.Sprintf("%s.", provider.JoinName(name, zoneId)),
+		Activated: true,
+	}
+}
@@ -0,0 +1,238 @@
+package webhook
+
+import (
+	"context"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testZoneName = "example.com"
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	c := f.newClient(t)
+
+	z, err := c.GetZone(ctx, "Example.COM.")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: testZoneName, Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: unexpected zone %+v", z)
+	}
+
+	d, err := c.GetByName(ctx, "www", z.Id, provider.RecordTypeA)
+	if err != nil || d != nil {
+		t.Fatalf("GetByName: expected nothing before create, got %v, %v", d, err)
+	}
+
+	d, err = c.Create(ctx, "www", z.Id, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("www", provider.RecordTypeA),
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.0.2.1", "192.0.2.2"},
+		TTL:       testTTLDefault,
+		ZoneId:    testZoneName,
+		ZoneName:  testZoneName,
+		FQDN:      "www.example.com.",
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+	if ep := f.endpoint("www.example.com", provider.RecordTypeA); ep == nil || ep.RecordTTL != testTTLDefault {
+		t.Fatalf("Create: expected endpoint adjusted by the webhook, got %+v", ep)
+	}
+
+	got, err := c.Get(ctx, d.Id, z.Id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Get: expected %+v, got %+v", expected, got)
+	}
+
+	d, err = c.Update(ctx, d.Id, z.Id, provider.RecordTypeA, []string{"192.0.2.3"}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, []string{"192.0.2.3"}) || d.TTL != 60 {
+		t.Fatalf("Update: unexpected recordset %+v", d)
+	}
+	changes := f.appliedChanges()
+	if last := changes[len(changes)-1]; len(last.UpdateOld) != 1 || len(last.UpdateNew) != 1 || len(last.Create)+len(last.Delete) > 0 {
+		t.Fatalf("Update: expected update of the endpoint, got %+v", last)
+	}
+
+	if err := c.Delete(ctx, d.Id, z.Id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ep := f.endpoint("www.example.com", provider.RecordTypeA); ep != nil {
+		t.Fatalf("Delete: expected no endpoint, got %+v", ep)
+	}
+	if d, err := c.Get(ctx, d.Id, z.Id); err != nil || d != nil {
+		t.Fatalf("Get: expected nothing after delete, got %v, %v", d, err)
+	}
+	if d, err := c.Update(ctx, expected.Id, z.Id, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil || d != nil {
+		t.Fatalf("Update: expected nothing for missing recordset, got %v, %v", d, err)
+	}
+	n := len(f.appliedChanges())
+	if err := c.Delete(ctx, expected.Id, z.Id); err != nil {
+		t.Fatalf("Delete: expected no error for missing recordset, got %v", err)
+	}
+	if len(f.appliedChanges()) != n {
+		t.Fatalf("Delete: expected no changes for missing recordset")
+	}
+}
+
+func TestClientRecordSetIsolatedByType(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	c := f.newClient(t)
+
+	f.addEndpoint(&Endpoint{DNSName: "www.example.com", RecordType: provider.RecordTypeAAAA, Targets: []string{"2001:db8::1"}, RecordTTL: 120})
+	// the endpoints of weighted or geo routing are identified by set identifier, which are not managed
+	f.addEndpoint(&Endpoint{DNSName: "www.example.com", RecordType: provider.RecordTypeA, Targets: []string{"192.0.2.9"}, SetIdentifier: "eu"})
+
+	if d, err := c.GetByName(ctx, "www", testZoneName, provider.RecordTypeA); err != nil || d != nil {
+		t.Fatalf("GetByName: expected endpoint with set identifier ignored, got %v, %v", d, err)
+	}
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	d, err := c.GetByName(ctx, "www", testZoneName, provider.RecordTypeAAAA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(d.Records, []string{"2001:db8::1"}) || d.TTL != 120 {
+		t.Fatalf("GetByName: expected only AAAA records, got %+v", d)
+	}
+
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeAAAA, []string{"2001:db8::2"}, 0, nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Create: expected error for existing recordset, got %v", err)
+	}
+}
+
+func TestClientUpdateChangesType(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	c := f.newClient(t)
+
+	d, err := c.Create(ctx, "app", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	d, err = c.Update(ctx, d.Id, testZoneName, provider.RecordTypeCNAME, []string{"lb.example.net"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != provider.GenerateRecordSetId("app", provider.RecordTypeCNAME) {
+		t.Fatalf("Update: expected id of the CNAME recordset, got %s", d.Id)
+	}
+
+	changes := f.appliedChanges()
+	if last := changes[len(changes)-1]; len(last.Delete) != 1 || len(last.Create) != 1 {
+		t.Fatalf("Update: expected delete and create in the same changes, got %+v", last)
+	}
+	if ep := f.endpoint("app.example.com", provider.RecordTypeA); ep != nil {
+		t.Fatalf("Update: expected A endpoint removed, got %+v", ep)
+	}
+	if ep := f.endpoint("app.example.com", provider.RecordTypeCNAME); ep == nil || !reflect.DeepEqual(ep.Targets, []string{"lb.example.net"}) {
+		t.Fatalf("Update: expected CNAME endpoint, got %+v", ep)
+	}
+}
+
+func TestClientDomainFilter(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	f.domainFilter = DomainFilter{Include: []string{"example.com", ".apps.example.org"}, Exclude: []string{"internal.example.com"}}
+	c := f.newClient(t)
+
+	for _, zoneName := range []string{"example.com", "dev.example.com", "example.org", "apps.example.org"} {
+		if _, err := c.GetZone(ctx, zoneName); err != nil {
+			t.Fatalf("GetZone: expected zone %s overlapping the domain filter, got %v", zoneName, err)
+		}
+	}
+	if _, err := c.GetZone(ctx, "example.net"); err == nil {
+		t.Fatalf("GetZone: expected error for zone out of the domain filter")
+	}
+
+	if _, err := c.Create(ctx, "www", "example.com", provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := c.Create(ctx, "db.internal", "example.com", provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err == nil || !strings.Contains(err.Error(), "domain filter") {
+		t.Fatalf("Create: expected error for excluded domain, got %v", err)
+	}
+	if _, err := c.Create(ctx, "www", "example.org", provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for domain out of the domain filter")
+	}
+	if _, err := c.Create(ctx, "web", "apps.example.org", provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := c.Create(ctx, "@", "apps.example.org", provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for the domain of the filter with leading dot")
+	}
+}
+
+func TestDomainFilterRegex(t *testing.T) {
+	filter := &DomainFilter{Include: []string{"example.org"}, RegexInclude: `\.example\.com$`, RegexExclude: `^internal\.`}
+	for domain, expected := range map[string]bool{
+		"www.example.com.":         true,
+		"internal.example.com":     false,
+		"www.example.org":          false,
+		"www.internal.example.com": true,
+	} {
+		match, err := filter.Match(domain)
+		if err != nil {
+			t.Fatalf("Match: %v", err)
+		}
+		if match != expected {
+			t.Errorf("Match(%s): expected %v, got %v", domain, expected, match)
+		}
+	}
+
+	if _, err := (&DomainFilter{RegexInclude: "("}).Match("example.com"); err == nil {
+		t.Fatalf("Match: expected error on invalid regex")
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeServer(t)
+	c := f.newClient(t)
+
+	if err := c.Verify(ctx); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	f.failChanges = "provider rejected the changes"
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err == nil || !strings.Contains(err.Error(), "provider rejected the changes") {
+		t.Fatalf("Create: expected error of the webhook, got %v", err)
+	}
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, nil, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for no records")
+	}
+
+	f.Close()
+	if err := c.Verify(ctx); err == nil {
+		t.Fatalf("Verify: expected error on unreachable webhook")
+	}
+}
+
+func TestNewWebhookClientWithSettings(t *testing.T) {
+	if _, err := NewWebhookClientWithSettings(map[string]string{SettingKeyURL: "http://localhost:8888"}); err != nil {
+		t.Fatalf("NewWebhookClientWithSettings: %v", err)
+	}
+	for _, invalid := range []map[string]string{{}, {SettingKeyURL: "localhost:8888"}} {
+		if _, err := NewWebhookClientWithSettings(invalid); err == nil {
+			t.Fatalf("NewWebhookClientWithSettings: expected error for settings %v", invalid)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package webhook
+
+import (
+	"github.com/sokdak/dns-ingress/pkg/provider/providertest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	providertest.RunConformance(t, func(t *testing.T) providertest.Fixture {
+		return providertest.Fixture{Client: newFakeServer(t).newClient(t), ZoneName: testZoneName}
+	})
+}
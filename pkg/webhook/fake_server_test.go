@@ -0,0 +1,160 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// testTTLDefault is the ttl the fake webhook adjusts the endpoints without ttl to
+const testTTLDefault = 300
+
+// fakeServer is a minimal stand-in of an external-dns webhook provider keeping the endpoints in memory
+type fakeServer struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	domainFilter DomainFilter
+	// endpoints by dns name and record type
+	endpoints map[string]*Endpoint
+	// changes are the changes applied in order
+	changes []Changes
+	// failChanges fails the changes with the message if set
+	failChanges string
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	f := &fakeServer{endpoints: map[string]*Endpoint{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeServer) newClient(t *testing.T) *Client {
+	c, err := NewWebhookClient(f.URL, f.Client())
+	if err != nil {
+		t.Fatalf("NewWebhookClient: %v", err)
+	}
+	return c
+}
+
+func endpointKey(dnsName, recordType string) string {
+	return fmt.Sprintf("%s/%s", normalizeName(dnsName), recordType)
+}
+
+// addEndpoint adds the endpoint as if it's been made by others
+func (f *fakeServer) addEndpoint(ep *Endpoint) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.endpoints[endpointKey(ep.DNSName, ep.RecordType)+"/"+ep.SetIdentifier] = ep
+}
+
+// endpoint returns the endpoint of the name and type without set identifier
+func (f *fakeServer) endpoint(dnsName, recordType string) *Endpoint {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.endpoints[endpointKey(dnsName, recordType)+"/"]
+}
+
+func (f *fakeServer) appliedChanges() []Changes {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Changes{}, f.changes...)
+}
+
+func (f *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Accept") != mediaTypeVersion1 {
+		http.Error(w, "client must provide an accept header", http.StatusNotAcceptable)
+		return
+	}
+	if r.Method == http.MethodPost && r.Header.Get("Content-Type") != mediaTypeVersion1 {
+		http.Error(w, "client must provide a content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == pathNegotiate:
+		f.writeJSON(w, f.domainFilter)
+	case r.Method == http.MethodGet && r.URL.Path == pathRecords:
+		endpoints := make([]*Endpoint, 0, len(f.endpoints))
+		for _, ep := range f.endpoints {
+			endpoints = append(endpoints, ep)
+		}
+		sort.Slice(endpoints, func(i, j int) bool {
+			return endpoints[i].DNSName < endpoints[j].DNSName
+		})
+		f.writeJSON(w, endpoints)
+	case r.Method == http.MethodPost && r.URL.Path == pathRecords:
+		changes := Changes{}
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := f.applyChanges(changes); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && r.URL.Path == pathAdjustEndpoints:
+		endpoints := make([]*Endpoint, 0)
+		if err := json.NewDecoder(r.Body).Decode(&endpoints); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, ep := range endpoints {
+			if ep.RecordTTL == 0 {
+				ep.RecordTTL = testTTLDefault
+			}
+		}
+		f.writeJSON(w, endpoints)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// applyChanges applies the changes as a batch, the deleted and updated endpoints have to match the current ones
+func (f *fakeServer) applyChanges(changes Changes) error {
+	if len(f.failChanges) > 0 {
+		return errors.New(f.failChanges)
+	}
+	if len(changes.UpdateOld) != len(changes.UpdateNew) {
+		return fmt.Errorf("update old and new don't pair")
+	}
+
+	endpoints := map[string]*Endpoint{}
+	for k, ep := range f.endpoints {
+		endpoints[k] = ep
+	}
+	for _, ep := range append(append([]*Endpoint{}, changes.Delete...), changes.UpdateOld...) {
+		key := endpointKey(ep.DNSName, ep.RecordType) + "/" + ep.SetIdentifier
+		current, found := endpoints[key]
+		if !found || fmt.Sprint(current.Targets) != fmt.Sprint(ep.Targets) {
+			return fmt.Errorf("endpoint %s doesn't match the current one", key)
+		}
+		delete(endpoints, key)
+	}
+	for _, ep := range append(append([]*Endpoint{}, changes.Create...), changes.UpdateNew...) {
+		key := endpointKey(ep.DNSName, ep.RecordType) + "/" + ep.SetIdentifier
+		if _, found := endpoints[key]; found {
+			return fmt.Errorf("endpoint %s already exists", key)
+		}
+		endpoints[key] = ep
+	}
+
+	f.endpoints = endpoints
+	f.changes = append(f.changes, changes)
+	return nil
+}
+
+func (f *fakeServer) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", mediaTypeVersion1)
+	_ = json.NewEncoder(w).Encode(v)
+}
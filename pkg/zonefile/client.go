@@ -0,0 +1,395 @@
+package zonefile
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/miekg/dns"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ProviderKey = "zonefile"
+
+const (
+	SettingKeyDirectory   = "directory"
+	SettingKeyConfigMap   = "configMap"
+	SettingKeySecret      = "secret"
+	SettingKeyNameServers = "nameServers"
+	SettingKeyHostmaster  = "hostmaster"
+)
+
+const recordComment = "created and managed by dns-ingress.io"
+
+// errRecordSetNotFound aborts the change of the zone if the recordset to change doesn't exist
+var errRecordSetNotFound = errors.New("recordset not found")
+
+func init() {
+	provider.Register(ProviderKey, NewZoneFileClientWithSettings)
+}
+
+// Config is the config of the zones, which are stored in one of the directory, configmap and secret
+type Config struct {
+	// Directory stores the zones as db.<zone> files
+	Directory string
+	// ConfigMap and Secret store the zones as db.<zone> keys, given in namespace/name
+	ConfigMap string
+	Secret    string
+	// NameServers are the NS records of the new zones, the first one is the primary of SOA. defaults to ns1.<zone>
+	NameServers []string
+	// Hostmaster is the mailbox of SOA, defaults to hostmaster.<zone>
+	Hostmaster string
+}
+
+// Validate checks the config and fills the defaults
+func (c *Config) Validate() error {
+	sinks := 0
+	for _, sink := range []string{c.Directory, c.ConfigMap, c.Secret} {
+		if len(sink) > 0 {
+			sinks++
+		}
+	}
+	if sinks != 1 {
+		return fmt.Errorf("exactly one of %s, %s and %s is required", SettingKeyDirectory, SettingKeyConfigMap, SettingKeySecret)
+	}
+	for _, object := range []string{c.ConfigMap, c.Secret} {
+		if _, _, err := splitNamespacedName(object); len(object) > 0 && err != nil {
+			return err
+		}
+	}
+	for _, ns := range c.NameServers {
+		if _, ok := dns.IsDomainName(ns); !ok || len(ns) == 0 {
+			return fmt.Errorf("invalid name server %s", ns)
+		}
+	}
+	return nil
+}
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;create;update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update
+
+type Client struct {
+	provider.Client
+	Config Config
+
+	store Store
+	// now returns the time which the serial is dated by
+	now func() time.Time
+
+	mu    sync.Mutex
+	zones map[string]*zoneState
+}
+
+// zoneState is the in-memory zone along with the rendered one and the version of the store last loaded or saved,
+// the lock serializes the changes of the zone
+type zoneState struct {
+	mu       sync.Mutex
+	zone     *Zone
+	rendered []byte
+	version  string
+}
+
+// NewZoneFileClientWithSettings creates the client with the provider settings, the kubernetes client is created from the in-cluster config if required
+func NewZoneFileClientWithSettings(settings map[string]string) (provider.Client, error) {
+	c := Config{
+		Directory:  settings[SettingKeyDirectory],
+		ConfigMap:  settings[SettingKeyConfigMap],
+		Secret:     settings[SettingKeySecret],
+		Hostmaster: settings[SettingKeyHostmaster],
+	}
+	for _, ns := range strings.Split(settings[SettingKeyNameServers], ",") {
+		if ns = strings.TrimSpace(ns); len(ns) > 0 {
+			c.NameServers = append(c.NameServers, ns)
+		}
+	}
+
+	var kubeClient kubernetes.Interface
+	if len(c.ConfigMap) > 0 || len(c.Secret) > 0 {
+		restConfig, err := config.GetConfig()
+		if err != nil {
+			return nil, fmt.Errorf("can't create new zonefile client: can't get kubernetes config: %w", err)
+		}
+		if kubeClient, err = kubernetes.NewForConfig(restConfig); err != nil {
+			return nil, fmt.Errorf("can't create new zonefile client: can't create kubernetes client: %w", err)
+		}
+	}
+
+	client, err := NewZoneFileClient(c, kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// NewZoneFileClient creates the client which stores the zones as the config says, kubeClient is required for configmap and secret
+func NewZoneFileClient(c Config, kubeClient kubernetes.Interface) (*Client, error) {
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("can't create new zonefile client: %w", err)
+	}
+
+	var store Store
+	switch {
+	case len(c.Directory) > 0:
+		store = &FileStore{Directory: c.Directory}
+	case kubeClient == nil:
+		return nil, fmt.Errorf("can't create new zonefile client: kubernetes client is required")
+	case len(c.ConfigMap) > 0:
+		namespace, name, _ := splitNamespacedName(c.ConfigMap)
+		store = &ConfigMapStore{Client: kubeClient, Namespace: namespace, Name: name}
+	default:
+		namespace, name, _ := splitNamespacedName(c.Secret)
+		store = &SecretStore{Client: kubeClient, Namespace: namespace, Name: name}
+	}
+
+	return &Client{
+		Config: c,
+		store:  store,
+		now:    time.Now,
+		zones:  map[string]*zoneState{},
+	}, nil
+}
+
+// Verify checks the zones can be stored
+func (c *Client) Verify(ctx context.Context) error {
+	if err := c.store.Verify(ctx); err != nil {
+		return fmt.Errorf("can't verify zone store: %w", err)
+	}
+	return nil
+}
+
+// GetZone returns the zone, which is created on the first change if not stored yet
+func (c *Client) GetZone(ctx context.Context, zoneName string) (*provider.Zone, error) {
+	name := strings.ToLower(strings.TrimSuffix(zoneName, "."))
+	if _, ok := dns.IsDomainName(name); !ok || len(name) == 0 {
+		return nil, fmt.Errorf("can't GetZone: invalid zone name %s", zoneName)
+	}
+
+	err := c.view(ctx, name, func(*Zone) error { return nil })
+	if err != nil {
+		return nil, fmt.Errorf("can't GetZone: %w", err)
+	}
+	return &provider.Zone{
+		Id:        name,
+		Name:      name,
+		Activated: true,
+	}, nil
+}
+
+func (c *Client) GetByName(ctx context.Context, name, zoneId, recordType string) (*provider.Domain, error) {
+	rrType, ok := dns.StringToType[recordType]
+	if !ok {
+		return nil, fmt.Errorf("can't GetByName: unknown record type %s", recordType)
+	}
+
+	var d *provider.Domain
+	err := c.view(ctx, zoneId, func(z *Zone) error {
+		if rrs := z.Lookup(fqdnOf(name, zoneId), rrType); len(rrs) > 0 {
+			d = convertRecordSet(name, zoneId, rrs)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't GetByName: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Get(ctx context.Context, id, zoneId string) (*provider.Domain, error) {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+
+	d, err := c.GetByName(ctx, name, zoneId, recordType)
+	if err != nil {
+		return nil, fmt.Errorf("can't Get: %w", err)
+	}
+	return d, nil
+}
+
+func (c *Client) Create(ctx context.Context, name, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Create: no records given for %s", name)
+	}
+	fqdn := fqdnOf(name, zoneId)
+	rrs, err := newRecordSet(fqdn, recordType, records, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	rrType := rrs[0].Header().Rrtype
+
+	err = c.change(ctx, zoneId, func(z *Zone) error {
+		if len(z.Lookup(fqdn, rrType)) > 0 {
+			return fmt.Errorf("recordset %s %s already exists", fqdn, recordType)
+		}
+		return z.Replace(fqdn, rrType, rrs)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't Create: %w", err)
+	}
+	return convertRecordSet(name, zoneId, rrs), nil
+}
+
+func (c *Client) Update(ctx context.Context, id, zoneId, recordType string, records []string, ttl int, _ map[string]string) (*provider.Domain, error) {
+	name, currentType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	currentRRType, ok := dns.StringToType[currentType]
+	if !ok {
+		return nil, fmt.Errorf("can't Update: unknown record type %s", currentType)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("can't Update: no records given for %s", name)
+	}
+	fqdn := fqdnOf(name, zoneId)
+	rrs, err := newRecordSet(fqdn, recordType, records, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+
+	err = c.change(ctx, zoneId, func(z *Zone) error {
+		if len(z.Lookup(fqdn, currentRRType)) == 0 {
+			return errRecordSetNotFound
+		}
+		// the current recordset is removed first, so the type can be changed to CNAME
+		if err := z.Replace(fqdn, currentRRType, nil); err != nil {
+			return err
+		}
+		return z.Replace(fqdn, rrs[0].Header().Rrtype, rrs)
+	})
+	if errors.Is(err, errRecordSetNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("can't Update: %w", err)
+	}
+	return convertRecordSet(name, zoneId, rrs), nil
+}
+
+func (c *Client) Delete(ctx context.Context, id, zoneId string) error {
+	name, recordType, err := provider.ParseRecordSetId(id)
+	if err != nil {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	rrType, ok := dns.StringToType[recordType]
+	if !ok {
+		return fmt.Errorf("can't Delete: unknown record type %s", recordType)
+	}
+
+	fqdn := fqdnOf(name, zoneId)
+	err = c.change(ctx, zoneId, func(z *Zone) error {
+		if len(z.Lookup(fqdn, rrType)) == 0 {
+			return errRecordSetNotFound
+		}
+		return z.Replace(fqdn, rrType, nil)
+	})
+	// deleting the recordset which doesn't exist succeeds without changing the zone
+	if err != nil && !errors.Is(err, errRecordSetNotFound) {
+		return fmt.Errorf("can't Delete: %w", err)
+	}
+	return nil
+}
+
+// zoneState returns the state of the zone, which is created on first access
+func (c *Client) zoneState(zoneName string) *zoneState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	zs, ok := c.zones[zoneName]
+	if !ok {
+		zs = &zoneState{}
+		c.zones[zoneName] = zs
+	}
+	return zs
+}
+
+// view calls fn with the current zone, fn must not change the zone
+func (c *Client) view(ctx context.Context, zoneName string, fn func(z *Zone) error) error {
+	zs := c.zoneState(zoneName)
+	zs.mu.Lock()
+	defer zs.mu.Unlock()
+
+	if err := c.load(ctx, zoneName, zs); err != nil {
+		return err
+	}
+	return fn(zs.zone)
+}
+
+// change applies fn on the copy of the zone and stores it with the serial bumped,
+// the in-memory zone is replaced only if fn succeeds and the zone is stored successfully.
+// fn is applied again on the zone loaded again if the store has been changed since the last load.
+func (c *Client) change(ctx context.Context, zoneName string, fn func(z *Zone) error) error {
+	zs := c.zoneState(zoneName)
+	zs.mu.Lock()
+	defer zs.mu.Unlock()
+
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if err := c.load(ctx, zoneName, zs); err != nil {
+			return err
+		}
+		z := zs.zone.Clone()
+		if err := fn(z); err != nil {
+			return err
+		}
+		z.BumpSerial(c.now())
+
+		rendered := z.Render()
+		version, err := c.store.Save(ctx, zoneName, rendered, zs.version)
+		if err != nil {
+			return fmt.Errorf("can't save zone %s: %w", zoneName, err)
+		}
+		zs.zone, zs.rendered, zs.version = z, rendered, version
+		return nil
+	})
+}
+
+// load loads the stored zone, which is parsed again only if it has been changed outside since the last load or save
+func (c *Client) load(ctx context.Context, zoneName string, zs *zoneState) error {
+	data, version, err := c.store.Load(ctx, zoneName)
+	if err != nil {
+		return fmt.Errorf("can't load zone %s: %w", zoneName, err)
+	}
+	// the version changes by the other zones sharing the store, while the zone itself may not
+	zs.version = version
+	if zs.zone != nil && bytes.Equal(data, zs.rendered) {
+		return nil
+	}
+
+	if len(data) == 0 {
+		zs.zone, zs.rendered = c.newZone(zoneName), nil
+		return nil
+	}
+	z, err := ParseZone(zoneName, data)
+	if err != nil {
+		return err
+	}
+	zs.zone, zs.rendered = z, data
+	return nil
+}
+
+// newZone creates the empty zone of the configured name servers
+func (c *Client) newZone(zoneName string) *Zone {
+	nameServers := c.Config.NameServers
+	if len(nameServers) == 0 {
+		nameServers = []string{fmt.Sprintf("ns1.%s", zoneName)}
+	}
+	hostmaster := c.Config.Hostmaster
+	if len(hostmaster) == 0 {
+		hostmaster = fmt.Sprintf("hostmaster.%s", zoneName)
+	}
+	return NewZone(zoneName, hostmaster, nameServers)
+}
+
+// splitNamespacedName splits the namespace/name
+func splitNamespacedName(s string) (string, string, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", fmt.Errorf("%s is not in namespace/name form", s)
+	}
+	return parts[0], parts[1], nil
+}
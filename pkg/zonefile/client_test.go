@@ -0,0 +1,475 @@
+package zonefile
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testZoneName = "example.com"
+
+// newTestClient returns the client which stores the zones in a temporary directory unless the config says otherwise
+func newTestClient(t *testing.T, config Config) *Client {
+	if len(config.ConfigMap) == 0 && len(config.Secret) == 0 {
+		config.Directory = t.TempDir()
+	}
+	c, err := NewZoneFileClient(config, fake.NewSimpleClientset())
+	if err != nil {
+		t.Fatalf("can't create client: %v", err)
+	}
+	c.now = func() time.Time { return testNow }
+	return c
+}
+
+// storedZone returns the rendered zone in the store
+func storedZone(t *testing.T, c *Client, zoneName string) []byte {
+	data, _, err := c.store.Load(context.Background(), zoneName)
+	if err != nil {
+		t.Fatalf("can't load zone: %v", err)
+	}
+	return data
+}
+
+// storedSerial returns the serial of the zone in the store
+func storedSerial(t *testing.T, c *Client, zoneName string) uint32 {
+	z, err := ParseZone(zoneName, storedZone(t, c, zoneName))
+	if err != nil {
+		t.Fatalf("can't parse zone: %v", err)
+	}
+	return z.SOA.Serial
+}
+
+func TestClientRecordSetLifecycle(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, Config{})
+
+	z, err := c.GetZone(ctx, "Example.COM.")
+	if err != nil {
+		t.Fatalf("GetZone: %v", err)
+	}
+	if !reflect.DeepEqual(z, &provider.Zone{Id: testZoneName, Name: testZoneName, Activated: true}) {
+		t.Fatalf("GetZone: expected zone %s, got %+v", testZoneName, z)
+	}
+
+	d, err := c.GetByName(ctx, "www", z.Id, provider.RecordTypeA)
+	if err != nil || d != nil {
+		t.Fatalf("GetByName: expected nothing before create, got %v, %v", d, err)
+	}
+
+	d, err = c.Create(ctx, "www", z.Id, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expected := &provider.Domain{
+		Id:        provider.GenerateRecordSetId("www", provider.RecordTypeA),
+		Name:      "www",
+		Type:      provider.RecordTypeA,
+		Records:   []string{"192.0.2.1", "192.0.2.2"},
+		TTL:       ttlDefault,
+		ZoneId:    testZoneName,
+		ZoneName:  testZoneName,
+		FQDN:      "www.example.com.",
+		Activated: true,
+	}
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("Create: expected %+v, got %+v", expected, d)
+	}
+	if serial := storedSerial(t, c, testZoneName); serial != 2026101600 {
+		t.Fatalf("Create: expected serial dated today, got %d", serial)
+	}
+
+	// a fresh client loads the zone from the store
+	fresh, err := NewZoneFileClient(c.Config, nil)
+	if err != nil {
+		t.Fatalf("NewZoneFileClient: %v", err)
+	}
+	got, err := fresh.Get(ctx, d.Id, z.Id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Get: expected %+v, got %+v", expected, got)
+	}
+
+	d, err = c.Update(ctx, d.Id, z.Id, provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}, 60, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if want := []string{"192.0.2.2", "192.0.2.3", "192.0.2.4"}; !reflect.DeepEqual(d.Records, want) || d.TTL != 60 {
+		t.Fatalf("Update: expected records %v with ttl 60, got %v with ttl %d", want, d.Records, d.TTL)
+	}
+	if serial := storedSerial(t, c, testZoneName); serial != 2026101601 {
+		t.Fatalf("Update: expected serial bumped, got %d", serial)
+	}
+
+	if err := c.Delete(ctx, d.Id, z.Id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	d, err = c.Get(ctx, d.Id, z.Id)
+	if err != nil || d != nil {
+		t.Fatalf("Get: expected nothing after delete, got %v, %v", d, err)
+	}
+	if strings.Contains(string(storedZone(t, c, testZoneName)), "www") {
+		t.Fatalf("Delete: expected records removed from the zone file")
+	}
+
+	// nothing is changed for the missing recordset
+	if err := c.Delete(ctx, expected.Id, z.Id); err != nil {
+		t.Fatalf("Delete: expected no error for missing recordset, got %v", err)
+	}
+	if d, err := c.Update(ctx, expected.Id, z.Id, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil || d != nil {
+		t.Fatalf("Update: expected nothing for missing recordset, got %v, %v", d, err)
+	}
+	if serial := storedSerial(t, c, testZoneName); serial != 2026101602 {
+		t.Fatalf("Delete: expected serial bumped only once, got %d", serial)
+	}
+}
+
+func TestClientRecordSetIsolatedByType(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, Config{})
+
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeAAAA, []string{"2001:db8::1"}, 120, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	a, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	aaaa, err := c.GetByName(ctx, "www", testZoneName, provider.RecordTypeAAAA)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(aaaa.Records, []string{"2001:db8::1"}) || aaaa.TTL != 120 {
+		t.Fatalf("GetByName: expected only AAAA records, got %+v", aaaa)
+	}
+
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeAAAA, []string{"2001:db8::2"}, 0, nil); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Create: expected error for existing recordset, got %v", err)
+	}
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeCNAME, []string{"lb.example.net"}, 0, nil); err == nil {
+		t.Fatalf("Create: expected error for CNAME conflicting with the other records")
+	}
+
+	if err := c.Delete(ctx, a.Id, testZoneName); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if aaaa, err := c.GetByName(ctx, "www", testZoneName, provider.RecordTypeAAAA); err != nil || aaaa == nil {
+		t.Fatalf("Delete: expected AAAA records untouched, got %v, %v", aaaa, err)
+	}
+}
+
+func TestClientUpdateChangesType(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, Config{})
+
+	d, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1", "192.0.2.2"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	d, err = c.Update(ctx, d.Id, testZoneName, provider.RecordTypeCNAME, []string{"lb.example.net"}, 120, nil)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if d.Id != provider.GenerateRecordSetId("www", provider.RecordTypeCNAME) {
+		t.Fatalf("Update: expected id of the CNAME recordset, got %s", d.Id)
+	}
+	if a, err := c.GetByName(ctx, "www", testZoneName, provider.RecordTypeA); err != nil || a != nil {
+		t.Fatalf("Update: expected A records removed, got %v, %v", a, err)
+	}
+	got, err := c.GetByName(ctx, "www", testZoneName, provider.RecordTypeCNAME)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if !reflect.DeepEqual(got, d) {
+		t.Fatalf("GetByName: expected %+v, got %+v", d, got)
+	}
+}
+
+func TestClientTXTRecordSet(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, Config{})
+
+	long := strings.Repeat("a", provider.TXTStringMaxLength+10)
+	records := []string{`heritage=dns-ingress,"quoted\"`, long}
+	d, err := c.Create(ctx, "_owner", testZoneName, provider.RecordTypeTXT, records, 0, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	fresh, _ := NewZoneFileClient(c.Config, nil)
+	got, err := fresh.GetByName(ctx, "_owner", testZoneName, provider.RecordTypeTXT)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if want := []string{long, records[0]}; !reflect.DeepEqual(got.Records, want) || !reflect.DeepEqual(d.Records, want) {
+		t.Fatalf("GetByName: expected records %v, got %v", want, got.Records)
+	}
+}
+
+func TestClientSerializesConcurrentChanges(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, Config{})
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := c.Create(ctx, fmt.Sprintf("host-%d", i), testZoneName, provider.RecordTypeA, []string{fmt.Sprintf("192.0.2.%d", i+1)}, 0, nil)
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	z, err := ParseZone(testZoneName, storedZone(t, c, testZoneName))
+	if err != nil {
+		t.Fatalf("ParseZone: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		if rrs := z.Lookup(fmt.Sprintf("host-%d.example.com", i), 1); len(rrs) != 1 {
+			t.Fatalf("expected record of host-%d stored, got %v", i, rrs)
+		}
+	}
+	if z.SOA.Serial != 2026101600+n-1 {
+		t.Fatalf("expected serial bumped on every change, got %d", z.SOA.Serial)
+	}
+}
+
+func TestClientConfigMapAndSecretStore(t *testing.T) {
+	ctx := context.Background()
+
+	for _, config := range []Config{{ConfigMap: "dns/zones"}, {Secret: "dns/zones"}} {
+		kubeClient := fake.NewSimpleClientset()
+		c, err := NewZoneFileClient(config, kubeClient)
+		if err != nil {
+			t.Fatalf("NewZoneFileClient: %v", err)
+		}
+		if err := provider.Verify(ctx, c); err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+		if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if _, err := c.Create(ctx, "@", "example.org", provider.RecordTypeA, []string{"192.0.2.2"}, 0, nil); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		stored := map[string]string{}
+		if len(config.ConfigMap) > 0 {
+			cm, err := kubeClient.CoreV1().ConfigMaps("dns").Get(ctx, "zones", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("expected configmap created, got %v", err)
+			}
+			stored = cm.Data
+		} else {
+			secret, err := kubeClient.CoreV1().Secrets("dns").Get(ctx, "zones", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("expected secret created, got %v", err)
+			}
+			for k, v := range secret.Data {
+				stored[k] = string(v)
+			}
+		}
+		if len(stored) != 2 || !strings.Contains(stored["db.example.com"], "www\t300\tIN\tA\t192.0.2.1") || !strings.Contains(stored["db.example.org"], "@\t300\tIN\tA\t192.0.2.2") {
+			t.Fatalf("expected both zones stored, got %v", stored)
+		}
+	}
+}
+
+func TestClientReloadsChangedStore(t *testing.T) {
+	ctx := context.Background()
+	kubeClient := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "dns", Name: "zones"},
+		Data:       map[string]string{"unrelated": "kept"},
+	})
+	c, err := NewZoneFileClient(Config{ConfigMap: "dns/zones"}, kubeClient)
+	if err != nil {
+		t.Fatalf("NewZoneFileClient: %v", err)
+	}
+	c.now = func() time.Time { return testNow }
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// the zone is edited outside of dns-ingress
+	cm, _ := kubeClient.CoreV1().ConfigMaps("dns").Get(ctx, "zones", metav1.GetOptions{})
+	cm.Data["db.example.com"] += "manual\t300\tIN\tA\t192.0.2.99\n"
+	if _, err := kubeClient.CoreV1().ConfigMaps("dns").Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("can't update configmap: %v", err)
+	}
+
+	if _, err := c.Create(ctx, "api", testZoneName, provider.RecordTypeA, []string{"192.0.2.2"}, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	cm, _ = kubeClient.CoreV1().ConfigMaps("dns").Get(ctx, "zones", metav1.GetOptions{})
+	if !strings.Contains(cm.Data["db.example.com"], "manual\t300\tIN\tA\t192.0.2.99") || cm.Data["unrelated"] != "kept" {
+		t.Fatalf("expected the changes outside kept, got %v", cm.Data)
+	}
+}
+
+// versionedClientset bumps the resourceVersion of the objects on every write as the api server does, which the fake doesn't
+func versionedClientset(objects ...runtime.Object) *fake.Clientset {
+	c := fake.NewSimpleClientset(objects...)
+	version := 1
+	c.PrependReactor("*", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		var obj runtime.Object
+		switch a := action.(type) {
+		case k8stesting.CreateAction:
+			obj = a.GetObject()
+		case k8stesting.UpdateAction:
+			obj = a.GetObject()
+		default:
+			return false, nil, nil
+		}
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return true, nil, err
+		}
+		version++
+		accessor.SetResourceVersion(strconv.Itoa(version))
+		return false, nil, nil
+	})
+	return c
+}
+
+// racingStore changes the store outside of dns-ingress right before the first save
+type racingStore struct {
+	Store
+	race func()
+}
+
+func (s *racingStore) Save(ctx context.Context, zoneName string, data []byte, version string) (string, error) {
+	if race := s.race; race != nil {
+		s.race = nil
+		race()
+	}
+	return s.Store.Save(ctx, zoneName, data, version)
+}
+
+func TestStoreSaveConflict(t *testing.T) {
+	ctx := context.Background()
+	kubeClient := versionedClientset(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "dns", Name: "zones", ResourceVersion: "1"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "dns", Name: "zones", ResourceVersion: "1"}},
+	)
+	for _, s := range []Store{
+		&ConfigMapStore{Client: kubeClient, Namespace: "dns", Name: "zones"},
+		&SecretStore{Client: kubeClient, Namespace: "dns", Name: "zones"},
+		&ConfigMapStore{Client: kubeClient, Namespace: "dns", Name: "missing"},
+	} {
+		_, version, err := s.Load(ctx, testZoneName)
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if _, err := s.Save(ctx, "example.org", []byte("changed meanwhile"), version); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if _, err := s.Save(ctx, testZoneName, []byte("stale"), version); !k8serrors.IsConflict(err) {
+			t.Fatalf("Save: expected conflict on the store changed since loaded, got %v", err)
+		}
+
+		_, version, err = s.Load(ctx, testZoneName)
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if _, err := s.Save(ctx, testZoneName, []byte("fresh"), version); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		if data, _, _ := s.Load(ctx, "example.org"); string(data) != "changed meanwhile" {
+			t.Fatalf("expected the other zone kept, got %q", data)
+		}
+	}
+}
+
+func TestClientRetriesChangedStore(t *testing.T) {
+	ctx := context.Background()
+	kubeClient := versionedClientset()
+	c, err := NewZoneFileClient(Config{ConfigMap: "dns/zones"}, kubeClient)
+	if err != nil {
+		t.Fatalf("NewZoneFileClient: %v", err)
+	}
+	c.now = func() time.Time { return testNow }
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// the zone is edited outside of dns-ingress between the load and the save of the client
+	c.store = &racingStore{Store: c.store, race: func() {
+		cm, _ := kubeClient.CoreV1().ConfigMaps("dns").Get(ctx, "zones", metav1.GetOptions{})
+		cm.Data["db.example.com"] += "manual\t300\tIN\tA\t192.0.2.99\n"
+		if _, err := kubeClient.CoreV1().ConfigMaps("dns").Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+			t.Fatalf("can't update configmap: %v", err)
+		}
+	}}
+
+	if _, err := c.Create(ctx, "api", testZoneName, provider.RecordTypeA, []string{"192.0.2.2"}, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	stored := string(storedZone(t, c, testZoneName))
+	if !strings.Contains(stored, "manual\t300\tIN\tA\t192.0.2.99") || !strings.Contains(stored, "api\t300\tIN\tA\t192.0.2.2") {
+		t.Fatalf("expected the change outside kept along with the new recordset, got %s", stored)
+	}
+}
+
+func TestNewZoneFileClientWithSettings(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewZoneFileClientWithSettings(map[string]string{
+		SettingKeyDirectory:   dir,
+		SettingKeyNameServers: "ns1.example.net, ns2.example.net",
+	})
+	if err != nil {
+		t.Fatalf("NewZoneFileClientWithSettings: %v", err)
+	}
+	expected := Config{Directory: dir, NameServers: []string{"ns1.example.net", "ns2.example.net"}}
+	if got := c.(*Client).Config; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected config %+v, got %+v", expected, got)
+	}
+	if err := provider.Verify(context.Background(), c); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	missing, err := NewZoneFileClientWithSettings(map[string]string{SettingKeyDirectory: dir + "/missing"})
+	if err != nil {
+		t.Fatalf("NewZoneFileClientWithSettings: %v", err)
+	}
+	if err := provider.Verify(context.Background(), missing); err == nil {
+		t.Fatalf("Verify: expected error for missing directory")
+	}
+
+	for _, invalid := range []map[string]string{
+		{},
+		{SettingKeyDirectory: dir, SettingKeyConfigMap: "dns/zones"},
+		{SettingKeyConfigMap: "zones"},
+		{SettingKeySecret: "dns/zones/db"},
+		{SettingKeyDirectory: dir, SettingKeyNameServers: "ns1..example.net"},
+	} {
+		if _, err := NewZoneFileClientWithSettings(invalid); err == nil {
+			t.Fatalf("NewZoneFileClientWithSettings: expected error for settings %v", invalid)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package zonefile
+
+import (
+	"testing"
+
+	"github.com/sokdak/dns-ingress/pkg/provider/providertest"
+)
+
+func TestConformance(t *testing.T) {
+	providertest.RunConformance(t, func(t *testing.T) providertest.Fixture {
+		return providertest.Fixture{Client: newTestClient(t, Config{}), ZoneName: testZoneName}
+	})
+}
@@ -0,0 +1,207 @@
+package zonefile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"os"
+	"path/filepath"
+)
+
+// Store persists the rendered zones
+type Store interface {
+	// Load returns the rendered zone, nil if not stored yet, along with the version of the stored object
+	Load(ctx context.Context, zoneName string) ([]byte, string, error)
+	// Save stores the rendered zone if the stored object is still of the version loaded, and returns the new version.
+	// it fails with the conflict error of kubernetes if the stored object has been changed meanwhile.
+	Save(ctx context.Context, zoneName string, data []byte, version string) (string, error)
+	// Verify checks the zones can be stored
+	Verify(ctx context.Context) error
+}
+
+// zoneFileName returns the file name of the zone, following the db.<zone> convention of BIND
+func zoneFileName(zoneName string) string {
+	return fmt.Sprintf("db.%s", zoneName)
+}
+
+// FileStore stores the zones as the files in the directory, the files have no version as the client is their only writer
+type FileStore struct {
+	Directory string
+}
+
+func (s *FileStore) Load(_ context.Context, zoneName string) ([]byte, string, error) {
+	data, err := os.ReadFile(filepath.Join(s.Directory, zoneFileName(zoneName)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", nil
+	}
+	return data, "", err
+}
+
+// Save replaces the file atomically, so the name server never reads the partially written zone
+func (s *FileStore) Save(_ context.Context, zoneName string, data []byte, _ string) (string, error) {
+	f, err := os.CreateTemp(s.Directory, "."+zoneFileName(zoneName)+".*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return "", err
+	}
+	return "", os.Rename(f.Name(), filepath.Join(s.Directory, zoneFileName(zoneName)))
+}
+
+func (s *FileStore) Verify(_ context.Context) error {
+	info, err := os.Stat(s.Directory)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.Directory)
+	}
+	return nil
+}
+
+// ConfigMapStore stores the zones as the keys of the configmap, which is created if not exists
+type ConfigMapStore struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+}
+
+func (s *ConfigMapStore) Load(ctx context.Context, zoneName string) ([]byte, string, error) {
+	cm, err := s.Client.CoreV1().ConfigMaps(s.Namespace).Get(ctx, s.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, "", nil
+	} else if err != nil {
+		return nil, "", err
+	}
+	if data, ok := cm.Data[zoneFileName(zoneName)]; ok {
+		return []byte(data), cm.ResourceVersion, nil
+	}
+	return nil, cm.ResourceVersion, nil
+}
+
+// Save updates the configmap of the resourceVersion loaded, the other keys of the configmap are kept
+func (s *ConfigMapStore) Save(ctx context.Context, zoneName string, data []byte, version string) (string, error) {
+	configMaps := s.Client.CoreV1().ConfigMaps(s.Namespace)
+	cm, err := configMaps.Get(ctx, s.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) && len(version) == 0 {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: s.Namespace, Name: s.Name},
+			Data:       map[string]string{zoneFileName(zoneName): string(data)},
+		}
+		if cm, err = configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil {
+			return "", conflictOnExists("configmaps", s.Name, err)
+		}
+		return cm.ResourceVersion, nil
+	} else if err != nil && !k8serrors.IsNotFound(err) {
+		return "", err
+	}
+	if err != nil || cm.ResourceVersion != version {
+		return "", changedError("configmaps", s.Name, zoneName)
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[zoneFileName(zoneName)] = string(data)
+	// the update carrying the resourceVersion fails on conflict if the configmap is changed after the get
+	if cm, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return "", err
+	}
+	return cm.ResourceVersion, nil
+}
+
+func (s *ConfigMapStore) Verify(ctx context.Context) error {
+	_, err := s.Client.CoreV1().ConfigMaps(s.Namespace).Get(ctx, s.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// SecretStore stores the zones as the keys of the secret, which is created if not exists
+type SecretStore struct {
+	Client    kubernetes.Interface
+	Namespace string
+	Name      string
+}
+
+func (s *SecretStore) Load(ctx context.Context, zoneName string) ([]byte, string, error) {
+	secret, err := s.Client.CoreV1().Secrets(s.Namespace).Get(ctx, s.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil, "", nil
+	} else if err != nil {
+		return nil, "", err
+	}
+	if data, ok := secret.Data[zoneFileName(zoneName)]; ok {
+		return data, secret.ResourceVersion, nil
+	}
+	return nil, secret.ResourceVersion, nil
+}
+
+// Save updates the secret of the resourceVersion loaded, the other keys of the secret are kept
+func (s *SecretStore) Save(ctx context.Context, zoneName string, data []byte, version string) (string, error) {
+	secrets := s.Client.CoreV1().Secrets(s.Namespace)
+	secret, err := secrets.Get(ctx, s.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) && len(version) == 0 {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: s.Namespace, Name: s.Name},
+			Data:       map[string][]byte{zoneFileName(zoneName): data},
+		}
+		if secret, err = secrets.Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			return "", conflictOnExists("secrets", s.Name, err)
+		}
+		return secret.ResourceVersion, nil
+	} else if err != nil && !k8serrors.IsNotFound(err) {
+		return "", err
+	}
+	if err != nil || secret.ResourceVersion != version {
+		return "", changedError("secrets", s.Name, zoneName)
+	}
+
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[zoneFileName(zoneName)] = data
+	// the update carrying the resourceVersion fails on conflict if the secret is changed after the get
+	if secret, err = secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return "", err
+	}
+	return secret.ResourceVersion, nil
+}
+
+func (s *SecretStore) Verify(ctx context.Context) error {
+	_, err := s.Client.CoreV1().Secrets(s.Namespace).Get(ctx, s.Name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// changedError is the conflict error of the object changed since the zone has been loaded from it
+func changedError(resource string, name, zoneName string) error {
+	return k8serrors.NewConflict(schema.GroupResource{Resource: resource}, name,
+		fmt.Errorf("zone %s has been changed since loaded", zoneName))
+}
+
+// conflictOnExists turns the error of the object created meanwhile into the conflict error
+func conflictOnExists(resource string, name string, err error) error {
+	if k8serrors.IsAlreadyExists(err) {
+		return k8serrors.NewConflict(schema.GroupResource{Resource: resource}, name, err)
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+package zonefile
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/miekg/dns"
+	"github.com/sokdak/dns-ingress/pkg/provider"
+	"sort"
+	"strings"
+	"time"
+)
+
+// soa timers of the zone, which are the ones recommended by RIPE-203
+const (
+	soaTTL     = 3600
+	soaRefresh = 7200
+	soaRetry   = 3600
+	soaExpire  = 1209600
+	soaMinimum = 300
+)
+
+// Zone is the in-memory zone, which holds the resource records other than SOA
+type Zone struct {
+	// Name is the canonical zone name with trailing dot
+	Name    string
+	SOA     *dns.SOA
+	records []dns.RR
+}
+
+// NewZone creates the empty zone of the name servers, the first one is the primary
+func NewZone(zoneName, hostmaster string, nameServers []string) *Zone {
+	origin := dns.CanonicalName(zoneName)
+	z := &Zone{
+		Name: origin,
+		SOA: &dns.SOA{
+			Hdr:     dns.RR_Header{Name: origin, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: soaTTL},
+			Ns:      dns.CanonicalName(nameServers[0]),
+			Mbox:    dns.CanonicalName(hostmaster),
+			Refresh: soaRefresh,
+			Retry:   soaRetry,
+			Expire:  soaExpire,
+			Minttl:  soaMinimum,
+		},
+	}
+	for _, ns := range nameServers {
+		z.records = append(z.records, &dns.NS{
+			Hdr: dns.RR_Header{Name: origin, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: soaTTL},
+			Ns:  dns.CanonicalName(ns),
+		})
+	}
+	return z
+}
+
+// ParseZone parses the zone in RFC 1035 master file format
+func ParseZone(zoneName string, data []byte) (*Zone, error) {
+	origin := dns.CanonicalName(zoneName)
+	z := &Zone{Name: origin}
+
+	zp := dns.NewZoneParser(bytes.NewReader(data), origin, "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rr.Header().Name = dns.CanonicalName(rr.Header().Name)
+		if !dns.IsSubDomain(origin, rr.Header().Name) {
+			return nil, fmt.Errorf("record %s is out of zone %s", rr.Header().Name, origin)
+		}
+		if soa, ok := rr.(*dns.SOA); ok {
+			if rr.Header().Name != origin {
+				return nil, fmt.Errorf("SOA record of %s is not at the apex", rr.Header().Name)
+			}
+			z.SOA = soa
+			continue
+		}
+		z.records = append(z.records, rr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("can't parse zone %s: %w", origin, err)
+	}
+	if z.SOA == nil {
+		return nil, fmt.Errorf("zone %s has no SOA record", origin)
+	}
+	return z, nil
+}
+
+// Lookup returns the records which have the fqdn and type
+func (z *Zone) Lookup(fqdn string, rrType uint16) []dns.RR {
+	fqdn = dns.CanonicalName(fqdn)
+	rrs := make([]dns.RR, 0)
+	for _, rr := range z.records {
+		if rr.Header().Name == fqdn && rr.Header().Rrtype == rrType {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs
+}
+
+// Replace replaces the records of the fqdn and type, which are removed if none given
+func (z *Zone) Replace(fqdn string, rrType uint16, rrs []dns.RR) error {
+	fqdn = dns.CanonicalName(fqdn)
+	kept := make([]dns.RR, 0, len(z.records)+len(rrs))
+	for _, rr := range z.records {
+		if rr.Header().Name != fqdn || rr.Header().Rrtype != rrType {
+			kept = append(kept, rr)
+		}
+	}
+
+	// CNAME can't coexist with the other records of the same name, the zone would fail to load otherwise
+	if len(rrs) > 0 {
+		for _, rr := range kept {
+			if rr.Header().Name == fqdn && (rrType == dns.TypeCNAME || rr.Header().Rrtype == dns.TypeCNAME) {
+				return fmt.Errorf("CNAME of %s can't coexist with the other records", fqdn)
+			}
+		}
+	}
+	z.records = append(kept, rrs...)
+	return nil
+}
+
+// Clone returns the deep copy of the zone
+func (z *Zone) Clone() *Zone {
+	c := &Zone{Name: z.Name, SOA: dns.Copy(z.SOA).(*dns.SOA), records: make([]dns.RR, 0, len(z.records))}
+	for _, rr := range z.records {
+		c.records = append(c.records, dns.Copy(rr))
+	}
+	return c
+}
+
+// BumpSerial increases the serial in the YYYYMMDDnn convention, or by one if it's already ahead of the date
+func (z *Zone) BumpSerial(now time.Time) {
+	y, m, d := now.UTC().Date()
+	dated := uint32(y*1000000 + int(m)*10000 + d*100)
+	if z.SOA.Serial+1 > dated {
+		z.SOA.Serial++
+		return
+	}
+	z.SOA.Serial = dated
+}
+
+// Render renders the zone in RFC 1035 master file format, the records are sorted by name, type and data
+func (z *Zone) Render() []byte {
+	records := append([]dns.RR{}, z.records...)
+	sort.SliceStable(records, func(i, j int) bool {
+		ni, nj := records[i].Header().Name, records[j].Header().Name
+		if ni != nj {
+			// the apex comes first
+			if ni == z.Name || nj == z.Name {
+				return ni == z.Name
+			}
+			return ni < nj
+		}
+		ti, tj := records[i].Header().Rrtype, records[j].Header().Rrtype
+		if ti != tj {
+			// NS comes first as BIND lists them
+			if ti == dns.TypeNS || tj == dns.TypeNS {
+				return ti == dns.TypeNS
+			}
+			return dns.TypeToString[ti] < dns.TypeToString[tj]
+		}
+		return presentation(records[i]) < presentation(records[j])
+	})
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "; zone %s, %s\n", z.Name, recordComment)
+	fmt.Fprintf(&b, "$ORIGIN %s\n", z.Name)
+	fmt.Fprintf(&b, "$TTL %d\n", ttlDefault)
+	for _, rr := range append([]dns.RR{z.SOA}, records...) {
+		hdr := rr.Header()
+		fmt.Fprintf(&b, "%s\t%d\tIN\t%s\t%s\n",
+			provider.RelativeName(hdr.Name, z.Name), hdr.Ttl, dns.TypeToString[hdr.Rrtype], presentation(rr))
+	}
+	return b.Bytes()
+}
+
+// presentation returns the record data in presentation format
+func presentation(rr dns.RR) string {
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
@@ -0,0 +1,129 @@
+package zonefile
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sokdak/dns-ingress/pkg/provider"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// testNow is the time which the serials are dated by
+var testNow = time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+// assertGolden compares the rendered zone with the golden file under testdata
+func assertGolden(t *testing.T, name string, rendered []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, rendered, 0644); err != nil {
+			t.Fatalf("can't update golden file: %v", err)
+		}
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("can't read golden file: %v", err)
+	}
+	if string(golden) != string(rendered) {
+		t.Fatalf("rendered zone differs from %s:\n%s", path, rendered)
+	}
+}
+
+func TestZoneRender(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, Config{NameServers: []string{"ns1.example.net", "ns2.example.net."}, Hostmaster: "dns-admin.example.net"})
+
+	for _, r := range []struct {
+		name       string
+		recordType string
+		records    []string
+		ttl        int
+	}{
+		{"www", provider.RecordTypeA, []string{"192.0.2.2", "192.0.2.1"}, 0},
+		{"@", provider.RecordTypeA, []string{"192.0.2.10"}, 60},
+		{"www", provider.RecordTypeAAAA, []string{"2001:db8::1"}, 0},
+		{"app", provider.RecordTypeCNAME, []string{"lb.example.org"}, 120},
+		{"*.apps", provider.RecordTypeA, []string{"192.0.2.20"}, 0},
+		{"_owner.www", provider.RecordTypeTXT, []string{`heritage=dns-ingress,owner="default"`}, 0},
+		{"mail", "MX", []string{"10 mx.example.org."}, 0},
+	} {
+		if _, err := c.Create(ctx, r.name, testZoneName, r.recordType, r.records, r.ttl, nil); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	rendered := storedZone(t, c, testZoneName)
+	assertGolden(t, "db.example.com", rendered)
+
+	// the rendered zone is parsed back into the same zone
+	z, err := ParseZone(testZoneName, rendered)
+	if err != nil {
+		t.Fatalf("ParseZone: %v", err)
+	}
+	if got := z.Render(); string(got) != string(rendered) {
+		t.Fatalf("ParseZone: expected the same zone rendered, got:\n%s", got)
+	}
+}
+
+func TestZoneParsePreservesForeignRecords(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	zone := `; maintained by hand
+$ORIGIN example.com.
+$TTL 3600
+@	IN	SOA	ns.example.com. root.example.com. ( 2026101605 3600 900 604800 60 )
+	IN	NS	ns.example.com.
+ns	IN	A	192.0.2.53
+_sip._tcp	IN	SRV	10 60 5060 sip.example.com.
+legacy	300	IN	CNAME	www
+`
+	if err := os.WriteFile(filepath.Join(dir, "db.example.com"), []byte(zone), 0644); err != nil {
+		t.Fatalf("can't write zone: %v", err)
+	}
+	c, err := NewZoneFileClient(Config{Directory: dir}, nil)
+	if err != nil {
+		t.Fatalf("NewZoneFileClient: %v", err)
+	}
+	c.now = func() time.Time { return testNow }
+
+	d, err := c.GetByName(ctx, "legacy", testZoneName, provider.RecordTypeCNAME)
+	if err != nil {
+		t.Fatalf("GetByName: %v", err)
+	}
+	if d == nil || d.Records[0] != "www.example.com" || d.TTL != 300 {
+		t.Fatalf("GetByName: expected the CNAME relative to the origin, got %+v", d)
+	}
+
+	if _, err := c.Create(ctx, "www", testZoneName, provider.RecordTypeA, []string{"192.0.2.1"}, 0, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	// the serial ahead of the date is increased by one
+	assertGolden(t, "db.example.com.foreign", storedZone(t, c, testZoneName))
+}
+
+func TestZoneBumpSerial(t *testing.T) {
+	tests := []struct {
+		current  uint32
+		expected uint32
+	}{
+		{current: 0, expected: 2026101600},
+		{current: 1, expected: 2026101600},
+		{current: 2026101500, expected: 2026101600},
+		{current: 2026101600, expected: 2026101601},
+		{current: 2026101699, expected: 2026101700},
+		{current: 4000000000, expected: 4000000001},
+	}
+	for _, tt := range tests {
+		z := NewZone(testZoneName, "hostmaster.example.com", []string{"ns1.example.com"})
+		z.SOA.Serial = tt.current
+		z.BumpSerial(testNow)
+		if z.SOA.Serial != tt.expected {
+			t.Fatalf("BumpSerial: expected %d from %d, got %d", tt.expected, tt.current, z.SOA.Serial)
+		}
+	}
+}